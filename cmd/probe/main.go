@@ -2,23 +2,38 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/monitorly-app/probe/collectors"
+	"github.com/monitorly-app/probe/internal/aggregation"
 	"github.com/monitorly-app/probe/internal/collector"
+	probecollector "github.com/monitorly-app/probe/internal/collector/probe"
 	"github.com/monitorly-app/probe/internal/collector/system"
 	"github.com/monitorly-app/probe/internal/config"
 	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/monitorly-app/probe/internal/privileges"
 	"github.com/monitorly-app/probe/internal/sender"
+	"github.com/monitorly-app/probe/internal/serialization"
 	"github.com/monitorly-app/probe/internal/version"
 )
 
@@ -29,6 +44,11 @@ type CommandLineFlags struct {
 	CheckUpdate     bool
 	SkipUpdateCheck bool
 	ForceUpdate     bool
+	Diag            bool
+	Selftest        bool
+	ValidateConfig  bool
+	ValidateWithAPI bool
+	DryRun          bool
 }
 
 // parseCommandLineFlags parses command-line arguments and returns flag values
@@ -39,6 +59,11 @@ func parseCommandLineFlags() *CommandLineFlags {
 	flag.BoolVar(&flags.CheckUpdate, "check-update", false, "Check for updates and exit")
 	flag.BoolVar(&flags.SkipUpdateCheck, "skip-update-check", false, "Skip update check at startup")
 	flag.BoolVar(&flags.ForceUpdate, "update", false, "Check for updates and update if available")
+	flag.BoolVar(&flags.Diag, "diag", false, "Show version information and the resolved config file, then exit")
+	flag.BoolVar(&flags.Selftest, "selftest", false, "Check that the external tools needed by the enabled collectors are installed, then exit")
+	flag.BoolVar(&flags.DryRun, "dry-run", false, "Run each enabled collector once and print the metrics as JSON without sending them, then exit")
+	flag.BoolVar(&flags.ValidateConfig, "validate-config", false, "Validate the configuration file, then exit")
+	flag.BoolVar(&flags.ValidateWithAPI, "validate-with-api", false, "With -validate-config, also have the API validate the configuration when it is the sender target")
 	flag.Parse()
 	return flags
 }
@@ -48,8 +73,159 @@ func handleVersionFlag() {
 	fmt.Println(version.Info())
 }
 
+// handleDiagFlag handles the --diag flag
+func handleDiagFlag(configFlag string) {
+	fmt.Print(diagInfo(configFlag))
+}
+
+// diagInfo builds the support diagnostics: version information, the config file
+// that would be used, where it was found and whether it's readable
+func diagInfo(configFlag string) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, version.Info())
+	fmt.Fprintf(&b, "Go version: %s\n", runtime.Version())
+
+	configPath, err := findConfigFile(configFlag)
+	if err != nil {
+		fmt.Fprintf(&b, "Config file: not found (%v)\n", err)
+		return b.String()
+	}
+
+	source := "search path"
+	if absFlag, err := filepath.Abs(configFlag); err == nil && absFlag == configPath {
+		source = "-config flag"
+	}
+	fmt.Fprintf(&b, "Config file: %s (source: %s)\n", configPath, source)
+
+	if file, err := os.Open(configPath); err != nil {
+		fmt.Fprintf(&b, "Config readable: no (%v)\n", err)
+	} else {
+		file.Close()
+		fmt.Fprintln(&b, "Config readable: yes")
+	}
+
+	return b.String()
+}
+
+// handleSelftestFlag handles the --selftest flag: it loads the configuration and fails if
+// any enabled collector is missing an external tool
+func handleSelftestFlag(configFlag string) error {
+	configPath, err := findConfigFile(configFlag)
+	if err != nil {
+		return fmt.Errorf("failed to find config file: %w", err)
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	messages := missingToolsMessages(cfg)
+	for _, msg := range messages {
+		fmt.Println(msg)
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("%d enabled collector(s) are missing external tools", len(messages))
+	}
+	fmt.Println("All external tools needed by the enabled collectors were found")
+	return nil
+}
+
+// handleValidateConfigFlag handles the --validate-config flag: it loads and validates the configuration
+// and, with withAPI and the API target, has the API validate it too. The config file is never
+// changed, changes the API would make are printed instead
+func handleValidateConfigFlag(configFlag string, withAPI bool) error {
+	configPath, err := findConfigFile(configFlag)
+	if err != nil {
+		return fmt.Errorf("failed to find config file: %w", err)
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("configuration %s is invalid: %w", configPath, err)
+	}
+
+	if withAPI && cfg.Sender.Target == "api" {
+		proposed, err := newValidationSender(cfg, configPath, nil).CheckConfigValidation(configPath)
+		if err != nil {
+			return fmt.Errorf("configuration %s was rejected by the API: %w", configPath, err)
+		}
+		if proposed != nil {
+			fmt.Printf("The API would change configuration %s to:\n%s\n", configPath, proposed)
+		}
+	}
+	fmt.Printf("Configuration %s is valid\n", configPath)
+	return nil
+}
+
+// dryRunTimeout bounds the single collection cycle of the --dry-run flag
+const dryRunTimeout = time.Minute
+
+// handleDryRunFlag handles the --dry-run flag: it prints the metrics of one collection cycle
+func handleDryRunFlag(configFlag string) error {
+	data, err := dryRunMetrics(configFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// dryRunMetrics runs each collector enabled in the configuration once and returns their metrics as
+// indented JSON. Collectors that fail are logged and left out, and no collector state is persisted
+func dryRunMetrics(configFlag string) ([]byte, error) {
+	configPath, err := findConfigFile(configFlag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find config file: %w", err)
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// The running probe resumes login failures from the state file, a dry run must leave it alone
+	cfg.Collection.LoginFailures.StateFile = ""
+
+	for _, name := range collectors.Unregistered(cfg) {
+		log.Printf("Custom collector %s is enabled but not registered, skipping it", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dryRunTimeout)
+	defer cancel()
+	metrics, err := collectors.NewRegistry(cfg).CollectAll(ctx)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("collection did not finish within %v", dryRunTimeout)
+	}
+	if err != nil {
+		log.Printf("Some collectors failed: %v", err)
+	}
+
+	if metrics == nil {
+		metrics = []collector.Metrics{}
+	}
+	return serialization.SerializeMetricsIndented(metrics)
+}
+
+// missingToolsMessages describes the external tools missing for each enabled collector, sorted by collector
+func missingToolsMessages(cfg *config.Config) []string {
+	missing := system.MissingTools(system.RequiredTools(cfg))
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, fmt.Sprintf("%s collector enabled but %s not found in PATH", name, strings.Join(missing[name], ", ")))
+	}
+	return messages
+}
+
 // handleCheckUpdateFlag handles the --check-update flag
-func handleCheckUpdateFlag() error {
+func handleCheckUpdateFlag(configFlag string) error {
+	// The configured release channel decides which version is the latest
+	if err := loadUpdateSettings(configFlag); err != nil {
+		return fmt.Errorf("error applying update settings: %w", err)
+	}
+
 	updateAvailable, latestVersion, err := version.CheckForUpdates()
 	if err != nil {
 		return fmt.Errorf("error checking for updates: %w", err)
@@ -65,7 +241,11 @@ func handleCheckUpdateFlag() error {
 }
 
 // handleForceUpdateFlag handles the --update flag
-func handleForceUpdateFlag() error {
+func handleForceUpdateFlag(configFlag string) error {
+	if err := loadUpdateSettings(configFlag); err != nil {
+		return fmt.Errorf("error applying update settings: %w", err)
+	}
+
 	fmt.Println("Checking for updates...")
 	updateAvailable, latestVersion, err := version.CheckForUpdates()
 	if err != nil {
@@ -85,7 +265,8 @@ func handleForceUpdateFlag() error {
 	return nil
 }
 
-// performStartupUpdateCheck performs the automatic update check at startup
+// performStartupUpdateCheck performs the automatic update check at startup, with the update
+// settings already applied by loadUpdateSettings
 func performStartupUpdateCheck() {
 	log.Println("Checking for updates...")
 	updateAvailable, latestVersion, err := version.CheckForUpdates()
@@ -138,6 +319,10 @@ func startUpdateChecker(ctx context.Context, cfg *config.Config) {
 	if !cfg.Updates.Enabled {
 		return
 	}
+	if err := applyUpdateSettings(cfg); err != nil {
+		log.Printf("Automatic updates disabled: %v", err)
+		return
+	}
 
 	nextCheck, err := cfg.GetUpdateCheckTime()
 	if err != nil {
@@ -145,8 +330,59 @@ func startUpdateChecker(ctx context.Context, cfg *config.Config) {
 		nextCheck = time.Now().Add(24 * time.Hour).Truncate(24 * time.Hour) // Next midnight
 	}
 	retryDelay := cfg.GetUpdateRetryDelay()
-	log.Printf("Automatic updates enabled, next check at %s", nextCheck.Format("2006-01-02 15:04:05"))
-	version.StartUpdateChecker(ctx, nextCheck, retryDelay)
+	if cfg.Updates.CheckJitter > 0 {
+		log.Printf("Automatic updates enabled, next check between %s and %s", nextCheck.Format("2006-01-02 15:04:05"), nextCheck.Add(cfg.Updates.CheckJitter).Format("15:04:05"))
+	} else {
+		log.Printf("Automatic updates enabled, next check at %s", nextCheck.Format("2006-01-02 15:04:05"))
+	}
+	version.StartUpdateChecker(ctx, nextCheck, cfg.Updates.CheckJitter, retryDelay)
+}
+
+// applyUpdateSettings sets up update requests and signature verification from cfg
+func applyUpdateSettings(cfg *config.Config) error {
+	if err := version.SetProxy(cfg.API.Proxy); err != nil {
+		log.Printf("Error setting update proxy: %v, using the proxy from the environment", err)
+	}
+	if err := version.SetReleaseChannel(cfg.Updates.ReleaseURL, cfg.Updates.IncludePrereleases); err != nil {
+		return err
+	}
+	machineName, _ := cfg.GetMachineName()
+	if err := version.SetUpdateWebhook(cfg.Updates.NotifyWebhook, machineName); err != nil {
+		log.Printf("Error setting update webhook: %v, updates won't be notified", err)
+	}
+
+	if !cfg.Updates.VerifySignature {
+		return version.SetSignatureKey(nil)
+	}
+
+	key := []byte(version.ReleasePublicKey)
+	if cfg.Updates.PublicKeyFile != "" {
+		var err error
+		if key, err = os.ReadFile(cfg.Updates.PublicKeyFile); err != nil {
+			return fmt.Errorf("failed to read update public key: %w", err)
+		}
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("updates.verify_signature is enabled but this build has no release key, set updates.public_key_file")
+	}
+	if err := version.SetSignatureKey(key); err != nil {
+		return fmt.Errorf("invalid update public key: %w", err)
+	}
+	return nil
+}
+
+// loadUpdateSettings applies the update settings of the config file, for updates made before the
+// configuration is loaded. Without a config file, updates are made with the default settings
+func loadUpdateSettings(configFlag string) error {
+	path, err := findConfigFile(configFlag)
+	if err != nil {
+		return nil
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return applyUpdateSettings(cfg)
 }
 
 // runMainLoop runs the main application loop with config reloading
@@ -156,71 +392,94 @@ func runMainLoop(ctx context.Context, configPath string, initialConfig *config.C
 	for {
 		// Start the application with the current config
 		appCtx, appCancel := context.WithCancel(ctx)
-		appWg := runApp(appCtx, cfg, configPath, restartChan)
+		app := runApp(appCtx, cfg, configPath, restartChan)
 
-		// Wait for either a config change or application shutdown
-		select {
-		case <-ctx.Done():
+		// Wait for either a valid config change that needs a restart or application shutdown
+		newCfg := waitForRestart(ctx, app, configPath, restartChan)
+		appCancel()
+		app.Wait()
+		if newCfg == nil {
 			// Global shutdown requested
-			appCancel()
-			appWg.Wait()
 			return
-		case <-restartChan:
-			// Config changed, validate with API before restarting
-			log.Println("Configuration changed, validating with API...")
-			appCancel()
-			appWg.Wait()
+		}
+		cfg = newCfg
+
+		log.Println("Configuration validated and loaded successfully, restarting...")
+	}
+}
 
-			// Load the new configuration to get API credentials for validation
-			newCfg, err := loadConfig(configPath)
+// waitForRestart waits for config changes and validates them while app keeps running with its
+// config, so an invalid change is logged and ignored. Changes limited to intervals and enabled
+// collectors are applied to app in place. It returns the config to restart with on the first
+// other change, or nil once ctx is done
+func waitForRestart(ctx context.Context, app *runningApp, configPath string, restartChan chan struct{}) *config.Config {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-restartChan:
+			log.Println("Configuration changed, validating...")
+			newCfg, err := validateConfig(configPath, restartChan)
 			if err != nil {
-				log.Printf("Error loading new configuration: %v, continuing with old config", err)
+				log.Printf("%v, continuing with old config", err)
 				continue
 			}
-
-			// Only validate with API if sender target is 'api'
-			if newCfg.Sender.Target == "api" {
-				// Create a temporary APISender for config validation
-				machineName, err := newCfg.GetMachineName()
-				if err != nil {
-					log.Printf("Warning: Failed to get machine name for config validation: %v", err)
-					machineName = "unknown"
-				}
-
-				apiSender := sender.NewAPISender(
-					newCfg.API.URL,
-					newCfg.API.OrganizationID,
-					newCfg.API.ServerID,
-					newCfg.API.ApplicationToken,
-					machineName,
-					newCfg.API.EncryptionKey,
-					configPath,
-					restartChan,
-				)
-
-				// Send configuration for validation
-				if err := apiSender.SendConfigValidation(configPath); err != nil {
-					log.Printf("Configuration validation failed: %v", err)
-					// For 422 errors, the SendConfigValidation method will call log.Fatalf
-					// For other errors, we continue with the old config
-					if strings.Contains(err.Error(), "FATAL:") {
-						os.Exit(1)
-					}
-					continue
-				}
+			if !app.reload(newCfg) {
+				return newCfg
 			}
+			log.Println("Configuration validated, new intervals applied without restarting")
+		}
+	}
+}
 
-			// Reload configuration again (it might have been updated by the API)
-			finalCfg, err := loadConfig(configPath)
-			if err != nil {
-				log.Printf("Error reloading configuration after validation: %v, continuing with old config", err)
-				continue
-			}
-			cfg = finalCfg
+// validateConfig loads the changed config file and, with the API target, has the API validate it.
+// The API may update the file, so the returned config is the one loaded after the validation
+func validateConfig(configPath string, restartChan chan struct{}) (*config.Config, error) {
+	// Load the new configuration to get API credentials for validation
+	newCfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading new configuration: %w", err)
+	}
 
-			log.Println("Configuration validated and loaded successfully, restarting...")
+	// Only validate with API if sender target is 'api'
+	if newCfg.Sender.Target == "api" {
+		// Send configuration for validation
+		// A rejected config is returned as an error, the probe keeps running the old one
+		if err := newValidationSender(newCfg, configPath, restartChan).SendConfigValidation(configPath); err != nil {
+			return nil, fmt.Errorf("configuration validation failed: %w", err)
 		}
 	}
+
+	// Reload configuration again (it might have been updated by the API)
+	finalCfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reloading configuration after validation: %w", err)
+	}
+	if err := checkRuntimeSetup(finalCfg); err != nil {
+		return nil, err
+	}
+	return finalCfg, nil
+}
+
+// newValidationSender creates a temporary APISender to have the API validate the config file of cfg
+func newValidationSender(cfg *config.Config, configPath string, restartChan chan struct{}) *sender.APISender {
+	machineName, err := cfg.GetMachineName()
+	if err != nil {
+		logger.Warnf("Failed to get machine name for config validation: %v", err)
+		machineName = "unknown"
+	}
+
+	return sender.NewAPISender(
+		cfg.API.URL,
+		cfg.API.OrganizationID,
+		cfg.API.ServerID,
+		cfg.API.ApplicationToken,
+		machineName,
+		cfg.API.EncryptionKey,
+		configPath,
+		restartChan,
+		cfg.API.RequestTimeout,
+	)
 }
 
 // runApplication is the main application logic, extracted from main() for testability
@@ -231,21 +490,58 @@ func runApplication(flags *CommandLineFlags) error {
 		return nil
 	}
 
+	// Handle diag flag
+	if flags.Diag {
+		handleDiagFlag(flags.ConfigPath)
+		return nil
+	}
+
+	// Handle selftest flag
+	if flags.Selftest {
+		return handleSelftestFlag(flags.ConfigPath)
+	}
+
+	// Handle dry-run flag
+	if flags.DryRun {
+		return handleDryRunFlag(flags.ConfigPath)
+	}
+
+	// Handle validate-config flag
+	if flags.ValidateConfig {
+		return handleValidateConfigFlag(flags.ConfigPath, flags.ValidateWithAPI)
+	}
+
 	// Handle check-update flag
 	if flags.CheckUpdate {
-		return handleCheckUpdateFlag()
+		return handleCheckUpdateFlag(flags.ConfigPath)
 	}
 
 	// Handle force update flag
 	if flags.ForceUpdate {
-		return handleForceUpdateFlag()
+		return handleForceUpdateFlag(flags.ConfigPath)
 	}
 
 	log.Printf("Starting %s", version.Info())
 
+	// Apply the update settings first, so a rollback by the post-update check reaches the webhook
+	updateSettingsErr := loadUpdateSettings(flags.ConfigPath)
+
+	// A freshly updated binary must complete a collection cycle before the update is kept. On
+	// failure the previous binary is restored and we exit so the service manager restarts it
+	if err := version.RunPostUpdateCheck(func() error {
+		_, err := dryRunMetrics(flags.ConfigPath)
+		return err
+	}); err != nil {
+		return err
+	}
+
 	// Check for updates at startup, unless skipped
 	if !flags.SkipUpdateCheck {
-		performStartupUpdateCheck()
+		if updateSettingsErr != nil {
+			log.Printf("Skipping startup update check: %v", updateSettingsErr)
+		} else {
+			performStartupUpdateCheck()
+		}
 	}
 
 	// Find the config file
@@ -274,6 +570,11 @@ func runApplication(flags *CommandLineFlags) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	// Make sure the output paths, the sender and the runtime user can be set up before starting collection
+	if err := checkRuntimeSetup(cfg); err != nil {
+		return err
+	}
+
 	// Set up a channel to restart the application on config changes
 	restartChan := make(chan struct{})
 
@@ -331,7 +632,8 @@ func findConfigFile(configFlag string) (string, error) {
 			continue // Skip this path if we can't get the absolute path
 		}
 
-		if _, err := os.Stat(absPath); err == nil {
+		// An empty or directory path resolves to a directory, which is never a config file
+		if info, err := os.Stat(absPath); err == nil && !info.IsDir() {
 			log.Printf("Using config file: %s", absPath)
 			return absPath, nil
 		}
@@ -354,11 +656,195 @@ func loadConfig(path string) (*config.Config, error) {
 	return cfg, nil
 }
 
+// writablePaths returns the file paths the configuration will write to
+func writablePaths(cfg *config.Config) []string {
+	paths := []string{cfg.Logging.FilePath}
+	if cfg.Sender.Target == "log_file" {
+		paths = append(paths, cfg.LogFile.Path)
+	}
+	if cfg.Sender.Target == "api" && cfg.Sender.SequenceFile != "" {
+		paths = append(paths, cfg.Sender.SequenceFile)
+	}
+	if cfg.Collection.LoginFailures.Enabled && cfg.Collection.LoginFailures.StateFile != "" {
+		paths = append(paths, cfg.Collection.LoginFailures.StateFile)
+	}
+	return paths
+}
+
+// dropPrivileges switches to the configured runtime user, handing over the probe's own
+// output paths first so they remain writable after the switch and across restarts
+// With the API target the config file is handed over too, so config updates from the API still apply
+func dropPrivileges(cfg *config.Config, configPath string) error {
+	ownedPaths := writablePaths(cfg)
+	if cfg.Sender.Target == "api" {
+		ownedPaths = append(ownedPaths, configPath)
+		if cfg.Sender.SpoolDir != "" {
+			ownedPaths = append(ownedPaths, cfg.Sender.SpoolDir)
+		}
+	}
+
+	if err := privileges.Drop(cfg.Runtime.User, cfg.Runtime.Group, ownedPaths); err != nil {
+		return err
+	}
+	logger.Printf("Running as user %s", cfg.Runtime.User)
+
+	if err := checkWritablePaths(cfg); err != nil {
+		logger.Warnf("%v after dropping privileges", err)
+	}
+	return nil
+}
+
+// checkRuntimeSetup checks the parts of cfg that runApp can only fail on: the output paths, the
+// sender setup and the switch to the runtime user. A reloaded config is checked before the running
+// app is stopped, so a config that can't start is rejected and the probe keeps the old one
+func checkRuntimeSetup(cfg *config.Config) error {
+	if err := checkWritablePaths(cfg); err != nil {
+		return err
+	}
+	if err := checkSenderSetup(cfg); err != nil {
+		return err
+	}
+	if cfg.Runtime.User != "" {
+		if err := privileges.Check(cfg.Runtime.User, cfg.Runtime.Group); err != nil {
+			return fmt.Errorf("cannot run as user %s: %w", cfg.Runtime.User, err)
+		}
+	}
+	return nil
+}
+
+// checkSenderSetup builds the parts of the configured sender that can fail, such as TLS certificates
+// and endpoints, without connecting anywhere
+func checkSenderSetup(cfg *config.Config) error {
+	machineName, err := cfg.GetMachineName()
+	if err != nil {
+		machineName = "unknown"
+	}
+
+	switch cfg.Sender.Target {
+	case "api":
+		if _, err := apiTLSConfig(cfg); err != nil {
+			return fmt.Errorf("failed to set up TLS for API requests: %w", err)
+		}
+		if cfg.API.Proxy != "" {
+			if err := newValidationSender(cfg, "", nil).SetProxy(cfg.API.Proxy); err != nil {
+				return fmt.Errorf("failed to set API proxy: %w", err)
+			}
+		}
+	case "otlp":
+		if _, err := sender.NewOTLPSender(cfg.OTLP.Endpoint, cfg.OTLP.Protocol, cfg.OTLP.Headers, machineName, version.GetVersion(), cfg.OTLP.Timeout); err != nil {
+			return fmt.Errorf("failed to set up OTLP export: %w", err)
+		}
+	case "mqtt":
+		mqttOpts, err := mqttOptions(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to set up TLS for MQTT: %w", err)
+		}
+		if _, err := sender.NewMQTTSender(mqttOpts, machineName); err != nil {
+			return fmt.Errorf("failed to set up MQTT output: %w", err)
+		}
+	case "syslog":
+		if _, err := sender.NewSyslogSender(cfg.Syslog.Network, cfg.Syslog.Address, cfg.Syslog.Facility, cfg.Syslog.Tag); err != nil {
+			return fmt.Errorf("failed to set up syslog output: %w", err)
+		}
+	}
+	return nil
+}
+
+// apiTLSConfig loads the TLS config of API requests, or returns nil when the defaults are used
+func apiTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	apiTLS := cfg.API.TLS
+	if apiTLS.CAFile == "" && apiTLS.ClientCertFile == "" {
+		return nil, nil
+	}
+	return sender.LoadTLSConfig(apiTLS.CAFile, apiTLS.ClientCertFile, apiTLS.ClientKeyFile)
+}
+
+// mqttOptions builds the MQTT connection options, loading the TLS config when TLS is enabled
+func mqttOptions(cfg *config.Config) (sender.MQTTOptions, error) {
+	mqttOpts := sender.MQTTOptions{
+		Address:   net.JoinHostPort(cfg.MQTT.Host, strconv.Itoa(cfg.MQTT.Port)),
+		Topic:     cfg.MQTT.Topic,
+		ClientID:  cfg.MQTT.ClientID,
+		Username:  cfg.MQTT.Username,
+		Password:  cfg.MQTT.Password,
+		QoS:       byte(cfg.MQTT.QoS),
+		KeepAlive: cfg.MQTT.KeepAlive,
+		Timeout:   cfg.MQTT.Timeout,
+	}
+	if mqttTLS := cfg.MQTT.TLS; mqttTLS.Enabled {
+		tlsConfig, err := sender.LoadTLSConfig(mqttTLS.CAFile, mqttTLS.ClientCertFile, mqttTLS.ClientKeyFile)
+		if err != nil {
+			return mqttOpts, err
+		}
+		mqttOpts.TLSConfig = tlsConfig
+	}
+	return mqttOpts, nil
+}
+
+// checkWritablePaths verifies that every configured output file can be created and appended to
+// All unwritable paths are reported in a single error
+func checkWritablePaths(cfg *config.Config) error {
+	var failures []string
+
+	for _, path := range writablePaths(cfg) {
+		if err := checkFileWritable(path); err != nil {
+			failures = append(failures, fmt.Sprintf("%s (%v)", path, err))
+		}
+	}
+
+	if cfg.Sender.Target == "api" && cfg.Sender.SpoolDir != "" {
+		if err := checkDirWritable(cfg.Sender.SpoolDir); err != nil {
+			failures = append(failures, fmt.Sprintf("%s (%v)", cfg.Sender.SpoolDir, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("unwritable paths: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// checkDirWritable creates the directory if needed and verifies a file can be created in it
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkFileWritable creates the parent directory if needed and opens the file for appending
+func checkFileWritable(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	return file.Close()
+}
+
+// configDebounce is how long the config file must go without changes before a restart is signaled,
+// so a save made in several steps (truncate then write) triggers a single restart
+const configDebounce = 500 * time.Millisecond
+
 // watchConfigFile monitors the config file for changes
 func watchConfigFile(ctx context.Context, watcher *fsnotify.Watcher, configPath string, restartChan chan struct{}) {
 	configFileName := filepath.Base(configPath)
 	configDir := filepath.Dir(configPath)
 
+	// Started by each change and fired once the changes settle
+	debounce := time.NewTimer(configDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -368,21 +854,32 @@ func watchConfigFile(ctx context.Context, watcher *fsnotify.Watcher, configPath
 				return
 			}
 
+			// The directory itself was moved or deleted, as when a deployment swaps it, which ends its watch
+			if event.Name == configDir && (event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove)) {
+				rewatchConfigDir(watcher, configDir)
+				debounce.Reset(configDebounce)
+				continue
+			}
+
 			// Check if this event is for our config file
 			if filepath.Base(event.Name) == configFileName && filepath.Dir(event.Name) == configDir {
-				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-					// Wait a short time to ensure the file is fully written
-					time.Sleep(100 * time.Millisecond)
-
-					// Signal a restart
-					select {
-					case restartChan <- struct{}{}:
-						log.Printf("Detected change to config file: %s", configPath)
-					default:
-						// A restart is already pending, no need to send again
-					}
+				switch {
+				case event.Has(fsnotify.Write):
+					debounce.Reset(configDebounce)
+				case event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove):
+					// Saved by renaming a temp file over it, or moved away before the new file is written
+					rewatchConfigDir(watcher, configDir)
+					debounce.Reset(configDebounce)
 				}
 			}
+		case <-debounce.C:
+			// Signal a restart
+			select {
+			case restartChan <- struct{}{}:
+				log.Printf("Detected change to config file: %s", configPath)
+			default:
+				// A restart is already pending, no need to send again
+			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
@@ -392,10 +889,22 @@ func watchConfigFile(ctx context.Context, watcher *fsnotify.Watcher, configPath
 	}
 }
 
+// rewatchConfigDir makes sure configDir is watched after its entries or the directory itself were
+// replaced, so later edits are still detected. It is a no-op when the watch is still current
+func rewatchConfigDir(watcher *fsnotify.Watcher, configDir string) {
+	if err := watcher.Add(configDir); err != nil {
+		log.Printf("Error watching config directory %s: %v", configDir, err)
+	}
+}
+
 // runApp starts the application with the given configuration
-func runApp(ctx context.Context, cfg *config.Config, configPath string, restartChan chan struct{}) *sync.WaitGroup {
-	// Initialize logger
-	if err := logger.Initialize(cfg.Logging.FilePath); err != nil {
+// The setup that can fail was checked by checkRuntimeSetup, so failures here are fatal
+func runApp(ctx context.Context, cfg *config.Config, configPath string, restartChan chan struct{}) *runningApp {
+	// Initialize logger, the level and format were validated with the configuration
+	level, _ := logger.ParseLevel(cfg.Logging.Level)
+	logger.SetLevel(level)
+	format, _ := logger.ParseFormat(cfg.Logging.Format)
+	if err := logger.Initialize(cfg.Logging.FilePath, format); err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 	defer func() {
@@ -404,10 +913,30 @@ func runApp(ctx context.Context, cfg *config.Config, configPath string, restartC
 		}
 	}()
 
+	// Point out collectors that will never report anything because an external tool is missing
+	if !cfg.Runtime.SkipToolCheck {
+		for _, msg := range missingToolsMessages(cfg) {
+			logger.Warnf("%s", msg)
+		}
+	}
+
+	// Warn about metadata keys reserved for the probe, as a development aid
+	if cfg.Debug.ValidateMetadata {
+		collector.SetMetadataValidation(logger.Printf)
+	} else {
+		collector.SetMetadataValidation(nil)
+	}
+
+	// Spread out fleet-wide restarts so probes don't all hit the API at the same time
+	if !waitStartupJitter(ctx, cfg.Runtime.StartupJitter) {
+		logger.Printf("Startup canceled during startup jitter")
+		return &runningApp{}
+	}
+
 	// Get the machine name for metrics
 	machineName, err := cfg.GetMachineName()
 	if err != nil {
-		logger.Printf("Warning: Failed to get machine name: %v. Using 'unknown'", err)
+		logger.Warnf("Failed to get machine name: %v. Using 'unknown'", err)
 		machineName = "unknown"
 	}
 	logger.Printf("Using machine name: %s", machineName)
@@ -417,7 +946,7 @@ func runApp(ctx context.Context, cfg *config.Config, configPath string, restartC
 
 	switch cfg.Sender.Target {
 	case "api":
-		metricSender = sender.NewAPISender(
+		apiSender := sender.NewAPISender(
 			cfg.API.URL,
 			cfg.API.OrganizationID,
 			cfg.API.ServerID,
@@ -426,14 +955,149 @@ func runApp(ctx context.Context, cfg *config.Config, configPath string, restartC
 			cfg.API.EncryptionKey,
 			configPath,
 			restartChan,
+			cfg.API.RequestTimeout,
 		)
-		logger.Printf("Metrics will be sent to API: %s for organization: %s", cfg.API.URL, cfg.API.OrganizationID)
-		if cfg.API.EncryptionKey != "" {
-			logger.Printf("Encryption enabled for API communication")
+		apiSender.SetRetryPolicy(sender.RetryPolicy{
+			MaxRetries:     cfg.Sender.MaxRetries,
+			InitialBackoff: cfg.Sender.InitialBackoff,
+			MaxBackoff:     cfg.Sender.MaxBackoff,
+		})
+		apiSender.SetConfigFetchRetryPolicy(sender.RetryPolicy{
+			MaxRetries:     cfg.API.ConfigFetch.MaxRetries,
+			InitialBackoff: cfg.API.ConfigFetch.InitialBackoff,
+			MaxBackoff:     cfg.API.ConfigFetch.MaxBackoff,
+		})
+		if apiTLS := cfg.API.TLS; apiTLS.CAFile != "" || apiTLS.ClientCertFile != "" {
+			tlsConfig, err := apiTLSConfig(cfg)
+			if err != nil {
+				logger.Fatalf("Failed to set up TLS for API requests: %v", err)
+			}
+			apiSender.SetTLSConfig(tlsConfig)
+			if apiTLS.CAFile != "" {
+				logger.Printf("API server certificates are verified against CA file: %s", apiTLS.CAFile)
+			}
+			if apiTLS.ClientCertFile != "" {
+				logger.Printf("API requests present client certificate: %s", apiTLS.ClientCertFile)
+			}
+		}
+		compressionDisabled := cfg.API.Compression.Enabled != nil && !*cfg.API.Compression.Enabled
+		apiSender.SetCompression(sender.Compression{
+			Disabled: compressionDisabled,
+			Level:    cfg.API.Compression.Level,
+			MinSize:  cfg.API.Compression.MinSize,
+		})
+		if compressionDisabled {
+			logger.Printf("API request bodies will be sent uncompressed")
+		}
+		if cfg.API.Proxy != "" {
+			if err := apiSender.SetProxy(cfg.API.Proxy); err != nil {
+				logger.Fatalf("Failed to set API proxy: %v", err)
+			}
+			// The proxy URL may embed credentials, which must not end up in the logs
+			if proxy, err := url.Parse(cfg.API.Proxy); err == nil {
+				logger.Printf("API requests will go through proxy: %s", proxy.Redacted())
+			}
+		}
+		if cfg.API.MaxBodyBytes > 0 {
+			priority := make([]collector.MetricCategory, 0, len(cfg.API.CategoryPriority))
+			for _, category := range cfg.API.CategoryPriority {
+				priority = append(priority, collector.MetricCategory(category))
+			}
+			apiSender.SetBodyLimit(sender.BodyLimit{MaxBytes: cfg.API.MaxBodyBytes, CategoryPriority: priority})
+			logger.Printf("Metrics requests are limited to %d bytes", cfg.API.MaxBodyBytes)
+		}
+		if cfg.Debug.LogRequests {
+			apiSender.SetDebugRequests(true)
+			if logger.Enabled(logger.LevelDebug) {
+				logger.Debugf("API requests will be logged")
+			} else {
+				logger.Warnf("debug.log_requests needs logging.level debug, API requests won't be logged")
+			}
+		}
+		if cfg.Sender.MaxBatchSize > 0 {
+			apiSender.SetMaxBatchSize(cfg.Sender.MaxBatchSize)
+			logger.Printf("Metrics will be sent in batches of at most %d metrics", cfg.Sender.MaxBatchSize)
+		}
+		if cfg.Sender.Streaming {
+			apiSender.SetStreaming(true)
+			logger.Printf("Metrics will be streamed to the API as NDJSON")
+		}
+		if cfg.Sender.IncludeDigest {
+			apiSender.SetDigest(true)
+			logger.Printf("Metrics payloads will include their SHA-256 digest")
+		}
+		if cfg.Sender.Serialization == "protobuf" {
+			apiSender.SetProtobuf(true)
+			logger.Printf("Metrics payloads will be serialized as protobuf")
+		}
+		if cfg.Sender.IncludeChangeManifest {
+			apiSender.SetChangeManifest(sender.NewChangeTracker())
+			logger.Printf("Metrics payloads will list the metrics changed since the last send")
+		}
+		if cfg.Sender.SequenceFile != "" {
+			seq, err := sender.NewSequence(cfg.Sender.SequenceFile)
+			if err != nil {
+				logger.Warnf("Send sequence numbers disabled: %v", err)
+			} else {
+				apiSender.SetSequence(seq)
+				logger.Printf("Numbering metric sends in session %s from %d", seq.SessionID(), seq.Next())
+			}
+		}
+		metricSender = apiSender
+		logger.Printf("Metrics will be sent to API: %s for organization: %s", cfg.API.URL, cfg.API.OrganizationID)
+		if cfg.API.EncryptionKey != "" {
+			logger.Printf("Encryption enabled for API communication")
+		}
+	case "log_file":
+		var fileLogger *sender.FileLogger
+		if cfg.Sender.EncryptAtRest {
+			fileLogger = sender.NewEncryptedFileLogger(cfg.LogFile.Path, cfg.API.EncryptionKey)
+			logger.Printf("Metrics will be logged to file: %s (encrypted at rest)", cfg.LogFile.Path)
+		} else {
+			fileLogger = sender.NewFileLogger(cfg.LogFile.Path)
+			logger.Printf("Metrics will be logged to file: %s", cfg.LogFile.Path)
+		}
+		if cfg.Sender.IncludeDigest {
+			fileLogger.SetDigest(true)
+			logger.Printf("Each logged batch will include its SHA-256 digest")
+		}
+		fileLogger.SetCSV(cfg.LogFile.Format == "csv")
+		if cfg.LogFile.Format == "csv" {
+			logger.Printf("Metrics will be logged as CSV rows")
+		}
+		metricSender = fileLogger
+	case "statsd":
+		metricSender = sender.NewStatsDSender(cfg.StatsD.Address, cfg.StatsD.Prefix)
+		logger.Printf("Metrics will be sent to StatsD server: %s", cfg.StatsD.Address)
+	case "otlp":
+		otlpSender, err := sender.NewOTLPSender(cfg.OTLP.Endpoint, cfg.OTLP.Protocol, cfg.OTLP.Headers, machineName, version.GetVersion(), cfg.OTLP.Timeout)
+		if err != nil {
+			logger.Fatalf("Failed to set up OTLP export: %v", err)
+		}
+		metricSender = otlpSender
+		logger.Printf("Metrics will be exported to OpenTelemetry collector: %s over %s", cfg.OTLP.Endpoint, cfg.OTLP.Protocol)
+	case "mqtt":
+		mqttOpts, err := mqttOptions(cfg)
+		if err != nil {
+			logger.Fatalf("Failed to set up TLS for MQTT: %v", err)
+		}
+		mqttSender, err := sender.NewMQTTSender(mqttOpts, machineName)
+		if err != nil {
+			logger.Fatalf("Failed to set up MQTT output: %v", err)
+		}
+		metricSender = mqttSender
+		logger.Printf("Metrics will be published to MQTT broker %s (QoS %d, TLS: %t)", mqttOpts.Address, cfg.MQTT.QoS, cfg.MQTT.TLS.Enabled)
+	case "syslog":
+		syslogSender, err := sender.NewSyslogSender(cfg.Syslog.Network, cfg.Syslog.Address, cfg.Syslog.Facility, cfg.Syslog.Tag)
+		if err != nil {
+			logger.Fatalf("Failed to set up syslog output: %v", err)
+		}
+		metricSender = syslogSender
+		if cfg.Syslog.Network == "" {
+			logger.Printf("Metrics will be sent to the local syslog daemon (facility: %s)", cfg.Syslog.Facility)
+		} else {
+			logger.Printf("Metrics will be sent to syslog server: %s over %s (facility: %s)", cfg.Syslog.Address, cfg.Syslog.Network, cfg.Syslog.Facility)
 		}
-	case "log_file":
-		metricSender = sender.NewFileLogger(cfg.LogFile.Path)
-		logger.Printf("Metrics will be logged to file: %s", cfg.LogFile.Path)
 	default:
 		logger.Fatalf("Unknown sender target: %s", cfg.Sender.Target)
 	}
@@ -442,97 +1106,83 @@ func runApp(ctx context.Context, cfg *config.Config, configPath string, restartC
 	systemInfoCollector := system.NewSystemInfoCollector()
 	systemInfo, err := systemInfoCollector.Collect()
 	if err != nil {
-		logger.Printf("Warning: Failed to collect system information: %v", err)
+		logger.Warnf("Failed to collect system information: %v", err)
 	} else {
-		if err := metricSender.Send(systemInfo); err != nil {
-			logger.Printf("Warning: Failed to send system information: %v", err)
+		if err := metricSender.Send(applyLabels(systemInfo, cfg.Labels)); err != nil {
+			logger.Warnf("Failed to send system information: %v", err)
 		} else {
 			logger.Printf("Initial system information sent successfully")
 		}
 	}
 
-	// Channel for collected metrics
-	metricsChan := make(chan []collector.Metrics, 100)
+	// Give up root once the privileged setup is done
+	if cfg.Runtime.User != "" {
+		if err := dropPrivileges(cfg, configPath); err != nil {
+			logger.Fatalf("Failed to drop privileges: %v", err)
+		}
+	}
+
+	// Queue for collected metrics
+	queue := newMetricsQueue(100, cfg.Sender.OnFull)
 
-	// Use WaitGroup to track goroutines
-	var wg sync.WaitGroup
+	app := &runningApp{sendIntervals: make(chan time.Duration, 1)}
 
 	// Start collectors based on configuration
-	if cfg.Collection.CPU.Enabled {
-		wg.Add(1)
-		cpuCollector := system.NewCPUCollector()
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "CPU", cpuCollector, metricsChan, cfg.Collection.CPU.Interval)
-		}()
-		logger.Printf("CPU collector started with interval: %v", cfg.Collection.CPU.Interval)
+	scheduler := newCollectorScheduler(ctx, &app.wg, queue, cfg.Collection.Scheduler, cfg.Collection.Workers)
+	if cfg.Collection.Scheduler == "pool" {
+		logger.Printf("Collectors will run in a pool of %d workers", cfg.Collection.Workers)
 	}
-
-	if cfg.Collection.RAM.Enabled {
-		wg.Add(1)
-		ramCollector := system.NewRAMCollector()
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "RAM", ramCollector, metricsChan, cfg.Collection.RAM.Interval)
-		}()
-		logger.Printf("RAM collector started with interval: %v", cfg.Collection.RAM.Interval)
+	for _, name := range collectors.Unregistered(cfg) {
+		logger.Warnf("Custom collector %s is enabled but not registered, skipping it", name)
 	}
-
-	if cfg.Collection.Disk.Enabled {
-		wg.Add(1)
-		diskCollector := system.NewDiskCollector(cfg.Collection.Disk.MountPoints)
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "Disk", diskCollector, metricsChan, cfg.Collection.Disk.Interval)
-		}()
-		logger.Printf("Disk collector started with interval: %v", cfg.Collection.Disk.Interval)
+	registry := collectors.NewRegistry(cfg)
+	if unsupported := registry.Unsupported(); len(unsupported) > 0 {
+		logger.Printf("Skipping collectors unsupported on %s: %s", runtime.GOOS, strings.Join(unsupported, ", "))
 	}
-
-	if cfg.Collection.Service.Enabled {
-		wg.Add(1)
-		serviceCollector := system.NewServiceCollector(cfg.Collection.Service.Services)
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "Service", serviceCollector, metricsChan, cfg.Collection.Service.Interval)
-		}()
-		logger.Printf("Service collector started with interval: %v", cfg.Collection.Service.Interval)
+	for _, name := range registry.Names() {
+		scheduler.add(name, registry.Collector(name), registry.Interval(name))
+		logger.Printf("%s collector started with interval: %v", name, registry.Interval(name))
 	}
 
-	if cfg.Collection.UserActivity.Enabled {
-		wg.Add(1)
-		userActivityCollector := system.NewUserActivityCollector()
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "UserActivity", userActivityCollector, metricsChan, cfg.Collection.UserActivity.Interval)
-		}()
-		logger.Printf("User activity collector started with interval: %v", cfg.Collection.UserActivity.Interval)
-	}
+	// The probe's own health is always reported, once per send so each send carries the previous one's outcome
+	sendStats := &probecollector.SendStats{}
+	selfCollector := collector.WithTTL(collector.WithTags(probecollector.NewSelfCollector(sendStats), cfg.Labels, nil), 2*cfg.Sender.SendInterval)
+	scheduler.add("Probe", selfCollector, cfg.Sender.SendInterval)
+	scheduler.start()
+	app.cfg, app.scheduler, app.registry, app.collectors, app.selfCollector = cfg, scheduler, registry, registry.Collectors(), selfCollector
 
-	if cfg.Collection.LoginFailures.Enabled {
-		wg.Add(1)
-		loginFailuresCollector := system.NewLoginFailuresCollector()
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "LoginFailures", loginFailuresCollector, metricsChan, cfg.Collection.LoginFailures.Interval)
-		}()
-		logger.Printf("Login failures collector started with interval: %v", cfg.Collection.LoginFailures.Interval)
+	opts := sendOptions{queue: queue, stats: sendStats, intervals: app.sendIntervals, shutdownTimeout: cfg.Sender.ShutdownTimeout}
+
+	// Set up per-interval aggregation if enabled
+	if cfg.Sender.Aggregate.Enabled {
+		opts.aggregator = aggregation.NewAggregator(cfg.Sender.Aggregate.Metrics, cfg.Sender.Aggregate.KeepRaw, cfg.GetPrecision(nil))
+		logger.Printf("Aggregation enabled for metrics: %s", strings.Join(cfg.Sender.Aggregate.Metrics, ", "))
 	}
 
-	if cfg.Collection.Port.Enabled {
-		wg.Add(1)
-		portCollector := system.NewPortCollector()
-		go func() {
-			defer wg.Done()
-			collectRoutine(ctx, "Port", portCollector, metricsChan, cfg.Collection.Port.Interval)
-		}()
-		logger.Printf("Port monitoring collector started with interval: %v", cfg.Collection.Port.Interval)
+	// Set up the disk spool if enabled
+	if cfg.Sender.Target == "api" && cfg.Sender.SpoolDir != "" {
+		maxBytes := int64(cfg.Sender.SpoolMaxSizeMB) * 1024 * 1024
+		if cfg.Sender.EncryptAtRest {
+			opts.spool = sender.NewEncryptedSpool(cfg.Sender.SpoolDir, maxBytes, cfg.API.EncryptionKey, cfg.GetDecryptionKeys())
+		} else {
+			opts.spool = sender.NewSpool(cfg.Sender.SpoolDir, maxBytes)
+		}
+		opts.spool.SetDigest(cfg.Sender.IncludeDigest)
+		logger.Printf("Unsent metrics will be spooled to: %s (max %d MB)", cfg.Sender.SpoolDir, cfg.Sender.SpoolMaxSizeMB)
 	}
 
 	// Start sender routine
-	wg.Add(1)
+	app.wg.Add(1)
 	go func() {
-		defer wg.Done()
-		sendRoutine(ctx, metricSender, metricsChan, cfg.Sender.SendInterval)
+		defer app.wg.Done()
+		sendRoutine(ctx, metricSender, queue.ch, cfg.Sender.SendInterval, opts)
+
+		// Release network resources held by senders such as StatsD
+		if closer, ok := metricSender.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Printf("Error closing sender: %v", err)
+			}
+		}
 	}()
 
 	// Setup a goroutine to wait for the context to be done
@@ -541,41 +1191,556 @@ func runApp(ctx context.Context, cfg *config.Config, configPath string, restartC
 		logger.Printf("Context canceled, shutting down collectors and sender...")
 	}()
 
-	return &wg
+	return app
+}
+
+// runningApp is the application started by runApp
+type runningApp struct {
+	wg            sync.WaitGroup
+	cfg           *config.Config                 // Configuration the app runs with, nil if it was canceled before starting
+	scheduler     collectorScheduler             // Runs the collectors of registry and the probe health collector
+	registry      *collectors.Registry           // Intervals and TTLs of the collectors enabled in cfg
+	collectors    map[string]collector.Collector // Running collectors keyed by name, from the registry they were enabled in
+	selfCollector collector.Collector            // Probe health collector, run every send interval
+	sendIntervals chan time.Duration             // Send interval changes for the sender routine
+}
+
+// Wait blocks until the collectors and the sender of the app have stopped
+func (a *runningApp) Wait() {
+	a.wg.Wait()
+}
+
+// reload applies cfg to the running app without restarting it. Only changes to the send interval
+// and to the enabled flag, interval and TTL of collectors can be applied, see config.OnlySchedulingChanged.
+// It reports false, leaving the app unchanged, if cfg has other changes
+func (a *runningApp) reload(cfg *config.Config) bool {
+	if a.cfg == nil || !config.OnlySchedulingChanged(a.cfg, cfg) {
+		return false
+	}
+
+	registry := collectors.NewRegistry(cfg)
+	for _, name := range a.registry.Names() {
+		if registry.Collector(name) == nil {
+			a.scheduler.remove(name)
+			delete(a.collectors, name)
+			logger.Printf("%s collector stopped", name)
+		}
+	}
+	for _, name := range registry.Names() {
+		interval := registry.Interval(name)
+		// Running collectors are kept since they may hold state between collections
+		c, ok := a.collectors[name]
+		if !ok {
+			a.collectors[name] = registry.Collector(name)
+			a.scheduler.add(name, registry.Collector(name), interval)
+			logger.Printf("%s collector started with interval: %v", name, interval)
+			continue
+		}
+		if ttl := registry.TTL(name); ttl != a.registry.TTL(name) {
+			collector.SetTTL(c, ttl)
+		}
+		if interval != a.registry.Interval(name) {
+			a.scheduler.setInterval(name, interval)
+		}
+	}
+
+	if cfg.Sender.SendInterval != a.cfg.Sender.SendInterval {
+		collector.SetTTL(a.selfCollector, 2*cfg.Sender.SendInterval)
+		a.scheduler.setInterval("Probe", cfg.Sender.SendInterval)
+		sendLatest(a.sendIntervals, cfg.Sender.SendInterval)
+	}
+
+	a.cfg, a.registry = cfg, registry
+	return true
 }
 
-func collectRoutine(ctx context.Context, name string, collector collector.Collector, metricsChan chan []collector.Metrics, interval time.Duration) {
+// waitStartupJitter sleeps for a random duration up to maxJitter
+// It returns false if ctx is done before the delay is over
+func waitStartupJitter(ctx context.Context, maxJitter time.Duration) bool {
+	if maxJitter <= 0 {
+		return true
+	}
+
+	delay := time.Duration(rand.Int63n(int64(maxJitter)))
+	logger.Printf("Delaying startup by %v (startup jitter up to %v)", delay.Round(time.Millisecond), maxJitter)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func collectRoutine(ctx context.Context, name string, c collector.Collector, queue *metricsQueue, interval time.Duration, intervals <-chan time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	bounded := &boundedCollector{c: c}
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Printf("%s collection routine shutting down", name)
 			return
+		case newInterval := <-intervals:
+			interval = newInterval
+			ticker.Reset(interval)
+			logger.Printf("%s collection interval changed to %v", name, interval)
 		case <-ticker.C:
-			metrics, err := collector.Collect()
-			if err != nil {
-				logger.Printf("Error collecting %s metrics: %v", name, err)
-				continue
+			if !collectOnce(ctx, name, bounded, queue, collectTimeout(interval)) {
+				return
 			}
+		}
+	}
+}
 
-			if len(metrics) == 0 {
-				continue
+// minCollectTimeout is the least time allowed for a collection, whatever the collector's interval
+const minCollectTimeout = time.Second
+
+var (
+	// errCollectTimeout is returned for a collection that exceeded its deadline
+	errCollectTimeout = errors.New("collection exceeded its deadline")
+	// errCollectRunning is returned while an abandoned collection of the same collector is still running
+	errCollectRunning = errors.New("previous collection still running")
+)
+
+// collectTimeout returns the time allowed for a collection of a collector running every interval,
+// which must be done by the time the next one is due
+func collectTimeout(interval time.Duration) time.Duration {
+	return max(interval, minCollectTimeout)
+}
+
+// boundedCollector runs the collections of a collector with a deadline. Collectors implementing
+// collector.ContextCollector are interrupted when it passes. Others can't be: their collection is
+// abandoned and keeps running in the background, and the following ones fail with errCollectRunning
+// until it returns, so a collector never runs concurrently with itself
+type boundedCollector struct {
+	c       collector.Collector
+	running atomic.Bool
+}
+
+// collect returns the metrics of a collection taking at most timeout, errCollectTimeout if it takes
+// longer, or ctx.Err() once ctx is done
+func (b *boundedCollector) collect(ctx context.Context, timeout time.Duration) ([]collector.Metrics, error) {
+	if !b.running.CompareAndSwap(false, true) {
+		return nil, errCollectRunning
+	}
+	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		metrics []collector.Metrics
+		err     error
+	}
+	results := make(chan result, 1)
+	go func() {
+		metrics, err := collector.CollectWithContext(deadlineCtx, b.c)
+		b.running.Store(false)
+		results <- result{metrics: metrics, err: err}
+	}()
+
+	select {
+	case r := <-results:
+		// Collectors giving up on the deadline return its error, or one wrapping it
+		if r.err == nil || deadlineCtx.Err() == nil {
+			return r.metrics, r.err
+		}
+	case <-deadlineCtx.Done():
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, errCollectTimeout
+}
+
+// collectOnce collects metrics from c within timeout and queues them. A collection that exceeds
+// timeout is logged and its cycle skipped
+// It returns false if ctx was canceled while collecting or waiting for room in the queue
+func collectOnce(ctx context.Context, name string, c *boundedCollector, queue *metricsQueue, timeout time.Duration) bool {
+	metrics, err := c.collect(ctx, timeout)
+	if ctx.Err() != nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, errCollectTimeout):
+		logger.Warnf("%s collection took longer than %v, skipping this cycle", name, timeout)
+		return true
+	case errors.Is(err, errCollectRunning):
+		logger.Warnf("%s collection of a previous cycle is still running, skipping this cycle", name)
+		return true
+	case err != nil:
+		logger.Printf("Error collecting %s metrics: %v", name, err)
+		return true
+	}
+
+	if len(metrics) == 0 {
+		return true
+	}
+
+	queued, ok := queue.push(ctx, metrics)
+	if !ok {
+		return false
+	}
+	if queued {
+		for _, m := range metrics {
+			logMetric(name, m)
+		}
+	}
+	return true
+}
+
+// collectorScheduler runs collectors at their interval until its context is canceled
+type collectorScheduler interface {
+	// add registers a collector, which may start running right away. Collectors added after
+	// start run one interval later
+	add(name string, c collector.Collector, interval time.Duration)
+	// start runs the collectors registered so far, it must be called once all of them were added
+	start()
+	// setInterval changes the interval of a collector, its next run is one new interval from now
+	setInterval(name string, interval time.Duration)
+	// remove stops running a collector
+	remove(name string)
+}
+
+// newCollectorScheduler creates the scheduler for the given mode: "pool" runs collectors in a bounded
+// worker pool, any other mode runs each collector in its own goroutine
+func newCollectorScheduler(ctx context.Context, wg *sync.WaitGroup, queue *metricsQueue, mode string, workers int) collectorScheduler {
+	if mode == "pool" {
+		return &poolScheduler{ctx: ctx, wg: wg, queue: queue, workers: workers}
+	}
+	return &goroutineScheduler{ctx: ctx, wg: wg, queue: queue, routines: make(map[string]*collectorRoutine)}
+}
+
+// goroutineScheduler runs each collector in its own goroutine with its own ticker
+type goroutineScheduler struct {
+	ctx      context.Context
+	wg       *sync.WaitGroup
+	queue    *metricsQueue
+	routines map[string]*collectorRoutine
+}
+
+// collectorRoutine controls the goroutine running a collector for a goroutineScheduler
+type collectorRoutine struct {
+	cancel    context.CancelFunc
+	intervals chan time.Duration // Holds the latest interval change not yet applied
+}
+
+func (s *goroutineScheduler) add(name string, c collector.Collector, interval time.Duration) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	routine := &collectorRoutine{cancel: cancel, intervals: make(chan time.Duration, 1)}
+	s.routines[name] = routine
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		collectRoutine(ctx, name, c, s.queue, interval, routine.intervals)
+	}()
+}
+
+func (s *goroutineScheduler) start() {}
+
+func (s *goroutineScheduler) setInterval(name string, interval time.Duration) {
+	if routine, ok := s.routines[name]; ok {
+		sendLatest(routine.intervals, interval)
+	}
+}
+
+func (s *goroutineScheduler) remove(name string) {
+	if routine, ok := s.routines[name]; ok {
+		routine.cancel()
+		delete(s.routines, name)
+	}
+}
+
+// sendLatest puts d in ch, a channel with a buffer of one, replacing a value not received yet
+// It must not be called concurrently for the same channel
+func sendLatest(ch chan time.Duration, d time.Duration) {
+	select {
+	case ch <- d:
+	default:
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- d
+	}
+}
+
+// scheduledCollector is a collector registered with a poolScheduler
+type scheduledCollector struct {
+	name     string
+	c        *boundedCollector
+	interval time.Duration
+	timeout  time.Duration // Deadline of the current run, set when it is dispatched
+	next     time.Time     // When the collector is due next
+	removed  bool          // Not rescheduled once its current run is done
+}
+
+// poolUpdate changes the collectors of a running poolScheduler
+type poolUpdate struct {
+	name     string
+	c        collector.Collector // Collector to add, nil to change the interval of name or remove it
+	interval time.Duration       // New interval, zero to remove the collector
+}
+
+// poolScheduler runs due collectors through a bounded pool of workers, driven by a single timer
+// A collector is never run concurrently with itself: like a ticker, it skips the runs it missed
+// while it was still running or waiting for a worker
+type poolScheduler struct {
+	ctx        context.Context
+	wg         *sync.WaitGroup
+	queue      *metricsQueue
+	workers    int
+	collectors []*scheduledCollector // Only used by run once started
+	updates    chan poolUpdate       // Changes applied by run, set by start
+}
+
+func (s *poolScheduler) add(name string, c collector.Collector, interval time.Duration) {
+	if s.updates != nil {
+		s.update(poolUpdate{name: name, c: c, interval: interval})
+		return
+	}
+	s.collectors = append(s.collectors, &scheduledCollector{name: name, c: &boundedCollector{c: c}, interval: interval})
+}
+
+func (s *poolScheduler) setInterval(name string, interval time.Duration) {
+	s.update(poolUpdate{name: name, interval: interval})
+}
+
+func (s *poolScheduler) remove(name string) {
+	s.update(poolUpdate{name: name})
+}
+
+// update hands u to the running scheduler, it is dropped once the context is canceled
+func (s *poolScheduler) update(u poolUpdate) {
+	select {
+	case s.updates <- u:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *poolScheduler) start() {
+	s.updates = make(chan poolUpdate)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(time.Now())
+	}()
+}
+
+// run dispatches due collectors to the workers until the context is canceled
+func (s *poolScheduler) run(now time.Time) {
+	work := make(chan *scheduledCollector)
+	done := make(chan *scheduledCollector)
+	var workers sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for sc := range work {
+				if !collectOnce(s.ctx, sc.name, sc.c, s.queue, sc.timeout) {
+					return
+				}
+				select {
+				case done <- sc:
+				case <-s.ctx.Done():
+					return
+				}
 			}
+		}()
+	}
+	defer func() {
+		close(work)
+		workers.Wait()
+		logger.Printf("Collector pool shutting down")
+	}()
 
-			select {
-			case metricsChan <- metrics:
-				for _, m := range metrics {
-					logMetric(name, m)
+	// Collectors waiting for their next run, and due collectors waiting for a worker
+	waiting := make([]*scheduledCollector, 0, len(s.collectors))
+	var due []*scheduledCollector
+	for _, sc := range s.collectors {
+		sc.next = now.Add(sc.interval)
+		waiting = append(waiting, sc)
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		// Arm the timer for the earliest collector not already due
+		var next time.Time
+		for _, sc := range waiting {
+			if next.IsZero() || sc.next.Before(next) {
+				next = sc.next
+			}
+		}
+		timer.Stop()
+		var timerC <-chan time.Time
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+			timerC = timer.C
+		}
+
+		// Only offer work when a collector is due
+		var dispatch chan<- *scheduledCollector
+		var first *scheduledCollector
+		if len(due) > 0 {
+			dispatch, first = work, due[0]
+			first.timeout = collectTimeout(first.interval)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-timerC:
+			remaining := waiting[:0]
+			for _, sc := range waiting {
+				if sc.next.After(now) {
+					remaining = append(remaining, sc)
+				} else {
+					due = append(due, sc)
 				}
-			case <-ctx.Done():
-				return
+			}
+			waiting = remaining
+		case dispatch <- first:
+			due = due[1:]
+		case sc := <-done:
+			if sc.removed {
+				break
+			}
+			// Skip the runs missed while the collector was busy, as a ticker would
+			for !sc.next.After(time.Now()) {
+				sc.next = sc.next.Add(sc.interval)
+			}
+			waiting = append(waiting, sc)
+		case u := <-s.updates:
+			waiting, due = s.apply(u, waiting, due, time.Now())
+		}
+	}
+}
+
+// apply makes the change u to the scheduled collectors, given the collectors waiting for their
+// next run and the ones due, and returns both lists updated
+func (s *poolScheduler) apply(u poolUpdate, waiting, due []*scheduledCollector, now time.Time) ([]*scheduledCollector, []*scheduledCollector) {
+	if u.c != nil {
+		sc := &scheduledCollector{name: u.name, c: &boundedCollector{c: u.c}, interval: u.interval, next: now.Add(u.interval)}
+		s.collectors = append(s.collectors, sc)
+		return append(waiting, sc), due
+	}
+
+	var sc *scheduledCollector
+	for _, c := range s.collectors {
+		if c.name == u.name {
+			sc = c
+		}
+	}
+	if sc == nil {
+		return waiting, due
+	}
+
+	if u.interval <= 0 {
+		sc.removed = true
+		s.collectors = withoutCollector(s.collectors, sc)
+		return withoutCollector(waiting, sc), withoutCollector(due, sc)
+	}
+
+	// A due or running collector picks up the interval when it is rescheduled
+	sc.interval = u.interval
+	for _, c := range waiting {
+		if c == sc {
+			sc.next = now.Add(u.interval)
+		}
+	}
+	return waiting, due
+}
+
+// withoutCollector returns a copy of list without sc
+func withoutCollector(list []*scheduledCollector, sc *scheduledCollector) []*scheduledCollector {
+	kept := make([]*scheduledCollector, 0, len(list))
+	for _, c := range list {
+		if c != sc {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// metricsQueue carries collected metrics from the collectors to the sender
+type metricsQueue struct {
+	ch      chan []collector.Metrics
+	onFull  string        // Policy when ch is full: block, drop_oldest or drop_new
+	dropped atomic.Uint64 // Metrics dropped because ch was full
+	warned  sync.Once
+}
+
+// newMetricsQueue creates a queue holding up to size batches
+func newMetricsQueue(size int, onFull string) *metricsQueue {
+	return &metricsQueue{
+		ch:     make(chan []collector.Metrics, size),
+		onFull: onFull,
+	}
+}
+
+// push queues a batch according to the on_full policy. It reports whether the batch was queued,
+// and returns ok false if ctx was done while blocked on a full queue
+func (q *metricsQueue) push(ctx context.Context, metrics []collector.Metrics) (queued bool, ok bool) {
+	switch q.onFull {
+	case "drop_new":
+		select {
+		case q.ch <- metrics:
+			return true, true
+		default:
+			q.drop(len(metrics))
+			return false, true
+		}
+	case "drop_oldest":
+		for {
+			select {
+			case q.ch <- metrics:
+				return true, true
+			default:
+			}
+			// The sender may have drained the queue in the meantime, so don't wait for a batch
+			select {
+			case oldest := <-q.ch:
+				q.drop(len(oldest))
+			default:
 			}
 		}
+	default:
+		select {
+		case q.ch <- metrics:
+			return true, true
+		case <-ctx.Done():
+			return false, false
+		}
 	}
 }
 
+// drop counts n dropped metrics, logging only the first drop as the count is reported on each send
+func (q *metricsQueue) drop(n int) {
+	q.dropped.Add(uint64(n))
+	q.warned.Do(func() {
+		logger.Warnf("Metrics queue is full, dropping metrics (on_full: %s)", q.onFull)
+	})
+}
+
+// droppedMetric returns the total number of dropped metrics as a metric, if any were dropped
+func (q *metricsQueue) droppedMetric() (collector.Metrics, bool) {
+	dropped := q.dropped.Load()
+	if dropped == 0 {
+		return collector.Metrics{}, false
+	}
+	return collector.Metrics{
+		Timestamp: time.Now(),
+		Category:  collector.CategoryProbe,
+		Name:      collector.NameMetricsDropped,
+		Metadata:  collector.MetricMetadata{"policy": q.onFull},
+		Value:     dropped,
+	}, true
+}
+
 func logMetric(collectorName string, metric collector.Metrics) {
 	var metadataStr string
 	if len(metric.Metadata) > 0 {
@@ -589,7 +1754,19 @@ func logMetric(collectorName string, metric collector.Metrics) {
 		collectorName, metric.Category, metric.Name, metadataStr, metric.Value)
 }
 
-func sendRoutine(ctx context.Context, metricSender sender.Sender, metricsChan chan []collector.Metrics, interval time.Duration) {
+// sendOptions holds the optional stages of the send pipeline
+type sendOptions struct {
+	aggregator *aggregation.Aggregator   // Adds per-interval summaries to each send
+	spool      *sender.Spool             // Keeps batches on disk when the API is unreachable
+	queue      *metricsQueue             // Reports the metrics dropped by collectors on each send
+	stats      *probecollector.SendStats // Counts sends and their latency for the probe health metrics
+	intervals  <-chan time.Duration      // Changes to the send interval applied without restarting
+
+	shutdownTimeout time.Duration // Time allowed for the final send on shutdown, unbounded when zero
+}
+
+// sendRoutine buffers collected metrics and sends them every interval
+func sendRoutine(ctx context.Context, metricSender sender.Sender, metricsChan chan []collector.Metrics, interval time.Duration, opts sendOptions) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -599,43 +1776,54 @@ func sendRoutine(ctx context.Context, metricSender sender.Sender, metricsChan ch
 	for {
 		select {
 		case <-ctx.Done():
+			if opts.aggregator != nil {
+				allMetrics = append(allMetrics, opts.aggregator.Flush(time.Now())...)
+			}
 			// Try to send any remaining metrics before shutting down
 			if len(allMetrics) > 0 {
-				if err := metricSender.Send(allMetrics); err != nil {
-					// Check if this is a fatal error
-					if strings.Contains(err.Error(), "FATAL:") {
-						logger.Printf("Fatal error encountered: %v", err)
-						logger.Printf("Shutting down probe service due to fatal error")
-						os.Exit(1)
-					} else if strings.Contains(err.Error(), "WARNING:") {
-						// Warning error - log but continue
-						logger.Printf("Warning: %v", err)
-					} else {
-						// Non-fatal error - log and continue
-						logger.Printf("Error sending final metrics: %v", err)
-					}
-				} else {
-					logger.Printf("Sent %d final metrics", len(allMetrics))
-				}
+				sendFinalMetrics(metricSender, allMetrics, opts)
 			}
 			logger.Printf("Sender routine shutting down")
 			return
 		case metrics := <-metricsChan:
+			if opts.aggregator != nil {
+				metrics = opts.aggregator.Add(metrics)
+			}
 			allMetrics = append(allMetrics, metrics...)
+		case interval := <-opts.intervals:
+			ticker.Reset(interval)
+			logger.Printf("Send interval changed to %v", interval)
 		case <-ticker.C:
+			if opts.aggregator != nil {
+				allMetrics = append(allMetrics, opts.aggregator.Flush(time.Now())...)
+			}
+			if opts.queue != nil {
+				if m, ok := opts.queue.droppedMetric(); ok {
+					allMetrics = append(allMetrics, m)
+				}
+			}
+
+			// Replay spooled batches first so metrics are delivered in order
+			if opts.spool != nil {
+				if sent, err := opts.spool.Flush(ctx, metricSender.SendWithContext); err != nil {
+					handleSendError(err, "Error sending spooled metrics")
+				} else if sent > 0 {
+					logger.Printf("Sent %d spooled metrics", sent)
+				}
+			}
+
 			if len(allMetrics) > 0 {
-				if err := metricSender.Send(allMetrics); err != nil {
-					// Check if this is a fatal error
-					if strings.Contains(err.Error(), "FATAL:") {
-						logger.Printf("Fatal error encountered: %v", err)
-						logger.Printf("Shutting down probe service due to fatal error")
-						os.Exit(1)
-					} else if strings.Contains(err.Error(), "WARNING:") {
-						// Warning error - log but continue (metrics will be buffered for next attempt)
-						logger.Printf("Warning: %v", err)
-					} else {
-						// Non-fatal error - log and continue (metrics will be buffered for next attempt)
-						logger.Printf("Error sending metrics: %v", err)
+				start := time.Now()
+				err := metricSender.SendWithContext(ctx, allMetrics)
+				if opts.stats != nil {
+					opts.stats.Record(time.Since(start), err)
+				}
+				if err != nil {
+					// Metrics will be buffered for next attempt, except the batches already accepted
+					handleSendError(err, "Error sending metrics")
+					allMetrics = allMetrics[sender.SentBefore(err):]
+					if spoolMetrics(opts.spool, allMetrics, err) {
+						allMetrics = []collector.Metrics{}
 					}
 				} else {
 					logger.Printf("Sent %d metrics", len(allMetrics))
@@ -646,3 +1834,76 @@ func sendRoutine(ctx context.Context, metricSender sender.Sender, metricsChan ch
 		}
 	}
 }
+
+// sendFinalMetrics sends the metrics still buffered on shutdown. The collection context is already
+// canceled at this point, so the send gets a fresh context bounded by the shutdown timeout
+func sendFinalMetrics(metricSender sender.Sender, metrics []collector.Metrics, opts sendOptions) {
+	ctx := context.Background()
+	if opts.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.shutdownTimeout)
+		defer cancel()
+	}
+
+	err := metricSender.SendWithContext(ctx, metrics)
+	if err == nil {
+		logger.Printf("Sent %d final metrics", len(metrics))
+		return
+	}
+
+	handleSendError(err, "Error sending final metrics")
+	metrics = metrics[sender.SentBefore(err):]
+	// Keep the metrics on disk so they are sent after the next start
+	if spoolMetrics(opts.spool, metrics, err) {
+		return
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Printf("Final send timed out after %v, dropped %d metrics", opts.shutdownTimeout, len(metrics))
+	} else {
+		logger.Printf("Dropped %d metrics that could not be sent before shutdown", len(metrics))
+	}
+}
+
+// applyLabels adds global labels to the metadata of metrics sent outside of collectRoutine, such as system information
+// Metadata maps are replaced by merged copies, so maps shared by collectors are never modified
+func applyLabels(metrics []collector.Metrics, labels map[string]string) []collector.Metrics {
+	if len(labels) == 0 {
+		return metrics
+	}
+	for i := range metrics {
+		metrics[i].Metadata = collector.MergeMetadata(metrics[i].Metadata, labels)
+	}
+	return metrics
+}
+
+// handleSendError logs a send error, exiting the probe if the error is fatal
+func handleSendError(err error, action string) {
+	// Check if this is a fatal error
+	if strings.Contains(err.Error(), "FATAL:") {
+		logger.Printf("Fatal error encountered: %v", err)
+		logger.Printf("Shutting down probe service due to fatal error")
+		os.Exit(1)
+	} else if strings.Contains(err.Error(), "WARNING:") {
+		// Warning error - log but continue
+		logger.Warnf("%v", err)
+	} else {
+		// Non-fatal error - log and continue
+		logger.Printf("%s: %v", action, err)
+	}
+}
+
+// spoolMetrics writes metrics to the spool if the send error is retryable
+// Returns true if the metrics were spooled and can be dropped from memory
+func spoolMetrics(spool *sender.Spool, metrics []collector.Metrics, sendErr error) bool {
+	if spool == nil || !sender.IsRetryable(sendErr) {
+		return false
+	}
+
+	if err := spool.Write(metrics); err != nil {
+		logger.Printf("Error spooling metrics: %v", err)
+		return false
+	}
+
+	logger.Printf("Spooled %d metrics to disk", len(metrics))
+	return true
+}
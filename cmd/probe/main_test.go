@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,8 +22,12 @@ import (
 	"strings"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/monitorly-app/probe/internal/aggregation"
 	"github.com/monitorly-app/probe/internal/collector"
+	probecollector "github.com/monitorly-app/probe/internal/collector/probe"
 	"github.com/monitorly-app/probe/internal/config"
+	"github.com/monitorly-app/probe/internal/sender"
+	"github.com/monitorly-app/probe/internal/version"
 )
 
 // Note: The fatal error handling in sendRoutine (calling os.Exit on 401/404 errors)
@@ -121,10 +131,210 @@ func TestHandleVersionFlag(t *testing.T) {
 	}
 }
 
+func TestDiagInfo(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("test: config"), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		configFlag   string
+		wantContains []string
+	}{
+		{
+			name:         "config found from flag",
+			configFlag:   configPath,
+			wantContains: []string{"Monitorly Probe", "Config file: " + configPath, "source: -config flag", "Config readable: yes"},
+		},
+		{
+			name:         "config not found",
+			configFlag:   filepath.Join(tempDir, "missing.yaml"),
+			wantContains: []string{"Monitorly Probe", "Config file: not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := diagInfo(tt.configFlag)
+			for _, want := range tt.wantContains {
+				if !strings.Contains(output, want) {
+					t.Errorf("diagInfo() output = %q, want it to contain %q", output, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSelftestFlag(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	configYAML := `
+api:
+  url: "https://api.example.com"
+  organization_id: "org"
+  server_id: "server"
+  application_token: "token"
+collection:
+  service_restarts:
+    enabled: true
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	// A PATH without systemctl
+	t.Setenv("PATH", tempDir)
+	err := handleSelftestFlag(configPath)
+	if err == nil || !strings.Contains(err.Error(), "missing external tools") {
+		t.Errorf("handleSelftestFlag() error = %v, want missing external tools", err)
+	}
+	cfg, _ := loadConfig(configPath)
+	want := []string{
+		"service_restarts collector enabled but systemctl not found in PATH",
+		"user_activity collector enabled but who, w not found in PATH",
+	}
+	if got := missingToolsMessages(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("missingToolsMessages() = %v, want %v", got, want)
+	}
+}
+
+func TestHandleValidateConfigFlag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/servers/rejected/") {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/servers/changed/") {
+			w.WriteHeader(http.StatusResetContent)
+			w.Write([]byte("sender:\n  target: \"log_file\"\n"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	apiConfig := func(serverID string) string {
+		return `
+api:
+  url: "` + server.URL + `"
+  organization_id: "org"
+  server_id: "` + serverID + `"
+  application_token: "token"
+`
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		withAPI     bool
+		errContains string
+	}{
+		{name: "valid log file config", content: "sender:\n  target: \"log_file\"\nlog_file:\n  path: \"/tmp/test.log\"\n"},
+		{name: "invalid config", content: "sender:\n  target: \"carrier_pigeon\"\n", errContains: "is invalid"},
+		{name: "accepted by the API", content: apiConfig("accepted"), withAPI: true},
+		{name: "rejected by the API", content: apiConfig("rejected"), withAPI: true, errContains: "was rejected by the API"},
+		{name: "API not asked without the flag", content: apiConfig("rejected")},
+		// The config file is left as is, the API changes are only printed
+		{name: "changed by the API", content: apiConfig("changed"), withAPI: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create test config file: %v", err)
+			}
+
+			err := handleValidateConfigFlag(configPath, tt.withAPI)
+			if tt.errContains == "" && err != nil {
+				t.Errorf("handleValidateConfigFlag() error = %v, want nil", err)
+			}
+			if tt.errContains != "" && (err == nil || !strings.Contains(err.Error(), tt.errContains)) {
+				t.Errorf("handleValidateConfigFlag() error = %v, want it to contain %q", err, tt.errContains)
+			}
+			if data, err := os.ReadFile(configPath); err != nil || string(data) != tt.content {
+				t.Errorf("handleValidateConfigFlag() changed the config file to %q", data)
+			}
+		})
+	}
+}
+
+func TestDryRunMetrics(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configYAML := `
+sender:
+  target: "log_file"
+log_file:
+  path: "/tmp/test.log"
+labels:
+  env: "test"
+collection:
+  ram:
+    enabled: true
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	data, err := dryRunMetrics(configPath)
+	if err != nil {
+		t.Fatalf("dryRunMetrics() error = %v", err)
+	}
+	var metrics []collector.Metrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		t.Fatalf("dryRunMetrics() output is not a JSON list of metrics: %v", err)
+	}
+	if len(metrics) == 0 {
+		t.Fatal("dryRunMetrics() returned no metrics for the enabled RAM collector")
+	}
+	for _, m := range metrics {
+		if m.Metadata["env"] != "test" {
+			t.Errorf("dryRunMetrics() metric %s metadata = %v, want the env label", m.Name, m.Metadata)
+		}
+	}
+
+	if _, err := dryRunMetrics(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("dryRunMetrics() with a missing config file error = nil, want an error")
+	}
+}
+
+func TestDryRunMetrics_KeepsLoginFailuresState(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "login_failures.json")
+	state := `{"last_check":"2026-01-02T03:04:05Z"}`
+	if err := os.WriteFile(stateFile, []byte(state), 0644); err != nil {
+		t.Fatalf("Failed to write state file: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	configYAML := `
+sender:
+  target: "log_file"
+log_file:
+  path: "` + filepath.Join(dir, "metrics.log") + `"
+collection:
+  login_failures:
+    enabled: true
+    state_file: "` + stateFile + `"
+`
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	if _, err := dryRunMetrics(configPath); err != nil {
+		t.Fatalf("dryRunMetrics() error = %v", err)
+	}
+	// The daemon resumes from the state file, a dry run must not skip the failures it reported
+	if data, err := os.ReadFile(stateFile); err != nil || string(data) != state {
+		t.Errorf("state file after a dry run = %q, %v, want it unchanged", data, err)
+	}
+}
+
 func TestHandleCheckUpdateFlag(t *testing.T) {
 	// This test would require mocking the version.CheckForUpdates function
 	// For now, we'll test that it doesn't panic and returns an error or nil
-	err := handleCheckUpdateFlag()
+	err := handleCheckUpdateFlag("config.yaml")
 	// We expect either no error (if update check succeeds) or an error (if it fails)
 	// Both are acceptable in a test environment
 	t.Logf("handleCheckUpdateFlag() returned: %v", err)
@@ -133,7 +343,7 @@ func TestHandleCheckUpdateFlag(t *testing.T) {
 func TestHandleForceUpdateFlag(t *testing.T) {
 	// This test would require mocking the version.CheckForUpdates function
 	// For now, we'll test that it doesn't panic and returns an error or nil
-	err := handleForceUpdateFlag()
+	err := handleForceUpdateFlag("config.yaml")
 	// We expect either no error (if update check succeeds) or an error (if it fails)
 	// Both are acceptable in a test environment
 	t.Logf("handleForceUpdateFlag() returned: %v", err)
@@ -227,6 +437,59 @@ func TestSetupConfigWatcher(t *testing.T) {
 	}
 }
 
+func TestApplyUpdateSettings(t *testing.T) {
+	defer version.SetSignatureKey(nil)
+	defer version.SetReleaseChannel("", false)
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "update.pub")
+	// Ed25519 public key in PEM
+	keyPEM := "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=\n-----END PUBLIC KEY-----\n"
+	if err := os.WriteFile(keyFile, []byte(keyPEM), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalidKeyFile := filepath.Join(dir, "invalid.pub")
+	if err := os.WriteFile(invalidKeyFile, []byte("not a key!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	originalKey := version.ReleasePublicKey
+	defer func() { version.ReleasePublicKey = originalKey }()
+	version.ReleasePublicKey = ""
+
+	tests := []struct {
+		name          string
+		verify        bool
+		publicKeyFile string
+		errContains   string
+	}{
+		{name: "verification disabled"},
+		{name: "pinned key", verify: true, publicKeyFile: keyFile},
+		{name: "no key available", verify: true, errContains: "no release key"},
+		{name: "invalid key", verify: true, publicKeyFile: invalidKeyFile, errContains: "invalid update public key"},
+		{name: "unreadable key", verify: true, publicKeyFile: filepath.Join(dir, "missing.pub"), errContains: "failed to read update public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Updates.VerifySignature = tt.verify
+			cfg.Updates.PublicKeyFile = tt.publicKeyFile
+
+			err := applyUpdateSettings(cfg)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("applyUpdateSettings() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("applyUpdateSettings() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
 func TestStartUpdateChecker(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -352,6 +615,78 @@ updates:
 	})
 }
 
+func TestWaitForRestart(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	validConfig := `
+sender:
+  target: "log_file"
+log_file:
+  path: "` + filepath.Join(filepath.Dir(configPath), "metrics.log") + `"
+logging:
+  file_path: "` + filepath.Join(filepath.Dir(configPath), "app.log") + `"
+`
+
+	// The API accepts configs for the "accepted" server and rejects the others
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/accepted/") {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error": "Invalid configuration", "details": ["unknown collector"]}`))
+	}))
+	defer server.Close()
+	// A CA file that exists passes config validation, but can only fail once it is parsed
+	malformedCA := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(malformedCA, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	apiConfig := func(serverID string) string {
+		return `
+sender:
+  target: "api"
+api:
+  url: "` + server.URL + `"
+  organization_id: "org"
+  server_id: "` + serverID + `"
+  application_token: "token"
+logging:
+  file_path: "` + filepath.Join(filepath.Dir(configPath), "app.log") + `"
+`
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    bool // Whether a config to restart with is returned
+	}{
+		{name: "valid config restarts", content: validConfig, want: true},
+		{name: "invalid config keeps running", content: "sender:\n  target: \"carrier_pigeon\"\n", want: false},
+		{name: "unparsable config keeps running", content: "sender: [", want: false},
+		{name: "config accepted by the API restarts", content: apiConfig("accepted"), want: true},
+		{name: "config rejected by the API keeps running", content: apiConfig("rejected"), want: false},
+		{name: "malformed CA file keeps running", content: strings.Replace(apiConfig("accepted"), "logging:", "  tls:\n    ca_file: \""+malformedCA+"\"\nlogging:", 1), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			restartChan := make(chan struct{}, 1)
+			restartChan <- struct{}{}
+
+			got := waitForRestart(ctx, &runningApp{}, configPath, restartChan)
+			if (got != nil) != tt.want {
+				t.Errorf("waitForRestart() = %v, want a config: %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestRunApplication(t *testing.T) {
 	// Create a temporary directory for test files
 	tempDir := t.TempDir()
@@ -585,6 +920,16 @@ func TestSearchPaths(t *testing.T) {
 	}
 }
 
+func TestLoadUpdateSettings_NoConfigFile(t *testing.T) {
+	// An empty -config resolves to the working directory, which must not be loaded as the config
+	t.Chdir(t.TempDir())
+	for _, flag := range []string{"", ".", "config.yaml"} {
+		if err := loadUpdateSettings(flag); err != nil {
+			t.Errorf("loadUpdateSettings(%q) error = %v, want the default settings", flag, err)
+		}
+	}
+}
+
 func TestFindConfigFile(t *testing.T) {
 	// Create a temporary directory for test files
 	tempDir := t.TempDir()
@@ -630,6 +975,12 @@ log_file:
 			wantErr:     true,
 			errContains: "no config file found in search paths",
 		},
+		{
+			name:        "directory instead of a file",
+			configFlag:  tempDir,
+			wantErr:     true,
+			errContains: "specified config file not found",
+		},
 		{
 			name:        "invalid path with permission error",
 			configFlag:  "/root/restricted/config.yaml",
@@ -857,11 +1208,12 @@ func TestCollectRoutine(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 			defer cancel()
 
-			// Create metrics channel
-			metricsChan := make(chan []collector.Metrics, 10)
+			// Create metrics queue
+			queue := newMetricsQueue(10, "block")
+			metricsChan := queue.ch
 
 			// Run collect routine
-			collectRoutine(ctx, "test-collector", tt.collector, metricsChan, tt.interval)
+			collectRoutine(ctx, "test-collector", tt.collector, queue, tt.interval, nil)
 
 			// Check for metrics
 			if tt.expectMetric {
@@ -888,6 +1240,96 @@ func TestCollectRoutine(t *testing.T) {
 	}
 }
 
+// blockingCollector blocks in Collect until release is closed
+type blockingCollector struct {
+	release chan struct{}
+}
+
+func (c *blockingCollector) Collect() ([]collector.Metrics, error) {
+	<-c.release
+	return []collector.Metrics{{Name: collector.NameCPU, Value: 1.0}}, nil
+}
+
+// cancellableCollector blocks in CollectWithContext until its context is done
+type cancellableCollector struct {
+	blockingCollector
+}
+
+func (c *cancellableCollector) CollectWithContext(ctx context.Context) ([]collector.Metrics, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("interrupted: %w", ctx.Err())
+}
+
+func TestBoundedCollector(t *testing.T) {
+	t.Run("hung collector is abandoned and skipped until it returns", func(t *testing.T) {
+		inner := &blockingCollector{release: make(chan struct{})}
+		b := &boundedCollector{c: inner}
+
+		if _, err := b.collect(context.Background(), 20*time.Millisecond); !errors.Is(err, errCollectTimeout) {
+			t.Fatalf("collect() error = %v, want errCollectTimeout", err)
+		}
+		if _, err := b.collect(context.Background(), 20*time.Millisecond); !errors.Is(err, errCollectRunning) {
+			t.Fatalf("collect() while the abandoned collection runs error = %v, want errCollectRunning", err)
+		}
+
+		close(inner.release)
+		deadline := time.Now().Add(time.Second)
+		for b.running.Load() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		metrics, err := b.collect(context.Background(), time.Second)
+		if err != nil || len(metrics) != 1 {
+			t.Errorf("collect() once the abandoned collection returned = %v, %v, want a metric", metrics, err)
+		}
+	})
+
+	t.Run("context collector is interrupted", func(t *testing.T) {
+		b := &boundedCollector{c: &cancellableCollector{}}
+		if _, err := b.collect(context.Background(), 20*time.Millisecond); !errors.Is(err, errCollectTimeout) {
+			t.Fatalf("collect() error = %v, want errCollectTimeout", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for b.running.Load() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if b.running.Load() {
+			t.Error("interrupted collection still running")
+		}
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		b := &boundedCollector{c: &cancellableCollector{}}
+		if _, err := b.collect(ctx, time.Second); !errors.Is(err, context.Canceled) {
+			t.Errorf("collect() error = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestCollectRoutine_HungCollector(t *testing.T) {
+	inner := &blockingCollector{release: make(chan struct{})}
+	defer close(inner.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	queue := newMetricsQueue(10, "block")
+
+	done := make(chan struct{})
+	go func() {
+		collectRoutine(ctx, "hung", inner, queue, 20*time.Millisecond, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collectRoutine() didn't return on shutdown while its collector hung")
+	}
+	if len(queue.ch) != 0 {
+		t.Errorf("collectRoutine() queued %d batches from a hung collector, want none", len(queue.ch))
+	}
+}
+
 func TestSendRoutine(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -937,7 +1379,7 @@ func TestSendRoutine(t *testing.T) {
 			}
 
 			// Run send routine
-			sendRoutine(ctx, tt.sender, metricsChan, 50*time.Millisecond)
+			sendRoutine(ctx, tt.sender, metricsChan, 50*time.Millisecond, sendOptions{})
 
 			// Check results
 			if tt.expectSent {
@@ -951,6 +1393,409 @@ func TestSendRoutine(t *testing.T) {
 	}
 }
 
+func TestSendRoutineWithAggregation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	metricsChan := make(chan []collector.Metrics, 10)
+	for _, v := range []float64{10, 20, 30} {
+		metricsChan <- []collector.Metrics{
+			{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: v},
+		}
+	}
+
+	mockSender := &MockSender{}
+	sendRoutine(ctx, mockSender, metricsChan, 50*time.Millisecond, sendOptions{aggregator: aggregation.NewAggregator([]string{"cpu"}, false, collector.DefaultPrecision)})
+
+	var sent []collector.Metrics
+	for _, batch := range mockSender.sentMetrics {
+		sent = append(sent, batch...)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sendRoutine() sent %d metrics, want 1 summary", len(sent))
+	}
+	value, ok := sent[0].Value.(map[string]interface{})
+	if !ok || value["count"] != 3 || value["avg"] != 20.0 {
+		t.Errorf("sendRoutine() sent summary %v, want count=3 avg=20", sent[0].Value)
+	}
+}
+
+func TestCollectRoutineWithLabels(t *testing.T) {
+	labels := map[string]string{"environment": "prod", "region": "eu-west"}
+	collectors := map[string]collector.Collector{
+		"CPU": &MockCollector{metrics: []collector.Metrics{
+			{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.5},
+		}},
+		"Disk": &MockCollector{metrics: []collector.Metrics{
+			{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameDisk, Metadata: collector.MetricMetadata{"path": "/", "label": "Root"}, Value: 40.0},
+		}},
+	}
+
+	for name, c := range collectors {
+		ctx, cancel := context.WithTimeout(context.Background(), 80*time.Millisecond)
+		queue := newMetricsQueue(10, "block")
+		collectRoutine(ctx, name, collector.WithTags(c, labels, nil), queue, 50*time.Millisecond, nil)
+		cancel()
+
+		select {
+		case metrics := <-queue.ch:
+			metadata := metrics[0].Metadata
+			if metadata["environment"] != "prod" || metadata["region"] != "eu-west" {
+				t.Errorf("%s metric metadata = %v, want global labels", name, metadata)
+			}
+			if name == "Disk" && metadata["path"] != "/" {
+				t.Errorf("Disk metric metadata = %v, want collector metadata kept", metadata)
+			}
+		default:
+			t.Errorf("%s collector sent no metrics", name)
+		}
+	}
+}
+
+// concurrencyCollector records how many collections run at the same time
+type concurrencyCollector struct {
+	running  *atomic.Int32
+	peak     *atomic.Int32
+	self     atomic.Int32 // Collections of this collector running at the same time
+	overlaps atomic.Int32
+	runs     atomic.Int32
+}
+
+func (c *concurrencyCollector) Collect() ([]collector.Metrics, error) {
+	if c.self.Add(1) > 1 {
+		c.overlaps.Add(1)
+	}
+	defer c.self.Add(-1)
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	c.runs.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}, nil
+}
+
+func TestPoolScheduler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	queue := newMetricsQueue(1000, "drop_new")
+	scheduler := newCollectorScheduler(ctx, &wg, queue, "pool", 2)
+	collectors := make([]*concurrencyCollector, 4)
+	for i := range collectors {
+		collectors[i] = &concurrencyCollector{running: &running, peak: &peak}
+		scheduler.add(fmt.Sprintf("Test%d", i), collectors[i], 10*time.Millisecond)
+	}
+	scheduler.start()
+	wg.Wait()
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("pool ran %d collections at the same time, want at most 2 workers", got)
+	}
+	for i, c := range collectors {
+		if c.runs.Load() == 0 {
+			t.Errorf("collector %d never ran", i)
+		}
+		if c.overlaps.Load() > 0 {
+			t.Errorf("collector %d ran concurrently with itself", i)
+		}
+	}
+}
+
+func TestCollectorSchedulerUpdates(t *testing.T) {
+	for _, mode := range []string{"goroutine", "pool"} {
+		t.Run(mode, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var running, peak atomic.Int32
+			var wg sync.WaitGroup
+			queue := newMetricsQueue(1000, "drop_new")
+			scheduler := newCollectorScheduler(ctx, &wg, queue, mode, 2)
+			slow := &concurrencyCollector{running: &running, peak: &peak}
+			removed := &concurrencyCollector{running: &running, peak: &peak}
+			scheduler.add("Slow", slow, time.Hour)
+			scheduler.add("Removed", removed, 10*time.Millisecond)
+			scheduler.start()
+
+			scheduler.setInterval("Slow", 10*time.Millisecond)
+			scheduler.remove("Removed")
+			added := &concurrencyCollector{running: &running, peak: &peak}
+			scheduler.add("Added", added, 10*time.Millisecond)
+			removedRuns := removed.runs.Load()
+
+			time.Sleep(150 * time.Millisecond)
+			cancel()
+			wg.Wait()
+
+			if slow.runs.Load() == 0 {
+				t.Error("collector never ran after its interval was shortened")
+			}
+			if added.runs.Load() == 0 {
+				t.Error("collector added after start never ran")
+			}
+			// The removed collector may finish the run it had started
+			if got := removed.runs.Load(); got > removedRuns+1 {
+				t.Errorf("removed collector ran %d more times", got-removedRuns)
+			}
+		})
+	}
+}
+
+func TestMetricsQueuePush(t *testing.T) {
+	batch := func(v float64) []collector.Metrics {
+		return []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: v}}
+	}
+
+	tests := []struct {
+		name        string
+		onFull      string
+		wantQueued  bool
+		wantOK      bool
+		wantValues  []float64
+		wantDropped uint64
+	}{
+		{name: "block waits until canceled", onFull: "block", wantQueued: false, wantOK: false, wantValues: []float64{1, 2}},
+		{name: "drop_oldest replaces the oldest batch", onFull: "drop_oldest", wantQueued: true, wantOK: true, wantValues: []float64{2, 3}, wantDropped: 1},
+		{name: "drop_new discards the new batch", onFull: "drop_new", wantQueued: false, wantOK: true, wantValues: []float64{1, 2}, wantDropped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newMetricsQueue(2, tt.onFull)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			for _, v := range []float64{1, 2} {
+				if queued, ok := queue.push(ctx, batch(v)); !queued || !ok {
+					t.Fatalf("push(%v) = %v, %v on a queue with room", v, queued, ok)
+				}
+			}
+			queued, ok := queue.push(ctx, batch(3))
+			if queued != tt.wantQueued || ok != tt.wantOK {
+				t.Errorf("push() on a full queue = %v, %v, want %v, %v", queued, ok, tt.wantQueued, tt.wantOK)
+			}
+
+			var values []float64
+			for len(queue.ch) > 0 {
+				values = append(values, (<-queue.ch)[0].Value.(float64))
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("queued values = %v, want %v", values, tt.wantValues)
+			}
+			if got := queue.dropped.Load(); got != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestSendRoutineReportsDroppedMetrics(t *testing.T) {
+	queue := newMetricsQueue(1, "drop_new")
+	queue.push(context.Background(), []collector.Metrics{{Name: collector.NameCPU, Value: 1.0}})
+	queue.push(context.Background(), []collector.Metrics{{Name: collector.NameCPU, Value: 2.0}, {Name: collector.NameCPU, Value: 3.0}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 80*time.Millisecond)
+	defer cancel()
+	mockSender := &MockSender{}
+	sendRoutine(ctx, mockSender, queue.ch, 50*time.Millisecond, sendOptions{queue: queue})
+
+	var dropped *collector.Metrics
+	for _, batch := range mockSender.sentMetrics {
+		for i := range batch {
+			if batch[i].Name == collector.NameMetricsDropped {
+				dropped = &batch[i]
+			}
+		}
+	}
+	if dropped == nil {
+		t.Fatal("sendRoutine() sent no metrics_dropped metric")
+	}
+	if dropped.Value != uint64(2) || dropped.Metadata["policy"] != "drop_new" {
+		t.Errorf("metrics_dropped = %v %v, want 2 with policy drop_new", dropped.Value, dropped.Metadata)
+	}
+}
+
+func TestSendRoutineRecordsSendStats(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		sender     *MockSender
+		wantFailed uint64
+	}{
+		{name: "successful send", sender: &MockSender{}},
+		{name: "failed send", sender: &MockSender{err: fmt.Errorf("send failed")}, wantFailed: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			metricsChan := make(chan []collector.Metrics, 1)
+			metricsChan <- []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 80*time.Millisecond)
+			defer cancel()
+			stats := &probecollector.SendStats{}
+			sendRoutine(ctx, tt.sender, metricsChan, 50*time.Millisecond, sendOptions{stats: stats})
+
+			metrics, err := probecollector.NewSelfCollector(stats).Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			value := metrics[0].Value.(map[string]interface{})
+			if value["sends_succeeded"] != 1-tt.wantFailed || value["sends_failed"] != tt.wantFailed {
+				t.Errorf("send stats = %v succeeded, %v failed, want %d failed of 1", value["sends_succeeded"], value["sends_failed"], tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestWaitStartupJitter(t *testing.T) {
+	if !waitStartupJitter(context.Background(), 0) {
+		t.Error("waitStartupJitter() without jitter = false, want true")
+	}
+
+	start := time.Now()
+	if !waitStartupJitter(context.Background(), 20*time.Millisecond) {
+		t.Error("waitStartupJitter() = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("waitStartupJitter() took %v, want less than the max jitter plus slack", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	if waitStartupJitter(ctx, time.Hour) {
+		t.Error("waitStartupJitter() with canceled context = true, want false")
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("waitStartupJitter() with canceled context took %v, want immediate return", elapsed)
+	}
+}
+
+func TestCheckWritablePaths(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// A regular file can't be used as a directory, even by root
+	blocker := filepath.Join(tempDir, "blocker")
+	if err := os.WriteFile(blocker, []byte{}, 0644); err != nil {
+		t.Fatalf("Failed to create blocker file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		logging     string
+		logFile     string
+		wantErr     bool
+		errContains []string
+	}{
+		{
+			name:    "all paths writable",
+			target:  "log_file",
+			logging: filepath.Join(tempDir, "logs", "app.log"),
+			logFile: filepath.Join(tempDir, "logs", "metrics.log"),
+		},
+		{
+			name:        "unwritable log file ignored for api target",
+			target:      "api",
+			logging:     filepath.Join(tempDir, "app.log"),
+			logFile:     filepath.Join(blocker, "metrics.log"),
+			wantErr:     false,
+			errContains: nil,
+		},
+		{
+			name:        "every unwritable path is listed",
+			target:      "log_file",
+			logging:     filepath.Join(blocker, "app.log"),
+			logFile:     filepath.Join(blocker, "metrics.log"),
+			wantErr:     true,
+			errContains: []string{"app.log", "metrics.log"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Sender.Target = tt.target
+			cfg.Logging.FilePath = tt.logging
+			cfg.LogFile.Path = tt.logFile
+
+			err := checkWritablePaths(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkWritablePaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.errContains {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("checkWritablePaths() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRuntimeSetup(t *testing.T) {
+	tempDir := t.TempDir()
+	malformedPEM := filepath.Join(tempDir, "malformed.pem")
+	if err := os.WriteFile(malformedPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Failed to write PEM file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		setup       func(cfg *config.Config)
+		errContains string
+	}{
+		{name: "log file target", setup: func(cfg *config.Config) {}},
+		{name: "malformed API CA file", setup: func(cfg *config.Config) {
+			cfg.Sender.Target = "api"
+			cfg.API.TLS.CAFile = malformedPEM
+		}, errContains: "failed to set up TLS for API requests"},
+		{name: "invalid API proxy", setup: func(cfg *config.Config) {
+			cfg.Sender.Target = "api"
+			cfg.API.Proxy = "http://proxy\x7f"
+		}, errContains: "failed to set API proxy"},
+		{name: "malformed MQTT client certificate", setup: func(cfg *config.Config) {
+			cfg.Sender.Target = "mqtt"
+			cfg.MQTT.Host, cfg.MQTT.Port, cfg.MQTT.Topic = "broker", 8883, "metrics"
+			cfg.MQTT.TLS.Enabled = true
+			cfg.MQTT.TLS.ClientCertFile, cfg.MQTT.TLS.ClientKeyFile = malformedPEM, malformedPEM
+		}, errContains: "failed to set up TLS for MQTT"},
+		{name: "invalid OTLP endpoint", setup: func(cfg *config.Config) {
+			cfg.Sender.Target = "otlp"
+			cfg.OTLP.Endpoint, cfg.OTLP.Protocol = "collector:4318", "http"
+		}, errContains: "failed to set up OTLP export"},
+		{name: "invalid syslog facility", setup: func(cfg *config.Config) {
+			cfg.Sender.Target = "syslog"
+			cfg.Syslog.Facility = "nowhere"
+		}, errContains: "failed to set up syslog output"},
+		{name: "unknown runtime user", setup: func(cfg *config.Config) {
+			cfg.Runtime.User = "no-such-user-for-monitorly-tests"
+		}, errContains: "cannot run as user no-such-user-for-monitorly-tests"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Sender.Target = "log_file"
+			cfg.Logging.FilePath = filepath.Join(tempDir, "app.log")
+			cfg.LogFile.Path = filepath.Join(tempDir, "metrics.log")
+			tt.setup(cfg)
+
+			err := checkRuntimeSetup(cfg)
+			if tt.errContains == "" && err != nil {
+				t.Errorf("checkRuntimeSetup() error = %v, want nil", err)
+			}
+			if tt.errContains != "" && (err == nil || !strings.Contains(err.Error(), tt.errContains)) {
+				t.Errorf("checkRuntimeSetup() error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
 func TestWatchConfigFile(t *testing.T) {
 	// Create a temporary directory for test files
 	tempDir := t.TempDir()
@@ -1023,6 +1868,99 @@ log_file:
 	}
 }
 
+func TestWatchConfigFileDebounce(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("machine_name: \"test-machine\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create initial config file: %v", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(tempDir); err != nil {
+		t.Fatalf("Failed to add directory to watcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restartChan := make(chan struct{}, 1)
+	go watchConfigFile(ctx, watcher, configPath, restartChan)
+	time.Sleep(100 * time.Millisecond)
+
+	// A burst of writes, as an editor truncating then writing the file, well within the debounce delay
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(configPath, []byte(fmt.Sprintf("machine_name: \"test-machine-%d\"\n", i)), 0644); err != nil {
+			t.Fatalf("Failed to modify config file: %v", err)
+		}
+		time.Sleep(configDebounce / 10)
+	}
+
+	restarts := 0
+	timeout := time.After(3 * configDebounce)
+	for done := false; !done; {
+		select {
+		case <-restartChan:
+			restarts++
+		case <-timeout:
+			done = true
+		}
+	}
+	if restarts != 1 {
+		t.Errorf("watchConfigFile() signaled %d restarts for a burst of writes, want 1", restarts)
+	}
+}
+
+func TestWatchConfigFileRenameSave(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("machine_name: \"test-machine\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create initial config file: %v", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(tempDir); err != nil {
+		t.Fatalf("Failed to add directory to watcher: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restartChan := make(chan struct{}, 1)
+	go watchConfigFile(ctx, watcher, configPath, restartChan)
+	time.Sleep(100 * time.Millisecond)
+
+	waitRestart := func(what string) {
+		t.Helper()
+		select {
+		case <-restartChan:
+		case <-time.After(4 * configDebounce):
+			t.Fatalf("No restart signaled after %s", what)
+		}
+	}
+
+	// Save as editors and deployment tools do: write a temp file and rename it over the config
+	tempPath := filepath.Join(tempDir, ".config.yaml.tmp")
+	if err := os.WriteFile(tempPath, []byte("machine_name: \"renamed\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write temp config file: %v", err)
+	}
+	if err := os.Rename(tempPath, configPath); err != nil {
+		t.Fatalf("Failed to rename temp config file: %v", err)
+	}
+	waitRestart("a rename save")
+
+	// Edits of the new file are still detected
+	if err := os.WriteFile(configPath, []byte("machine_name: \"edited\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify config file: %v", err)
+	}
+	waitRestart("editing the renamed file")
+}
+
 func TestRunApp(t *testing.T) {
 	// Create a temporary directory for test files
 	tempDir := t.TempDir()
@@ -1098,6 +2036,77 @@ func TestRunApp(t *testing.T) {
 	}
 }
 
+func TestRunningAppReload(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	newConfig := func() *config.Config {
+		cfg := &config.Config{MachineName: "test-machine"}
+		cfg.Sender.Target = "log_file"
+		cfg.Sender.SendInterval = time.Hour
+		cfg.LogFile.Path = filepath.Join(tempDir, "test.log")
+		cfg.Logging.FilePath = filepath.Join(tempDir, "app.log")
+		cfg.Collection.CPU.Enabled = true
+		cfg.Collection.CPU.Interval = time.Hour
+		cfg.Collection.CPU.TTL = 2 * time.Hour
+		return cfg
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app := runApp(ctx, newConfig(), configPath, make(chan struct{}, 1))
+	defer func() {
+		cancel()
+		app.Wait()
+	}()
+
+	changed := newConfig()
+	changed.Sender.SendInterval = time.Minute
+	changed.Collection.CPU.Interval = time.Minute
+	changed.Collection.RAM.Enabled = true
+	changed.Collection.RAM.Interval = time.Minute
+	if !app.reload(changed) {
+		t.Fatal("reload() = false for interval and enabled changes, want true")
+	}
+	if got := app.sendIntervals; len(got) != 1 || <-got != time.Minute {
+		t.Error("reload() did not pass the new send interval to the sender")
+	}
+	if _, ok := app.collectors["RAM"]; !ok {
+		t.Error("reload() did not start the enabled RAM collector")
+	}
+
+	structural := newConfig()
+	structural.Labels = map[string]string{"env": "prod"}
+	if app.reload(structural) {
+		t.Error("reload() = true for changed labels, want false")
+	}
+}
+
+func TestSendRoutineIntervalChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mockSender := &MockSender{}
+	metricsChan := make(chan []collector.Metrics, 1)
+	metricsChan <- []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+	intervals := make(chan time.Duration, 1)
+	intervals <- 20 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendRoutine(ctx, mockSender, metricsChan, time.Hour, sendOptions{intervals: intervals})
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	mockSender.mu.Lock()
+	sent := len(mockSender.sentMetrics)
+	mockSender.mu.Unlock()
+	cancel()
+	<-done
+
+	if sent != 1 {
+		t.Errorf("sendRoutine() sent %d batches before shutdown, want 1 with the shortened interval", sent)
+	}
+}
+
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || (len(s) > len(substr) &&
@@ -1148,3 +2157,103 @@ func (m *MockSender) Send(metrics []collector.Metrics) error {
 func (m *MockSender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
 	return m.Send(metrics)
 }
+
+func TestSendRoutineWithSpool(t *testing.T) {
+	spool := sender.NewSpool(t.TempDir(), 0)
+	metricsChan := make(chan []collector.Metrics, 10)
+	metricsChan <- []collector.Metrics{
+		{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 10.0},
+	}
+
+	// API unreachable: the batch ends up in the spool
+	failing := &MockSender{err: &sender.RetryableError{Err: fmt.Errorf("failed to send request")}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	sendRoutine(ctx, failing, metricsChan, 50*time.Millisecond, sendOptions{spool: spool})
+	cancel()
+
+	if spool.Len() != 1 {
+		t.Fatalf("spool has %d batches, want 1", spool.Len())
+	}
+
+	// API reachable again: the spooled batch is replayed
+	working := &MockSender{}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	sendRoutine(ctx, working, metricsChan, 50*time.Millisecond, sendOptions{spool: spool})
+	cancel()
+
+	if spool.Len() != 0 {
+		t.Errorf("spool has %d batches after replay, want 0", spool.Len())
+	}
+	if len(working.sentMetrics) != 1 {
+		t.Errorf("sender received %d batches, want 1 replayed batch", len(working.sentMetrics))
+	}
+}
+
+// blockingSender waits for its context to be done, like a send to an unresponsive API
+type blockingSender struct {
+	ctxErrAtStart error
+}
+
+func (b *blockingSender) Send(metrics []collector.Metrics) error {
+	return b.SendWithContext(context.Background(), metrics)
+}
+
+func (b *blockingSender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
+	b.ctxErrAtStart = ctx.Err()
+	<-ctx.Done()
+	return &sender.RetryableError{Err: fmt.Errorf("failed to send request: %w", ctx.Err())}
+}
+
+func TestSendRoutineShutdownFlush(t *testing.T) {
+	metrics := []collector.Metrics{
+		{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 10.0},
+	}
+
+	t.Run("final send uses a fresh context", func(t *testing.T) {
+		metricsChan := make(chan []collector.Metrics, 10)
+		metricsChan <- metrics
+		mockSender := &MockSender{}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		sendRoutine(ctx, mockSender, metricsChan, time.Hour, sendOptions{shutdownTimeout: time.Second})
+
+		if len(mockSender.sentMetrics) != 1 {
+			t.Errorf("sendRoutine() sent %d batches on shutdown, want 1", len(mockSender.sentMetrics))
+		}
+	})
+
+	t.Run("final send is bounded by the shutdown timeout", func(t *testing.T) {
+		metricsChan := make(chan []collector.Metrics, 10)
+		metricsChan <- metrics
+		blocking := &blockingSender{}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		sendRoutine(ctx, blocking, metricsChan, time.Hour, sendOptions{shutdownTimeout: 100 * time.Millisecond})
+
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("sendRoutine() took %v to shut down, want it bounded by the shutdown timeout", elapsed)
+		}
+		if blocking.ctxErrAtStart != nil {
+			t.Errorf("final send started with a done context: %v", blocking.ctxErrAtStart)
+		}
+	})
+
+	t.Run("timed out metrics are spooled", func(t *testing.T) {
+		spool := sender.NewSpool(t.TempDir(), 0)
+		metricsChan := make(chan []collector.Metrics, 10)
+		metricsChan <- metrics
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		sendRoutine(ctx, &blockingSender{}, metricsChan, time.Hour, sendOptions{spool: spool, shutdownTimeout: 50 * time.Millisecond})
+
+		working := &MockSender{}
+		sent, err := spool.Flush(context.Background(), working.SendWithContext)
+		if err != nil || sent != 1 {
+			t.Errorf("spool.Flush() = %d, %v, want the final metrics spooled", sent, err)
+		}
+	})
+}
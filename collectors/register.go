@@ -0,0 +1,95 @@
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory creates a registered collector from the probe configuration. Its settings are in
+// cfg.Collection.Custom under the registered name. Returning nil leaves the collector out
+type Factory func(cfg *Config) Collector
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// builtinNames are the names of the collectors created by NewRegistry from their own config section
+var builtinNames = map[string]bool{
+	"CPU": true, "RAM": true, "Disk": true, "Service": true, "UserActivity": true, "LoginFailures": true,
+	"Port": true, "PortCheck": true, "Freshness": true, "TCPStates": true, "Process": true, "DB": true,
+	"HTTPCheck": true, "Inotify": true, "HugePages": true, "DirQueue": true, "ServiceRestarts": true,
+	"SSHSessions": true, "PIDFile": true, "ShutdownState": true, "CoreDumps": true, "Suspend": true,
+	"JournalLag": true, "SystemdJobs": true, "FsckStatus": true, "Uptime": true, "Temperature": true,
+	"Probe": true,
+}
+
+// Register makes a collector available under name, typically from the init function of the
+// package providing it. NewRegistry creates it when collection.custom.<name>.enabled is set.
+// Register panics if name is empty, already registered or used by a built-in collector, or if
+// factory is nil
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if name == "" {
+		panic("collectors: Register called with an empty name")
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("collectors: Register factory for %s is nil", name))
+	}
+	if builtinNames[name] {
+		panic(fmt.Sprintf("collectors: Register called with the built-in collector name %s", name))
+	}
+	if _, dup := factories[name]; dup {
+		panic(fmt.Sprintf("collectors: Register called twice for %s", name))
+	}
+	factories[name] = factory
+}
+
+// Registered returns the names of the registered collectors, sorted
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// factory returns the factory registered under name, nil if there is none
+func factory(name string) Factory {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	return factories[name]
+}
+
+// addCustom adds the registered collectors enabled in cfg, sorted by name
+func (r *Registry) addCustom(cfg *Config) {
+	for _, name := range Registered() {
+		custom, ok := cfg.Collection.Custom[name]
+		if !ok || !custom.Enabled {
+			continue
+		}
+		if c := factory(name)(cfg); c != nil {
+			r.add(cfg, name, c, custom.Tags, custom.TTL, custom.Interval)
+		}
+	}
+}
+
+// Unregistered returns the names of the custom collectors enabled in cfg that no package
+// registered, sorted
+func Unregistered(cfg *Config) []string {
+	var names []string
+	for name, custom := range cfg.Collection.Custom {
+		if custom.Enabled && factory(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
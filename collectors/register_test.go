@@ -0,0 +1,100 @@
+package collectors
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestRegister(t *testing.T) {
+	var gotOptions map[string]string
+	Register("TestRedis", func(cfg *Config) Collector {
+		gotOptions = cfg.Collection.Custom["TestRedis"].Options
+		return &stubCollector{metrics: []Metrics{{Name: "redis", Value: 1.0}}}
+	})
+	Register("TestDisabled", func(cfg *Config) Collector {
+		t.Error("factory of a collector that isn't enabled was called")
+		return nil
+	})
+	Register("TestNil", func(cfg *Config) Collector { return nil })
+
+	cfg := &Config{Labels: map[string]string{"env": "prod"}}
+	cfg.Collection.CPU.Enabled = true
+	cfg.Collection.Custom = map[string]config.CustomCollector{
+		"TestRedis":   {Enabled: true, Interval: 10 * time.Second, Tags: map[string]string{"db": "cache"}, Options: map[string]string{"address": "127.0.0.1:6379"}},
+		"TestNil":     {Enabled: true, Interval: time.Minute},
+		"TestMissing": {Enabled: true, Interval: time.Minute},
+	}
+
+	r := NewRegistry(cfg)
+	if got, want := r.Names(), []string{"CPU", "TestRedis"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	if r.Interval("TestRedis") != 10*time.Second {
+		t.Errorf("Interval(\"TestRedis\") = %v, want 10s", r.Interval("TestRedis"))
+	}
+	if gotOptions["address"] != "127.0.0.1:6379" {
+		t.Errorf("factory got options %v, want the configured address", gotOptions)
+	}
+
+	metrics, err := r.Collector("TestRedis").Collect()
+	if err != nil || len(metrics) != 1 || metrics[0].Metadata["db"] != "cache" || metrics[0].Metadata["env"] != "prod" {
+		t.Errorf("Collect() = %+v, %v, want the metric with labels and tags", metrics, err)
+	}
+
+	if got := Unregistered(cfg); !reflect.DeepEqual(got, []string{"TestMissing"}) {
+		t.Errorf("Unregistered() = %v, want [TestMissing]", got)
+	}
+}
+
+func TestRegister_Panics(t *testing.T) {
+	Register("TestDuplicate", func(cfg *Config) Collector { return nil })
+
+	tests := []struct {
+		name      string
+		register  string
+		factory   Factory
+		wantPanic string
+	}{
+		{name: "empty name", register: "", factory: func(cfg *Config) Collector { return nil }, wantPanic: "empty name"},
+		{name: "nil factory", register: "TestNilFactory", factory: nil, wantPanic: "is nil"},
+		{name: "duplicate", register: "TestDuplicate", factory: func(cfg *Config) Collector { return nil }, wantPanic: "twice"},
+		{name: "built-in name", register: "CPU", factory: func(cfg *Config) Collector { return nil }, wantPanic: "built-in"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), tt.wantPanic) {
+					t.Errorf("Register() panic = %v, want one containing %q", r, tt.wantPanic)
+				}
+			}()
+			Register(tt.register, tt.factory)
+		})
+	}
+}
+
+func TestBuiltinNames(t *testing.T) {
+	// Every collector NewRegistry creates must be reserved against Register
+	cfg := &Config{}
+	collection := reflect.ValueOf(&cfg.Collection).Elem()
+	for i := 0; i < collection.NumField(); i++ {
+		if collection.Field(i).Kind() != reflect.Struct {
+			continue
+		}
+		if enabled := collection.Field(i).FieldByName("Enabled"); enabled.IsValid() {
+			enabled.SetBool(true)
+		}
+	}
+	cfg.Collection.Port.Targets = []config.PortTarget{{Address: "127.0.0.1:22"}}
+
+	for _, name := range NewRegistry(cfg).Names() {
+		if !builtinNames[name] {
+			t.Errorf("built-in collector %s is missing from builtinNames", name)
+		}
+	}
+}
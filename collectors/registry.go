@@ -0,0 +1,281 @@
+// Package collectors builds the probe's metric collectors from a configuration, so they can be
+// embedded in another program without running the probe daemon
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/collector/system"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// Config is the probe configuration, see config.yaml.example for the available settings
+type Config = config.Config
+
+// Collector collects a set of metrics
+type Collector = collector.Collector
+
+// Metrics is a single collected metric
+type Metrics = collector.Metrics
+
+// LoadConfig reads, applies defaults to and validates the configuration file at path
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
+
+// Registry holds the collectors enabled in a configuration, keyed by name
+type Registry struct {
+	names       []string // Collector names in registration order
+	unsupported []string // Names of the enabled collectors skipped as unsupported on this OS
+	collectors  map[string]Collector
+	intervals   map[string]time.Duration
+	ttls        map[string]time.Duration
+	workers     int // Collectors run at once by CollectAll, all of them when not positive
+}
+
+// NewRegistry creates the built-in and registered collectors enabled in cfg, each wrapped to add the global labels,
+// its tags and its TTL to the metadata of its metrics. The probe's own health collector is not
+// included since it reports on the daemon's sends
+func NewRegistry(cfg *Config) *Registry {
+	r := &Registry{
+		collectors: make(map[string]Collector),
+		intervals:  make(map[string]time.Duration),
+		ttls:       make(map[string]time.Duration),
+		workers:    cfg.Collection.Workers,
+	}
+	c := &cfg.Collection
+
+	if c.CPU.Enabled {
+		r.add(cfg, "CPU", system.NewCPUCollector(cfg.GetPrecision(c.CPU.Precision)), c.CPU.Tags, c.CPU.TTL, c.CPU.Interval)
+	}
+	if c.RAM.Enabled {
+		r.add(cfg, "RAM", system.NewRAMCollector(cfg.GetPrecision(c.RAM.Precision)), c.RAM.Tags, c.RAM.TTL, c.RAM.Interval)
+	}
+	if c.Disk.Enabled {
+		r.add(cfg, "Disk", system.NewDiskCollector(c.Disk.MountPoints, c.Disk.AutoDiscover, c.Disk.ExcludeFSTypes, c.Disk.ExcludePaths, cfg.GetPrecision(c.Disk.Precision)), c.Disk.Tags, c.Disk.TTL, c.Disk.Interval)
+	}
+	if c.Service.Enabled {
+		r.add(cfg, "Service", system.NewServiceCollector(c.Service.Services, c.Service.Accounting), c.Service.Tags, c.Service.TTL, c.Service.Interval)
+	}
+	if c.UserActivity.Enabled {
+		r.add(cfg, "UserActivity", system.NewUserActivityCollector(c.UserActivity.CountOnly), c.UserActivity.Tags, c.UserActivity.TTL, c.UserActivity.Interval)
+	}
+	if lf := c.LoginFailures; lf.Enabled {
+		r.add(cfg, "LoginFailures", system.NewLoginFailuresCollector(lf.SummarizeBySource, lf.Aggregate, lf.Services, lf.InitialLookback, lf.MaxLookback, lf.StateFile), lf.Tags, lf.TTL, lf.Interval)
+	}
+	if c.Port.Enabled {
+		r.add(cfg, "Port", system.NewPortCollector(c.Port.ExpectedOpen, c.Port.ExpectedClosed), c.Port.Tags, c.Port.TTL, c.Port.Interval)
+	}
+	if c.Port.Enabled && len(c.Port.Targets) > 0 {
+		r.add(cfg, "PortCheck", system.NewPortCheckCollector(c.Port.Targets), c.Port.Tags, c.Port.TTL, c.Port.Interval)
+	}
+	if c.Freshness.Enabled {
+		r.add(cfg, "Freshness", system.NewFreshnessCollector(c.Freshness.Files), c.Freshness.Tags, c.Freshness.TTL, c.Freshness.Interval)
+	}
+	if c.TCPStates.Enabled {
+		r.add(cfg, "TCPStates", system.NewTCPStatesCollector(c.TCPStates.ListenEstablishedOnly), c.TCPStates.Tags, c.TCPStates.TTL, c.TCPStates.Interval)
+	}
+	if c.Process.Enabled {
+		r.add(cfg, "Process", system.NewProcessCollector(c.Process.Match, c.Process.MaxProcesses, cfg.GetPrecision(c.Process.Precision)), c.Process.Tags, c.Process.TTL, c.Process.Interval)
+	}
+	if c.DB.Enabled {
+		r.add(cfg, "DB", system.NewDBCollector(c.DB.Databases, cfg.GetPrecision(c.DB.Precision)), c.DB.Tags, c.DB.TTL, c.DB.Interval)
+	}
+	if c.HTTPCheck.Enabled {
+		r.add(cfg, "HTTPCheck", system.NewHTTPCheckCollector(c.HTTPCheck.Endpoints), c.HTTPCheck.Tags, c.HTTPCheck.TTL, c.HTTPCheck.Interval)
+	}
+	if c.Inotify.Enabled {
+		r.add(cfg, "Inotify", system.NewInotifyCollector(), c.Inotify.Tags, c.Inotify.TTL, c.Inotify.Interval)
+	}
+	if c.HugePages.Enabled {
+		r.add(cfg, "HugePages", system.NewHugePagesCollector(), c.HugePages.Tags, c.HugePages.TTL, c.HugePages.Interval)
+	}
+	if c.DirQueue.Enabled {
+		r.add(cfg, "DirQueue", system.NewDirQueueCollector(c.DirQueue.Directories, c.DirQueue.WalkTimeout), c.DirQueue.Tags, c.DirQueue.TTL, c.DirQueue.Interval)
+	}
+	if c.ServiceRestarts.Enabled {
+		r.add(cfg, "ServiceRestarts", system.NewServiceRestartsCollector(c.ServiceRestarts.Units, c.ServiceRestarts.Threshold), c.ServiceRestarts.Tags, c.ServiceRestarts.TTL, c.ServiceRestarts.Interval)
+	}
+	if c.SSHSessions.Enabled {
+		r.add(cfg, "SSHSessions", system.NewSSHSessionsCollector(c.SSHSessions.Port, c.SSHSessions.TopSources), c.SSHSessions.Tags, c.SSHSessions.TTL, c.SSHSessions.Interval)
+	}
+	if c.PIDFile.Enabled {
+		r.add(cfg, "PIDFile", system.NewPIDFileCollector(c.PIDFile.Files), c.PIDFile.Tags, c.PIDFile.TTL, c.PIDFile.Interval)
+	}
+	if c.ShutdownState.Enabled {
+		r.add(cfg, "ShutdownState", system.NewShutdownStateCollector(), c.ShutdownState.Tags, c.ShutdownState.TTL, c.ShutdownState.Interval)
+	}
+	if c.CoreDumps.Enabled {
+		r.add(cfg, "CoreDumps", system.NewCoreDumpsCollector(c.CoreDumps.Dir), c.CoreDumps.Tags, c.CoreDumps.TTL, c.CoreDumps.Interval)
+	}
+	if c.SuspendDetect.Enabled {
+		r.add(cfg, "Suspend", system.NewSuspendCollector(c.SuspendDetect.Threshold), c.SuspendDetect.Tags, c.SuspendDetect.TTL, c.SuspendDetect.Interval)
+	}
+	if c.JournalLag.Enabled {
+		r.add(cfg, "JournalLag", system.NewJournalLagCollector(c.JournalLag.Cursors), c.JournalLag.Tags, c.JournalLag.TTL, c.JournalLag.Interval)
+	}
+	if c.SystemdJobs.Enabled {
+		r.add(cfg, "SystemdJobs", system.NewSystemdJobsCollector(), c.SystemdJobs.Tags, c.SystemdJobs.TTL, c.SystemdJobs.Interval)
+	}
+	if c.FsckStatus.Enabled {
+		r.add(cfg, "FsckStatus", system.NewFsckStatusCollector(c.FsckStatus.MountsMargin, c.FsckStatus.DueWithin), c.FsckStatus.Tags, c.FsckStatus.TTL, c.FsckStatus.Interval)
+	}
+	if c.Uptime.Enabled {
+		r.add(cfg, "Uptime", system.NewUptimeCollector(), c.Uptime.Tags, c.Uptime.TTL, c.Uptime.Interval)
+	}
+	if c.Temperature.Enabled {
+		r.add(cfg, "Temperature", system.NewTemperatureCollector(cfg.GetPrecision(c.Temperature.Precision)), c.Temperature.Tags, c.Temperature.TTL, c.Temperature.Interval)
+	}
+
+	// Collectors added with Register come last
+	r.addCustom(cfg)
+
+	return r
+}
+
+// add registers c under name with the labels of cfg and its own tags, TTL and interval
+// Collectors that can't run on the current operating system are left out, see Unsupported
+func (r *Registry) add(cfg *Config, name string, c Collector, tags map[string]string, ttl, interval time.Duration) {
+	if !collector.Supported(c) {
+		r.unsupported = append(r.unsupported, name)
+		return
+	}
+	r.names = append(r.names, name)
+	r.collectors[name] = collector.WithTTL(collector.WithTags(c, cfg.Labels, tags), ttl)
+	r.intervals[name] = interval
+	r.ttls[name] = ttl
+}
+
+// Names returns the names of the enabled collectors, in a stable order
+func (r *Registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Unsupported returns the names of the collectors enabled in the configuration but left out because
+// they can't run on the current operating system, in registration order
+func (r *Registry) Unsupported() []string {
+	return append([]string(nil), r.unsupported...)
+}
+
+// Collectors returns the enabled collectors keyed by name
+func (r *Registry) Collectors() map[string]Collector {
+	collectors := make(map[string]Collector, len(r.collectors))
+	for name, c := range r.collectors {
+		collectors[name] = c
+	}
+	return collectors
+}
+
+// Collector returns the collector registered under name, nil if it isn't enabled
+func (r *Registry) Collector(name string) Collector {
+	return r.collectors[name]
+}
+
+// Interval returns the configured collection interval of the collector registered under name
+func (r *Registry) Interval(name string) time.Duration {
+	return r.intervals[name]
+}
+
+// TTL returns the configured TTL of the collector registered under name
+func (r *Registry) TTL(name string) time.Duration {
+	return r.ttls[name]
+}
+
+// CollectAll runs every collector once and returns their metrics in the order of Names. Collectors
+// run concurrently, as many at once as the configured collection workers, and each has its interval
+// to return. Collectors that fail or run late are reported together in the error, along with the
+// metrics of the others. If ctx is done first, CollectAll returns ctx.Err() without waiting for the
+// collectors
+func (r *Registry) CollectAll(ctx context.Context) ([]Metrics, error) {
+	results := make([]collectResult, len(r.names))
+	workers := r.workers
+	if workers <= 0 || workers > len(r.names) {
+		workers = len(r.names)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = r.collect(ctx, r.names[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range r.names {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+	}
+
+	var all []Metrics
+	var errs []error
+	for _, res := range results {
+		all = append(all, res.metrics...)
+		if res.err != nil {
+			errs = append(errs, res.err)
+		}
+	}
+	return all, errors.Join(errs...)
+}
+
+// collectResult is the outcome of a collection run by CollectAll
+type collectResult struct {
+	metrics []Metrics
+	err     error
+}
+
+// collect runs the collector registered under name, giving up once its interval passed
+// A collector that can't be interrupted, see collector.ContextCollector, is left running in the
+// background so the worker can move on
+func (r *Registry) collect(ctx context.Context, name string) collectResult {
+	timeout := r.intervals[name]
+	collectCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		collectCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	results := make(chan collectResult, 1)
+	go func() {
+		metrics, err := collector.CollectWithContext(collectCtx, r.collectors[name])
+		results <- collectResult{metrics: metrics, err: err}
+	}()
+
+	var res collectResult
+	select {
+	case res = <-results:
+	case <-collectCtx.Done():
+		res.err = collectCtx.Err()
+	}
+	if res.err != nil && collectCtx.Err() != nil && ctx.Err() == nil {
+		return collectResult{err: fmt.Errorf("%s collector: no result within %v", name, timeout)}
+	}
+	if res.err != nil {
+		res.err = fmt.Errorf("%s collector: %w", name, res.err)
+	}
+	return res
+}
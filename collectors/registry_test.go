@@ -0,0 +1,221 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+type stubCollector struct {
+	metrics []Metrics
+	err     error
+	block   chan struct{}
+}
+
+func (c *stubCollector) Collect() ([]Metrics, error) {
+	if c.block != nil {
+		<-c.block
+	}
+	return c.metrics, c.err
+}
+
+func TestNewRegistry(t *testing.T) {
+	cfg := &Config{Labels: map[string]string{"env": "prod"}}
+	cfg.Collection.CPU.Enabled = true
+	cfg.Collection.CPU.Interval = 30 * time.Second
+	cfg.Collection.CPU.Tags = map[string]string{"team": "infra"}
+	cfg.Collection.RAM.Enabled = true
+	cfg.Collection.RAM.Interval = time.Minute
+	cfg.Collection.Port.Enabled = true
+	cfg.Collection.Port.Interval = 5 * time.Minute
+	cfg.Collection.Port.Targets = []config.PortTarget{{Address: "127.0.0.1:22"}}
+
+	r := NewRegistry(cfg)
+
+	wantNames := []string{"CPU", "RAM", "Port", "PortCheck"}
+	if got := r.Names(); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("Names() = %v, want %v", got, wantNames)
+	}
+	if got := len(r.Collectors()); got != len(wantNames) {
+		t.Errorf("Collectors() returned %d collectors, want %d", got, len(wantNames))
+	}
+	if r.Collector("Disk") != nil {
+		t.Error("Collector(\"Disk\") should be nil when disk collection is disabled")
+	}
+	if got := r.Interval("RAM"); got != time.Minute {
+		t.Errorf("Interval(\"RAM\") = %v, want 1m", got)
+	}
+
+	// Modifying the returned map must not change the registry
+	delete(r.Collectors(), "CPU")
+	if r.Collector("CPU") == nil {
+		t.Error("Collectors() returned the registry's own map")
+	}
+}
+
+func TestNewRegistry_Empty(t *testing.T) {
+	r := NewRegistry(&Config{})
+	if len(r.Names()) != 0 {
+		t.Errorf("Names() = %v, want none", r.Names())
+	}
+
+	metrics, err := r.CollectAll(context.Background())
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("CollectAll() = %v, %v, want no metrics and no error", metrics, err)
+	}
+}
+
+// platformCollector is a stubCollector only supported on some operating systems
+type platformCollector struct {
+	stubCollector
+	supported bool
+}
+
+func (c *platformCollector) Supported() bool {
+	return c.supported
+}
+
+func TestRegistry_Unsupported(t *testing.T) {
+	r := &Registry{collectors: map[string]Collector{}, intervals: map[string]time.Duration{}, ttls: map[string]time.Duration{}}
+	r.add(&Config{}, "CPU", &stubCollector{}, nil, 0, time.Second)
+	r.add(&Config{}, "Journal", &platformCollector{supported: false}, nil, 0, time.Second)
+	r.add(&Config{}, "Inotify", &platformCollector{supported: true}, nil, 0, time.Second)
+
+	if got, want := r.Names(), []string{"CPU", "Inotify"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	if got, want := r.Unsupported(), []string{"Journal"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unsupported() = %v, want %v", got, want)
+	}
+	if r.Collector("Journal") != nil {
+		t.Error("Collector(\"Journal\") should be nil for an unsupported collector")
+	}
+}
+
+func TestRegistry_CollectAll(t *testing.T) {
+	cfg := &Config{Labels: map[string]string{"env": "prod"}}
+	r := &Registry{collectors: map[string]Collector{}, intervals: map[string]time.Duration{}, ttls: map[string]time.Duration{}}
+	r.add(cfg, "CPU", &stubCollector{metrics: []Metrics{{Name: collector.NameCPU, Value: 12.5}}}, map[string]string{"team": "infra"}, 0, time.Second)
+	r.add(cfg, "Broken", &stubCollector{err: errors.New("no data")}, nil, 0, time.Second)
+	r.add(cfg, "RAM", &stubCollector{metrics: []Metrics{{Name: collector.NameRAM, Value: 40.0}}}, nil, 0, time.Second)
+
+	metrics, err := r.CollectAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Broken collector: no data") {
+		t.Errorf("CollectAll() error = %v, want the failing collector reported", err)
+	}
+	if len(metrics) != 2 || metrics[0].Name != collector.NameCPU || metrics[1].Name != collector.NameRAM {
+		t.Fatalf("CollectAll() = %+v, want the cpu and ram metrics in order", metrics)
+	}
+	if metrics[0].Metadata["env"] != "prod" || metrics[0].Metadata["team"] != "infra" {
+		t.Errorf("CollectAll() metadata = %v, want labels and tags applied", metrics[0].Metadata)
+	}
+}
+
+func TestRegistry_CollectAll_Canceled(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	r := &Registry{collectors: map[string]Collector{}, intervals: map[string]time.Duration{}, ttls: map[string]time.Duration{}}
+	r.add(&Config{}, "Slow", &stubCollector{block: block}, nil, 0, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.CollectAll(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CollectAll() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRegistry_CollectAll_SlowCollector(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	// A single worker must not be held up by the hung collector ahead of the others
+	r := &Registry{collectors: map[string]Collector{}, intervals: map[string]time.Duration{}, ttls: map[string]time.Duration{}, workers: 1}
+	r.add(&Config{}, "Slow", &stubCollector{block: block}, nil, 0, 50*time.Millisecond)
+	r.add(&Config{}, "Broken", &stubCollector{err: errors.New("no data")}, nil, 0, time.Second)
+	r.add(&Config{}, "CPU", &stubCollector{metrics: []Metrics{{Name: collector.NameCPU, Value: 12.5}}}, nil, 0, time.Second)
+
+	start := time.Now()
+	metrics, err := r.CollectAll(context.Background())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("CollectAll() took %v, want the slow collector given up after its interval", elapsed)
+	}
+	if len(metrics) != 1 || metrics[0].Name != collector.NameCPU {
+		t.Errorf("CollectAll() = %+v, want the cpu metric", metrics)
+	}
+	for _, want := range []string{"Slow collector: no result within 50ms", "Broken collector: no data"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CollectAll() error = %v, want %q", err, want)
+		}
+	}
+}
+
+// countingCollector records the highest number of its instances collecting at once
+type countingCollector struct {
+	running, peak *atomic.Int32
+}
+
+func (c *countingCollector) Collect() ([]Metrics, error) {
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return []Metrics{{Name: collector.NameCPU}}, nil
+}
+
+func TestRegistry_CollectAll_Workers(t *testing.T) {
+	var running, peak atomic.Int32
+	r := &Registry{collectors: map[string]Collector{}, intervals: map[string]time.Duration{}, ttls: map[string]time.Duration{}, workers: 2}
+	for i := 0; i < 6; i++ {
+		r.add(&Config{}, fmt.Sprintf("C%d", i), &countingCollector{running: &running, peak: &peak}, nil, 0, time.Second)
+	}
+
+	metrics, err := r.CollectAll(context.Background())
+	if err != nil || len(metrics) != 6 {
+		t.Fatalf("CollectAll() = %d metrics, %v, want 6 metrics", len(metrics), err)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("CollectAll() ran %d collectors at once, want at most 2 workers", got)
+	}
+}
+
+// sleepCollector takes d to collect
+type sleepCollector struct {
+	d time.Duration
+}
+
+func (c sleepCollector) Collect() ([]Metrics, error) {
+	time.Sleep(c.d)
+	return []Metrics{{Name: collector.NameCPU}}, nil
+}
+
+func BenchmarkRegistry_CollectAll(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			r := &Registry{collectors: map[string]Collector{}, intervals: map[string]time.Duration{}, ttls: map[string]time.Duration{}, workers: workers}
+			for i := 0; i < 16; i++ {
+				r.add(&Config{}, fmt.Sprintf("C%d", i), sleepCollector{d: time.Millisecond}, nil, 0, time.Second)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.CollectAll(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
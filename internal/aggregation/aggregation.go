@@ -0,0 +1,177 @@
+// Package aggregation reduces scalar metric samples to per-interval statistical summaries
+package aggregation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// accumulator holds the running statistics for a single metric series
+type accumulator struct {
+	category collector.MetricCategory
+	name     collector.MetricName
+	metadata collector.MetricMetadata
+	min      float64
+	max      float64
+	sum      float64
+	count    int
+}
+
+// Aggregator computes min/max/avg/count over the samples of selected scalar metrics
+// Accumulators are kept per metric series (name plus metadata) and reset on every flush
+type Aggregator struct {
+	names     map[collector.MetricName]bool
+	keepRaw   bool
+	precision int // Decimal places of the averages
+
+	mu           sync.Mutex
+	accumulators map[string]*accumulator
+	order        []string
+}
+
+// NewAggregator creates a new Aggregator for the given metric names
+// If keepRaw is true, raw samples are passed through alongside the summaries. Averages are rounded
+// to precision decimal places
+func NewAggregator(names []string, keepRaw bool, precision int) *Aggregator {
+	selected := make(map[collector.MetricName]bool, len(names))
+	for _, name := range names {
+		selected[collector.MetricName(name)] = true
+	}
+
+	return &Aggregator{
+		names:        selected,
+		keepRaw:      keepRaw,
+		precision:    precision,
+		accumulators: make(map[string]*accumulator),
+	}
+}
+
+// Add records the aggregatable samples and returns the metrics that should be sent as-is
+func (a *Aggregator) Add(metrics []collector.Metrics) []collector.Metrics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	passthrough := make([]collector.Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		value, ok := toFloat(m.Value)
+		if !ok || !a.names[m.Name] {
+			passthrough = append(passthrough, m)
+			continue
+		}
+
+		a.record(m, value)
+		if a.keepRaw {
+			passthrough = append(passthrough, m)
+		}
+	}
+
+	return passthrough
+}
+
+// Flush returns one summary metric per series seen since the last flush and resets the accumulators
+func (a *Aggregator) Flush(now time.Time) []collector.Metrics {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summaries := make([]collector.Metrics, 0, len(a.order))
+	for _, key := range a.order {
+		acc := a.accumulators[key]
+
+		metadata := collector.MetricMetadata{"aggregation": "summary"}
+		for k, v := range acc.metadata {
+			metadata[k] = v
+		}
+
+		summaries = append(summaries, collector.Metrics{
+			Timestamp: now,
+			Category:  acc.category,
+			Name:      acc.name,
+			Metadata:  metadata,
+			Value: map[string]interface{}{
+				"min":   acc.min,
+				"max":   acc.max,
+				"avg":   collector.RoundTo(acc.sum/float64(acc.count), a.precision),
+				"count": acc.count,
+			},
+		})
+	}
+
+	a.accumulators = make(map[string]*accumulator)
+	a.order = nil
+
+	return summaries
+}
+
+// record adds a sample to the accumulator of its series
+func (a *Aggregator) record(m collector.Metrics, value float64) {
+	key := seriesKey(m)
+
+	acc, ok := a.accumulators[key]
+	if !ok {
+		acc = &accumulator{
+			category: m.Category,
+			name:     m.Name,
+			metadata: m.Metadata,
+			min:      value,
+			max:      value,
+		}
+		a.accumulators[key] = acc
+		a.order = append(a.order, key)
+	}
+
+	if value < acc.min {
+		acc.min = value
+	}
+	if value > acc.max {
+		acc.max = value
+	}
+	acc.sum += value
+	acc.count++
+}
+
+// seriesKey builds a stable key from the metric name and its metadata
+func seriesKey(m collector.Metrics) string {
+	keys := make([]string, 0, len(m.Metadata))
+	for k := range m.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(string(m.Category))
+	b.WriteString("/")
+	b.WriteString(string(m.Name))
+	for _, k := range keys {
+		b.WriteString("|")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(m.Metadata[k])
+	}
+	return b.String()
+}
+
+// toFloat converts numeric scalar metric values to float64
+func toFloat(value collector.MetricValue) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,89 @@
+package aggregation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestAggregator(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		keepRaw         bool
+		wantPassthrough int
+	}{
+		{name: "summaries only", keepRaw: false, wantPassthrough: 1},
+		{name: "summaries alongside raw samples", keepRaw: true, wantPassthrough: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAggregator([]string{"cpu"}, tt.keepRaw, collector.DefaultPrecision)
+
+			passthrough := a.Add([]collector.Metrics{
+				{Timestamp: now, Category: collector.CategorySystem, Name: collector.NameCPU, Value: 10.0},
+				{Timestamp: now, Category: collector.CategorySystem, Name: collector.NameCPU, Value: 30.0},
+				{Timestamp: now, Category: collector.CategorySystem, Name: collector.NameRAM, Value: 50.0},
+			})
+			passthrough = append(passthrough, a.Add([]collector.Metrics{
+				{Timestamp: now, Category: collector.CategorySystem, Name: collector.NameCPU, Value: 20.0},
+			})...)
+
+			if len(passthrough) != tt.wantPassthrough {
+				t.Errorf("Add() passed through %d metrics, want %d", len(passthrough), tt.wantPassthrough)
+			}
+
+			summaries := a.Flush(now)
+			if len(summaries) != 1 {
+				t.Fatalf("Flush() returned %d summaries, want 1", len(summaries))
+			}
+
+			value := summaries[0].Value.(map[string]interface{})
+			if value["min"] != 10.0 || value["max"] != 30.0 || value["avg"] != 20.0 || value["count"] != 3 {
+				t.Errorf("Flush() summary = %v, want min=10 max=30 avg=20 count=3", value)
+			}
+			if summaries[0].Metadata["aggregation"] != "summary" {
+				t.Errorf("Flush() summary metadata = %v, want aggregation=summary", summaries[0].Metadata)
+			}
+
+			if again := a.Flush(now); len(again) != 0 {
+				t.Errorf("Flush() after reset returned %d summaries, want 0", len(again))
+			}
+		})
+	}
+}
+
+func TestAggregator_SeparatesSeriesByMetadata(t *testing.T) {
+	a := NewAggregator([]string{"disk"}, false, collector.DefaultPrecision)
+
+	a.Add([]collector.Metrics{
+		{Name: collector.NameDisk, Metadata: collector.MetricMetadata{"mountpoint": "/"}, Value: 10},
+		{Name: collector.NameDisk, Metadata: collector.MetricMetadata{"mountpoint": "/home"}, Value: 20},
+		{Name: collector.NameDisk, Metadata: collector.MetricMetadata{"mountpoint": "/"}, Value: 30},
+	})
+
+	summaries := a.Flush(time.Now())
+	if len(summaries) != 2 {
+		t.Fatalf("Flush() returned %d summaries, want 2", len(summaries))
+	}
+	if summaries[0].Metadata["mountpoint"] != "/" || summaries[0].Value.(map[string]interface{})["count"] != 2 {
+		t.Errorf("Flush() first summary = %+v, want mountpoint=/ count=2", summaries[0])
+	}
+}
+
+func TestAggregator_NonScalarPassthrough(t *testing.T) {
+	a := NewAggregator([]string{"disk"}, false, collector.DefaultPrecision)
+
+	passthrough := a.Add([]collector.Metrics{
+		{Name: collector.NameDisk, Value: map[string]interface{}{"percent": 10.0}},
+	})
+	if len(passthrough) != 1 {
+		t.Errorf("Add() passed through %d non-scalar metrics, want 1", len(passthrough))
+	}
+	if summaries := a.Flush(time.Now()); len(summaries) != 0 {
+		t.Errorf("Flush() returned %d summaries for non-scalar metrics, want 0", len(summaries))
+	}
+}
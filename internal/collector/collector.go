@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"math"
 	"time"
 )
@@ -14,6 +15,8 @@ type MetricName string
 const (
 	// CategorySystem is the category for system metrics
 	CategorySystem MetricCategory = "system"
+	// CategoryProbe is the category for metrics about the probe itself
+	CategoryProbe MetricCategory = "probe"
 
 	// NameCPU is the name for CPU metrics
 	NameCPU MetricName = "cpu"
@@ -29,6 +32,52 @@ const (
 	NameLoginFailures MetricName = "login_failures"
 	// NamePort is the name for port monitoring metrics
 	NamePort MetricName = "port"
+	// NameFreshness is the name for file freshness metrics
+	NameFreshness MetricName = "freshness"
+	// NameTCPStates is the name for TCP connection state metrics
+	NameTCPStates MetricName = "tcp_states"
+	// NameProcess is the name for per-process resource metrics
+	NameProcess MetricName = "process"
+	// NameDBConnections is the name for database connection count metrics
+	NameDBConnections MetricName = "db_connections"
+	// NameHTTPCheck is the name for HTTP endpoint health check metrics
+	NameHTTPCheck MetricName = "http_check"
+	// NameInotify is the name for inotify instance and watch usage metrics
+	NameInotify MetricName = "inotify"
+	// NameHugePages is the name for huge page usage metrics
+	NameHugePages MetricName = "hugepages"
+	// NameDirQueue is the name for directory backlog metrics
+	NameDirQueue MetricName = "dir_queue"
+	// NameServiceRestarts is the name for systemd unit restart count metrics
+	NameServiceRestarts MetricName = "service_restarts"
+	// NameSSHSessions is the name for SSH connections by source metrics
+	NameSSHSessions MetricName = "ssh_sessions"
+	// NamePIDFile is the name for PID file liveness metrics
+	NamePIDFile MetricName = "pidfile"
+	// NameShutdownState is the name for scheduled shutdown and reboot metrics
+	NameShutdownState MetricName = "shutdown_state"
+	// NameCoreDumps is the name for new core dump metrics
+	NameCoreDumps MetricName = "coredumps"
+	// NameSuspend is the name for host suspend detection metrics
+	NameSuspend MetricName = "suspend"
+	// NameJournalLag is the name for journal consumer lag metrics
+	NameJournalLag MetricName = "journal_lag"
+	// NameSystemdJobs is the name for systemd job queue metrics
+	NameSystemdJobs MetricName = "systemd_jobs"
+	// NameFsckStatus is the name for ext filesystem forced fsck metrics
+	NameFsckStatus MetricName = "fsck_status"
+	// NamePortCheck is the name for remote TCP port reachability metrics
+	NamePortCheck MetricName = "port_check"
+	// NameUptime is the name for host uptime metrics
+	NameUptime MetricName = "uptime"
+	// NameTemperature is the name for thermal sensor metrics
+	NameTemperature MetricName = "temperature"
+	// NamePortDrift is the name for expected open/closed port deviation metrics
+	NamePortDrift MetricName = "port_drift"
+	// NameMetricsDropped is the name for the probe's count of metrics dropped under backpressure
+	NameMetricsDropped MetricName = "metrics_dropped"
+	// NameProbeHealth is the name for the probe's own health metrics
+	NameProbeHealth MetricName = "probe_health"
 	// NameSystemInfo is the name for system information metrics
 	NameSystemInfo MetricName = "system_info"
 )
@@ -53,13 +102,57 @@ type Collector interface {
 	Collect() ([]Metrics, error)
 }
 
+// ContextCollector is implemented by collectors that can give up a collection when its context is
+// done, e.g. by killing the command they wait for
+type ContextCollector interface {
+	Collector
+	CollectWithContext(ctx context.Context) ([]Metrics, error)
+}
+
+// CollectWithContext collects the metrics of c, with ctx if c implements ContextCollector
+// Other collectors collect with Collect, which runs to completion whatever the state of ctx
+func CollectWithContext(ctx context.Context, c Collector) ([]Metrics, error) {
+	if cc, ok := c.(ContextCollector); ok {
+		return cc.CollectWithContext(ctx)
+	}
+	return c.Collect()
+}
+
+// PlatformCollector is implemented by collectors that only work on some operating systems
+type PlatformCollector interface {
+	Collector
+	// Supported reports whether the collector can run on the current operating system
+	Supported() bool
+}
+
+// Supported reports whether c can run on the current operating system
+// Collectors that don't implement PlatformCollector are supported everywhere
+func Supported(c Collector) bool {
+	if pc, ok := c.(PlatformCollector); ok {
+		return pc.Supported()
+	}
+	return true
+}
+
+// DefaultPrecision is the number of decimal places metric values are rounded to by default
+const DefaultPrecision = 2
+
 // RoundToTwoDecimalPlaces rounds a float64 to two decimal places
 func RoundToTwoDecimalPlaces(value float64) float64 {
+	return RoundTo(value, DefaultPrecision)
+}
+
+// RoundTo rounds a float64 to the given number of decimal places, 0 rounding to an integer
+func RoundTo(value float64, places int) float64 {
 	if math.IsNaN(value) || math.IsInf(value, 0) {
 		return value
 	}
+	if places < 0 {
+		places = 0
+	}
 	// Use math.Round to handle edge cases like 1.005 correctly
 	// We add a small epsilon to handle floating point precision issues
 	epsilon := 1e-10
-	return math.Round((value+epsilon)*100) / 100
+	scale := math.Pow(10, float64(places))
+	return math.Round((value+epsilon)*scale) / scale
 }
@@ -131,6 +131,35 @@ func TestRoundToTwoDecimalPlaces(t *testing.T) {
 	}
 }
 
+func TestRoundTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		places int
+		want   float64
+	}{
+		{name: "small rate lost with two places", value: 0.001, places: 2, want: 0},
+		{name: "small rate kept with three places", value: 0.001, places: 3, want: 0.001},
+		{name: "small rate rounded with four places", value: 0.00123456, places: 4, want: 0.0012},
+		{name: "edge case kept with three places", value: 1.0005, places: 3, want: 1.001},
+		{name: "integer", value: 75.5, places: 0, want: 76},
+		{name: "negative places round to an integer", value: 75.4, places: -1, want: 75},
+		{name: "negative value", value: -0.00456, places: 3, want: -0.005},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundTo(tt.value, tt.places); got != tt.want {
+				t.Errorf("RoundTo(%v, %d) = %v, want %v", tt.value, tt.places, got, tt.want)
+			}
+		})
+	}
+
+	if got := RoundTo(math.NaN(), 3); !math.IsNaN(got) {
+		t.Errorf("RoundTo(NaN, 3) = %v, want NaN", got)
+	}
+}
+
 func TestMetricConstants(t *testing.T) {
 	// Test that constants have expected values
 	tests := []struct {
@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ReservedMetadataKeys are metadata keys with a meaning shared by all metrics.
+// They are set by the probe itself (e.g. through global labels) and collectors shouldn't use them
+var ReservedMetadataKeys = []string{"host", "machine_name", "category", "unit", "status", "ttl"}
+
+// WarnFunc reports a metadata problem, typically logger.Printf
+type WarnFunc func(format string, v ...interface{})
+
+// metadataWarn is the WarnFunc used when metadata validation is enabled
+var metadataWarn atomic.Pointer[WarnFunc]
+
+// SetMetadataValidation enables metadata validation, reporting problems to warn.
+// Validation is a development aid and never drops or alters metrics. A nil warn disables it
+func SetMetadataValidation(warn WarnFunc) {
+	if warn == nil {
+		metadataWarn.Store(nil)
+		return
+	}
+	metadataWarn.Store(&warn)
+}
+
+// ValidateMetadata warns about metrics whose metadata sets a reserved key.
+// It does nothing unless validation was enabled with SetMetadataValidation
+func ValidateMetadata(metrics []Metrics) {
+	warn := metadataWarn.Load()
+	if warn == nil {
+		return
+	}
+	for _, m := range metrics {
+		for _, key := range ReservedMetadataKeys {
+			if _, ok := m.Metadata[key]; ok {
+				(*warn)("Debug: %s metric sets reserved metadata key %q", m.Name, key)
+			}
+		}
+	}
+}
+
+// MergeMetadata returns a copy of metadata with overrides applied on top of it. Keys already set
+// to a different value are reported as collisions when validation is enabled. metadata is never modified
+func MergeMetadata(metadata MetricMetadata, overrides map[string]string) MetricMetadata {
+	if len(overrides) == 0 {
+		return metadata
+	}
+
+	merged := make(MetricMetadata, len(metadata)+len(overrides))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+
+	warn := metadataWarn.Load()
+	for k, v := range overrides {
+		if existing, ok := merged[k]; ok && existing != v && warn != nil {
+			(*warn)("Debug: metadata key %q set to %q is overridden by %q", k, existing, v)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// taggedCollector adds global labels and collector tags to the metadata of a wrapped collector
+type taggedCollector struct {
+	Collector
+	overrides map[string]string
+}
+
+// WithTags wraps c so its metrics carry the global labels and the collector's own tags.
+// Tags take precedence over labels, which take precedence over metadata set by the collector.
+// The collector's own metadata is checked with ValidateMetadata before labels and tags are merged
+func WithTags(c Collector, labels, tags map[string]string) Collector {
+	overrides := make(map[string]string, len(labels)+len(tags))
+	for k, v := range labels {
+		overrides[k] = v
+	}
+	for k, v := range tags {
+		overrides[k] = v
+	}
+	return &taggedCollector{Collector: c, overrides: overrides}
+}
+
+// Collect gathers the metrics of the wrapped collector and merges the labels and tags into copies
+// of their metadata, so slices or maps reused by the collector are never modified
+func (c *taggedCollector) Collect() ([]Metrics, error) {
+	return c.tag(c.Collector.Collect())
+}
+
+// CollectWithContext is Collect passing ctx to the wrapped collector, see CollectWithContext
+func (c *taggedCollector) CollectWithContext(ctx context.Context) ([]Metrics, error) {
+	return c.tag(CollectWithContext(ctx, c.Collector))
+}
+
+// tag merges the labels and tags into copies of the metadata of metrics
+func (c *taggedCollector) tag(metrics []Metrics, err error) ([]Metrics, error) {
+	if len(metrics) == 0 {
+		return metrics, err
+	}
+	ValidateMetadata(metrics)
+	if len(c.overrides) == 0 {
+		return metrics, err
+	}
+
+	tagged := make([]Metrics, len(metrics))
+	for i, m := range metrics {
+		m.Metadata = MergeMetadata(m.Metadata, c.overrides)
+		tagged[i] = m
+	}
+	return tagged, err
+}
+
+// ttlCollector sets the ttl metadata key on the metrics of a wrapped collector
+type ttlCollector struct {
+	Collector
+	ttl atomic.Value // map[string]string holding the ttl key, replaced by SetTTL
+}
+
+// WithTTL wraps c so its metrics carry a "ttl" metadata key with ttl in whole seconds, telling the
+// backend how long a sample stays current. A zero or negative ttl leaves c unwrapped
+func WithTTL(c Collector, ttl time.Duration) Collector {
+	if ttl <= 0 {
+		return c
+	}
+	tc := &ttlCollector{Collector: c}
+	tc.ttl.Store(ttlMetadata(ttl))
+	return tc
+}
+
+// SetTTL changes the ttl of a collector returned by WithTTL while it may be collecting
+// It reports false if c doesn't set a ttl or ttl isn't positive, leaving c unchanged
+func SetTTL(c Collector, ttl time.Duration) bool {
+	tc, ok := c.(*ttlCollector)
+	if !ok || ttl <= 0 {
+		return false
+	}
+	tc.ttl.Store(ttlMetadata(ttl))
+	return true
+}
+
+// ttlMetadata returns the metadata setting the ttl key to ttl in whole seconds
+func ttlMetadata(ttl time.Duration) map[string]string {
+	seconds := int64(ttl.Round(time.Second) / time.Second)
+	return map[string]string{"ttl": strconv.FormatInt(seconds, 10)}
+}
+
+// Collect gathers the metrics of the wrapped collector and sets the ttl on copies of their metadata
+func (c *ttlCollector) Collect() ([]Metrics, error) {
+	return c.setTTL(c.Collector.Collect())
+}
+
+// CollectWithContext is Collect passing ctx to the wrapped collector, see CollectWithContext
+func (c *ttlCollector) CollectWithContext(ctx context.Context) ([]Metrics, error) {
+	return c.setTTL(CollectWithContext(ctx, c.Collector))
+}
+
+// setTTL sets the ttl on copies of the metadata of metrics
+func (c *ttlCollector) setTTL(metrics []Metrics, err error) ([]Metrics, error) {
+	if len(metrics) == 0 {
+		return metrics, err
+	}
+
+	ttl := c.ttl.Load().(map[string]string)
+	withTTL := make([]Metrics, len(metrics))
+	for i, m := range metrics {
+		m.Metadata = MergeMetadata(m.Metadata, ttl)
+		withTTL[i] = m
+	}
+	return withTTL, err
+}
@@ -0,0 +1,242 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordWarnings enables metadata validation for the duration of a test and returns the warnings logged
+func recordWarnings(t *testing.T) *[]string {
+	var warnings []string
+	SetMetadataValidation(func(format string, v ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, v...))
+	})
+	t.Cleanup(func() { SetMetadataValidation(nil) })
+	return &warnings
+}
+
+func TestValidateMetadata(t *testing.T) {
+	metrics := []Metrics{
+		{Name: NameDisk, Metadata: MetricMetadata{"path": "/", "label": "Root"}},
+		{Name: NameService, Metadata: MetricMetadata{"name": "nginx", "status": "up"}},
+	}
+
+	// Disabled by default
+	ValidateMetadata(metrics)
+
+	warnings := recordWarnings(t)
+	ValidateMetadata(metrics)
+	if len(*warnings) != 1 || !strings.Contains((*warnings)[0], `service metric sets reserved metadata key "status"`) {
+		t.Errorf("ValidateMetadata() warnings = %v, want one about status", *warnings)
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	warnings := recordWarnings(t)
+
+	metadata := MetricMetadata{"path": "/", "environment": "staging"}
+	overrides := map[string]string{"environment": "prod", "region": "eu-west"}
+
+	merged := MergeMetadata(metadata, overrides)
+	want := MetricMetadata{"path": "/", "environment": "prod", "region": "eu-west"}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("MergeMetadata() = %v, want %v", merged, want)
+	}
+	if metadata["environment"] != "staging" || len(metadata) != 2 {
+		t.Errorf("MergeMetadata() modified its input: %v", metadata)
+	}
+	if len(*warnings) != 1 || !strings.Contains((*warnings)[0], `"environment" set to "staging" is overridden by "prod"`) {
+		t.Errorf("MergeMetadata() warnings = %v, want one about environment", *warnings)
+	}
+
+	if got := MergeMetadata(nil, overrides); !reflect.DeepEqual(got, MetricMetadata(overrides)) {
+		t.Errorf("MergeMetadata(nil) = %v, want %v", got, overrides)
+	}
+	if got := MergeMetadata(metadata, nil); !reflect.DeepEqual(got, metadata) {
+		t.Errorf("MergeMetadata() without overrides = %v, want %v", got, metadata)
+	}
+}
+
+// staticCollector returns the same metrics on every collection
+type staticCollector struct {
+	metrics []Metrics
+}
+
+func (c *staticCollector) Collect() ([]Metrics, error) {
+	return c.metrics, nil
+}
+
+func TestWithTags(t *testing.T) {
+	labels := map[string]string{"environment": "prod", "region": "eu-west"}
+
+	tests := []struct {
+		name     string
+		metrics  []Metrics
+		labels   map[string]string
+		tags     map[string]string
+		wantMeta []MetricMetadata
+	}{
+		{
+			name:     "no labels or tags",
+			metrics:  []Metrics{{Name: NameCPU}},
+			wantMeta: []MetricMetadata{nil},
+		},
+		{
+			name:     "labels on metrics without metadata",
+			metrics:  []Metrics{{Name: NameCPU}},
+			labels:   labels,
+			wantMeta: []MetricMetadata{{"environment": "prod", "region": "eu-west"}},
+		},
+		{
+			name:     "labels and collector metadata",
+			metrics:  []Metrics{{Name: NameDisk, Metadata: MetricMetadata{"path": "/", "label": "Root"}}},
+			labels:   labels,
+			wantMeta: []MetricMetadata{{"path": "/", "label": "Root", "environment": "prod", "region": "eu-west"}},
+		},
+		{
+			name:     "tags over labels",
+			metrics:  []Metrics{{Name: NameService, Metadata: MetricMetadata{"name": "nginx"}}},
+			labels:   labels,
+			tags:     map[string]string{"team": "payments", "environment": "payments-prod"},
+			wantMeta: []MetricMetadata{{"name": "nginx", "team": "payments", "environment": "payments-prod", "region": "eu-west"}},
+		},
+		{
+			name:     "labels over collector metadata",
+			metrics:  []Metrics{{Name: NameDisk, Metadata: MetricMetadata{"path": "/", "region": "local"}}},
+			labels:   labels,
+			wantMeta: []MetricMetadata{{"path": "/", "environment": "prod", "region": "eu-west"}},
+		},
+		{
+			name:     "tags over labels over collector metadata",
+			metrics:  []Metrics{{Name: NameDisk, Metadata: MetricMetadata{"team": "infra", "region": "local"}}},
+			labels:   map[string]string{"team": "ops", "region": "eu-west"},
+			tags:     map[string]string{"team": "storage"},
+			wantMeta: []MetricMetadata{{"team": "storage", "region": "eu-west"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &staticCollector{metrics: tt.metrics}
+			original := make([]MetricMetadata, len(tt.metrics))
+			for i, m := range tt.metrics {
+				original[i] = MergeMetadata(nil, m.Metadata)
+			}
+
+			metrics, err := WithTags(inner, tt.labels, tt.tags).Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			for i, m := range metrics {
+				if !reflect.DeepEqual(m.Metadata, tt.wantMeta[i]) {
+					t.Errorf("metric %d metadata = %v, want %v", i, m.Metadata, tt.wantMeta[i])
+				}
+				if !reflect.DeepEqual(inner.metrics[i].Metadata, original[i]) {
+					t.Errorf("collector metadata was modified: %v, want %v", inner.metrics[i].Metadata, original[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithTagsValidatesCollectorMetadata(t *testing.T) {
+	warnings := recordWarnings(t)
+
+	inner := &staticCollector{metrics: []Metrics{{Name: NameCPU}}}
+	if _, err := WithTags(inner, map[string]string{"host": "web-1"}, nil).Collect(); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(*warnings) != 0 {
+		t.Errorf("labels using reserved keys were reported: %v", *warnings)
+	}
+
+	inner.metrics[0].Metadata = MetricMetadata{"host": "db-1"}
+	if _, err := WithTags(inner, nil, nil).Collect(); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(*warnings) != 1 || !strings.Contains((*warnings)[0], `reserved metadata key "host"`) {
+		t.Errorf("warnings = %v, want one about host", *warnings)
+	}
+}
+
+func TestWithTTL(t *testing.T) {
+	inner := &staticCollector{metrics: []Metrics{{Name: NameCPU, Metadata: MetricMetadata{"team": "payments"}}}}
+
+	metrics, err := WithTTL(inner, 6*time.Minute).Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	want := MetricMetadata{"team": "payments", "ttl": "360"}
+	if !reflect.DeepEqual(metrics[0].Metadata, want) {
+		t.Errorf("Collect() metadata = %v, want %v", metrics[0].Metadata, want)
+	}
+	if _, ok := inner.metrics[0].Metadata["ttl"]; ok {
+		t.Error("WithTTL() modified the wrapped collector's metadata")
+	}
+
+	if c := WithTTL(inner, 0); c != Collector(inner) {
+		t.Errorf("WithTTL() with a zero ttl = %T, want the collector unwrapped", c)
+	}
+}
+
+func TestSetTTL(t *testing.T) {
+	inner := &staticCollector{metrics: []Metrics{{Name: NameCPU}}}
+	c := WithTTL(inner, time.Minute)
+
+	if !SetTTL(c, 90*time.Second) {
+		t.Fatal("SetTTL() = false, want true for a collector returned by WithTTL")
+	}
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if got := metrics[0].Metadata["ttl"]; got != "90" {
+		t.Errorf("Collect() ttl = %q, want \"90\"", got)
+	}
+
+	if SetTTL(c, 0) {
+		t.Error("SetTTL() with a zero ttl = true, want false")
+	}
+	if SetTTL(inner, time.Minute) {
+		t.Error("SetTTL() of an unwrapped collector = true, want false")
+	}
+}
+
+// contextCollector records the context it collects with
+type contextCollector struct {
+	staticCollector
+	ctx context.Context
+}
+
+func (c *contextCollector) CollectWithContext(ctx context.Context) ([]Metrics, error) {
+	c.ctx = ctx
+	return c.Collect()
+}
+
+func TestCollectWithContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "collection")
+
+	inner := &contextCollector{staticCollector: staticCollector{metrics: []Metrics{{Name: NameCPU}}}}
+	wrapped := WithTTL(WithTags(inner, map[string]string{"env": "prod"}, nil), time.Minute)
+	metrics, err := CollectWithContext(ctx, wrapped)
+	if err != nil || len(metrics) != 1 {
+		t.Fatalf("CollectWithContext() = %v, %v, want the wrapped collector's metric", metrics, err)
+	}
+	if inner.ctx != ctx {
+		t.Error("CollectWithContext() didn't pass the context through the tags and ttl wrappers")
+	}
+	if metrics[0].Metadata["env"] != "prod" || metrics[0].Metadata["ttl"] != "60" {
+		t.Errorf("CollectWithContext() metadata = %v, want the tags and ttl applied", metrics[0].Metadata)
+	}
+
+	// Collectors without CollectWithContext fall back to Collect
+	plain := &staticCollector{metrics: []Metrics{{Name: NameRAM}}}
+	if metrics, err := CollectWithContext(ctx, plain); err != nil || len(metrics) != 1 || metrics[0].Name != NameRAM {
+		t.Errorf("CollectWithContext() of a plain collector = %v, %v, want its metric", metrics, err)
+	}
+}
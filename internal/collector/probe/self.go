@@ -0,0 +1,70 @@
+package probe
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// SendStats counts the sends made by the sender routine. It is safe for concurrent use
+type SendStats struct {
+	succeeded    atomic.Uint64
+	failed       atomic.Uint64
+	latencyTotal atomic.Int64 // Sum of send latencies in nanoseconds
+	latencyLast  atomic.Int64 // Latency of the last send in nanoseconds
+}
+
+// Record counts a send that took latency and failed with err, if not nil
+func (s *SendStats) Record(latency time.Duration, err error) {
+	if err != nil {
+		s.failed.Add(1)
+	} else {
+		s.succeeded.Add(1)
+	}
+	s.latencyTotal.Add(int64(latency))
+	s.latencyLast.Store(int64(latency))
+}
+
+// SelfCollector implements the collector.Collector interface for the probe's own health
+type SelfCollector struct {
+	Stats *SendStats // Send counters fed by the sender routine
+}
+
+// NewSelfCollector creates a new instance of SelfCollector reporting stats
+func NewSelfCollector(stats *SendStats) collector.Collector {
+	return &SelfCollector{
+		Stats: stats,
+	}
+}
+
+// Collect reports the goroutine count, heap usage and the sends made since the previous collection
+func (c *SelfCollector) Collect() ([]collector.Metrics, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	succeeded := c.Stats.succeeded.Swap(0)
+	failed := c.Stats.failed.Swap(0)
+	latencyTotal := time.Duration(c.Stats.latencyTotal.Swap(0))
+
+	value := map[string]interface{}{
+		"goroutines":       runtime.NumGoroutine(),
+		"heap_alloc_bytes": mem.HeapAlloc,
+		"sends_succeeded":  succeeded,
+		"sends_failed":     failed,
+	}
+	if sends := succeeded + failed; sends > 0 {
+		value["send_latency_ms"] = float64(latencyTotal.Microseconds()) / 1000 / float64(sends)
+		value["last_send_latency_ms"] = float64(time.Duration(c.Stats.latencyLast.Load()).Microseconds()) / 1000
+	}
+
+	return []collector.Metrics{
+		{
+			Timestamp: time.Now(),
+			Category:  collector.CategoryProbe,
+			Name:      collector.NameProbeHealth,
+			Value:     value,
+		},
+	}, nil
+}
@@ -0,0 +1,50 @@
+package probe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestSelfCollector_Collect(t *testing.T) {
+	stats := &SendStats{}
+	stats.Record(100*time.Millisecond, nil)
+	stats.Record(300*time.Millisecond, errors.New("connection refused"))
+	stats.Record(200*time.Millisecond, nil)
+
+	c := NewSelfCollector(stats)
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Collect() returned %d metrics, want 1", len(metrics))
+	}
+	m := metrics[0]
+	if m.Category != collector.CategoryProbe || m.Name != collector.NameProbeHealth {
+		t.Errorf("Collect() metric = %s/%s, want probe/probe_health", m.Category, m.Name)
+	}
+
+	value := m.Value.(map[string]interface{})
+	if value["sends_succeeded"] != uint64(2) || value["sends_failed"] != uint64(1) {
+		t.Errorf("Collect() sends = %v succeeded, %v failed, want 2 and 1", value["sends_succeeded"], value["sends_failed"])
+	}
+	if value["send_latency_ms"] != 200.0 || value["last_send_latency_ms"] != 200.0 {
+		t.Errorf("Collect() latency = %v avg, %v last, want 200 and 200", value["send_latency_ms"], value["last_send_latency_ms"])
+	}
+	if goroutines, ok := value["goroutines"].(int); !ok || goroutines < 1 {
+		t.Errorf("Collect() goroutines = %v, want at least 1", value["goroutines"])
+	}
+
+	// Counters are reset on each collection
+	metrics, _ = c.Collect()
+	value = metrics[0].Value.(map[string]interface{})
+	if value["sends_succeeded"] != uint64(0) || value["sends_failed"] != uint64(0) {
+		t.Errorf("second Collect() sends = %v, %v, want 0 and 0", value["sends_succeeded"], value["sends_failed"])
+	}
+	if _, ok := value["send_latency_ms"]; ok {
+		t.Error("second Collect() reported a send latency without sends")
+	}
+}
@@ -0,0 +1,211 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// systemdCoredumpDir is where systemd-coredump stores dumps, a variable to allow testing
+var systemdCoredumpDir = "/var/lib/systemd/coredump"
+
+// CoreDumpsCollector implements the collector.Collector interface for new core dumps
+type CoreDumpsCollector struct {
+	Dir string // Directory holding core dumps, found from kernel.core_pattern when empty
+
+	seen        map[string]bool // Dump files already present at the previous check
+	initialized bool            // Whether the existing dumps were recorded, they are not reported as new
+}
+
+// NewCoreDumpsCollector creates a new instance of CoreDumpsCollector
+func NewCoreDumpsCollector(dir string) collector.Collector {
+	return &CoreDumpsCollector{
+		Dir: dir,
+	}
+}
+
+// coreDumpLocation is where core dumps are written and how their names map to executables
+type coreDumpLocation struct {
+	dir        string
+	executable func(name string) string // Executable name from a dump file name, "" when unknown
+}
+
+// Supported reports whether the host runs Linux, whose kernel core pattern locates the dumps
+func (c *CoreDumpsCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect reports the core dumps written since the previous check and the executables that produced them
+// Dumps present when the probe starts are not reported. When cores are piped to a program other than
+// systemd-coredump (e.g. apport), they can't be counted and detectable is false
+func (c *CoreDumpsCollector) Collect() ([]collector.Metrics, error) {
+	location, err := c.location()
+	if err != nil {
+		return nil, err
+	}
+
+	value := map[string]interface{}{
+		"new_dumps":   0,
+		"executables": []string{},
+		"detectable":  location != nil,
+	}
+	if location != nil {
+		names, err := listCoreDumps(location.dir)
+		if err != nil {
+			return nil, err
+		}
+
+		var newDumps []string
+		current := make(map[string]bool, len(names))
+		for _, name := range names {
+			current[name] = true
+			if c.initialized && !c.seen[name] {
+				newDumps = append(newDumps, name)
+			}
+		}
+		// Dumps removed in the meantime are forgotten, so the set doesn't grow forever
+		c.seen = current
+		c.initialized = true
+
+		value["new_dumps"] = len(newDumps)
+		value["executables"] = dumpExecutables(newDumps, location.executable)
+	}
+
+	return []collector.Metrics{
+		{
+			Timestamp: time.Now(),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameCoreDumps,
+			Value:     value,
+		},
+	}, nil
+}
+
+// location returns where core dumps are written, or nil if they can't be found on disk
+func (c *CoreDumpsCollector) location() (*coreDumpLocation, error) {
+	if c.Dir != "" {
+		return &coreDumpLocation{dir: c.Dir, executable: systemdDumpExecutable}, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(procRoot, "sys/kernel/core_pattern"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read core_pattern: %w", err)
+	}
+	return coreDumpLocationFromPattern(strings.TrimSpace(string(data))), nil
+}
+
+// coreDumpLocationFromPattern interprets kernel.core_pattern. Cores piped to systemd-coredump are
+// found in its storage directory, cores piped to other programs or written relative to the crashing
+// process's working directory can't be found
+func coreDumpLocationFromPattern(pattern string) *coreDumpLocation {
+	if program, ok := strings.CutPrefix(pattern, "|"); ok {
+		fields := strings.Fields(program)
+		if len(fields) > 0 && filepath.Base(fields[0]) == "systemd-coredump" {
+			return &coreDumpLocation{dir: systemdCoredumpDir, executable: systemdDumpExecutable}
+		}
+		return nil
+	}
+
+	dir, base := filepath.Split(pattern)
+	if !filepath.IsAbs(pattern) || strings.Contains(dir, "%") {
+		return nil
+	}
+	re := corePatternRegexp(base)
+	return &coreDumpLocation{
+		dir: filepath.Clean(dir),
+		executable: func(name string) string {
+			if m := re.FindStringSubmatch(name); m != nil {
+				return m[1]
+			}
+			return ""
+		},
+	}
+}
+
+// corePatternRegexp turns the file name part of core_pattern into a regexp capturing the
+// executable name (%e) in its first group. The kernel appends .PID when core_uses_pid is set
+func corePatternRegexp(base string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	hasExecutable := false
+	for i := 0; i < len(base); i++ {
+		if base[i] != '%' || i+1 == len(base) {
+			b.WriteString(regexp.QuoteMeta(base[i : i+1]))
+			continue
+		}
+		i++
+		switch base[i] {
+		case '%':
+			b.WriteString("%")
+		case 'e':
+			if hasExecutable {
+				b.WriteString(".*?")
+			} else {
+				b.WriteString("(.+?)")
+				hasExecutable = true
+			}
+		default:
+			b.WriteString(".*?")
+		}
+	}
+	if !hasExecutable {
+		b.WriteString("()")
+	}
+	b.WriteString(`(?:\.\d+)?$`)
+	return regexp.MustCompile(b.String())
+}
+
+// systemdDumpExecutable returns the executable name from a systemd-coredump file name,
+// core.<executable>.<uid>.<boot id>.<pid>.<timestamp>[.compression]
+func systemdDumpExecutable(name string) string {
+	fields := strings.Split(name, ".")
+	if last := fields[len(fields)-1]; strings.Trim(last, "0123456789") != "" {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) < 6 || fields[0] != "core" {
+		return ""
+	}
+	// Executable names may contain dots, the four fields that follow them don't
+	return strings.Join(fields[1:len(fields)-4], ".")
+}
+
+// listCoreDumps returns the names of the regular files in dir, none if it doesn't exist yet
+func listCoreDumps(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read core dump directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// dumpExecutables returns the sorted, distinct executable names of dumps
+func dumpExecutables(dumps []string, executable func(string) string) []string {
+	set := make(map[string]bool)
+	for _, name := range dumps {
+		if exe := executable(name); exe != "" {
+			set[exe] = true
+		}
+	}
+	executables := make([]string, 0, len(set))
+	for exe := range set {
+		executables = append(executables, exe)
+	}
+	sort.Strings(executables)
+	return executables
+}
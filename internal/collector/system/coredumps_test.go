@@ -0,0 +1,111 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCoreDumpsCollector_Collect(t *testing.T) {
+	originalProcRoot, originalCoredumpDir := procRoot, systemdCoredumpDir
+	defer func() { procRoot, systemdCoredumpDir = originalProcRoot, originalCoredumpDir }()
+
+	procRoot = t.TempDir()
+	systemdCoredumpDir = filepath.Join(t.TempDir(), "coredump")
+	if err := os.MkdirAll(filepath.Join(procRoot, "sys/kernel"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writePattern := func(pattern string) {
+		if err := os.WriteFile(filepath.Join(procRoot, "sys/kernel/core_pattern"), []byte(pattern+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeDump := func(dir, name string) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("core"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collect := func(c *CoreDumpsCollector) map[string]interface{} {
+		t.Helper()
+		metrics, err := c.Collect()
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		return metrics[0].Value.(map[string]interface{})
+	}
+
+	t.Run("systemd-coredump", func(t *testing.T) {
+		writePattern("|/usr/lib/systemd/systemd-coredump %P %u %g %s %t %c %h")
+		writeDump(systemdCoredumpDir, "core.nginx.33.0a1b2c.1234.1700000000000000.zst")
+
+		c := &CoreDumpsCollector{}
+		// Dumps present at startup are not new
+		if got := collect(c); got["new_dumps"] != 0 || got["detectable"] != true {
+			t.Errorf("first Collect() = %v, want no new dumps", got)
+		}
+
+		writeDump(systemdCoredumpDir, "core.nginx.33.0a1b2c.1250.1700000100000000.zst")
+		writeDump(systemdCoredumpDir, "core.my.app.1000.0a1b2c.1300.1700000200000000.lz4")
+		got := collect(c)
+		want := map[string]interface{}{"new_dumps": 2, "executables": []string{"my.app", "nginx"}, "detectable": true}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Collect() = %v, want %v", got, want)
+		}
+
+		if got := collect(c); got["new_dumps"] != 0 {
+			t.Errorf("Collect() reported %v new dumps again", got["new_dumps"])
+		}
+	})
+
+	t.Run("core_pattern path", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "cores")
+		writePattern(filepath.Join(dir, "core-%e-%p-%t"))
+
+		c := &CoreDumpsCollector{}
+		collect(c)
+		writeDump(dir, "core-postgres-4242-1700000000")
+		got := collect(c)
+		want := map[string]interface{}{"new_dumps": 1, "executables": []string{"postgres"}, "detectable": true}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Collect() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("piped to another program", func(t *testing.T) {
+		writePattern("|/usr/share/apport/apport -p%p -s%s -c%c")
+
+		got := collect(&CoreDumpsCollector{})
+		want := map[string]interface{}{"new_dumps": 0, "executables": []string{}, "detectable": false}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Collect() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestCorePatternRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    string
+	}{
+		{pattern: "core.%e.%p", name: "core.redis-server.812", want: "redis-server"},
+		{pattern: "%e.core", name: "java.core.4242", want: "java"},
+		{pattern: "core", name: "core.4242", want: ""},
+		{pattern: "core.%e", name: "other", want: ""},
+	}
+
+	for _, tt := range tests {
+		m := corePatternRegexp(tt.pattern).FindStringSubmatch(tt.name)
+		got := ""
+		if m != nil {
+			got = m[1]
+		}
+		if got != tt.want {
+			t.Errorf("corePatternRegexp(%q) on %q = %q, want %q", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -8,11 +8,13 @@ import (
 )
 
 // CPUCollector implements the collector.Collector interface for CPU metrics
-type CPUCollector struct{}
+type CPUCollector struct {
+	Precision int // Decimal places of the reported usage
+}
 
 // NewCPUCollector creates a new instance of CPUCollector
-func NewCPUCollector() collector.Collector {
-	return &CPUCollector{}
+func NewCPUCollector(precision int) collector.Collector {
+	return &CPUCollector{Precision: precision}
 }
 
 // Collect gathers CPU metrics
@@ -27,7 +29,7 @@ func (c *CPUCollector) Collect() ([]collector.Metrics, error) {
 	}
 
 	if len(cpuPercent) > 0 {
-		value := collector.RoundToTwoDecimalPlaces(cpuPercent[0])
+		value := collector.RoundTo(cpuPercent[0], c.Precision)
 		metrics = append(metrics, collector.Metrics{
 			Timestamp: now,
 			Category:  collector.CategorySystem,
@@ -0,0 +1,191 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// DBCollector implements the collector.Collector interface for database connection metrics
+// It relies on the psql and mysql command line clients rather than embedding database drivers
+type DBCollector struct {
+	Databases []config.Database
+	Precision int // Decimal places of the reported connection usage
+}
+
+// NewDBCollector creates a new instance of DBCollector
+func NewDBCollector(databases []config.Database, precision int) collector.Collector {
+	return &DBCollector{
+		Databases: databases,
+		Precision: precision,
+	}
+}
+
+// Collect gathers current and maximum connection counts for each configured database
+// Connection or authentication failures are reported as unreachable instead of returning an error
+func (c *DBCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, len(c.Databases))
+	now := time.Now()
+
+	for _, db := range c.Databases {
+		value := map[string]interface{}{"reachable": false}
+
+		current, max, err := c.queryConnections(db)
+		if err == nil {
+			value = map[string]interface{}{
+				"reachable":           true,
+				"connections_current": current,
+				"connections_max":     max,
+			}
+			if max > 0 {
+				value["connections_percent"] = collector.RoundTo(float64(current)/float64(max)*100, c.Precision)
+			}
+		}
+
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameDBConnections,
+			Metadata: collector.MetricMetadata{
+				"type":  db.Type,
+				"label": db.Label,
+			},
+			Value: value,
+		})
+	}
+
+	return metrics, nil
+}
+
+// queryConnections returns the current and maximum number of connections for a database
+func (c *DBCollector) queryConnections(db config.Database) (int, int, error) {
+	switch db.Type {
+	case "postgres":
+		return c.queryPostgres(db)
+	case "mysql":
+		return c.queryMySQL(db)
+	default:
+		return 0, 0, fmt.Errorf("unsupported database type: %s", db.Type)
+	}
+}
+
+// queryPostgres queries pg_stat_activity through psql
+// Only client backends count against max_connections, background workers and the WAL writer are left out
+func (c *DBCollector) queryPostgres(db config.Database) (int, int, error) {
+	args := []string{"-X", "-A", "-t", "-c", "SELECT count(*), current_setting('max_connections') FROM pg_stat_activity WHERE backend_type = 'client backend'"}
+	if db.Socket != "" {
+		args = append(args, "-h", db.Socket)
+	} else if db.Host != "" {
+		args = append(args, "-h", db.Host)
+	}
+	if db.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(db.Port))
+	}
+	if db.User != "" {
+		args = append(args, "-U", db.User)
+	}
+	if db.Database != "" {
+		args = append(args, "-d", db.Database)
+	}
+
+	cmd := execCommand("psql", args...)
+	cmd.Env = append(os.Environ(), "PGCONNECT_TIMEOUT=5")
+	if db.Password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+db.Password)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query postgres: %w", err)
+	}
+
+	return parsePostgresConnections(string(output))
+}
+
+// queryMySQL queries the global status and variables through the mysql client
+func (c *DBCollector) queryMySQL(db config.Database) (int, int, error) {
+	args := []string{"-N", "-B", "--connect-timeout=5", "-e", "SHOW GLOBAL STATUS LIKE 'Threads_connected'; SHOW GLOBAL VARIABLES LIKE 'max_connections'"}
+	if db.Socket != "" {
+		args = append(args, "-S", db.Socket)
+	} else if db.Host != "" {
+		args = append(args, "-h", db.Host)
+	}
+	if db.Port != 0 {
+		args = append(args, "-P", strconv.Itoa(db.Port))
+	}
+	if db.User != "" {
+		args = append(args, "-u", db.User)
+	}
+	if db.Database != "" {
+		args = append(args, db.Database)
+	}
+
+	cmd := execCommand("mysql", args...)
+	cmd.Env = os.Environ()
+	if db.Password != "" {
+		cmd.Env = append(cmd.Env, "MYSQL_PWD="+db.Password)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query mysql: %w", err)
+	}
+
+	return parseMySQLConnections(string(output))
+}
+
+// parsePostgresConnections parses psql unaligned output in the form "current|max"
+func parsePostgresConnections(output string) (int, int, error) {
+	fields := strings.Split(strings.TrimSpace(output), "|")
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected psql output: %q", output)
+	}
+
+	current, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid connection count: %w", err)
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid max connections: %w", err)
+	}
+
+	return current, max, nil
+}
+
+// parseMySQLConnections parses mysql batch output of the form "Threads_connected\t5\nmax_connections\t151"
+func parseMySQLConnections(output string) (int, int, error) {
+	current, max := -1, -1
+	scanner := bufio.NewScanner(strings.NewReader(output))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case "threads_connected":
+			current = n
+		case "max_connections":
+			max = n
+		}
+	}
+
+	if current < 0 || max < 0 {
+		return 0, 0, fmt.Errorf("unexpected mysql output: %q", output)
+	}
+
+	return current, max, nil
+}
@@ -0,0 +1,130 @@
+package system
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestNewDBCollector(t *testing.T) {
+	databases := []config.Database{{Type: "postgres", Label: "main"}}
+
+	c := NewDBCollector(databases, 2)
+
+	dc, ok := c.(*DBCollector)
+	if !ok {
+		t.Fatalf("NewDBCollector() returned wrong type: %T", c)
+	}
+	if len(dc.Databases) != 1 {
+		t.Errorf("NewDBCollector() databases length = %d, want 1", len(dc.Databases))
+	}
+}
+
+func TestParsePostgresConnections(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		wantCurrent int
+		wantMax     int
+		wantErr     bool
+	}{
+		{name: "valid output", output: "12|100\n", wantCurrent: 12, wantMax: 100},
+		{name: "missing separator", output: "12\n", wantErr: true},
+		{name: "non numeric", output: "a|100\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, max, err := parsePostgresConnections(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePostgresConnections() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if current != tt.wantCurrent || max != tt.wantMax {
+				t.Errorf("parsePostgresConnections() = %d, %d, want %d, %d", current, max, tt.wantCurrent, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestParseMySQLConnections(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		wantCurrent int
+		wantMax     int
+		wantErr     bool
+	}{
+		{name: "valid output", output: "Threads_connected\t5\nmax_connections\t151\n", wantCurrent: 5, wantMax: 151},
+		{name: "missing max", output: "Threads_connected\t5\n", wantErr: true},
+		{name: "empty output", output: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, max, err := parseMySQLConnections(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMySQLConnections() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if current != tt.wantCurrent || max != tt.wantMax {
+				t.Errorf("parseMySQLConnections() = %d, %d, want %d, %d", current, max, tt.wantCurrent, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestDBCollector_CollectUnreachable(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	mock := &mockExecCommand{err: errors.New("connection refused")}
+	execCommand = mock.Command
+
+	c := &DBCollector{
+		Databases: []config.Database{
+			{Type: "postgres", Label: "pg"},
+			{Type: "mysql", Label: "my"},
+		},
+	}
+
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("DBCollector.Collect() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("DBCollector.Collect() returned %d metrics, want 2", len(metrics))
+	}
+
+	for _, m := range metrics {
+		if m.Name != collector.NameDBConnections {
+			t.Errorf("metric name = %v, want %v", m.Name, collector.NameDBConnections)
+		}
+		value, ok := m.Value.(map[string]interface{})
+		if !ok {
+			t.Fatalf("metric value is not map[string]interface{}: %T", m.Value)
+		}
+		if value["reachable"] != false {
+			t.Errorf("%s: reachable = %v, want false", m.Metadata["label"], value["reachable"])
+		}
+	}
+}
+
+func TestDBCollector_PostgresCountsClientBackends(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	mock := &mockExecCommand{}
+	execCommand = mock.Command
+
+	c := &DBCollector{}
+	c.queryPostgres(config.Database{Type: "postgres", Host: "localhost", Port: 5432})
+
+	if mock.command != "psql" {
+		t.Fatalf("command = %q, want psql", mock.command)
+	}
+	if query := strings.Join(mock.args, " "); !strings.Contains(query, "WHERE backend_type = 'client backend'") {
+		t.Errorf("psql args = %v, want the query limited to client backends", mock.args)
+	}
+}
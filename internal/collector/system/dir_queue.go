@@ -0,0 +1,115 @@
+package system
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// errWalkLimit stops a directory walk once its file count or time bound is reached
+var errWalkLimit = errors.New("walk limit reached")
+
+// DirQueueCollector implements the collector.Collector interface for directory backlog metrics
+type DirQueueCollector struct {
+	Directories []config.QueueDir
+	WalkTimeout time.Duration
+}
+
+// NewDirQueueCollector creates a new instance of DirQueueCollector
+func NewDirQueueCollector(directories []config.QueueDir, walkTimeout time.Duration) collector.Collector {
+	return &DirQueueCollector{
+		Directories: directories,
+		WalkTimeout: walkTimeout,
+	}
+}
+
+// Collect gathers the number of files in each configured directory and the age of the oldest one
+func (c *DirQueueCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, len(c.Directories))
+	now := time.Now()
+
+	for _, dir := range c.Directories {
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameDirQueue,
+			Metadata: collector.MetricMetadata{
+				"path":  dir.Path,
+				"label": dir.Label,
+			},
+			Value: c.scanDir(dir, now),
+		})
+	}
+
+	return metrics, nil
+}
+
+// scanDir counts the regular files in a directory, stopping at MaxFiles or WalkTimeout
+// When the walk stops early the counts only cover the files seen so far and truncated is set
+func (c *DirQueueCollector) scanDir(dir config.QueueDir, now time.Time) map[string]interface{} {
+	if info, err := os.Stat(dir.Path); err != nil || !info.IsDir() {
+		return map[string]interface{}{
+			"exists": false,
+		}
+	}
+
+	var deadline time.Time
+	if c.WalkTimeout > 0 {
+		deadline = now.Add(c.WalkTimeout)
+	}
+
+	count := 0
+	var oldest time.Time
+	err := filepath.WalkDir(dir.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files removed while walking are expected in a queue directory, skip them
+			if path != dir.Path && d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != dir.Path && !dir.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if (dir.MaxFiles > 0 && count >= dir.MaxFiles) || (!deadline.IsZero() && time.Now().After(deadline)) {
+			return errWalkLimit
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		count++
+		if oldest.IsZero() || info.ModTime().Before(oldest) {
+			oldest = info.ModTime()
+		}
+		return nil
+	})
+
+	value := map[string]interface{}{
+		"exists":             true,
+		"file_count":         count,
+		"oldest_age_seconds": 0.0,
+		"truncated":          errors.Is(err, errWalkLimit),
+	}
+	if !oldest.IsZero() {
+		if age := now.Sub(oldest).Seconds(); age > 0 {
+			value["oldest_age_seconds"] = age
+		}
+	}
+
+	return value
+}
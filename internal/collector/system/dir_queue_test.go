@@ -0,0 +1,127 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestDirQueueCollector_Collect(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+
+	// Three files at the top level, the oldest one hour old, and two in a subdirectory
+	for i, age := range []time.Duration{time.Hour, time.Minute, 0} {
+		path := filepath.Join(root, fmt.Sprintf("msg%d", i))
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+	}
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(sub, fmt.Sprintf("msg%d", i))
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, now.Add(-3*time.Hour), now.Add(-3*time.Hour))
+	}
+
+	tests := []struct {
+		name          string
+		dir           config.QueueDir
+		wantExists    bool
+		wantCount     int
+		wantMinAge    time.Duration
+		wantTruncated bool
+	}{
+		{
+			name:       "top level only",
+			dir:        config.QueueDir{Path: root, Label: "queue"},
+			wantExists: true,
+			wantCount:  3,
+			wantMinAge: time.Hour,
+		},
+		{
+			name:       "recursive",
+			dir:        config.QueueDir{Path: root, Label: "queue", Recursive: true},
+			wantExists: true,
+			wantCount:  5,
+			wantMinAge: 3 * time.Hour,
+		},
+		{
+			name:          "bounded by max files",
+			dir:           config.QueueDir{Path: root, Label: "queue", Recursive: true, MaxFiles: 2},
+			wantExists:    true,
+			wantCount:     2,
+			wantTruncated: true,
+		},
+		{
+			name: "missing directory",
+			dir:  config.QueueDir{Path: filepath.Join(root, "missing"), Label: "missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewDirQueueCollector([]config.QueueDir{tt.dir}, 5*time.Second)
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("DirQueueCollector.Collect() error = %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].Name != collector.NameDirQueue {
+				t.Fatalf("DirQueueCollector.Collect() returned unexpected metrics: %+v", metrics)
+			}
+			if metrics[0].Metadata["label"] != tt.dir.Label {
+				t.Errorf("DirQueueCollector.Collect() label = %q, want %q", metrics[0].Metadata["label"], tt.dir.Label)
+			}
+
+			value := metrics[0].Value.(map[string]interface{})
+			if value["exists"] != tt.wantExists {
+				t.Fatalf("DirQueueCollector.Collect() exists = %v, want %v", value["exists"], tt.wantExists)
+			}
+			if !tt.wantExists {
+				return
+			}
+
+			if value["file_count"] != tt.wantCount {
+				t.Errorf("DirQueueCollector.Collect() file_count = %v, want %d", value["file_count"], tt.wantCount)
+			}
+			if value["truncated"] != tt.wantTruncated {
+				t.Errorf("DirQueueCollector.Collect() truncated = %v, want %v", value["truncated"], tt.wantTruncated)
+			}
+			age := value["oldest_age_seconds"].(float64)
+			if tt.wantMinAge > 0 && (age < tt.wantMinAge.Seconds()-1 || age > tt.wantMinAge.Seconds()+60) {
+				t.Errorf("DirQueueCollector.Collect() oldest_age_seconds = %v, want about %v", age, tt.wantMinAge.Seconds())
+			}
+		})
+	}
+}
+
+func TestDirQueueCollector_WalkTimeout(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 10; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("job%d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A deadline already in the past stops the walk at the first file
+	c := &DirQueueCollector{WalkTimeout: time.Nanosecond}
+	value := c.scanDir(config.QueueDir{Path: root, Label: "jobs"}, time.Now().Add(-time.Second))
+
+	if value["truncated"] != true {
+		t.Errorf("scanDir() truncated = %v, want true", value["truncated"])
+	}
+	if value["file_count"] != 0 {
+		t.Errorf("scanDir() file_count = %v, want 0", value["file_count"])
+	}
+}
@@ -1,31 +1,45 @@
 package system
 
 import (
+	"path/filepath"
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
 	"github.com/monitorly-app/probe/internal/config"
+	"github.com/monitorly-app/probe/internal/logger"
 	"github.com/shirou/gopsutil/v4/disk"
 )
 
+// diskPartitions lists the mounted filesystems, replaced in tests
+var diskPartitions = disk.Partitions
+
 // DiskCollector implements the collector.Collector interface for disk metrics
 type DiskCollector struct {
-	MountPoints []config.MountPoint
+	MountPoints    []config.MountPoint
+	AutoDiscover   bool     // Also monitor every mounted filesystem not filtered out below
+	ExcludeFSTypes []string // Filesystem types never discovered
+	ExcludePaths   []string // Glob patterns of mount points never discovered
+	Precision      int      // Decimal places of the reported percentages
 }
 
 // NewDiskCollector creates a new instance of DiskCollector
-func NewDiskCollector(mountPoints []config.MountPoint) collector.Collector {
+func NewDiskCollector(mountPoints []config.MountPoint, autoDiscover bool, excludeFSTypes, excludePaths []string, precision int) collector.Collector {
 	return &DiskCollector{
-		MountPoints: mountPoints,
+		MountPoints:    mountPoints,
+		AutoDiscover:   autoDiscover,
+		ExcludeFSTypes: excludeFSTypes,
+		ExcludePaths:   excludePaths,
+		Precision:      precision,
 	}
 }
 
 // Collect gathers disk metrics for specified mount points
 func (c *DiskCollector) Collect() ([]collector.Metrics, error) {
-	metrics := make([]collector.Metrics, 0, len(c.MountPoints)) // One metric per mount point
+	mountPoints := c.mountPoints()
+	metrics := make([]collector.Metrics, 0, len(mountPoints)) // One metric per mount point
 	now := time.Now()
 
-	for _, mp := range c.MountPoints {
+	for _, mp := range mountPoints {
 		// Collect disk usage for the specified path
 		diskInfo, err := disk.Usage(mp.Path)
 		if err != nil {
@@ -41,7 +55,7 @@ func (c *DiskCollector) Collect() ([]collector.Metrics, error) {
 		diskMetric := map[string]interface{}{}
 
 		if mp.CollectPercent {
-			percentValue := collector.RoundToTwoDecimalPlaces(diskInfo.UsedPercent)
+			percentValue := collector.RoundTo(diskInfo.UsedPercent, c.Precision)
 			diskMetric["percent"] = percentValue
 		}
 
@@ -51,6 +65,10 @@ func (c *DiskCollector) Collect() ([]collector.Metrics, error) {
 			diskMetric["available"] = diskInfo.Free
 		}
 
+		if mp.CollectInodes {
+			addInodeFields(diskMetric, diskInfo, c.Precision)
+		}
+
 		// Add the combined metric for this mount point
 		metrics = append(metrics, collector.Metrics{
 			Timestamp: now,
@@ -63,3 +81,66 @@ func (c *DiskCollector) Collect() ([]collector.Metrics, error) {
 
 	return metrics, nil
 }
+
+// mountPoints returns the configured mount points followed by the discovered ones, discovery
+// running on every collection so volumes mounted later are picked up. A path is only
+// reported once, configured settings winning over discovered defaults
+func (c *DiskCollector) mountPoints() []config.MountPoint {
+	if !c.AutoDiscover {
+		return c.MountPoints
+	}
+
+	partitions, err := diskPartitions(false)
+	if err != nil {
+		logger.Warnf("Failed to discover mount points, collecting configured ones only: %v", err)
+		return c.MountPoints
+	}
+
+	mountPoints := append([]config.MountPoint{}, c.MountPoints...)
+	seen := make(map[string]bool, len(c.MountPoints)+len(partitions))
+	for _, mp := range c.MountPoints {
+		seen[mp.Path] = true
+	}
+
+	for _, p := range partitions {
+		if seen[p.Mountpoint] || c.excluded(p) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+		mountPoints = append(mountPoints, config.MountPoint{
+			Path:           p.Mountpoint,
+			Label:          p.Mountpoint,
+			CollectUsage:   true,
+			CollectPercent: true,
+		})
+	}
+
+	return mountPoints
+}
+
+// excluded reports whether a discovered partition is filtered out by fstype or path
+func (c *DiskCollector) excluded(p disk.PartitionStat) bool {
+	for _, fstype := range c.ExcludeFSTypes {
+		if p.Fstype == fstype {
+			return true
+		}
+	}
+	for _, pattern := range c.ExcludePaths {
+		if matched, _ := filepath.Match(pattern, p.Mountpoint); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// addInodeFields adds inode usage to the disk metric value
+// Filesystems that don't report inodes (e.g. some tmpfs) are skipped instead of reporting zeros
+func addInodeFields(diskMetric map[string]interface{}, diskInfo *disk.UsageStat, precision int) {
+	if diskInfo.InodesTotal == 0 {
+		return
+	}
+
+	diskMetric["inodes_percent"] = collector.RoundTo(diskInfo.InodesUsedPercent, precision)
+	diskMetric["inodes_used"] = diskInfo.InodesUsed
+	diskMetric["inodes_free"] = diskInfo.InodesFree
+}
@@ -0,0 +1,65 @@
+package system
+
+import (
+	"os"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// FreshnessCollector implements the collector.Collector interface for marker file freshness metrics
+type FreshnessCollector struct {
+	Files []config.FreshnessFile
+}
+
+// NewFreshnessCollector creates a new instance of FreshnessCollector
+func NewFreshnessCollector(files []config.FreshnessFile) collector.Collector {
+	return &FreshnessCollector{
+		Files: files,
+	}
+}
+
+// Collect gathers the age of each configured marker file and whether it is stale
+func (c *FreshnessCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, len(c.Files))
+	now := time.Now()
+
+	for _, f := range c.Files {
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameFreshness,
+			Metadata: collector.MetricMetadata{
+				"path":  f.Path,
+				"label": f.Label,
+			},
+			Value: c.checkFile(f, now),
+		})
+	}
+
+	return metrics, nil
+}
+
+// checkFile computes the freshness value for a single file
+// A missing or unreadable file is always reported as stale
+func (c *FreshnessCollector) checkFile(f config.FreshnessFile, now time.Time) map[string]interface{} {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return map[string]interface{}{
+			"exists": false,
+			"stale":  true,
+		}
+	}
+
+	age := now.Sub(info.ModTime())
+	if age < 0 {
+		age = 0
+	}
+
+	return map[string]interface{}{
+		"exists":      true,
+		"age_seconds": int64(age.Seconds()),
+		"stale":       age > f.MaxAge,
+	}
+}
@@ -0,0 +1,75 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestNewFreshnessCollector(t *testing.T) {
+	files := []config.FreshnessFile{{Path: "/tmp/backup.done", Label: "backup", MaxAge: time.Hour}}
+
+	c := NewFreshnessCollector(files)
+
+	fc, ok := c.(*FreshnessCollector)
+	if !ok {
+		t.Fatalf("NewFreshnessCollector() returned wrong type: %T", c)
+	}
+	if len(fc.Files) != 1 {
+		t.Errorf("NewFreshnessCollector() files length = %d, want 1", len(fc.Files))
+	}
+}
+
+func TestFreshnessCollector_Collect(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	freshPath := filepath.Join(tmpDir, "fresh")
+	stalePath := filepath.Join(tmpDir, "stale")
+	for _, p := range []string{freshPath, stalePath} {
+		if err := os.WriteFile(p, []byte("done"), 0644); err != nil {
+			t.Fatalf("failed to write marker file: %v", err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stalePath, old, old); err != nil {
+		t.Fatalf("failed to set marker file time: %v", err)
+	}
+
+	c := &FreshnessCollector{
+		Files: []config.FreshnessFile{
+			{Path: freshPath, Label: "fresh", MaxAge: time.Hour},
+			{Path: stalePath, Label: "stale", MaxAge: time.Hour},
+			{Path: filepath.Join(tmpDir, "missing"), Label: "missing", MaxAge: time.Hour},
+		},
+	}
+
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("FreshnessCollector.Collect() error = %v", err)
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("FreshnessCollector.Collect() returned %d metrics, want 3", len(metrics))
+	}
+
+	wantStale := map[string]bool{"fresh": false, "stale": true, "missing": true}
+	for _, m := range metrics {
+		if m.Name != collector.NameFreshness {
+			t.Errorf("metric name = %v, want %v", m.Name, collector.NameFreshness)
+		}
+		value, ok := m.Value.(map[string]interface{})
+		if !ok {
+			t.Fatalf("metric value is not map[string]interface{}: %T", m.Value)
+		}
+		label := m.Metadata["label"]
+		if value["stale"] != wantStale[label] {
+			t.Errorf("%s: stale = %v, want %v", label, value["stale"], wantStale[label])
+		}
+		if _, hasAge := value["age_seconds"]; hasAge == (label == "missing") {
+			t.Errorf("%s: unexpected presence of age_seconds: %v", label, value)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package system
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// FsckStatusCollector implements the collector.Collector interface for the forced fsck state of ext filesystems
+// ext filesystems are checked at boot once they were mounted max-mount-count times or the check interval
+// elapsed since the last check, which can delay a reboot by a long time on large filesystems
+type FsckStatusCollector struct {
+	MountsMargin int           // fsck is due soon when at most this many mounts are left
+	DueWithin    time.Duration // fsck is due soon when the check interval ends within this duration
+}
+
+// NewFsckStatusCollector creates a new instance of FsckStatusCollector
+func NewFsckStatusCollector(mountsMargin int, dueWithin time.Duration) collector.Collector {
+	return &FsckStatusCollector{
+		MountsMargin: mountsMargin,
+		DueWithin:    dueWithin,
+	}
+}
+
+// extMount is a mounted ext2/3/4 filesystem
+type extMount struct {
+	device     string
+	mountpoint string
+}
+
+// fsckStatus holds the fsck related fields of tune2fs -l output
+type fsckStatus struct {
+	mountCount    int
+	maxMountCount int       // Negative or zero when mount count checks are disabled
+	lastChecked   time.Time // Zero when tune2fs doesn't report it
+	checkInterval time.Duration
+}
+
+// Supported reports whether the host runs Linux, where ext filesystems are inspected with tune2fs
+func (c *FsckStatusCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect reports the mount counts and check interval of each mounted ext filesystem
+// Nothing is returned when there are no ext filesystems or tune2fs isn't installed
+func (c *FsckStatusCollector) Collect() ([]collector.Metrics, error) {
+	mounts, err := readExtMounts(filepath.Join(procRoot, "mounts"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+
+	now := time.Now()
+	metrics := make([]collector.Metrics, 0, len(mounts))
+	for _, m := range mounts {
+		var value map[string]interface{}
+
+		output, err := execCommand("tune2fs", "-l", m.device).Output()
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			value = map[string]interface{}{"error": fmt.Sprintf("tune2fs failed: %v", err)}
+		} else if status, err := parseTune2fs(string(output)); err != nil {
+			value = map[string]interface{}{"error": err.Error()}
+		} else {
+			value = c.value(status, now)
+		}
+
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameFsckStatus,
+			Metadata: collector.MetricMetadata{
+				"mountpoint": m.mountpoint,
+				"device":     m.device,
+			},
+			Value: value,
+		})
+	}
+
+	return metrics, nil
+}
+
+// value builds the metric value for status, deciding whether a forced fsck is due soon at now
+func (c *FsckStatusCollector) value(status fsckStatus, now time.Time) map[string]interface{} {
+	dueSoon := status.maxMountCount > 0 && status.maxMountCount-status.mountCount <= c.MountsMargin
+
+	var lastChecked int64
+	if !status.lastChecked.IsZero() {
+		lastChecked = status.lastChecked.Unix()
+		if status.checkInterval > 0 && status.lastChecked.Add(status.checkInterval).Sub(now) <= c.DueWithin {
+			dueSoon = true
+		}
+	}
+
+	return map[string]interface{}{
+		"mount_count":     status.mountCount,
+		"max_mount_count": status.maxMountCount,
+		"last_checked":    lastChecked,
+		"check_interval":  int64(status.checkInterval.Seconds()),
+		"fsck_due_soon":   dueSoon,
+	}
+}
+
+// readExtMounts returns the ext2/3/4 filesystems listed in a /proc/mounts formatted file
+// A device mounted several times, e.g. with bind mounts, is only listed at its first mountpoint
+func readExtMounts(path string) ([]extMount, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mounts []extMount
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[2] {
+		case "ext2", "ext3", "ext4":
+		default:
+			continue
+		}
+		device := unescapeMountField(fields[0])
+		if seen[device] {
+			continue
+		}
+		seen[device] = true
+		mounts = append(mounts, extMount{device: device, mountpoint: unescapeMountField(fields[1])})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountField decodes the octal escapes (e.g. \040 for a space) used in /proc/mounts fields
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// parseTune2fs extracts the fsck related fields from tune2fs -l output, made of "Name:  value" lines
+func parseTune2fs(output string) (fsckStatus, error) {
+	var status fsckStatus
+	var foundCount bool
+	for _, line := range strings.Split(output, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(name) {
+		case "Mount count":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return status, fmt.Errorf("invalid mount count %q", value)
+			}
+			status.mountCount = n
+			foundCount = true
+		case "Maximum mount count":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return status, fmt.Errorf("invalid maximum mount count %q", value)
+			}
+			status.maxMountCount = n
+		case "Last checked":
+			// The value is in ctime format, in the host's local time
+			if t, err := time.ParseInLocation(time.ANSIC, value, time.Local); err == nil {
+				status.lastChecked = t
+			}
+		case "Check interval":
+			// e.g. "15552000 (6 months)" or "0 (<none>)"
+			seconds, _, _ := strings.Cut(value, " ")
+			n, err := strconv.ParseInt(seconds, 10, 64)
+			if err != nil {
+				return status, fmt.Errorf("invalid check interval %q", value)
+			}
+			status.checkInterval = time.Duration(n) * time.Second
+		}
+	}
+	if !foundCount {
+		return status, fmt.Errorf("tune2fs output has no mount count")
+	}
+	return status, nil
+}
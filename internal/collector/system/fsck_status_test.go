@@ -0,0 +1,163 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+const tune2fsOutput = `tune2fs 1.47.0 (5-Feb-2023)
+Filesystem volume name:   <none>
+Filesystem state:         clean
+Mount count:              %d
+Maximum mount count:      %d
+Last checked:             Mon Jan  1 00:00:00 2024
+Check interval:           %d (6 months)
+`
+
+func TestFsckStatusCollector_Collect(t *testing.T) {
+	originalExecCommand, originalProcRoot := execCommand, procRoot
+	defer func() { execCommand, procRoot = originalExecCommand, originalProcRoot }()
+
+	procRoot = t.TempDir()
+	mounts := "/dev/sda1 / ext4 rw,relatime 0 0\n" +
+		"proc /proc proc rw 0 0\n" +
+		"/dev/sdb1 /srv/my\\040data ext3 rw 0 0\n" +
+		"/dev/sda1 /var/lib/bind ext4 rw,relatime 0 0\n" +
+		"/dev/sdc1 /data xfs rw 0 0\n"
+	if err := os.WriteFile(filepath.Join(procRoot, "mounts"), []byte(mounts), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sixMonths := int64(15552000)
+	outputs := map[string]string{
+		// Far from both limits
+		"/dev/sda1": "printf '" + tune2fsOutput + "' 3 30 0",
+		// Two mounts left
+		"/dev/sdb1": "printf '" + tune2fsOutput + "' 28 30 15552000",
+	}
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if name != "tune2fs" || len(args) != 2 || args[0] != "-l" {
+			t.Errorf("unexpected command %s %v", name, args)
+		}
+		return exec.Command("sh", "-c", outputs[args[1]])
+	}
+
+	metrics, err := NewFsckStatusCollector(5, 7*24*time.Hour).Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("Collect() returned %d metrics, want 2 (bind mounts and non-ext filesystems skipped)", len(metrics))
+	}
+
+	lastChecked := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local).Unix()
+	want := []struct {
+		metadata collector.MetricMetadata
+		value    map[string]interface{}
+	}{
+		{
+			metadata: collector.MetricMetadata{"mountpoint": "/", "device": "/dev/sda1"},
+			value: map[string]interface{}{
+				"mount_count": 3, "max_mount_count": 30, "last_checked": lastChecked,
+				"check_interval": int64(0), "fsck_due_soon": false,
+			},
+		},
+		{
+			metadata: collector.MetricMetadata{"mountpoint": "/srv/my data", "device": "/dev/sdb1"},
+			value: map[string]interface{}{
+				"mount_count": 28, "max_mount_count": 30, "last_checked": lastChecked,
+				// The check interval ended long ago as well
+				"check_interval": sixMonths, "fsck_due_soon": true,
+			},
+		},
+	}
+	for i, w := range want {
+		if metrics[i].Name != collector.NameFsckStatus {
+			t.Errorf("metric %d name = %s, want %s", i, metrics[i].Name, collector.NameFsckStatus)
+		}
+		if !reflect.DeepEqual(metrics[i].Metadata, w.metadata) {
+			t.Errorf("metric %d metadata = %v, want %v", i, metrics[i].Metadata, w.metadata)
+		}
+		if !reflect.DeepEqual(metrics[i].Value, w.value) {
+			t.Errorf("metric %d value = %v, want %v", i, metrics[i].Value, w.value)
+		}
+	}
+}
+
+func TestFsckStatusCollector_Errors(t *testing.T) {
+	originalExecCommand, originalProcRoot := execCommand, procRoot
+	defer func() { execCommand, procRoot = originalExecCommand, originalProcRoot }()
+
+	procRoot = t.TempDir()
+	if err := os.WriteFile(filepath.Join(procRoot, "mounts"), []byte("/dev/sda1 / ext4 rw 0 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// tune2fs failing on a device, e.g. without root, is reported on that mount
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("false")
+	}
+	metrics, err := NewFsckStatusCollector(5, time.Hour).Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Collect() returned %d metrics, want 1", len(metrics))
+	}
+	if value, _ := metrics[0].Value.(map[string]interface{}); value["error"] == nil {
+		t.Errorf("Collect() value = %v, want an error", metrics[0].Value)
+	}
+
+	// Nothing is sent without tune2fs
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("tune2fs-not-installed")
+	}
+	metrics, err = NewFsckStatusCollector(5, time.Hour).Collect()
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("Collect() without tune2fs = %v, %v, want nothing", metrics, err)
+	}
+
+	// Nor without ext filesystems, tune2fs isn't run
+	if err := os.WriteFile(filepath.Join(procRoot, "mounts"), []byte("/dev/sda1 / xfs rw 0 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Errorf("tune2fs run without ext filesystems")
+		return exec.Command("true")
+	}
+	metrics, err = NewFsckStatusCollector(5, time.Hour).Collect()
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("Collect() without ext filesystems = %v, %v, want nothing", metrics, err)
+	}
+}
+
+func TestFsckStatusCollector_Value(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := &FsckStatusCollector{MountsMargin: 2, DueWithin: 24 * time.Hour}
+
+	tests := []struct {
+		name   string
+		status fsckStatus
+		want   bool
+	}{
+		{"checks disabled", fsckStatus{mountCount: 500, maxMountCount: -1}, false},
+		{"mounts left", fsckStatus{mountCount: 10, maxMountCount: 20}, false},
+		{"mount margin reached", fsckStatus{mountCount: 18, maxMountCount: 20}, true},
+		{"interval far", fsckStatus{lastChecked: now.Add(-time.Hour), checkInterval: 48 * time.Hour}, false},
+		{"interval ends soon", fsckStatus{lastChecked: now.Add(-30 * time.Hour), checkInterval: 48 * time.Hour}, true},
+		{"never checked", fsckStatus{checkInterval: 48 * time.Hour}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.value(tt.status, now)["fsck_due_soon"]; got != tt.want {
+				t.Errorf("fsck_due_soon = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
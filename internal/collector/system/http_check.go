@@ -0,0 +1,115 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// httpCheckMaxDrain bounds how much of a response body is read so the connection can be reused
+// Larger bodies are left unread and their connection is closed
+const httpCheckMaxDrain = 64 << 10
+
+// HTTPCheckCollector implements the collector.Collector interface for HTTP endpoint health checks
+type HTTPCheckCollector struct {
+	Endpoints []config.HTTPEndpoint
+	client    *http.Client
+}
+
+// NewHTTPCheckCollector creates a new instance of HTTPCheckCollector
+func NewHTTPCheckCollector(endpoints []config.HTTPEndpoint) collector.Collector {
+	return &HTTPCheckCollector{
+		Endpoints: endpoints,
+		client:    &http.Client{},
+	}
+}
+
+// Collect checks each configured endpoint and reports its status code, latency and health
+// Failures such as DNS errors or timeouts are reported as ok=false instead of aborting the collection
+func (c *HTTPCheckCollector) Collect() ([]collector.Metrics, error) {
+	return c.CollectWithContext(context.Background())
+}
+
+// CollectWithContext is Collect with the requests canceled once ctx is done. The endpoints are
+// checked concurrently, so unreachable endpoints don't add up their timeouts
+func (c *HTTPCheckCollector) CollectWithContext(ctx context.Context) ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, len(c.Endpoints))
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i, ep := range c.Endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			metadata := collector.MetricMetadata{
+				"url":   ep.URL,
+				"label": ep.Label,
+			}
+
+			value, err := c.checkEndpoint(ctx, ep)
+			if err != nil {
+				metadata["error"] = err.Error()
+			}
+
+			metrics[i] = collector.Metrics{
+				Timestamp: now,
+				Category:  collector.CategorySystem,
+				Name:      collector.NameHTTPCheck,
+				Metadata:  metadata,
+				Value:     value,
+			}
+		}()
+	}
+	wg.Wait()
+
+	return metrics, nil
+}
+
+// checkEndpoint issues a GET request to the endpoint within its timeout
+func (c *HTTPCheckCollector) checkEndpoint(ctx context.Context, ep config.HTTPEndpoint) (map[string]interface{}, error) {
+	value := map[string]interface{}{
+		"status_code": 0,
+		"latency_ms":  int64(0),
+		"ok":          false,
+	}
+
+	timeout := ep.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.URL, nil)
+	if err != nil {
+		return value, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return value, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.CopyN(io.Discard, resp.Body, httpCheckMaxDrain)
+
+	expectedStatus := ep.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+
+	value["status_code"] = resp.StatusCode
+	value["latency_ms"] = latency.Milliseconds()
+	value["ok"] = resp.StatusCode == expectedStatus
+
+	return value, nil
+}
@@ -0,0 +1,178 @@
+package system
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestNewHTTPCheckCollector(t *testing.T) {
+	endpoints := []config.HTTPEndpoint{{URL: "http://localhost/health", Label: "local"}}
+
+	c := NewHTTPCheckCollector(endpoints)
+
+	hc, ok := c.(*HTTPCheckCollector)
+	if !ok {
+		t.Fatalf("NewHTTPCheckCollector() returned wrong type: %T", c)
+	}
+	if len(hc.Endpoints) != 1 || hc.client == nil {
+		t.Errorf("NewHTTPCheckCollector() = %+v, want 1 endpoint and a client", hc)
+	}
+}
+
+func TestHTTPCheckCollector_Collect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		endpoint   config.HTTPEndpoint
+		wantOK     bool
+		wantStatus int
+		wantError  bool
+	}{
+		{
+			name:       "healthy endpoint",
+			endpoint:   config.HTTPEndpoint{URL: server.URL + "/health", Label: "health", ExpectedStatus: 200, Timeout: time.Second},
+			wantOK:     true,
+			wantStatus: 200,
+		},
+		{
+			name:       "unexpected status",
+			endpoint:   config.HTTPEndpoint{URL: server.URL + "/down", Label: "down", ExpectedStatus: 200, Timeout: time.Second},
+			wantOK:     false,
+			wantStatus: 503,
+		},
+		{
+			name:      "timeout",
+			endpoint:  config.HTTPEndpoint{URL: server.URL + "/slow", Label: "slow", ExpectedStatus: 200, Timeout: 50 * time.Millisecond},
+			wantOK:    false,
+			wantError: true,
+		},
+		{
+			name:      "unresolvable host",
+			endpoint:  config.HTTPEndpoint{URL: "http://nonexistent.invalid/health", Label: "dns", Timeout: time.Second},
+			wantOK:    false,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewHTTPCheckCollector([]config.HTTPEndpoint{tt.endpoint})
+
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("HTTPCheckCollector.Collect() error = %v", err)
+			}
+			if len(metrics) != 1 {
+				t.Fatalf("HTTPCheckCollector.Collect() returned %d metrics, want 1", len(metrics))
+			}
+
+			m := metrics[0]
+			if m.Name != collector.NameHTTPCheck {
+				t.Errorf("metric name = %v, want %v", m.Name, collector.NameHTTPCheck)
+			}
+			value := m.Value.(map[string]interface{})
+			if value["ok"] != tt.wantOK {
+				t.Errorf("ok = %v, want %v", value["ok"], tt.wantOK)
+			}
+			if !tt.wantError && value["status_code"] != tt.wantStatus {
+				t.Errorf("status_code = %v, want %v", value["status_code"], tt.wantStatus)
+			}
+			if _, hasError := m.Metadata["error"]; hasError != tt.wantError {
+				t.Errorf("error metadata present = %v, want %v", hasError, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestHTTPCheckCollector_Concurrent(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hang":
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+		case "/stream":
+			// An endless body is only drained up to the limit
+			w.WriteHeader(http.StatusOK)
+			chunk := make([]byte, 32<<10)
+			for {
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+			}
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+
+	c := &HTTPCheckCollector{
+		Endpoints: []config.HTTPEndpoint{
+			{URL: server.URL + "/hang", Label: "hang1", Timeout: 300 * time.Millisecond},
+			{URL: server.URL + "/hang", Label: "hang2", Timeout: 300 * time.Millisecond},
+			{URL: server.URL + "/hang", Label: "hang3", Timeout: 300 * time.Millisecond},
+			{URL: server.URL + "/stream", Label: "stream", Timeout: 5 * time.Second},
+			{URL: server.URL + "/health", Label: "health", Timeout: time.Second},
+		},
+		client: &http.Client{},
+	}
+
+	start := time.Now()
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("HTTPCheckCollector.Collect() error = %v", err)
+	}
+	// One timeout for all the hanging endpoints, not one after another
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("HTTPCheckCollector.Collect() took %v, want the endpoints checked concurrently", elapsed)
+	}
+
+	wantOK := map[string]bool{"hang1": false, "hang2": false, "hang3": false, "stream": true, "health": true}
+	if len(metrics) != len(wantOK) {
+		t.Fatalf("HTTPCheckCollector.Collect() returned %d metrics, want %d", len(metrics), len(wantOK))
+	}
+	for i, m := range metrics {
+		// Metrics keep the order of the configured endpoints
+		if m.Metadata["label"] != c.Endpoints[i].Label {
+			t.Errorf("metric %d label = %v, want %s", i, m.Metadata["label"], c.Endpoints[i].Label)
+		}
+		value := m.Value.(map[string]interface{})
+		if value["ok"] != wantOK[c.Endpoints[i].Label] {
+			t.Errorf("%s ok = %v, want %v", c.Endpoints[i].Label, value["ok"], wantOK[c.Endpoints[i].Label])
+		}
+	}
+
+	// The collection deadline cancels the requests
+	c.Endpoints = c.Endpoints[:1]
+	c.Endpoints[0].Timeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := c.CollectWithContext(ctx); err != nil {
+		t.Fatalf("HTTPCheckCollector.CollectWithContext() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("HTTPCheckCollector.CollectWithContext() took %v, want it to stop at the context deadline", elapsed)
+	}
+}
@@ -0,0 +1,137 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// sysRoot is a variable to allow pointing the collector at a fake /sys in tests
+var sysRoot = "/sys"
+
+// HugePagesCollector implements the collector.Collector interface for huge page metrics
+type HugePagesCollector struct{}
+
+// NewHugePagesCollector creates a new instance of HugePagesCollector
+func NewHugePagesCollector() collector.Collector {
+	return &HugePagesCollector{}
+}
+
+// Supported reports whether the host runs Linux, which exposes huge pages under /sys
+func (c *HugePagesCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect gathers the number of total, free and reserved huge pages
+// Values for the default page size come from /proc/meminfo, every configured page size
+// found under /sys/kernel/mm/hugepages is reported in by_size
+func (c *HugePagesCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, 1)
+	now := time.Now()
+
+	meminfo, err := readMeminfo(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read meminfo: %w", err)
+	}
+
+	total := meminfo["HugePages_Total"]
+	free := meminfo["HugePages_Free"]
+
+	value := map[string]interface{}{
+		"total":        total,
+		"free":         free,
+		"reserved":     meminfo["HugePages_Rsvd"],
+		"size_kb":      meminfo["Hugepagesize"],
+		"percent_used": hugePagesPercentUsed(total, free),
+	}
+
+	if bySize := readHugePageSizes(filepath.Join(sysRoot, "kernel/mm/hugepages")); len(bySize) > 0 {
+		value["by_size"] = bySize
+	}
+
+	metrics = append(metrics, collector.Metrics{
+		Timestamp: now,
+		Category:  collector.CategorySystem,
+		Name:      collector.NameHugePages,
+		Value:     value,
+	})
+
+	return metrics, nil
+}
+
+// readMeminfo parses the numeric fields of /proc/meminfo, ignoring the kB unit
+func readMeminfo(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, rest, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			values[key] = v
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// readHugePageSizes reads the per page size counters from sysfs, keyed by directory size (e.g. "2048kB")
+// Page sizes whose counters can't be read are skipped
+func readHugePageSizes(dir string) map[string]map[string]uint64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	bySize := make(map[string]map[string]uint64)
+	for _, entry := range entries {
+		size, ok := strings.CutPrefix(entry.Name(), "hugepages-")
+		if !ok {
+			continue
+		}
+
+		counters := make(map[string]uint64)
+		for field, file := range map[string]string{
+			"total":    "nr_hugepages",
+			"free":     "free_hugepages",
+			"reserved": "resv_hugepages",
+		} {
+			v, err := readProcInt(filepath.Join(dir, entry.Name(), file))
+			if err != nil {
+				continue
+			}
+			counters[field] = uint64(v)
+		}
+
+		if len(counters) > 0 {
+			bySize[size] = counters
+		}
+	}
+
+	return bySize
+}
+
+// hugePagesPercentUsed returns the share of huge pages in use, 0 when none are configured
+func hugePagesPercentUsed(total, free uint64) float64 {
+	if total == 0 || free > total {
+		return 0
+	}
+	return float64(total-free) / float64(total) * 100
+}
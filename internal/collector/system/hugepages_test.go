@@ -0,0 +1,102 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestHugePagesCollector_Collect(t *testing.T) {
+	originalProcRoot, originalSysRoot := procRoot, sysRoot
+	defer func() { procRoot, sysRoot = originalProcRoot, originalSysRoot }()
+
+	tests := []struct {
+		name    string
+		meminfo string
+		sizes   map[string]map[string]string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "hugepages configured",
+			meminfo: "MemTotal:       16318480 kB\n" +
+				"HugePages_Total:     512\n" +
+				"HugePages_Free:      128\n" +
+				"HugePages_Rsvd:       64\n" +
+				"HugePages_Surp:        0\n" +
+				"Hugepagesize:       2048 kB\n",
+			sizes: map[string]map[string]string{
+				"hugepages-2048kB":    {"nr_hugepages": "512\n", "free_hugepages": "128\n", "resv_hugepages": "64\n"},
+				"hugepages-1048576kB": {"nr_hugepages": "0\n", "free_hugepages": "0\n", "resv_hugepages": "0\n"},
+			},
+			want: map[string]interface{}{
+				"total":        uint64(512),
+				"free":         uint64(128),
+				"reserved":     uint64(64),
+				"size_kb":      uint64(2048),
+				"percent_used": 75.0,
+				"by_size": map[string]map[string]uint64{
+					"2048kB":    {"total": 512, "free": 128, "reserved": 64},
+					"1048576kB": {"total": 0, "free": 0, "reserved": 0},
+				},
+			},
+		},
+		{
+			name:    "hugepages not configured",
+			meminfo: "MemTotal:       16318480 kB\n",
+			want: map[string]interface{}{
+				"total":        uint64(0),
+				"free":         uint64(0),
+				"reserved":     uint64(0),
+				"size_kb":      uint64(0),
+				"percent_used": 0.0,
+			},
+		},
+		{
+			name:    "meminfo unavailable",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procRoot = t.TempDir()
+			sysRoot = t.TempDir()
+
+			if tt.meminfo != "" {
+				if err := os.WriteFile(filepath.Join(procRoot, "meminfo"), []byte(tt.meminfo), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for dir, files := range tt.sizes {
+				sizeDir := filepath.Join(sysRoot, "kernel/mm/hugepages", dir)
+				if err := os.MkdirAll(sizeDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				for name, content := range files {
+					if err := os.WriteFile(filepath.Join(sizeDir, name), []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			metrics, err := NewHugePagesCollector().Collect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HugePagesCollector.Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(metrics) != 1 || metrics[0].Name != collector.NameHugePages {
+				t.Fatalf("HugePagesCollector.Collect() returned unexpected metrics: %+v", metrics)
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("HugePagesCollector.Collect() value = %v, want %v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
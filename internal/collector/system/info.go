@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/host"
@@ -92,7 +93,7 @@ func (c *SystemInfoCollector) getSystemInfo() (*SystemInfo, error) {
 	publicIP, err := c.getPublicIP()
 	if err != nil {
 		// Log error but continue - public IP is not critical
-		fmt.Printf("Warning: Failed to get public IP: %v\n", err)
+		logger.Warnf("Failed to get public IP: %v", err)
 	}
 	info.PublicIP = publicIP
 
@@ -164,7 +165,7 @@ func (c *SystemInfoCollector) getSystemInfo() (*SystemInfo, error) {
 		output, err := cmd.Output()
 		if err != nil {
 			// Log error but continue - service list is not critical
-			fmt.Printf("Warning: Failed to get service list: %v\n", err)
+			logger.Warnf("Failed to get service list: %v", err)
 		} else {
 			lines := strings.Split(string(output), "\n")
 			for _, line := range lines {
@@ -185,7 +186,7 @@ func (c *SystemInfoCollector) getSystemInfo() (*SystemInfo, error) {
 		output, err := cmd.Output()
 		if err != nil {
 			// Log error but continue - service list is not critical
-			fmt.Printf("Warning: Failed to get service list: %v\n", err)
+			logger.Warnf("Failed to get service list: %v", err)
 		} else {
 			lines := strings.Split(string(output), "\n")
 			for _, line := range lines {
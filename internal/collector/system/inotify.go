@@ -0,0 +1,157 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// procRoot is a variable to allow pointing the collector at a fake /proc in tests
+var procRoot = "/proc"
+
+// readFDDir is a variable to allow mocking unreadable file descriptor directories in tests
+var readFDDir = os.ReadDir
+
+// InotifyCollector implements the collector.Collector interface for inotify usage metrics
+// The kernel limits are per user, so usage is summed per user over every process visible to the
+// probe and the highest user's usage is reported. Running as root is needed to see other users:
+// without root, or after dropping privileges, unreadable_processes counts the processes left out
+type InotifyCollector struct{}
+
+// NewInotifyCollector creates a new instance of InotifyCollector
+func NewInotifyCollector() collector.Collector {
+	return &InotifyCollector{}
+}
+
+// Supported reports whether the host runs Linux, the only OS with inotify
+func (c *InotifyCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect gathers the number of inotify instances and watches in use along with their limits
+func (c *InotifyCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, 1)
+	now := time.Now()
+
+	instancesMax, err := readProcInt(filepath.Join(procRoot, "sys/fs/inotify/max_user_instances"))
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read inotify instance limit: %w", err)
+	}
+	watchesMax, err := readProcInt(filepath.Join(procRoot, "sys/fs/inotify/max_user_watches"))
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read inotify watch limit: %w", err)
+	}
+
+	instancesUsed, watchesUsed, unreadable := countInotifyUsage()
+
+	value := map[string]int{
+		"instances_used": instancesUsed,
+		"instances_max":  instancesMax,
+		"watches_used":   watchesUsed,
+		"watches_max":    watchesMax,
+	}
+	// The usage of other users may be missing, so it can be told apart from no usage
+	if unreadable > 0 {
+		value["unreadable_processes"] = unreadable
+	}
+
+	metrics = append(metrics, collector.Metrics{
+		Timestamp: now,
+		Category:  collector.CategorySystem,
+		Name:      collector.NameInotify,
+		Value:     value,
+	})
+
+	return metrics, nil
+}
+
+// readProcInt reads a single integer value from a /proc file
+func readProcInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// countInotifyUsage walks the file descriptors of every process and counts inotify instances and
+// the watches registered on them by user, then returns the highest instance and watch counts of
+// any user, which may be two different users. Processes that exit during the walk are skipped,
+// unreadable counts the processes skipped because their file descriptors are not accessible
+func countInotifyUsage() (instances int, watches int, unreadable int) {
+	fdDirs, _ := filepath.Glob(filepath.Join(procRoot, "[0-9]*", "fd"))
+	instancesByUID := make(map[int]int)
+	watchesByUID := make(map[int]int)
+
+	for _, fdDir := range fdDirs {
+		entries, err := readFDDir(fdDir)
+		if err != nil {
+			if os.IsPermission(err) {
+				unreadable++
+			}
+			continue
+		}
+		uid, err := processUID(filepath.Dir(fdDir))
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+			if err != nil || target != "anon_inode:inotify" {
+				continue
+			}
+			instancesByUID[uid]++
+			watchesByUID[uid] += countInotifyWatches(filepath.Join(filepath.Dir(fdDir), "fdinfo", entry.Name()))
+		}
+	}
+
+	for _, n := range instancesByUID {
+		instances = max(instances, n)
+	}
+	for _, n := range watchesByUID {
+		watches = max(watches, n)
+	}
+	return instances, watches, unreadable
+}
+
+// processUID returns the effective user id of the process whose /proc directory is pidDir,
+// the user inotify instances and watches are charged to
+func processUID(pidDir string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(pidDir, "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Uid: real, effective, saved set and filesystem user ids
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "Uid:" {
+			return strconv.Atoi(fields[2])
+		}
+	}
+	return 0, fmt.Errorf("no Uid line in %s/status", pidDir)
+}
+
+// countInotifyWatches counts the watch entries listed in an inotify fdinfo file
+func countInotifyWatches(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "inotify wd:") {
+			count++
+		}
+	}
+	return count
+}
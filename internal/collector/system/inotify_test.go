@@ -0,0 +1,129 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// writeFakeProc builds a minimal /proc tree with inotify limits and three processes holding an
+// inotify instance each: two of uid 1000 with 2 and 1 watches and one of root with 4 watches
+func writeFakeProc(t *testing.T, root string, withLimits bool) {
+	t.Helper()
+
+	if withLimits {
+		limitsDir := filepath.Join(root, "sys/fs/inotify")
+		if err := os.MkdirAll(limitsDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(limitsDir, "max_user_instances"), []byte("128\n"), 0644)
+		os.WriteFile(filepath.Join(limitsDir, "max_user_watches"), []byte("8192\n"), 0644)
+	}
+
+	writeFakeProcess(t, root, "42", 1000, 2)
+	writeFakeProcess(t, root, "43", 1000, 1)
+	writeFakeProcess(t, root, "44", 0, 4)
+}
+
+// writeFakeProcess adds a process running as uid to a fake /proc tree, with an inotify instance
+// holding the given number of watches and an unrelated file descriptor
+func writeFakeProcess(t *testing.T, root, pid string, uid, watches int) {
+	t.Helper()
+
+	fdDir := filepath.Join(root, pid, "fd")
+	fdinfoDir := filepath.Join(root, pid, "fdinfo")
+	for _, dir := range []string{fdDir, fdinfoDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The real uid differs from the effective one the watches are charged to
+	status := fmt.Sprintf("Name:\tfake\nUid:\t65534\t%d\t%d\t%d\n", uid, uid, uid)
+	os.WriteFile(filepath.Join(root, pid, "status"), []byte(status), 0644)
+
+	if err := os.Symlink("anon_inode:inotify", filepath.Join(fdDir, "3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/dev/null", filepath.Join(fdDir, "4")); err != nil {
+		t.Fatal(err)
+	}
+	fdinfo := "pos:\t0\nflags:\t00\nmnt_id:\t15\n"
+	for wd := 1; wd <= watches; wd++ {
+		fdinfo += fmt.Sprintf("inotify wd:%d ino:%d sdev:800001 mask:fc6 ignored_mask:0\n", wd, wd+1)
+	}
+	os.WriteFile(filepath.Join(fdinfoDir, "3"), []byte(fdinfo), 0644)
+}
+
+func TestInotifyCollector_Collect(t *testing.T) {
+	originalProcRoot, originalReadFDDir := procRoot, readFDDir
+	defer func() { procRoot, readFDDir = originalProcRoot, originalReadFDDir }()
+
+	tests := []struct {
+		name       string
+		withLimits bool
+		unreadable string // PID whose file descriptors can't be read, as for another user without root
+		want       map[string]int
+		wantErr    bool
+	}{
+		{
+			name:       "usage and limits",
+			withLimits: true,
+			want: map[string]int{
+				"instances_used": 2, // uid 1000
+				"instances_max":  128,
+				"watches_used":   4, // root
+				"watches_max":    8192,
+			},
+		},
+		{
+			name:       "other user's process unreadable",
+			withLimits: true,
+			unreadable: "44",
+			want: map[string]int{
+				"instances_used":       2, // uid 1000
+				"instances_max":        128,
+				"watches_used":         3, // uid 1000, root's watches are unknown
+				"watches_max":          8192,
+				"unreadable_processes": 1,
+			},
+		},
+		{
+			name:    "limits unavailable",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procRoot = t.TempDir()
+			writeFakeProc(t, procRoot, tt.withLimits)
+			readFDDir = func(name string) ([]os.DirEntry, error) {
+				if tt.unreadable != "" && name == filepath.Join(procRoot, tt.unreadable, "fd") {
+					return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+				}
+				return os.ReadDir(name)
+			}
+
+			c := NewInotifyCollector()
+			metrics, err := c.Collect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InotifyCollector.Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(metrics) != 1 || metrics[0].Name != collector.NameInotify {
+				t.Fatalf("InotifyCollector.Collect() returned unexpected metrics: %+v", metrics)
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("InotifyCollector.Collect() value = %v, want %v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
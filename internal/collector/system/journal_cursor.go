@@ -0,0 +1,57 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemdBooted reports whether the host runs systemd, and therefore has a journal
+func systemdBooted() bool {
+	_, err := os.Stat(filepath.Join(runRoot, "systemd/system"))
+	return err == nil
+}
+
+// readJournalCursor reads a journal cursor persisted by a journal consumer, as written by
+// journalctl --cursor-file. Only the first line is used, surrounding whitespace is ignored
+func readJournalCursor(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cursor file: %w", err)
+	}
+	cursor, _, _ := strings.Cut(string(data), "\n")
+	cursor = strings.TrimSpace(cursor)
+	if cursor == "" {
+		return "", fmt.Errorf("cursor file %s is empty", path)
+	}
+	return cursor, nil
+}
+
+// journalCursorTime returns the realtime timestamp of the entry a cursor points at
+// Cursors are ;-separated key=value pairs where t is the entry's realtime in hexadecimal microseconds
+func journalCursorTime(cursor string) (time.Time, bool) {
+	for _, field := range strings.Split(cursor, ";") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key != "t" {
+			continue
+		}
+		usec, err := strconv.ParseInt(value, 16, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.UnixMicro(usec), true
+	}
+	return time.Time{}, false
+}
+
+// parseJournalRealtime parses a __REALTIME_TIMESTAMP field, decimal microseconds since the epoch
+func parseJournalRealtime(value string) (time.Time, bool) {
+	usec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMicro(usec), true
+}
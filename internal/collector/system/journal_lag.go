@@ -0,0 +1,135 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// JournalLagCollector implements the collector.Collector interface for the lag of journal consumers
+type JournalLagCollector struct {
+	Cursors []config.JournalCursor
+}
+
+// NewJournalLagCollector creates a new instance of JournalLagCollector
+func NewJournalLagCollector(cursors []config.JournalCursor) collector.Collector {
+	return &JournalLagCollector{
+		Cursors: cursors,
+	}
+}
+
+// Supported reports whether the host runs Linux, where the systemd journal keeps the cursors
+func (c *JournalLagCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect measures how many journal entries and how much time separate each persisted cursor from
+// the journal head. Nothing is returned on hosts without systemd or journalctl
+func (c *JournalLagCollector) Collect() ([]collector.Metrics, error) {
+	return c.CollectWithContext(context.Background())
+}
+
+// CollectWithContext is Collect killing journalctl and returning ctx.Err() once ctx is done
+func (c *JournalLagCollector) CollectWithContext(ctx context.Context) ([]collector.Metrics, error) {
+	if !systemdBooted() {
+		return nil, nil
+	}
+
+	metrics := make([]collector.Metrics, 0, len(c.Cursors))
+	now := time.Now()
+	for _, cursor := range c.Cursors {
+		value, err := journalLag(ctx, cursor.CursorFile)
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			value = map[string]interface{}{"error": err.Error()}
+		}
+
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameJournalLag,
+			Metadata: collector.MetricMetadata{
+				"name": cursor.Name,
+			},
+			Value: value,
+		})
+	}
+
+	return metrics, nil
+}
+
+// journalLag counts the journal entries after the cursor persisted in cursorFile and the time between
+// the cursor's entry and the newest one. The entries are streamed so a large backlog isn't held in memory
+func journalLag(ctx context.Context, cursorFile string) (map[string]interface{}, error) {
+	cursor, err := readJournalCursor(cursorFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := execCommand("journalctl", "--after-cursor", cursor, "--no-pager", "--quiet", "-o", "json", "--output-fields=__REALTIME_TIMESTAMP")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run journalctl: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run journalctl: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = cmd.Process.Kill() })
+	defer stop()
+
+	var entries uint64
+	var first, last time.Time
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Realtime string `json:"__REALTIME_TIMESTAMP"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries++
+		if ts, ok := parseJournalRealtime(entry.Realtime); ok {
+			if first.IsZero() {
+				first = ts
+			}
+			last = ts
+		}
+	}
+	scanErr := scanner.Err()
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("journalctl failed, the cursor may be invalid: %w", err)
+	}
+	if scanErr != nil {
+		return nil, fmt.Errorf("failed to read journalctl output: %w", scanErr)
+	}
+
+	// Without a timestamp in the cursor, the oldest unprocessed entry is the next best reference
+	secondsBehind := 0.0
+	if entries > 0 && !last.IsZero() {
+		reference, ok := journalCursorTime(cursor)
+		if !ok {
+			reference = first
+		}
+		if lag := last.Sub(reference); lag > 0 {
+			secondsBehind = lag.Seconds()
+		}
+	}
+
+	return map[string]interface{}{
+		"entries_behind": entries,
+		"seconds_behind": secondsBehind,
+	}, nil
+}
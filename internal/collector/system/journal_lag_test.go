@@ -0,0 +1,148 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestJournalLagCollector_Collect(t *testing.T) {
+	originalExecCommand, originalRunRoot := execCommand, runRoot
+	defer func() { execCommand, runRoot = originalExecCommand, originalRunRoot }()
+
+	runRoot = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(runRoot, "systemd/system"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cursorTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	writeCursor := func(name, cursor string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(cursor+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	withTime := writeCursor("with-time", "s=abc;i=1a;b=def;m=2b;t="+strconv.FormatInt(cursorTime.UnixMicro(), 16)+";x=42")
+	withoutTime := writeCursor("without-time", "s=abc;i=1a")
+	missing := filepath.Join(dir, "missing")
+
+	entry := func(ts time.Time) string {
+		return `{"__CURSOR":"s=abc","__REALTIME_TIMESTAMP":"` + strconv.FormatInt(ts.UnixMicro(), 10) + `"}`
+	}
+	output := strings.Join([]string{
+		entry(cursorTime.Add(10 * time.Second)),
+		entry(cursorTime.Add(40 * time.Second)),
+		entry(cursorTime.Add(90 * time.Second)),
+	}, "\n") + "\n"
+
+	var afterCursor []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		afterCursor = append(afterCursor, args[1])
+		return exec.Command("printf", "%s", output)
+	}
+
+	c := NewJournalLagCollector([]config.JournalCursor{
+		{Name: "with-time", CursorFile: withTime},
+		{Name: "without-time", CursorFile: withoutTime},
+		{Name: "missing", CursorFile: missing},
+	})
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("Collect() returned %d metrics, want 3", len(metrics))
+	}
+	if metrics[0].Name != collector.NameJournalLag || metrics[0].Metadata["name"] != "with-time" {
+		t.Errorf("metric = %s %v, want journal_lag named with-time", metrics[0].Name, metrics[0].Metadata)
+	}
+	if afterCursor[0] != "s=abc;i=1a;b=def;m=2b;t="+strconv.FormatInt(cursorTime.UnixMicro(), 16)+";x=42" {
+		t.Errorf("journalctl --after-cursor = %q, want the persisted cursor", afterCursor[0])
+	}
+
+	tests := []struct {
+		index   int
+		entries uint64
+		seconds float64
+	}{
+		{index: 0, entries: 3, seconds: 90}, // From the cursor's own timestamp
+		{index: 1, entries: 3, seconds: 80}, // From the oldest unprocessed entry
+	}
+	for _, tt := range tests {
+		value := metrics[tt.index].Value.(map[string]interface{})
+		if value["entries_behind"] != tt.entries || value["seconds_behind"] != tt.seconds {
+			t.Errorf("%s lag = %v, want %d entries and %v seconds behind", metrics[tt.index].Metadata["name"], value, tt.entries, tt.seconds)
+		}
+	}
+	if value := metrics[2].Value.(map[string]interface{}); value["error"] == nil {
+		t.Errorf("missing cursor file value = %v, want an error", value)
+	}
+}
+
+func TestJournalLagCollector_NoSystemd(t *testing.T) {
+	originalExecCommand, originalRunRoot := execCommand, runRoot
+	defer func() { execCommand, runRoot = originalExecCommand, originalRunRoot }()
+
+	runRoot = t.TempDir()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Errorf("journalctl run on a host without systemd")
+		return exec.Command("true")
+	}
+
+	metrics, err := NewJournalLagCollector([]config.JournalCursor{{Name: "shipper", CursorFile: "/nonexistent"}}).Collect()
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("Collect() = %v, %v, want no metrics", metrics, err)
+	}
+
+	// journalctl missing from a systemd host also degrades to no metrics
+	if err := os.MkdirAll(filepath.Join(runRoot, "systemd/system"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cursorFile := filepath.Join(t.TempDir(), "cursor")
+	os.WriteFile(cursorFile, []byte("s=abc"), 0644)
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("journalctl-not-installed")
+	}
+	metrics, err = NewJournalLagCollector([]config.JournalCursor{{Name: "shipper", CursorFile: cursorFile}}).Collect()
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("Collect() without journalctl = %v, %v, want no metrics", metrics, err)
+	}
+}
+
+func TestJournalLagCollector_CollectWithContext(t *testing.T) {
+	originalExecCommand, originalRunRoot := execCommand, runRoot
+	defer func() { execCommand, runRoot = originalExecCommand, originalRunRoot }()
+
+	runRoot = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(runRoot, "systemd/system"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cursorFile := filepath.Join(t.TempDir(), "cursor")
+	os.WriteFile(cursorFile, []byte("s=abc"), 0644)
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("sleep", "10")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	c := NewJournalLagCollector([]config.JournalCursor{{Name: "shipper", CursorFile: cursorFile}}).(collector.ContextCollector)
+	metrics, err := c.CollectWithContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || len(metrics) != 0 {
+		t.Errorf("CollectWithContext() = %v, %v, want context.DeadlineExceeded", metrics, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("CollectWithContext() returned after %v, want journalctl killed at the deadline", elapsed)
+	}
+}
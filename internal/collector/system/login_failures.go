@@ -2,28 +2,200 @@ package system
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/monitorly-app/probe/internal/privileges"
 )
 
+// syslogFailurePatterns match authentication failures in traditional syslog files
+var syslogFailurePatterns = []*regexp.Regexp{
+	// SSH authentication failures
+	regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd.*Failed password for (?:invalid user )?(\w+) from ([\da-fA-F:\.]+)`),
+	regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd.*Invalid user (\w+) from ([\da-fA-F:\.]+)`),
+	regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd.*Connection closed by ([\da-fA-F:\.]+) port \d+ \[preauth\]`),
+	// PAM authentication failures - specific patterns
+	regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*pam.*authentication failure.*rhost=([\da-fA-F:\.]+).*user=(\w+)`),
+	regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*pam.*authentication failure.*user=(\w+).*rhost=([\da-fA-F:\.]+)`),
+	// Login failures
+	regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*login.*FAILED LOGIN.*FROM ([\da-fA-F:\.]+).*FOR (\w+)`),
+}
+
+// journalFailurePatterns match authentication failures in journalctl output (ISO format timestamps)
+var journalFailurePatterns = []*regexp.Regexp{
+	// SSH authentication failures with ISO timestamp
+	regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*sshd.*Failed password for (?:invalid user )?(\w+) from ([\da-fA-F:\.]+)`),
+	regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*sshd.*Invalid user (\w+) from ([\da-fA-F:\.]+)`),
+	regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*sshd.*Connection closed by ([\da-fA-F:\.]+) port \d+ \[preauth\]`),
+	// PAM authentication failures - more specific patterns
+	regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*pam.*authentication failure.*rhost=([\da-fA-F:\.]+).*user=(\w+)`),
+	regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*pam.*authentication failure.*user=(\w+).*rhost=([\da-fA-F:\.]+)`),
+}
+
+// builtinServicePatterns are the pattern sets operators can pick for extra services,
+// using the named groups "user" and "ip"
+var builtinServicePatterns = map[string][]string{
+	"vsftpd": {
+		`(?:\[(?P<user>[^\]]+)\] )?FAIL LOGIN: Client "(?P<ip>[\da-fA-F:\.]+)"`,
+	},
+	"dovecot": {
+		`auth failed.*user=<(?P<user>[^>]*)>.*rip=(?P<ip>[\da-fA-F:\.]+)`,
+	},
+	"pam": {
+		`authentication failure.*\buser=(?P<user>\w+).*rhost=(?P<ip>[\da-fA-F:\.]+)`,
+		`authentication failure.*rhost=(?P<ip>[\da-fA-F:\.]+)(?:.*\buser=(?P<user>\w+))?`,
+	},
+}
+
+// syslogTimestampPattern and journalTimestampPattern extract the timestamp of a line
+// matched by an extra service's patterns
+var (
+	syslogTimestampPattern  = regexp.MustCompile(`^(\w+\s+\d+\s+\d+:\d+:\d+)`)
+	journalTimestampPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4})`)
+)
+
+// isIPAddress reports whether s is an IPv4 or IPv6 address, used to tell the
+// rhost and user captures apart in the PAM patterns
+func isIPAddress(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+// failureMarkers are literal substrings, one of which every failure pattern requires
+var failureMarkers = []string{
+	"Failed password for ",
+	"Invalid user ",
+	"Connection closed by ",
+	"authentication failure",
+	"FAILED LOGIN",
+}
+
+// mayBeLoginFailure is a cheap pre-filter run before the failure patterns
+// Lines without any failure marker can't match a pattern, so the regexes are skipped for them
+func mayBeLoginFailure(line string) bool {
+	for _, marker := range failureMarkers {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // LoginFailuresCollector implements the collector.Collector interface for login failure metrics
 type LoginFailuresCollector struct {
-	lastCheck time.Time
+	SummarizeBySource bool   // Report one summary per source IP instead of every failure
+	Aggregate         bool   // Report only a failure count per source IP instead of every failure
+	StateFile         string // Optional: File persisting the last check so restarts resume from it
+	services          []loginService
+	lastCheck         time.Time
+}
+
+// loginFailuresState is the content of the login failures state file
+type loginFailuresState struct {
+	LastCheck time.Time `json:"last_check"`
 }
 
-// NewLoginFailuresCollector creates a new instance of LoginFailuresCollector
-func NewLoginFailuresCollector() collector.Collector {
+// loginService is an extra authentication service with its compiled failure patterns
+type loginService struct {
+	name     string
+	unit     string
+	patterns []*regexp.Regexp
+}
+
+// NewLoginFailuresCollector creates a new instance of LoginFailuresCollector. The first
+// collection looks back initialLookback, or resumes from the last check persisted in
+// stateFile, going back at most maxLookback
+func NewLoginFailuresCollector(summarizeBySource, aggregate bool, services []config.LoginService, initialLookback, maxLookback time.Duration, stateFile string) collector.Collector {
 	return &LoginFailuresCollector{
-		lastCheck: time.Now().Add(-1 * time.Minute), // Start from 1 minute ago
+		SummarizeBySource: summarizeBySource,
+		Aggregate:         aggregate,
+		StateFile:         stateFile,
+		services:          compileLoginServices(services),
+		lastCheck:         loadLastCheck(stateFile, time.Now(), initialLookback, maxLookback),
 	}
 }
 
+// loadLastCheck returns the time to start scanning from. A missing, unreadable or
+// future state file falls back to the initial lookback; a persisted time older than
+// maxLookback is capped so a long outage doesn't trigger a scan of the whole log
+func loadLastCheck(path string, now time.Time, initialLookback, maxLookback time.Duration) time.Time {
+	initial := now.Add(-initialLookback)
+	if path == "" {
+		return initial
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("Failed to read login failures state file %s: %v", path, err)
+		}
+		return initial
+	}
+
+	var state loginFailuresState
+	if err := json.Unmarshal(data, &state); err != nil || state.LastCheck.IsZero() {
+		logger.Warnf("Invalid login failures state file %s, looking back %v", path, initialLookback)
+		return initial
+	}
+	if state.LastCheck.After(now) {
+		logger.Warnf("Login failures state file %s is in the future, looking back %v", path, initialLookback)
+		return initial
+	}
+	if maxLookback > 0 && state.LastCheck.Before(now.Add(-maxLookback)) {
+		logger.Warnf("Login failures last check %s is older than %v, skipping the rest of the downtime", state.LastCheck.Format(time.RFC3339), maxLookback)
+		return now.Add(-maxLookback)
+	}
+	return state.LastCheck
+}
+
+// saveLastCheck writes the last check to the state file in place
+func (c *LoginFailuresCollector) saveLastCheck() error {
+	data, err := json.Marshal(loginFailuresState{LastCheck: c.lastCheck})
+	if err != nil {
+		return fmt.Errorf("failed to marshal login failures state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.StateFile), 0755); err != nil {
+		return fmt.Errorf("failed to create login failures state directory: %w", err)
+	}
+	if err := os.WriteFile(c.StateFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write login failures state: %w", err)
+	}
+	return nil
+}
+
+// compileLoginServices resolves the built-in and custom patterns of each extra service,
+// skipping patterns that don't compile (config validation already rejects them)
+func compileLoginServices(services []config.LoginService) []loginService {
+	compiled := make([]loginService, 0, len(services))
+	for _, svc := range services {
+		ls := loginService{name: svc.Name, unit: svc.Unit}
+		if ls.unit == "" {
+			ls.unit = svc.Name
+		}
+
+		sources := append(append([]string{}, builtinServicePatterns[svc.Builtin]...), svc.Patterns...)
+		for _, source := range sources {
+			if pattern, err := regexp.Compile(source); err == nil {
+				ls.patterns = append(ls.patterns, pattern)
+			}
+		}
+		compiled = append(compiled, ls)
+	}
+	return compiled
+}
+
 // LoginFailure represents a failed login attempt
 type LoginFailure struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -33,60 +205,163 @@ type LoginFailure struct {
 	Message   string    `json:"message"`
 }
 
+// SourceSummary aggregates the login failures of a single source IP over a collection window
+type SourceSummary struct {
+	SourceIP       string    `json:"source_ip"`
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+	Count          int       `json:"count"`
+	UsernamesTried []string  `json:"usernames_tried"`
+}
+
+// Supported reports whether the host runs Linux, whose journal and auth logs are the sources of failures
+func (c *LoginFailuresCollector) Supported() bool {
+	return goos == "linux"
+}
+
 // Collect gathers login failure metrics by checking system logs since the last collection
 func (c *LoginFailuresCollector) Collect() ([]collector.Metrics, error) {
+	return c.CollectWithContext(context.Background())
+}
+
+// CollectWithContext is Collect killing journalctl once ctx is done. The failures of an interrupted
+// collection are picked up by the next one
+func (c *LoginFailuresCollector) CollectWithContext(ctx context.Context) ([]collector.Metrics, error) {
 	metrics := make([]collector.Metrics, 0, 1)
 	now := time.Now()
 
-	failures, err := c.getLoginFailuresSince(c.lastCheck)
+	failures, err := c.getLoginFailuresSince(ctx, c.lastCheck)
 	if err != nil {
 		return metrics, fmt.Errorf("failed to get login failures: %w", err)
 	}
 
 	// Update last check time
 	c.lastCheck = now
+	if c.StateFile != "" {
+		if err := c.saveLastCheck(); err != nil {
+			logger.Warnf("Login failures may be rescanned after a restart: %v", err)
+		}
+	}
+
+	var value interface{} = failures
+	switch {
+	case c.Aggregate:
+		value = countBySource(failures)
+	case c.SummarizeBySource:
+		value = summarizeBySource(failures)
+	}
 
 	// Create a single metric with all login failures since last check
 	metrics = append(metrics, collector.Metrics{
 		Timestamp: now,
 		Category:  collector.CategorySystem,
 		Name:      collector.NameLoginFailures,
-		Value:     failures,
+		Value:     value,
 	})
 
 	return metrics, nil
 }
 
+// summarizeBySource reduces failures to one entry per source IP, most active sources first
+func summarizeBySource(failures []LoginFailure) []SourceSummary {
+	bySource := make(map[string]*SourceSummary)
+	usernames := make(map[string]map[string]bool)
+
+	for _, f := range failures {
+		summary, ok := bySource[f.SourceIP]
+		if !ok {
+			summary = &SourceSummary{SourceIP: f.SourceIP, FirstSeen: f.Timestamp, LastSeen: f.Timestamp}
+			bySource[f.SourceIP] = summary
+			usernames[f.SourceIP] = make(map[string]bool)
+		}
+
+		summary.Count++
+		if f.Timestamp.Before(summary.FirstSeen) {
+			summary.FirstSeen = f.Timestamp
+		}
+		if f.Timestamp.After(summary.LastSeen) {
+			summary.LastSeen = f.Timestamp
+		}
+		if f.Username != "" && f.Username != "unknown" && !usernames[f.SourceIP][f.Username] {
+			usernames[f.SourceIP][f.Username] = true
+			summary.UsernamesTried = append(summary.UsernamesTried, f.Username)
+		}
+	}
+
+	summaries := make([]SourceSummary, 0, len(bySource))
+	for _, summary := range bySource {
+		if summary.UsernamesTried == nil {
+			summary.UsernamesTried = []string{}
+		}
+		sort.Strings(summary.UsernamesTried)
+		summaries = append(summaries, *summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].SourceIP < summaries[j].SourceIP
+	})
+
+	return summaries
+}
+
+// countBySource reduces failures to a count per source IP, so the payload stays
+// small while a host is being brute-forced
+func countBySource(failures []LoginFailure) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range failures {
+		counts[f.SourceIP]++
+	}
+	return counts
+}
+
 // getLoginFailuresSince retrieves login failures from system logs since the specified time
-func (c *LoginFailuresCollector) getLoginFailuresSince(since time.Time) ([]LoginFailure, error) {
+func (c *LoginFailuresCollector) getLoginFailuresSince(ctx context.Context, since time.Time) ([]LoginFailure, error) {
 	var failures []LoginFailure
 
 	// Try different log sources in order of preference
 	logSources := []func(time.Time) ([]LoginFailure, error){
-		c.getFailuresFromJournalctl,
+		func(since time.Time) ([]LoginFailure, error) { return c.getFailuresFromJournalctl(ctx, since) },
 		c.getFailuresFromAuthLog,
 		c.getFailuresFromSecureLog,
 	}
 
+	var lastErr error
 	for _, source := range logSources {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		sourceFailures, err := source(since)
 		if err == nil && len(sourceFailures) >= 0 {
 			// Successfully got data from this source, use it
-			failures = sourceFailures
-			break
+			return sourceFailures, nil
 		}
+		lastErr = err
+	}
+
+	// Without root the logs are usually unreadable, report why instead of an empty result
+	if privileges.Dropped() {
+		return nil, fmt.Errorf("no readable login log source after dropping privileges, add the runtime user to the adm or systemd-journal group: %w", lastErr)
 	}
 
 	return failures, nil
 }
 
 // getFailuresFromJournalctl gets login failures from systemd journal (modern systems)
-func (c *LoginFailuresCollector) getFailuresFromJournalctl(since time.Time) ([]LoginFailure, error) {
+func (c *LoginFailuresCollector) getFailuresFromJournalctl(ctx context.Context, since time.Time) ([]LoginFailure, error) {
 	// Format time for journalctl
 	sinceStr := since.Format("2006-01-02 15:04:05")
 
 	// Use journalctl to get authentication failures
-	cmd := exec.Command("journalctl", "--since", sinceStr, "-u", "ssh", "-u", "sshd", "-u", "systemd-logind", "--no-pager", "-o", "short-iso")
+	args := []string{"--since", sinceStr, "-u", "ssh", "-u", "sshd", "-u", "systemd-logind"}
+	for _, svc := range c.services {
+		args = append(args, "-u", svc.unit)
+	}
+	args = append(args, "--no-pager", "-o", "short-iso")
+
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute journalctl: %w", err)
@@ -116,23 +391,19 @@ func (c *LoginFailuresCollector) parseLogFile(logPath string, since time.Time) (
 	var failures []LoginFailure
 	scanner := bufio.NewScanner(file)
 
-	// Patterns for different types of authentication failures
-	patterns := []*regexp.Regexp{
-		// SSH authentication failures
-		regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd.*Failed password for (?:invalid user )?(\w+) from ([\d\.]+)`),
-		regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd.*Invalid user (\w+) from ([\d\.]+)`),
-		regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*sshd.*Connection closed by ([\d\.]+) port \d+ \[preauth\]`),
-		// PAM authentication failures - specific patterns
-		regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*pam.*authentication failure.*rhost=([\d\.]+).*user=(\w+)`),
-		regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*pam.*authentication failure.*user=(\w+).*rhost=([\d\.]+)`),
-		// Login failures
-		regexp.MustCompile(`(\w+\s+\d+\s+\d+:\d+:\d+).*login.*FAILED LOGIN.*FROM ([\d\.]+).*FOR (\w+)`),
-	}
-
 	for scanner.Scan() {
 		line := scanner.Text()
+		if failure, ok := c.matchService(line, syslogTimestampPattern, c.parseLogTimestamp); ok {
+			if !failure.Timestamp.Before(since) {
+				failures = append(failures, failure)
+			}
+			continue
+		}
+		if !mayBeLoginFailure(line) {
+			continue
+		}
 
-		for _, pattern := range patterns {
+		for _, pattern := range syslogFailurePatterns {
 			matches := pattern.FindStringSubmatch(line)
 			if len(matches) >= 3 {
 				// Parse timestamp
@@ -156,7 +427,7 @@ func (c *LoginFailuresCollector) parseLogFile(logPath string, since time.Time) (
 						// First pattern: rhost=IP user=USERNAME (matches[2]=IP, matches[3]=USERNAME)
 						// Second pattern: user=USERNAME rhost=IP (matches[2]=USERNAME, matches[3]=IP)
 						// Check which pattern matched by looking at the actual content
-						if strings.Contains(matches[2], ".") && !strings.Contains(matches[3], ".") {
+						if isIPAddress(matches[2]) && !isIPAddress(matches[3]) {
 							// matches[2] looks like an IP, matches[3] looks like a username
 							failure.SourceIP = matches[2]
 							failure.Username = matches[3]
@@ -192,25 +463,21 @@ func (c *LoginFailuresCollector) parseJournalctlOutput(output string) ([]LoginFa
 	var failures []LoginFailure
 	scanner := bufio.NewScanner(strings.NewReader(output))
 
-	// Patterns for journalctl output (ISO format timestamps)
-	patterns := []*regexp.Regexp{
-		// SSH authentication failures with ISO timestamp
-		regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*sshd.*Failed password for (?:invalid user )?(\w+) from ([\d\.]+)`),
-		regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*sshd.*Invalid user (\w+) from ([\d\.]+)`),
-		regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*sshd.*Connection closed by ([\d\.]+) port \d+ \[preauth\]`),
-		// PAM authentication failures - more specific patterns
-		regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*pam.*authentication failure.*rhost=([\d\.]+).*user=(\w+)`),
-		regexp.MustCompile(`(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}).*pam.*authentication failure.*user=(\w+).*rhost=([\d\.]+)`),
-	}
-
 	for scanner.Scan() {
 		line := scanner.Text()
+		if failure, ok := c.matchService(line, journalTimestampPattern, parseJournalTimestamp); ok {
+			failures = append(failures, failure)
+			continue
+		}
+		if !mayBeLoginFailure(line) {
+			continue
+		}
 
-		for _, pattern := range patterns {
+		for _, pattern := range journalFailurePatterns {
 			matches := pattern.FindStringSubmatch(line)
 			if len(matches) >= 3 {
 				// Parse ISO timestamp
-				timestamp, err := time.Parse("2006-01-02T15:04:05-0700", matches[1])
+				timestamp, err := parseJournalTimestamp(matches[1])
 				if err != nil {
 					continue
 				}
@@ -230,7 +497,7 @@ func (c *LoginFailuresCollector) parseJournalctlOutput(output string) ([]LoginFa
 						// First pattern: rhost=IP user=USERNAME (matches[2]=IP, matches[3]=USERNAME)
 						// Second pattern: user=USERNAME rhost=IP (matches[2]=USERNAME, matches[3]=IP)
 						// Check which pattern matched by looking at the actual content
-						if strings.Contains(matches[2], ".") && !strings.Contains(matches[3], ".") {
+						if isIPAddress(matches[2]) && !isIPAddress(matches[3]) {
 							// matches[2] looks like an IP, matches[3] looks like a username
 							failure.SourceIP = matches[2]
 							failure.Username = matches[3]
@@ -262,6 +529,53 @@ func (c *LoginFailuresCollector) parseJournalctlOutput(output string) ([]LoginFa
 	return failures, nil
 }
 
+// matchService matches a line against the extra services' patterns, reporting the failure
+// under the service's name. Lines must mention the service so its patterns don't claim
+// failures of other services
+func (c *LoginFailuresCollector) matchService(line string, timestampPattern *regexp.Regexp, parseTimestamp func(string) (time.Time, error)) (LoginFailure, bool) {
+	for _, svc := range c.services {
+		if !strings.Contains(line, svc.name) {
+			continue
+		}
+
+		for _, pattern := range svc.patterns {
+			matches := pattern.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+
+			stamp := timestampPattern.FindStringSubmatch(line)
+			if stamp == nil {
+				return LoginFailure{}, false
+			}
+			timestamp, err := parseTimestamp(stamp[1])
+			if err != nil {
+				return LoginFailure{}, false
+			}
+
+			failure := LoginFailure{
+				Timestamp: timestamp,
+				Username:  "unknown",
+				Message:   line,
+				Service:   svc.name,
+			}
+			if i := pattern.SubexpIndex("user"); i > 0 && matches[i] != "" {
+				failure.Username = matches[i]
+			}
+			if i := pattern.SubexpIndex("ip"); i > 0 {
+				failure.SourceIP = matches[i]
+			}
+			return failure, true
+		}
+	}
+	return LoginFailure{}, false
+}
+
+// parseJournalTimestamp parses the ISO timestamp of journalctl's short-iso output
+func parseJournalTimestamp(timeStr string) (time.Time, error) {
+	return time.Parse("2006-01-02T15:04:05-0700", timeStr)
+}
+
 // parseLogTimestamp parses traditional syslog timestamp format
 func (c *LoginFailuresCollector) parseLogTimestamp(timeStr string) (time.Time, error) {
 	// Current year for syslog format (which doesn't include year)
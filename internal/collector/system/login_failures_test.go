@@ -1,15 +1,20 @@
 package system
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
 )
 
 func TestNewLoginFailuresCollector(t *testing.T) {
-	c := NewLoginFailuresCollector()
+	c := NewLoginFailuresCollector(false, false, nil, time.Minute, 0, "")
 
 	if c == nil {
 		t.Errorf("NewLoginFailuresCollector() returned nil")
@@ -277,6 +282,70 @@ Jun  2 10:33:45 server pam[1237]: authentication failure; logname= uid=0 euid=0
 	}
 }
 
+func TestLoginFailuresCollector_IPv6Sources(t *testing.T) {
+	c := &LoginFailuresCollector{}
+
+	expectedFailures := []struct {
+		username string
+		sourceIP string
+		service  string
+	}{
+		{"admin", "2001:db8::1", "ssh"},
+		{"hacker", "2001:db8:0:1::50", "ssh"},
+		{"unknown", "fe80::1", "ssh"},
+		{"testuser", "2001:db8::200", "pam"},
+		{"root", "::ffff:192.0.2.10", "pam"},
+	}
+
+	journalOutput := `2024-06-02T10:30:15+0200 server sshd[1234]: Failed password for admin from 2001:db8::1 port 22 ssh2
+2024-06-02T10:31:20+0200 server sshd[1235]: Invalid user hacker from 2001:db8:0:1::50 port 22
+2024-06-02T10:32:30+0200 server sshd[1236]: Connection closed by fe80::1 port 22 [preauth]
+2024-06-02T10:33:45+0200 server pam[1237]: authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=2001:db8::200 user=testuser
+2024-06-02T10:34:50+0200 server pam[1238]: authentication failure; logname= uid=0 euid=0 tty=ssh ruser= user=root rhost=::ffff:192.0.2.10`
+
+	authLog := `Jun  2 10:30:15 server sshd[1234]: Failed password for admin from 2001:db8::1 port 22 ssh2
+Jun  2 10:31:20 server sshd[1235]: Invalid user hacker from 2001:db8:0:1::50 port 22
+Jun  2 10:32:30 server sshd[1236]: Connection closed by fe80::1 port 22 [preauth]
+Jun  2 10:33:45 server pam[1237]: authentication failure; logname= uid=0 euid=0 tty=ssh ruser= rhost=2001:db8::200 user=testuser
+Jun  2 10:34:50 server pam[1238]: authentication failure; logname= uid=0 euid=0 tty=ssh ruser= user=root rhost=::ffff:192.0.2.10
+`
+
+	logPath := filepath.Join(t.TempDir(), "auth.log")
+	if err := os.WriteFile(logPath, []byte(authLog), 0644); err != nil {
+		t.Fatalf("failed to write auth.log fixture: %v", err)
+	}
+
+	sources := map[string]func() ([]LoginFailure, error){
+		"journalctl": func() ([]LoginFailure, error) { return c.parseJournalctlOutput(journalOutput) },
+		"auth.log":   func() ([]LoginFailure, error) { return c.parseLogFile(logPath, time.Time{}) },
+	}
+
+	for name, parse := range sources {
+		t.Run(name, func(t *testing.T) {
+			failures, err := parse()
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+			if len(failures) != len(expectedFailures) {
+				t.Fatalf("parsed %d failures, want %d", len(failures), len(expectedFailures))
+			}
+
+			for i, expected := range expectedFailures {
+				failure := failures[i]
+				if failure.Username != expected.username {
+					t.Errorf("Failure %d username = %q, want %q", i, failure.Username, expected.username)
+				}
+				if failure.SourceIP != expected.sourceIP {
+					t.Errorf("Failure %d sourceIP = %q, want %q", i, failure.SourceIP, expected.sourceIP)
+				}
+				if failure.Service != expected.service {
+					t.Errorf("Failure %d service = %q, want %q", i, failure.Service, expected.service)
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkLoginFailuresCollector_Collect benchmarks login failure collection
 func BenchmarkLoginFailuresCollector_Collect(b *testing.B) {
 	c := &LoginFailuresCollector{
@@ -291,3 +360,289 @@ func BenchmarkLoginFailuresCollector_Collect(b *testing.B) {
 		}
 	}
 }
+
+func TestMayBeLoginFailure(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"Jun  2 10:30:15 server sshd[1234]: Failed password for admin from 192.168.1.100 port 22 ssh2", true},
+		{"Jun  2 10:31:20 server sshd[1235]: Invalid user hacker from 10.0.0.50 port 22", true},
+		{"Jun  2 10:32:30 server sshd[1236]: Connection closed by 172.16.0.1 port 22 [preauth]", true},
+		{"Jun  2 10:33:45 server pam[1237]: authentication failure; rhost=192.168.1.200 user=testuser", true},
+		{"Jun  2 10:34:00 server login[1238]: FAILED LOGIN 1 FROM 10.0.0.1 FOR root", true},
+		{"Jun  2 10:35:00 server CRON[1239]: pam_unix(cron:session): session opened for user root", false},
+		{"Jun  2 10:36:00 server sshd[1240]: Accepted publickey for deploy from 10.0.0.2 port 51234 ssh2", false},
+	}
+
+	for _, tt := range tests {
+		if got := mayBeLoginFailure(tt.line); got != tt.want {
+			t.Errorf("mayBeLoginFailure(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+// writeSyntheticAuthLog writes an auth log where roughly 1 in 100 lines is a login failure,
+// similar to a busy host dominated by cron and session noise
+func writeSyntheticAuthLog(b *testing.B, lines int) string {
+	b.Helper()
+
+	var sb strings.Builder
+	for i := 0; i < lines; i++ {
+		switch {
+		case i%100 == 0:
+			fmt.Fprintf(&sb, "Jun  2 10:30:15 server sshd[%d]: Failed password for admin from 192.168.1.%d port 22 ssh2\n", i, i%255)
+		case i%3 == 0:
+			fmt.Fprintf(&sb, "Jun  2 10:30:15 server CRON[%d]: pam_unix(cron:session): session opened for user root by (uid=0)\n", i)
+		case i%3 == 1:
+			fmt.Fprintf(&sb, "Jun  2 10:30:15 server systemd-logind[%d]: New session %d of user deploy.\n", i, i)
+		default:
+			fmt.Fprintf(&sb, "Jun  2 10:30:15 server sshd[%d]: Accepted publickey for deploy from 10.0.0.2 port 51234 ssh2\n", i)
+		}
+	}
+
+	path := filepath.Join(b.TempDir(), "auth.log")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatalf("failed to write synthetic auth log: %v", err)
+	}
+	return path
+}
+
+// BenchmarkLoginFailuresCollector_parseLogFile compares parsing with the pre-filter against
+// running every failure pattern on every line
+func BenchmarkLoginFailuresCollector_parseLogFile(b *testing.B) {
+	path := writeSyntheticAuthLog(b, 50000)
+	since := time.Time{}
+	c := &LoginFailuresCollector{}
+
+	b.Run("prefilter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := c.parseLogFile(path, since); err != nil {
+				b.Fatalf("parseLogFile() error = %v", err)
+			}
+		}
+	})
+
+	b.Run("regex_only", func(b *testing.B) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatalf("failed to read synthetic auth log: %v", err)
+		}
+		lines := strings.Split(string(data), "\n")
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, line := range lines {
+				for _, pattern := range syslogFailurePatterns {
+					pattern.FindStringSubmatch(line)
+				}
+			}
+		}
+	})
+}
+
+func TestSummarizeBySource(t *testing.T) {
+	base := time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC)
+	failures := []LoginFailure{
+		{Timestamp: base.Add(2 * time.Minute), Username: "root", SourceIP: "10.0.0.1"},
+		{Timestamp: base, Username: "admin", SourceIP: "10.0.0.1"},
+		{Timestamp: base.Add(5 * time.Minute), Username: "root", SourceIP: "10.0.0.1"},
+		{Timestamp: base.Add(time.Minute), Username: "unknown", SourceIP: "192.168.1.5"},
+	}
+
+	want := []SourceSummary{
+		{
+			SourceIP:       "10.0.0.1",
+			FirstSeen:      base,
+			LastSeen:       base.Add(5 * time.Minute),
+			Count:          3,
+			UsernamesTried: []string{"admin", "root"},
+		},
+		{
+			SourceIP:       "192.168.1.5",
+			FirstSeen:      base.Add(time.Minute),
+			LastSeen:       base.Add(time.Minute),
+			Count:          1,
+			UsernamesTried: []string{},
+		},
+	}
+
+	got := summarizeBySource(failures)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeBySource() = %+v, want %+v", got, want)
+	}
+
+	if got := summarizeBySource(nil); len(got) != 0 {
+		t.Errorf("summarizeBySource(nil) = %+v, want empty", got)
+	}
+}
+
+func TestCountBySource(t *testing.T) {
+	failures := []LoginFailure{
+		{Username: "root", SourceIP: "10.0.0.1"},
+		{Username: "admin", SourceIP: "10.0.0.1"},
+		{Username: "unknown", SourceIP: "2001:db8::1"},
+		{Username: "root", SourceIP: "10.0.0.1"},
+	}
+
+	want := map[string]int{"10.0.0.1": 3, "2001:db8::1": 1}
+	if got := countBySource(failures); !reflect.DeepEqual(got, want) {
+		t.Errorf("countBySource() = %v, want %v", got, want)
+	}
+
+	if got := countBySource(nil); got == nil || len(got) != 0 {
+		t.Errorf("countBySource(nil) = %v, want empty map", got)
+	}
+}
+
+func TestLoginFailuresCollector_Services(t *testing.T) {
+	c := NewLoginFailuresCollector(false, false, []config.LoginService{
+		{Name: "vsftpd", Builtin: "vsftpd"},
+		{Name: "dovecot", Builtin: "dovecot"},
+		{Name: "openvpn", Unit: "openvpn-server@corp", Builtin: "pam"},
+		{Name: "myapp", Patterns: []string{`login rejected for (?P<user>\w+) from (?P<ip>[\d\.]+)`}},
+	}, time.Minute, 0, "").(*LoginFailuresCollector)
+
+	if c.services[2].unit != "openvpn-server@corp" || c.services[0].unit != "vsftpd" {
+		t.Errorf("unexpected service units: %+v", c.services)
+	}
+
+	expectedFailures := []struct {
+		username string
+		sourceIP string
+		service  string
+	}{
+		{"bob", "::ffff:192.0.2.7", "vsftpd"},
+		{"alice", "2001:db8::9", "dovecot"},
+		{"carol", "198.51.100.4", "openvpn"},
+		{"dave", "203.0.113.5", "myapp"},
+		{"admin", "192.168.1.100", "ssh"},
+	}
+
+	journalOutput := `2024-06-02T10:30:15+0200 server vsftpd[1234]: [bob] FAIL LOGIN: Client "::ffff:192.0.2.7"
+2024-06-02T10:31:20+0200 server dovecot[1235]: imap-login: Disconnected (auth failed, 1 attempts in 2 secs): user=<alice>, method=PLAIN, rip=2001:db8::9, lip=2001:db8::1
+2024-06-02T10:32:30+0200 server openvpn[1236]: pam_unix(openvpn:auth): authentication failure; logname= uid=0 euid=0 tty= ruser= rhost=198.51.100.4  user=carol
+2024-06-02T10:33:45+0200 server myapp[1237]: login rejected for dave from 203.0.113.5
+2024-06-02T10:34:50+0200 server sshd[1238]: Failed password for admin from 192.168.1.100 port 22 ssh2
+2024-06-02T10:35:55+0200 server myapp[1239]: login accepted for erin from 203.0.113.6`
+
+	authLog := `Jun  2 10:30:15 server vsftpd[1234]: [bob] FAIL LOGIN: Client "::ffff:192.0.2.7"
+Jun  2 10:31:20 server dovecot[1235]: imap-login: Disconnected (auth failed, 1 attempts in 2 secs): user=<alice>, method=PLAIN, rip=2001:db8::9, lip=2001:db8::1
+Jun  2 10:32:30 server openvpn[1236]: pam_unix(openvpn:auth): authentication failure; logname= uid=0 euid=0 tty= ruser= rhost=198.51.100.4  user=carol
+Jun  2 10:33:45 server myapp[1237]: login rejected for dave from 203.0.113.5
+Jun  2 10:34:50 server sshd[1238]: Failed password for admin from 192.168.1.100 port 22 ssh2
+Jun  2 10:35:55 server myapp[1239]: login accepted for erin from 203.0.113.6
+`
+
+	logPath := filepath.Join(t.TempDir(), "auth.log")
+	if err := os.WriteFile(logPath, []byte(authLog), 0644); err != nil {
+		t.Fatalf("failed to write auth.log fixture: %v", err)
+	}
+
+	sources := map[string]func() ([]LoginFailure, error){
+		"journalctl": func() ([]LoginFailure, error) { return c.parseJournalctlOutput(journalOutput) },
+		"auth.log":   func() ([]LoginFailure, error) { return c.parseLogFile(logPath, time.Time{}) },
+	}
+
+	for name, parse := range sources {
+		t.Run(name, func(t *testing.T) {
+			failures, err := parse()
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+			if len(failures) != len(expectedFailures) {
+				t.Fatalf("parsed %d failures, want %d: %+v", len(failures), len(expectedFailures), failures)
+			}
+
+			for i, expected := range expectedFailures {
+				failure := failures[i]
+				if failure.Username != expected.username {
+					t.Errorf("Failure %d username = %q, want %q", i, failure.Username, expected.username)
+				}
+				if failure.SourceIP != expected.sourceIP {
+					t.Errorf("Failure %d sourceIP = %q, want %q", i, failure.SourceIP, expected.sourceIP)
+				}
+				if failure.Service != expected.service {
+					t.Errorf("Failure %d service = %q, want %q", i, failure.Service, expected.service)
+				}
+				if failure.Timestamp.IsZero() {
+					t.Errorf("Failure %d has zero timestamp", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadLastCheck(t *testing.T) {
+	now := time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	writeState := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "login_failures.json")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write state file: %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+		want time.Time
+	}{
+		{
+			name: "no state file configured",
+			path: func(t *testing.T) string { return "" },
+			want: now.Add(-5 * time.Minute),
+		},
+		{
+			name: "missing state file",
+			path: func(t *testing.T) string { return filepath.Join(dir, "missing.json") },
+			want: now.Add(-5 * time.Minute),
+		},
+		{
+			name: "resume from persisted last check",
+			path: func(t *testing.T) string { return writeState(t, `{"last_check":"2024-06-02T11:00:00Z"}`) },
+			want: now.Add(-time.Hour),
+		},
+		{
+			name: "persisted last check capped to max lookback",
+			path: func(t *testing.T) string { return writeState(t, `{"last_check":"2024-05-01T00:00:00Z"}`) },
+			want: now.Add(-24 * time.Hour),
+		},
+		{
+			name: "persisted last check in the future",
+			path: func(t *testing.T) string { return writeState(t, `{"last_check":"2024-06-03T00:00:00Z"}`) },
+			want: now.Add(-5 * time.Minute),
+		},
+		{
+			name: "corrupt state file",
+			path: func(t *testing.T) string { return writeState(t, `{"last_check":`) },
+			want: now.Add(-5 * time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := loadLastCheck(tt.path(t), now, 5*time.Minute, 24*time.Hour)
+			if !got.Equal(tt.want) {
+				t.Errorf("loadLastCheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginFailuresCollector_saveLastCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "login_failures.json")
+	lastCheck := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	c := &LoginFailuresCollector{StateFile: path, lastCheck: lastCheck}
+	if err := c.saveLastCheck(); err != nil {
+		t.Fatalf("saveLastCheck() error = %v", err)
+	}
+
+	restored := NewLoginFailuresCollector(false, false, nil, time.Minute, 24*time.Hour, path).(*LoginFailuresCollector)
+	if !restored.lastCheck.Equal(lastCheck) {
+		t.Errorf("restored lastCheck = %v, want %v", restored.lastCheck, lastCheck)
+	}
+}
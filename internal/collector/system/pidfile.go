@@ -0,0 +1,114 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// pidExists and processName are variables to allow mocking process lookups in tests
+var (
+	pidExists   = process.PidExists
+	processName = func(pid int32) (string, error) {
+		proc, err := process.NewProcess(pid)
+		if err != nil {
+			return "", err
+		}
+		return proc.Name()
+	}
+)
+
+// PIDFileCollector implements the collector.Collector interface for PID file liveness metrics
+type PIDFileCollector struct {
+	Files []config.PIDFile
+}
+
+// NewPIDFileCollector creates a new instance of PIDFileCollector
+func NewPIDFileCollector(files []config.PIDFile) collector.Collector {
+	return &PIDFileCollector{
+		Files: files,
+	}
+}
+
+// Collect checks whether the process referenced by each configured PID file is alive
+func (c *PIDFileCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, len(c.Files))
+	now := time.Now()
+
+	for _, f := range c.Files {
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NamePIDFile,
+			Metadata: collector.MetricMetadata{
+				"pidfile": f.PIDFile,
+				"label":   f.Label,
+			},
+			Value: checkPIDFile(f),
+		})
+	}
+
+	return metrics, nil
+}
+
+// checkPIDFile reads the PID from a PID file and checks that the process exists and, if configured,
+// has the expected name. stale is set when the file exists but its process doesn't
+func checkPIDFile(f config.PIDFile) map[string]interface{} {
+	data, err := os.ReadFile(f.PIDFile)
+	if err != nil {
+		value := map[string]interface{}{
+			"running": false,
+			"stale":   false,
+		}
+		if !os.IsNotExist(err) {
+			value["error"] = fmt.Sprintf("failed to read PID file: %v", err)
+		}
+		return value
+	}
+
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil || pid <= 0 {
+		return map[string]interface{}{
+			"running": false,
+			"stale":   true,
+			"error":   "PID file does not contain a valid PID",
+		}
+	}
+
+	value := map[string]interface{}{
+		"pid": int32(pid),
+	}
+	running, err := pidExists(int32(pid))
+	if errors.Is(err, os.ErrPermission) {
+		// The process exists but belongs to another user
+		running = true
+	} else if err != nil {
+		// Whether the process is alive is unknown, so the PID file isn't reported as stale
+		value["running"] = false
+		value["stale"] = false
+		value["error"] = fmt.Sprintf("failed to check process: %v", err)
+		return value
+	}
+	// A PID reused by another program counts as a dead process
+	if running && f.ProcessName != "" {
+		name, err := processName(int32(pid))
+		if err != nil {
+			// Whether the PID still belongs to the expected program is unknown, so it isn't reported as stale
+			value["running"] = false
+			value["stale"] = false
+			value["error"] = fmt.Sprintf("failed to get process name: %v", err)
+			return value
+		}
+		running = name == f.ProcessName
+	}
+	value["running"] = running
+	value["stale"] = !running
+	return value
+}
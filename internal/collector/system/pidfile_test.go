@@ -0,0 +1,122 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestPIDFileCollector_Collect(t *testing.T) {
+	originalPidExists, originalProcessName := pidExists, processName
+	defer func() { pidExists, processName = originalPidExists, originalProcessName }()
+
+	// PID 100 runs haproxy, PID 200 runs something else, PID 400 runs haproxy as another user,
+	// checking PID 500 fails, the name of PID 600 can't be read and other PIDs don't exist
+	pidExists = func(pid int32) (bool, error) {
+		switch pid {
+		case 400:
+			return false, os.NewSyscallError("kill", syscall.EPERM)
+		case 500:
+			return false, errors.New("proc not mounted")
+		}
+		return pid == 100 || pid == 200 || pid == 600, nil
+	}
+	processName = func(pid int32) (string, error) {
+		if pid == 100 || pid == 400 {
+			return "haproxy", nil
+		}
+		if pid == 200 {
+			return "bash", nil
+		}
+		if pid == 600 {
+			return "", errors.New("status unreadable")
+		}
+		return "", errors.New("process not found")
+	}
+
+	dir := t.TempDir()
+	writePIDFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write PID file: %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name string
+		file config.PIDFile
+		want map[string]interface{}
+	}{
+		{
+			name: "running",
+			file: config.PIDFile{PIDFile: writePIDFile("running.pid", "100\n")},
+			want: map[string]interface{}{"pid": int32(100), "running": true, "stale": false},
+		},
+		{
+			name: "running with expected name",
+			file: config.PIDFile{PIDFile: writePIDFile("named.pid", "100"), ProcessName: "haproxy"},
+			want: map[string]interface{}{"pid": int32(100), "running": true, "stale": false},
+		},
+		{
+			name: "PID reused by another process",
+			file: config.PIDFile{PIDFile: writePIDFile("reused.pid", "200\n"), ProcessName: "haproxy"},
+			want: map[string]interface{}{"pid": int32(200), "running": false, "stale": true},
+		},
+		{
+			name: "stale",
+			file: config.PIDFile{PIDFile: writePIDFile("stale.pid", "300\n")},
+			want: map[string]interface{}{"pid": int32(300), "running": false, "stale": true},
+		},
+		{
+			name: "process owned by another user",
+			file: config.PIDFile{PIDFile: writePIDFile("other-user.pid", "400\n"), ProcessName: "haproxy"},
+			want: map[string]interface{}{"pid": int32(400), "running": true, "stale": false},
+		},
+		{
+			name: "process check fails",
+			file: config.PIDFile{PIDFile: writePIDFile("unknown.pid", "500\n")},
+			want: map[string]interface{}{"pid": int32(500), "running": false, "stale": false, "error": "failed to check process: proc not mounted"},
+		},
+		{
+			name: "process name check fails",
+			file: config.PIDFile{PIDFile: writePIDFile("unnamed.pid", "600\n"), ProcessName: "haproxy"},
+			want: map[string]interface{}{"pid": int32(600), "running": false, "stale": false, "error": "failed to get process name: status unreadable"},
+		},
+		{
+			name: "missing PID file",
+			file: config.PIDFile{PIDFile: filepath.Join(dir, "missing.pid")},
+			want: map[string]interface{}{"running": false, "stale": false},
+		},
+		{
+			name: "invalid PID",
+			file: config.PIDFile{PIDFile: writePIDFile("invalid.pid", "not-a-pid\n")},
+			want: map[string]interface{}{"running": false, "stale": true, "error": "PID file does not contain a valid PID"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.file.Label = "Daemon"
+			metrics, err := NewPIDFileCollector([]config.PIDFile{tt.file}).Collect()
+			if err != nil {
+				t.Fatalf("PIDFileCollector.Collect() error = %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].Name != collector.NamePIDFile {
+				t.Fatalf("PIDFileCollector.Collect() returned unexpected metrics: %+v", metrics)
+			}
+			if metrics[0].Metadata["pidfile"] != tt.file.PIDFile || metrics[0].Metadata["label"] != "Daemon" {
+				t.Errorf("PIDFileCollector.Collect() metadata = %v", metrics[0].Metadata)
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("PIDFileCollector.Collect() value = %v, want %v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
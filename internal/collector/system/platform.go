@@ -0,0 +1,7 @@
+package system
+
+import "runtime"
+
+// goos is the operating system the probe runs on
+// It is a variable to allow testing the platform support of collectors
+var goos = runtime.GOOS
@@ -0,0 +1,36 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestCollectorsSupported(t *testing.T) {
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
+
+	tests := []struct {
+		name      string
+		collector collector.Collector
+		want      map[string]bool // Support by operating system
+	}{
+		{name: "user activity", collector: NewUserActivityCollector(false), want: map[string]bool{"linux": true, "darwin": true, "windows": false}},
+		{name: "login failures", collector: NewLoginFailuresCollector(false, false, nil, 0, 0, ""), want: map[string]bool{"linux": true, "darwin": false, "windows": false}},
+		{name: "systemd jobs", collector: NewSystemdJobsCollector(), want: map[string]bool{"linux": true, "darwin": false, "windows": false}},
+		{name: "inotify", collector: NewInotifyCollector(), want: map[string]bool{"linux": true, "darwin": false, "windows": false}},
+		{name: "cpu", collector: NewCPUCollector(collector.DefaultPrecision), want: map[string]bool{"linux": true, "darwin": true, "windows": true}},
+		{name: "uptime", collector: NewUptimeCollector(), want: map[string]bool{"linux": true, "darwin": true, "windows": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for platform, want := range tt.want {
+				goos = platform
+				if got := collector.Supported(tt.collector); got != want {
+					t.Errorf("Supported() on %s = %v, want %v", platform, got, want)
+				}
+			}
+		})
+	}
+}
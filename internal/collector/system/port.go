@@ -5,16 +5,23 @@ import (
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
 	"github.com/shirou/gopsutil/v4/net"
 	"github.com/shirou/gopsutil/v4/process"
 )
 
 // PortCollector implements the collector.Collector interface for port monitoring metrics
-type PortCollector struct{}
+type PortCollector struct {
+	ExpectedOpen   []config.ExpectedPort // Ports that should have a listener
+	ExpectedClosed []config.ExpectedPort // Ports that should not have a listener
+}
 
 // NewPortCollector creates a new instance of PortCollector
-func NewPortCollector() collector.Collector {
-	return &PortCollector{}
+func NewPortCollector(expectedOpen, expectedClosed []config.ExpectedPort) collector.Collector {
+	return &PortCollector{
+		ExpectedOpen:   expectedOpen,
+		ExpectedClosed: expectedClosed,
+	}
 }
 
 // PortInfo represents information about an open port and its process
@@ -47,9 +54,71 @@ func (c *PortCollector) Collect() ([]collector.Metrics, error) {
 		Value:     ports,
 	})
 
+	metrics = append(metrics, c.checkExpectedPorts(ports, now)...)
+
 	return metrics, nil
 }
 
+// checkExpectedPorts compares the listening ports against the expectations, reporting one
+// metric per expected port flagged with deviation when a port that should be open has no
+// listener or a port that should be closed has one
+func (c *PortCollector) checkExpectedPorts(ports []PortInfo, now time.Time) []collector.Metrics {
+	if len(c.ExpectedOpen) == 0 && len(c.ExpectedClosed) == 0 {
+		return nil
+	}
+
+	listening := make(map[string]bool)
+	for _, p := range ports {
+		if isListening(p) {
+			listening[fmt.Sprintf("%s/%d", p.Protocol, p.LocalPort)] = true
+		}
+	}
+
+	metrics := make([]collector.Metrics, 0, len(c.ExpectedOpen)+len(c.ExpectedClosed))
+	check := func(expected []config.ExpectedPort, wantOpen bool) {
+		for _, e := range expected {
+			protocol := e.Protocol
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			open := listening[fmt.Sprintf("%s/%d", protocol, e.Port)]
+
+			metadata := collector.MetricMetadata{
+				"protocol": protocol,
+				"port":     fmt.Sprintf("%d", e.Port),
+				"expected": "closed",
+			}
+			if wantOpen {
+				metadata["expected"] = "open"
+			}
+
+			metrics = append(metrics, collector.Metrics{
+				Timestamp: now,
+				Category:  collector.CategorySystem,
+				Name:      collector.NamePortDrift,
+				Metadata:  metadata,
+				Value: map[string]interface{}{
+					"listening": open,
+					"deviation": open != wantOpen,
+				},
+			})
+		}
+	}
+	check(c.ExpectedOpen, true)
+	check(c.ExpectedClosed, false)
+
+	return metrics
+}
+
+// isListening reports whether a socket accepts connections: a listening TCP socket or an
+// unconnected UDP socket
+func isListening(p PortInfo) bool {
+	if p.Protocol == "udp" {
+		return p.RemotePort == 0
+	}
+	return p.Status == "LISTEN"
+}
+
 // getOpenPorts retrieves all open TCP and UDP ports with their associated processes
 func (c *PortCollector) getOpenPorts() ([]PortInfo, error) {
 	var allPorts []PortInfo
@@ -0,0 +1,77 @@
+package system
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// PortCheckCollector implements the collector.Collector interface for remote TCP port reachability
+type PortCheckCollector struct {
+	Targets []config.PortTarget
+}
+
+// NewPortCheckCollector creates a new instance of PortCheckCollector
+func NewPortCheckCollector(targets []config.PortTarget) collector.Collector {
+	return &PortCheckCollector{
+		Targets: targets,
+	}
+}
+
+// Collect dials each configured target and reports whether it's reachable and the connect latency
+// Refused connections and dial timeouts are reported as reachable=false instead of aborting the collection
+func (c *PortCheckCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, len(c.Targets))
+	now := time.Now()
+
+	for _, target := range c.Targets {
+		metadata := collector.MetricMetadata{
+			"address": target.Address,
+			"label":   target.Label,
+		}
+
+		value, err := checkPort(target)
+		if err != nil {
+			metadata["error"] = err.Error()
+		}
+
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NamePortCheck,
+			Metadata:  metadata,
+			Value:     value,
+		})
+	}
+
+	return metrics, nil
+}
+
+// checkPort opens a TCP connection to the target within its timeout and closes it right away
+func checkPort(target config.PortTarget) (map[string]interface{}, error) {
+	value := map[string]interface{}{
+		"reachable":  false,
+		"latency_ms": int64(0),
+	}
+
+	timeout := target.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", target.Address, timeout)
+	latency := time.Since(start)
+	if err != nil {
+		return value, fmt.Errorf("dial failed: %w", err)
+	}
+	conn.Close()
+
+	value["reachable"] = true
+	value["latency_ms"] = latency.Milliseconds()
+
+	return value, nil
+}
@@ -0,0 +1,107 @@
+package system
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestNewPortCheckCollector(t *testing.T) {
+	targets := []config.PortTarget{{Address: "db.internal:5432", Label: "db"}}
+
+	c := NewPortCheckCollector(targets)
+
+	pc, ok := c.(*PortCheckCollector)
+	if !ok {
+		t.Fatalf("NewPortCheckCollector() returned wrong type: %T", c)
+	}
+	if len(pc.Targets) != 1 {
+		t.Errorf("NewPortCheckCollector() = %+v, want 1 target", pc)
+	}
+}
+
+func TestPortCheckCollector_Collect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// Grab a free port and release it so nothing is listening there
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name          string
+		target        config.PortTarget
+		wantReachable bool
+		wantError     bool
+	}{
+		{
+			name:          "reachable port",
+			target:        config.PortTarget{Address: listener.Addr().String(), Label: "open", Timeout: time.Second},
+			wantReachable: true,
+		},
+		{
+			name:          "refused port",
+			target:        config.PortTarget{Address: closedAddr, Label: "closed", Timeout: time.Second},
+			wantReachable: false,
+			wantError:     true,
+		},
+		{
+			name:          "dial timeout",
+			target:        config.PortTarget{Address: listener.Addr().String(), Label: "timeout", Timeout: time.Nanosecond},
+			wantReachable: false,
+			wantError:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewPortCheckCollector([]config.PortTarget{tt.target})
+
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v, want nil", err)
+			}
+			if len(metrics) != 1 {
+				t.Fatalf("Collect() returned %d metrics, want 1", len(metrics))
+			}
+
+			m := metrics[0]
+			if m.Name != collector.NamePortCheck || m.Category != collector.CategorySystem {
+				t.Errorf("unexpected metric name/category: %s/%s", m.Category, m.Name)
+			}
+			if m.Metadata["address"] != tt.target.Address || m.Metadata["label"] != tt.target.Label {
+				t.Errorf("unexpected metadata: %v", m.Metadata)
+			}
+			if _, hasError := m.Metadata["error"]; hasError != tt.wantError {
+				t.Errorf("metadata error present = %v, want %v (%v)", hasError, tt.wantError, m.Metadata["error"])
+			}
+
+			value := m.Value.(map[string]interface{})
+			if value["reachable"] != tt.wantReachable {
+				t.Errorf("reachable = %v, want %v", value["reachable"], tt.wantReachable)
+			}
+			if _, ok := value["latency_ms"].(int64); !ok {
+				t.Errorf("latency_ms = %v, want an int64", value["latency_ms"])
+			}
+		})
+	}
+}
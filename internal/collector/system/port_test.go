@@ -2,8 +2,10 @@ package system
 
 import (
 	"testing"
+	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
 )
 
 func TestPortCollector_Collect(t *testing.T) {
@@ -145,3 +147,85 @@ func BenchmarkPortCollector_Collect(b *testing.B) {
 		}
 	}
 }
+
+func TestPortCollector_checkExpectedPorts(t *testing.T) {
+	ports := []PortInfo{
+		{Protocol: "tcp", LocalAddr: "0.0.0.0", LocalPort: 22, Status: "LISTEN"},
+		{Protocol: "tcp", LocalAddr: "10.0.0.5", LocalPort: 5432, RemoteAddr: "10.0.0.9", RemotePort: 41000, Status: "ESTABLISHED"},
+		{Protocol: "tcp", LocalAddr: "::", LocalPort: 6379, Status: "LISTEN"},
+		{Protocol: "udp", LocalAddr: "0.0.0.0", LocalPort: 53},
+		{Protocol: "udp", LocalAddr: "10.0.0.5", LocalPort: 40000, RemoteAddr: "10.0.0.1", RemotePort: 123},
+	}
+
+	tests := []struct {
+		name           string
+		expectedOpen   []config.ExpectedPort
+		expectedClosed []config.ExpectedPort
+		want           []map[string]interface{} // protocol, port, expected, listening, deviation
+	}{
+		{
+			name: "no expectations",
+		},
+		{
+			name:         "expected open ports listening",
+			expectedOpen: []config.ExpectedPort{{Port: 22}, {Port: 53, Protocol: "udp"}},
+			want: []map[string]interface{}{
+				{"protocol": "tcp", "port": "22", "expected": "open", "listening": true, "deviation": false},
+				{"protocol": "udp", "port": "53", "expected": "open", "listening": true, "deviation": false},
+			},
+		},
+		{
+			name:         "expected open port without listener",
+			expectedOpen: []config.ExpectedPort{{Port: 5432}, {Port: 53}},
+			want: []map[string]interface{}{
+				{"protocol": "tcp", "port": "5432", "expected": "open", "listening": false, "deviation": true},
+				{"protocol": "tcp", "port": "53", "expected": "open", "listening": false, "deviation": true},
+			},
+		},
+		{
+			name:           "expected closed port with listener",
+			expectedClosed: []config.ExpectedPort{{Port: 6379}},
+			want: []map[string]interface{}{
+				{"protocol": "tcp", "port": "6379", "expected": "closed", "listening": true, "deviation": true},
+			},
+		},
+		{
+			name:           "expected closed ports without listener",
+			expectedClosed: []config.ExpectedPort{{Port: 3306}, {Port: 40000, Protocol: "udp"}},
+			want: []map[string]interface{}{
+				{"protocol": "tcp", "port": "3306", "expected": "closed", "listening": false, "deviation": false},
+				{"protocol": "udp", "port": "40000", "expected": "closed", "listening": false, "deviation": false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewPortCollector(tt.expectedOpen, tt.expectedClosed).(*PortCollector)
+			now := time.Now()
+
+			metrics := c.checkExpectedPorts(ports, now)
+			if len(metrics) != len(tt.want) {
+				t.Fatalf("checkExpectedPorts() returned %d metrics, want %d", len(metrics), len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				m := metrics[i]
+				if m.Name != collector.NamePortDrift || !m.Timestamp.Equal(now) {
+					t.Errorf("metric %d: name = %s, timestamp = %v", i, m.Name, m.Timestamp)
+				}
+				for _, key := range []string{"protocol", "port", "expected"} {
+					if m.Metadata[key] != want[key] {
+						t.Errorf("metric %d: metadata %s = %v, want %v", i, key, m.Metadata[key], want[key])
+					}
+				}
+				value := m.Value.(map[string]interface{})
+				for _, key := range []string{"listening", "deviation"} {
+					if value[key] != want[key] {
+						t.Errorf("metric %d: %s = %v, want %v", i, key, value[key], want[key])
+					}
+				}
+			}
+		})
+	}
+}
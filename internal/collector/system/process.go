@@ -0,0 +1,144 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// numFDs is a variable to allow mocking file descriptor counts in tests
+var numFDs = (*process.Process).NumFDs
+
+// ProcessCollector implements the collector.Collector interface for per-process resource metrics
+// The open file descriptors of processes owned by other users can only be counted as root, without
+// root or after dropping privileges they are flagged with open_fds_unreadable instead
+type ProcessCollector struct {
+	Match        []string
+	MaxProcesses int
+	Precision    int // Decimal places of the reported CPU usage
+
+	mu      sync.Mutex
+	tracked map[int32]*process.Process // Processes reported by the last collection, holding their last CPU times
+}
+
+// NewProcessCollector creates a new instance of ProcessCollector
+func NewProcessCollector(match []string, maxProcesses, precision int) collector.Collector {
+	return &ProcessCollector{
+		Match:        match,
+		MaxProcesses: maxProcesses,
+		Precision:    precision,
+	}
+}
+
+// Collect gathers CPU, memory and file descriptor usage for processes matching the configured patterns
+// CPU usage is measured since the previous collection, so it is only reported from a process's second sample
+func (c *ProcessCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0)
+	now := time.Now()
+
+	procs, err := process.Processes()
+	if err != nil {
+		return metrics, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tracked := make(map[int32]*process.Process)
+	defer func() { c.tracked = tracked }()
+
+	for _, proc := range procs {
+		if c.MaxProcesses > 0 && len(metrics) >= c.MaxProcesses {
+			break
+		}
+
+		// Any error here most likely means the process exited after enumeration, skip it
+		name, err := proc.Name()
+		if err != nil || !c.matches(name) {
+			continue
+		}
+
+		proc = c.track(proc)
+		value, err := c.getProcessUsage(proc)
+		if err != nil {
+			continue
+		}
+		tracked[proc.Pid] = proc
+
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameProcess,
+			Metadata: collector.MetricMetadata{
+				"pid":  fmt.Sprintf("%d", proc.Pid),
+				"name": name,
+			},
+			Value: value,
+		})
+	}
+
+	return metrics, nil
+}
+
+// matches reports whether a process name matches any of the configured patterns
+func (c *ProcessCollector) matches(name string) bool {
+	for _, pattern := range c.Match {
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// track returns the process reported by the last collection for proc's PID, which holds its CPU
+// times from then, or proc itself if it is new or the PID was reused by another process
+func (c *ProcessCollector) track(proc *process.Process) *process.Process {
+	prev, ok := c.tracked[proc.Pid]
+	if !ok {
+		return proc
+	}
+	prevCreated, err := prev.CreateTime()
+	if err != nil {
+		return proc
+	}
+	if created, err := proc.CreateTime(); err != nil || created != prevCreated {
+		return proc
+	}
+	return prev
+}
+
+// getProcessUsage retrieves resource usage for a single process
+// The first call for a process only records its CPU times, cpu_percent is left out until the next one
+func (c *ProcessCollector) getProcessUsage(proc *process.Process) (map[string]interface{}, error) {
+	seen := c.tracked[proc.Pid] == proc
+	cpuPercent, err := proc.Percent(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CPU percent for PID %d: %w", proc.Pid, err)
+	}
+
+	memInfo, err := proc.MemoryInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memory info for PID %d: %w", proc.Pid, err)
+	}
+
+	value := map[string]interface{}{
+		"rss_bytes": memInfo.RSS,
+	}
+	if seen {
+		value["cpu_percent"] = collector.RoundTo(cpuPercent, c.Precision)
+	}
+
+	// Open file descriptors may not be readable for processes owned by other users
+	if fds, err := numFDs(proc); err == nil {
+		value["open_fds"] = fds
+	} else if errors.Is(err, os.ErrPermission) {
+		value["open_fds_unreadable"] = true
+	}
+
+	return value, nil
+}
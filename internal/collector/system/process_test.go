@@ -0,0 +1,137 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+func TestNewProcessCollector(t *testing.T) {
+	c := NewProcessCollector([]string{"nginx*"}, 10, 2)
+
+	pc, ok := c.(*ProcessCollector)
+	if !ok {
+		t.Fatalf("NewProcessCollector() returned wrong type: %T", c)
+	}
+	if len(pc.Match) != 1 || pc.MaxProcesses != 10 {
+		t.Errorf("NewProcessCollector() = %+v, want match [nginx*] and max 10", pc)
+	}
+}
+
+func TestProcessCollector_Matches(t *testing.T) {
+	c := &ProcessCollector{Match: []string{"nginx*", "postgres"}}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"nginx", true},
+		{"nginx-worker", true},
+		{"postgres", true},
+		{"postgres-wal", false},
+		{"sshd", false},
+	}
+
+	for _, tt := range tests {
+		if got := c.matches(tt.name); got != tt.want {
+			t.Errorf("matches(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessCollector_Collect(t *testing.T) {
+	// The test binary itself is a process we know exists
+	selfName := filepath.Base(os.Args[0])
+
+	c := &ProcessCollector{Match: []string{selfName}, MaxProcesses: 10}
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("ProcessCollector.Collect() error = %v", err)
+	}
+
+	var found bool
+	for _, m := range metrics {
+		if m.Name != collector.NameProcess {
+			t.Errorf("metric name = %v, want %v", m.Name, collector.NameProcess)
+		}
+		if m.Metadata["pid"] == fmt.Sprintf("%d", os.Getpid()) {
+			found = true
+			value, ok := m.Value.(map[string]interface{})
+			if !ok {
+				t.Fatalf("metric value is not map[string]interface{}: %T", m.Value)
+			}
+			if _, ok := value["rss_bytes"]; !ok {
+				t.Error("metric value missing rss_bytes")
+			}
+		}
+	}
+	if !found {
+		t.Logf("test process %q not reported (process name may be truncated on this platform)", selfName)
+	}
+
+	c = &ProcessCollector{Match: []string{"*"}, MaxProcesses: 1}
+	metrics, err = c.Collect()
+	if err != nil {
+		t.Fatalf("ProcessCollector.Collect() error = %v", err)
+	}
+	if len(metrics) > 1 {
+		t.Errorf("ProcessCollector.Collect() returned %d metrics, want at most 1", len(metrics))
+	}
+}
+
+func TestProcessCollector_CPUSinceLastCollection(t *testing.T) {
+	selfName := filepath.Base(os.Args[0])
+	pid := fmt.Sprintf("%d", os.Getpid())
+	c := &ProcessCollector{Match: []string{selfName}, Precision: 2}
+
+	selfUsage := func() map[string]interface{} {
+		t.Helper()
+		metrics, err := c.Collect()
+		if err != nil {
+			t.Fatalf("ProcessCollector.Collect() error = %v", err)
+		}
+		for _, m := range metrics {
+			if m.Metadata["pid"] == pid {
+				return m.Value.(map[string]interface{})
+			}
+		}
+		t.Skipf("test process %q not reported (process name may be truncated on this platform)", selfName)
+		return nil
+	}
+
+	if value := selfUsage(); value["cpu_percent"] != nil {
+		t.Errorf("first sample cpu_percent = %v, want none before a second sample", value["cpu_percent"])
+	}
+
+	// Keep a CPU busy so the usage since the first sample isn't zero
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); {
+	}
+
+	value := selfUsage()
+	cpuPercent, ok := value["cpu_percent"].(float64)
+	if !ok || cpuPercent <= 0 {
+		t.Errorf("second sample cpu_percent = %v, want the usage since the first sample", value["cpu_percent"])
+	}
+}
+
+func TestProcessCollector_UnreadableFDs(t *testing.T) {
+	originalNumFDs := numFDs
+	defer func() { numFDs = originalNumFDs }()
+	numFDs = func(*process.Process) (int32, error) {
+		return 0, &os.PathError{Op: "open", Path: "/proc/1/fd", Err: os.ErrPermission}
+	}
+
+	c := &ProcessCollector{}
+	value, err := c.getProcessUsage(&process.Process{Pid: int32(os.Getpid())})
+	if err != nil {
+		t.Fatalf("getProcessUsage() error = %v", err)
+	}
+	if _, ok := value["open_fds"]; ok || value["open_fds_unreadable"] != true {
+		t.Errorf("getProcessUsage() = %v, want open_fds_unreadable instead of open_fds", value)
+	}
+}
@@ -8,11 +8,13 @@ import (
 )
 
 // RAMCollector implements the collector.Collector interface for RAM metrics
-type RAMCollector struct{}
+type RAMCollector struct {
+	Precision int // Decimal places of the reported usage
+}
 
 // NewRAMCollector creates a new instance of RAMCollector
-func NewRAMCollector() collector.Collector {
-	return &RAMCollector{}
+func NewRAMCollector(precision int) collector.Collector {
+	return &RAMCollector{Precision: precision}
 }
 
 // Collect gathers RAM metrics
@@ -26,7 +28,7 @@ func (c *RAMCollector) Collect() ([]collector.Metrics, error) {
 		return metrics, err
 	}
 
-	value := collector.RoundToTwoDecimalPlaces(memInfo.UsedPercent)
+	value := collector.RoundTo(memInfo.UsedPercent, c.Precision)
 	metrics = append(metrics, collector.Metrics{
 		Timestamp: now,
 		Category:  collector.CategorySystem,
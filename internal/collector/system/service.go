@@ -1,9 +1,15 @@
 package system
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/coreos/go-systemd/v22/dbus"
 	"github.com/monitorly-app/probe/internal/collector"
 	"github.com/monitorly-app/probe/internal/config"
 )
@@ -11,15 +17,82 @@ import (
 // execCommand is a variable to allow mocking exec.Command in tests
 var execCommand = exec.Command
 
+// unitStateClient abstracts the systemd D-Bus API used to query unit states
+type unitStateClient interface {
+	// UnitState returns the ActiveState and SubState of a unit
+	UnitState(ctx context.Context, unit string) (string, string, error)
+	// UnitAccounting returns the MemoryCurrent and CPUUsageNSec of a unit,
+	// set to accountingNotSet when accounting is disabled for the unit
+	UnitAccounting(ctx context.Context, unit string) (uint64, uint64, error)
+	Close()
+}
+
+// accountingNotSet is the value systemd reports for accounting properties that aren't tracked
+const accountingNotSet = math.MaxUint64
+
+// newUnitStateClient is a variable to allow mocking the systemd D-Bus connection in tests
+var newUnitStateClient = newDBusUnitStateClient
+
+// dbusUnitStateClient implements unitStateClient on top of a systemd D-Bus connection
+type dbusUnitStateClient struct {
+	conn *dbus.Conn
+}
+
+// newDBusUnitStateClient connects to the systemd D-Bus API
+func newDBusUnitStateClient(ctx context.Context) (unitStateClient, error) {
+	conn, err := dbus.NewWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to systemd D-Bus: %w", err)
+	}
+	return &dbusUnitStateClient{conn: conn}, nil
+}
+
+// UnitState returns the ActiveState and SubState of a unit
+func (c *dbusUnitStateClient) UnitState(ctx context.Context, unit string) (string, string, error) {
+	props, err := c.conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get properties for unit %s: %w", unit, err)
+	}
+
+	activeState, _ := props["ActiveState"].(string)
+	subState, _ := props["SubState"].(string)
+	return activeState, subState, nil
+}
+
+// UnitAccounting returns the MemoryCurrent and CPUUsageNSec of a unit
+func (c *dbusUnitStateClient) UnitAccounting(ctx context.Context, unit string) (uint64, uint64, error) {
+	props, err := c.conn.GetUnitTypePropertiesContext(ctx, unit, unitType(unit))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get accounting properties for unit %s: %w", unit, err)
+	}
+
+	memory, ok := props["MemoryCurrent"].(uint64)
+	if !ok {
+		memory = accountingNotSet
+	}
+	cpu, ok := props["CPUUsageNSec"].(uint64)
+	if !ok {
+		cpu = accountingNotSet
+	}
+	return memory, cpu, nil
+}
+
+// Close closes the D-Bus connection
+func (c *dbusUnitStateClient) Close() {
+	c.conn.Close()
+}
+
 // ServiceCollector implements the collector.Collector interface for service metrics
 type ServiceCollector struct {
-	Services []config.Service
+	Services   []config.Service
+	Accounting bool // Report per-unit memory and CPU usage when systemd accounting is enabled
 }
 
 // NewServiceCollector creates a new instance of ServiceCollector
-func NewServiceCollector(services []config.Service) collector.Collector {
+func NewServiceCollector(services []config.Service, accounting bool) collector.Collector {
 	return &ServiceCollector{
-		Services: services,
+		Services:   services,
+		Accounting: accounting,
 	}
 }
 
@@ -42,27 +115,122 @@ func (c *ServiceCollector) checkServiceStatusSysV(serviceName string) bool {
 	return cmd.Run() == nil
 }
 
+// checkServiceStatusExec checks if a service is running by shelling out to systemctl or SysV scripts
+func (c *ServiceCollector) checkServiceStatusExec(serviceName string) bool {
+	// Check if systemctl exists
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		return checkServiceStatusSystemd(serviceName)
+	}
+
+	// Fallback to SysV init
+	return c.checkServiceStatusSysV(serviceName)
+}
+
+// checkServiceAccountingExec reads a unit's accounting properties with systemctl show
+func checkServiceAccountingExec(unit string) (uint64, uint64, error) {
+	output, err := execCommand("systemctl", "show", "-p", "MemoryCurrent,CPUUsageNSec", unit).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to run systemctl show for unit %s: %w", unit, err)
+	}
+
+	memory, cpu := uint64(accountingNotSet), uint64(accountingNotSet)
+	for _, line := range strings.Split(string(output), "\n") {
+		key, val, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		// Unset properties are reported as "[not set]" and fail to parse
+		parsed, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "MemoryCurrent":
+			memory = parsed
+		case "CPUUsageNSec":
+			cpu = parsed
+		}
+	}
+	return memory, cpu, nil
+}
+
+// addAccountingFields adds memory and CPU usage to a service value,
+// omitting the fields that systemd doesn't track for the unit
+func addAccountingFields(value map[string]interface{}, memory, cpu uint64) {
+	if memory != accountingNotSet {
+		value["memory_bytes"] = memory
+	}
+	if cpu != accountingNotSet {
+		value["cpu_usage_seconds"] = float64(cpu) / float64(time.Second)
+	}
+}
+
+// unitType returns the D-Bus interface suffix for a unit, e.g. "Service" for nginx.service
+func unitType(unit string) string {
+	ext := unit[strings.LastIndex(unit, ".")+1:]
+	if ext == "" {
+		return "Service"
+	}
+	return strings.ToUpper(ext[:1]) + ext[1:]
+}
+
+// unitName returns the systemd unit name for a service, adding the .service suffix if missing
+func unitName(service string) string {
+	if strings.Contains(service, ".") {
+		return service
+	}
+	return service + ".service"
+}
+
+// Supported reports whether the host runs Linux, where services are systemd units
+func (c *ServiceCollector) Supported() bool {
+	return goos == "linux"
+}
+
 // Collect gathers service status metrics
 func (c *ServiceCollector) Collect() ([]collector.Metrics, error) {
 	metrics := make([]collector.Metrics, 0, len(c.Services))
 	now := time.Now()
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Prefer systemd over D-Bus, fall back to the exec based checks when it's unavailable
+	client, err := newUnitStateClient(ctx)
+	if err != nil {
+		client = nil
+	} else {
+		defer client.Close()
+	}
+
 	for _, service := range c.Services {
-		// Try systemd first, then fall back to SysV if systemd check fails
-		var isActive bool
-
-		// Check if systemctl exists
-		if _, err := exec.LookPath("systemctl"); err == nil {
-			isActive = checkServiceStatusSystemd(service.Name)
-		} else {
-			// Fallback to SysV init
-			isActive = c.checkServiceStatusSysV(service.Name)
+		value := map[string]interface{}{}
+
+		var queried bool
+		if client != nil {
+			if activeState, subState, err := client.UnitState(ctx, unitName(service.Name)); err == nil {
+				value["active"] = activeState == "active"
+				value["sub_state"] = subState
+				queried = true
+			}
+		}
+
+		if !queried {
+			value["active"] = c.checkServiceStatusExec(service.Name)
+			value["sub_state"] = "unknown"
 		}
 
-		// Convert status to float (0.0 = active, 1.0 = inactive)
-		status := 1.0
-		if isActive {
-			status = 0.0
+		if c.Accounting {
+			var memory, cpu uint64
+			var err error
+			if queried {
+				memory, cpu, err = client.UnitAccounting(ctx, unitName(service.Name))
+			} else {
+				memory, cpu, err = checkServiceAccountingExec(unitName(service.Name))
+			}
+			if err == nil {
+				addAccountingFields(value, memory, cpu)
+			}
 		}
 
 		metrics = append(metrics, collector.Metrics{
@@ -73,7 +241,7 @@ func (c *ServiceCollector) Collect() ([]collector.Metrics, error) {
 				"name":  service.Name,
 				"label": service.Label,
 			},
-			Value: status,
+			Value: value,
 		})
 	}
 
@@ -0,0 +1,133 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// ServiceRestart is the number of automatic restarts of a unit since it was loaded
+type ServiceRestart struct {
+	Unit      string `json:"unit"`
+	NRestarts uint64 `json:"n_restarts"`
+}
+
+// ServiceRestartsCollector implements the collector.Collector interface for systemd unit restart counts
+type ServiceRestartsCollector struct {
+	Units     []string // Units to check, all service units when empty
+	Threshold uint64   // Only units restarted more often than this are reported
+}
+
+// NewServiceRestartsCollector creates a new instance of ServiceRestartsCollector
+func NewServiceRestartsCollector(units []string, threshold uint64) collector.Collector {
+	return &ServiceRestartsCollector{
+		Units:     units,
+		Threshold: threshold,
+	}
+}
+
+// Supported reports whether the host runs Linux, where systemd counts unit restarts
+func (c *ServiceRestartsCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect gathers the units whose NRestarts is above the threshold, most restarted first
+func (c *ServiceRestartsCollector) Collect() ([]collector.Metrics, error) {
+	units := make([]string, 0, len(c.Units))
+	for _, unit := range c.Units {
+		units = append(units, unitName(unit))
+	}
+	if len(units) == 0 {
+		var err error
+		if units, err = listServiceUnits(); err != nil {
+			return nil, err
+		}
+	}
+
+	restarts := []ServiceRestart{}
+	if len(units) > 0 {
+		all, err := readRestartCounts(units)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range all {
+			if r.NRestarts > c.Threshold {
+				restarts = append(restarts, r)
+			}
+		}
+	}
+
+	sort.Slice(restarts, func(i, j int) bool {
+		if restarts[i].NRestarts != restarts[j].NRestarts {
+			return restarts[i].NRestarts > restarts[j].NRestarts
+		}
+		return restarts[i].Unit < restarts[j].Unit
+	})
+
+	return []collector.Metrics{
+		{
+			Timestamp: time.Now(),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameServiceRestarts,
+			Value:     restarts,
+		},
+	}, nil
+}
+
+// listServiceUnits returns the names of all service units known to systemd
+func listServiceUnits() ([]string, error) {
+	output, err := execCommand("systemctl", "list-units", "--type=service", "--all", "--plain", "--no-legend", "--no-pager").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service units: %w", err)
+	}
+
+	var units []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		units = append(units, fields[0])
+	}
+	return units, nil
+}
+
+// readRestartCounts reads NRestarts for the given units with a single systemctl show call
+// Units that don't exist or predate NRestarts (systemd < 235) are skipped
+func readRestartCounts(units []string) ([]ServiceRestart, error) {
+	args := append([]string{"show", "-p", "Id", "-p", "NRestarts", "--"}, units...)
+	output, err := execCommand("systemctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run systemctl show: %w", err)
+	}
+
+	// systemctl show separates the properties of each unit with a blank line
+	var restarts []ServiceRestart
+	for _, block := range strings.Split(string(output), "\n\n") {
+		var r ServiceRestart
+		var found bool
+		for _, line := range strings.Split(block, "\n") {
+			key, val, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Id":
+				r.Unit = val
+			case "NRestarts":
+				if n, err := strconv.ParseUint(val, 10, 64); err == nil {
+					r.NRestarts = n
+					found = true
+				}
+			}
+		}
+		if r.Unit != "" && found {
+			restarts = append(restarts, r)
+		}
+	}
+	return restarts, nil
+}
@@ -0,0 +1,105 @@
+package system
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestServiceRestartsCollector_Collect(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	const listOutput = "nginx.service loaded active running A high performance web server\n" +
+		"worker.service loaded activating auto-restart Queue worker\n" +
+		"cron.service loaded active running Regular background program processing daemon\n"
+	const showOutput = "Id=nginx.service\nNRestarts=2\n\n" +
+		"Id=worker.service\nNRestarts=17\n\n" +
+		"Id=cron.service\nNRestarts=0\n\n" +
+		"Id=missing.service\nNRestarts=[not set]\n"
+
+	tests := []struct {
+		name      string
+		units     []string
+		threshold uint64
+		wantArgs  []string // Units passed to systemctl show
+		want      []ServiceRestart
+	}{
+		{
+			name:     "all services",
+			wantArgs: []string{"nginx.service", "worker.service", "cron.service"},
+			want: []ServiceRestart{
+				{Unit: "worker.service", NRestarts: 17},
+				{Unit: "nginx.service", NRestarts: 2},
+			},
+		},
+		{
+			name:      "above threshold",
+			threshold: 2,
+			wantArgs:  []string{"nginx.service", "worker.service", "cron.service"},
+			want:      []ServiceRestart{{Unit: "worker.service", NRestarts: 17}},
+		},
+		{
+			name:     "configured units",
+			units:    []string{"nginx", "worker.service"},
+			wantArgs: []string{"nginx.service", "worker.service"},
+			want: []ServiceRestart{
+				{Unit: "worker.service", NRestarts: 17},
+				{Unit: "nginx.service", NRestarts: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shownUnits []string
+			execCommand = func(name string, args ...string) *exec.Cmd {
+				if args[0] == "list-units" {
+					return exec.Command("printf", "%s", listOutput)
+				}
+				for i, arg := range args {
+					if arg == "--" {
+						shownUnits = args[i+1:]
+					}
+				}
+				return exec.Command("printf", "%s", showOutput)
+			}
+
+			c := NewServiceRestartsCollector(tt.units, tt.threshold)
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if len(metrics) != 1 {
+				t.Fatalf("Collect() returned %d metrics, want 1", len(metrics))
+			}
+			if metrics[0].Name != collector.NameServiceRestarts {
+				t.Errorf("metric name = %v, want %v", metrics[0].Name, collector.NameServiceRestarts)
+			}
+			if !reflect.DeepEqual(shownUnits, tt.wantArgs) {
+				t.Errorf("systemctl show units = %v, want %v", shownUnits, tt.wantArgs)
+			}
+			got := metrics[0].Value.([]ServiceRestart)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Collect() value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceRestartsCollector_CollectError(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("false")
+	}
+
+	_, err := NewServiceRestartsCollector(nil, 0).Collect()
+	if err == nil || !strings.Contains(err.Error(), "failed to list service units") {
+		t.Errorf("Collect() error = %v, want list failure", err)
+	}
+}
@@ -1,7 +1,10 @@
 package system
 
 import (
+	"context"
+	"errors"
 	"os/exec"
+	"reflect"
 	"testing"
 
 	"github.com/monitorly-app/probe/internal/collector"
@@ -44,7 +47,7 @@ func TestNewServiceCollector(t *testing.T) {
 		},
 	}
 
-	c := NewServiceCollector(services)
+	c := NewServiceCollector(services, true)
 
 	if c == nil {
 		t.Errorf("NewServiceCollector() returned nil")
@@ -55,8 +58,11 @@ func TestNewServiceCollector(t *testing.T) {
 	var _ collector.Collector = c
 
 	// Test that it's the correct type
-	if _, ok := c.(*ServiceCollector); !ok {
+	sc, ok := c.(*ServiceCollector)
+	if !ok {
 		t.Errorf("NewServiceCollector() returned wrong type: %T", c)
+	} else if !sc.Accounting {
+		t.Error("NewServiceCollector() Accounting = false, want true")
 	}
 }
 
@@ -109,11 +115,244 @@ func TestServiceCollector_Collect(t *testing.T) {
 			t.Errorf("ServiceCollector.Collect() metric %d label metadata = %v, want %v", i, label, services[i].Label)
 		}
 
-		// Check that the value is a float64 (0.0 for active, 1.0 for inactive)
-		if value, ok := metric.Value.(float64); !ok {
-			t.Errorf("ServiceCollector.Collect() metric %d value is not float64: %T", i, metric.Value)
-		} else if value != 0.0 && value != 1.0 {
-			t.Errorf("ServiceCollector.Collect() metric %d value = %v, want 0.0 or 1.0", i, value)
+		// Check that the value reports the active flag and sub state
+		if value, ok := metric.Value.(map[string]interface{}); !ok {
+			t.Errorf("ServiceCollector.Collect() metric %d value is not map[string]interface{}: %T", i, metric.Value)
+		} else {
+			if _, ok := value["active"].(bool); !ok {
+				t.Errorf("ServiceCollector.Collect() metric %d active is not bool: %T", i, value["active"])
+			}
+			if _, ok := value["sub_state"].(string); !ok {
+				t.Errorf("ServiceCollector.Collect() metric %d sub_state is not string: %T", i, value["sub_state"])
+			}
+		}
+	}
+}
+
+// mockUnitStateClient implements unitStateClient for testing
+type mockUnitStateClient struct {
+	states     map[string][2]string
+	accounting map[string][2]uint64
+	closed     bool
+}
+
+func (m *mockUnitStateClient) UnitState(ctx context.Context, unit string) (string, string, error) {
+	state, ok := m.states[unit]
+	if !ok {
+		return "", "", errors.New("unit not found")
+	}
+	return state[0], state[1], nil
+}
+
+func (m *mockUnitStateClient) UnitAccounting(ctx context.Context, unit string) (uint64, uint64, error) {
+	acct, ok := m.accounting[unit]
+	if !ok {
+		return 0, 0, errors.New("unit not found")
+	}
+	return acct[0], acct[1], nil
+}
+
+func (m *mockUnitStateClient) Close() {
+	m.closed = true
+}
+
+func TestServiceCollector_CollectDBus(t *testing.T) {
+	originalNewUnitStateClient := newUnitStateClient
+	defer func() { newUnitStateClient = originalNewUnitStateClient }()
+
+	mockClient := &mockUnitStateClient{
+		states: map[string][2]string{
+			"nginx.service":      {"active", "running"},
+			"postgresql.service": {"failed", "failed"},
+		},
+	}
+	newUnitStateClient = func(ctx context.Context) (unitStateClient, error) {
+		return mockClient, nil
+	}
+
+	c := &ServiceCollector{
+		Services: []config.Service{
+			{Name: "nginx", Label: "Nginx"},
+			{Name: "postgresql.service", Label: "PostgreSQL"},
+		},
+	}
+
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("ServiceCollector.Collect() error = %v", err)
+	}
+
+	want := []map[string]interface{}{
+		{"active": true, "sub_state": "running"},
+		{"active": false, "sub_state": "failed"},
+	}
+	for i, metric := range metrics {
+		value := metric.Value.(map[string]interface{})
+		if value["active"] != want[i]["active"] || value["sub_state"] != want[i]["sub_state"] {
+			t.Errorf("ServiceCollector.Collect() metric %d value = %v, want %v", i, value, want[i])
+		}
+	}
+
+	if !mockClient.closed {
+		t.Error("ServiceCollector.Collect() did not close the D-Bus client")
+	}
+}
+
+func TestServiceCollector_CollectAccounting(t *testing.T) {
+	originalNewUnitStateClient := newUnitStateClient
+	defer func() { newUnitStateClient = originalNewUnitStateClient }()
+
+	mockClient := &mockUnitStateClient{
+		states: map[string][2]string{
+			"nginx.service":      {"active", "running"},
+			"postgresql.service": {"active", "running"},
+		},
+		accounting: map[string][2]uint64{
+			"nginx.service":      {52428800, 1500000000},
+			"postgresql.service": {accountingNotSet, accountingNotSet},
+		},
+	}
+	newUnitStateClient = func(ctx context.Context) (unitStateClient, error) {
+		return mockClient, nil
+	}
+
+	tests := []struct {
+		name       string
+		accounting bool
+		want       []map[string]interface{}
+	}{
+		{
+			name:       "accounting disabled",
+			accounting: false,
+			want: []map[string]interface{}{
+				{"active": true, "sub_state": "running"},
+				{"active": true, "sub_state": "running"},
+			},
+		},
+		{
+			name:       "accounting enabled",
+			accounting: true,
+			want: []map[string]interface{}{
+				{"active": true, "sub_state": "running", "memory_bytes": uint64(52428800), "cpu_usage_seconds": 1.5},
+				{"active": true, "sub_state": "running"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewServiceCollector([]config.Service{
+				{Name: "nginx", Label: "Nginx"},
+				{Name: "postgresql", Label: "PostgreSQL"},
+			}, tt.accounting)
+
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("ServiceCollector.Collect() error = %v", err)
+			}
+
+			for i, metric := range metrics {
+				if !reflect.DeepEqual(metric.Value, tt.want[i]) {
+					t.Errorf("ServiceCollector.Collect() metric %d value = %v, want %v", i, metric.Value, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckServiceAccountingExec(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	tests := []struct {
+		name       string
+		output     string
+		wantMemory uint64
+		wantCPU    uint64
+	}{
+		{
+			name:       "accounting enabled",
+			output:     "MemoryCurrent=1048576\nCPUUsageNSec=2000000000\n",
+			wantMemory: 1048576,
+			wantCPU:    2000000000,
+		},
+		{
+			name:       "accounting not set",
+			output:     "MemoryCurrent=[not set]\nCPUUsageNSec=[not set]\n",
+			wantMemory: accountingNotSet,
+			wantCPU:    accountingNotSet,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotArgs []string
+			execCommand = func(name string, args ...string) *exec.Cmd {
+				gotArgs = append([]string{name}, args...)
+				return exec.Command("printf", tt.output)
+			}
+
+			memory, cpu, err := checkServiceAccountingExec("nginx.service")
+			if err != nil {
+				t.Fatalf("checkServiceAccountingExec() error = %v", err)
+			}
+			if memory != tt.wantMemory || cpu != tt.wantCPU {
+				t.Errorf("checkServiceAccountingExec() = %d, %d, want %d, %d", memory, cpu, tt.wantMemory, tt.wantCPU)
+			}
+
+			wantArgs := []string{"systemctl", "show", "-p", "MemoryCurrent,CPUUsageNSec", "nginx.service"}
+			if !reflect.DeepEqual(gotArgs, wantArgs) {
+				t.Errorf("checkServiceAccountingExec() ran %v, want %v", gotArgs, wantArgs)
+			}
+		})
+	}
+}
+
+func TestServiceCollector_CollectExecFallback(t *testing.T) {
+	originalNewUnitStateClient := newUnitStateClient
+	originalExecCommand := execCommand
+	defer func() {
+		newUnitStateClient = originalNewUnitStateClient
+		execCommand = originalExecCommand
+	}()
+
+	newUnitStateClient = func(ctx context.Context) (unitStateClient, error) {
+		return nil, errors.New("no D-Bus")
+	}
+	execCommand = (&mockExecCommand{}).Command
+
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		t.Skip("systemctl not available, exec fallback uses SysV scripts")
+	}
+
+	c := &ServiceCollector{
+		Services: []config.Service{{Name: "test-service", Label: "Test Service"}},
+	}
+
+	metrics, err := c.Collect()
+	if err != nil {
+		t.Fatalf("ServiceCollector.Collect() error = %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("ServiceCollector.Collect() returned %d metrics, want 1", len(metrics))
+	}
+
+	value := metrics[0].Value.(map[string]interface{})
+	if value["active"] != true || value["sub_state"] != "unknown" {
+		t.Errorf("ServiceCollector.Collect() value = %v, want active=true sub_state=unknown", value)
+	}
+}
+
+func TestUnitName(t *testing.T) {
+	tests := map[string]string{
+		"nginx":         "nginx.service",
+		"nginx.service": "nginx.service",
+		"backup.timer":  "backup.timer",
+	}
+
+	for input, want := range tests {
+		if got := unitName(input); got != want {
+			t.Errorf("unitName(%q) = %q, want %q", input, got, want)
 		}
 	}
 }
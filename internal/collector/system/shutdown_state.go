@@ -0,0 +1,112 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// runRoot is a variable to allow pointing the collector at a fake /run in tests
+var runRoot = "/run"
+
+// ShutdownStateCollector implements the collector.Collector interface for scheduled shutdowns
+type ShutdownStateCollector struct{}
+
+// NewShutdownStateCollector creates a new instance of ShutdownStateCollector
+func NewShutdownStateCollector() collector.Collector {
+	return &ShutdownStateCollector{}
+}
+
+// Supported reports whether the host runs Linux, where systemd records scheduled shutdowns under /run
+func (c *ShutdownStateCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect reports whether a shutdown or reboot is scheduled (e.g. with shutdown -r +60)
+// systemd records the schedule in /run/systemd/shutdown/scheduled. On systems without it,
+// the /run/nologin file written shortly before a shutdown is used, without a scheduled time
+func (c *ShutdownStateCollector) Collect() ([]collector.Metrics, error) {
+	value, err := readScheduledShutdown(filepath.Join(runRoot, "systemd/shutdown/scheduled"))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		if value, err = readNologinShutdown(filepath.Join(runRoot, "nologin")); err != nil {
+			return nil, err
+		}
+	}
+	if value == nil {
+		value = map[string]interface{}{"shutdown_scheduled": false}
+	}
+
+	return []collector.Metrics{
+		{
+			Timestamp: time.Now(),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameShutdownState,
+			Value:     value,
+		},
+	}, nil
+}
+
+// readScheduledShutdown parses the KEY=value file systemd writes for a scheduled shutdown
+// It returns nil when no shutdown is scheduled
+func readScheduledShutdown(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduled shutdown: %w", err)
+	}
+
+	value := map[string]interface{}{"shutdown_scheduled": true}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "USEC":
+			if usec, err := strconv.ParseInt(val, 10, 64); err == nil {
+				value["scheduled_time"] = time.UnixMicro(usec).UTC().Format(time.RFC3339)
+			}
+		case "MODE":
+			value["mode"] = val
+		case "WALL_MESSAGE":
+			if val != "" {
+				value["reason"] = val
+			}
+		}
+	}
+	return value, nil
+}
+
+// readNologinShutdown reports a shutdown from the message in /run/nologin
+// The file is also created while the system boots, so only shutdown messages count
+func readNologinShutdown(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nologin file: %w", err)
+	}
+
+	message := strings.TrimSpace(string(data))
+	if !strings.Contains(message, "going down") {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"shutdown_scheduled": true,
+		"reason":             message,
+	}, nil
+}
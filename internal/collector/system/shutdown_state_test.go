@@ -0,0 +1,81 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestShutdownStateCollector_Collect(t *testing.T) {
+	originalRunRoot := runRoot
+	defer func() { runRoot = originalRunRoot }()
+
+	tests := []struct {
+		name      string
+		scheduled string
+		nologin   string
+		want      map[string]interface{}
+	}{
+		{
+			name: "nothing scheduled",
+			want: map[string]interface{}{"shutdown_scheduled": false},
+		},
+		{
+			name:      "reboot scheduled by systemd",
+			scheduled: "USEC=1700000000000000\nWARN_WALL=1\nMODE=reboot\nWALL_MESSAGE=Kernel upgrade\n",
+			want: map[string]interface{}{
+				"shutdown_scheduled": true,
+				"scheduled_time":     "2023-11-14T22:13:20Z",
+				"mode":               "reboot",
+				"reason":             "Kernel upgrade",
+			},
+		},
+		{
+			name:    "shutdown announced in nologin",
+			nologin: "\nSystem is going down for reboot at Tue 2023-11-14 22:13:20 UTC.\n\n",
+			want: map[string]interface{}{
+				"shutdown_scheduled": true,
+				"reason":             "System is going down for reboot at Tue 2023-11-14 22:13:20 UTC.",
+			},
+		},
+		{
+			name:    "nologin while booting",
+			nologin: "System is booting up. Unprivileged users are not permitted to log in yet.\n",
+			want:    map[string]interface{}{"shutdown_scheduled": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runRoot = t.TempDir()
+			if tt.scheduled != "" {
+				dir := filepath.Join(runRoot, "systemd/shutdown")
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "scheduled"), []byte(tt.scheduled), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.nologin != "" {
+				if err := os.WriteFile(filepath.Join(runRoot, "nologin"), []byte(tt.nologin), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			metrics, err := NewShutdownStateCollector().Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].Name != collector.NameShutdownState {
+				t.Fatalf("Collect() = %v, want one shutdown_state metric", metrics)
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("Collect() value = %v, want %v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
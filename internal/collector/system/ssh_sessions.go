@@ -0,0 +1,86 @@
+package system
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// SSHSource is the number of established SSH connections from a remote IP
+type SSHSource struct {
+	SourceIP string `json:"source_ip"`
+	Count    int    `json:"count"`
+}
+
+// SSHSessionsCollector implements the collector.Collector interface for SSH connections by source
+// On Linux connections are read from the kernel's socket tables, which list the sockets of every user,
+// so the counts stay complete after dropping privileges. Elsewhere running as root may be required
+type SSHSessionsCollector struct {
+	Port       uint32 // Local port the SSH server listens on
+	TopSources int    // Maximum number of sources reported, the most connected first
+}
+
+// NewSSHSessionsCollector creates a new instance of SSHSessionsCollector
+func NewSSHSessionsCollector(port uint32, topSources int) collector.Collector {
+	return &SSHSessionsCollector{
+		Port:       port,
+		TopSources: topSources,
+	}
+}
+
+// Collect gathers the number of established SSH connections, grouped by remote IP
+func (c *SSHSessionsCollector) Collect() ([]collector.Metrics, error) {
+	metrics := make([]collector.Metrics, 0, 1)
+	now := time.Now()
+
+	conns, err := netConnections("tcp")
+	if err != nil {
+		return metrics, fmt.Errorf("failed to get TCP connections: %w", err)
+	}
+
+	metrics = append(metrics, collector.Metrics{
+		Timestamp: now,
+		Category:  collector.CategorySystem,
+		Name:      collector.NameSSHSessions,
+		Value:     c.countSources(conns),
+	})
+
+	return metrics, nil
+}
+
+// countSources counts established connections to the SSH port per remote IP
+// The total and number of distinct sources cover all connections, not only the reported top sources
+func (c *SSHSessionsCollector) countSources(conns []net.ConnectionStat) map[string]interface{} {
+	counts := make(map[string]int)
+	total := 0
+	for _, conn := range conns {
+		if conn.Status != "ESTABLISHED" || conn.Laddr.Port != c.Port || conn.Raddr.IP == "" {
+			continue
+		}
+		counts[conn.Raddr.IP]++
+		total++
+	}
+
+	sources := make([]SSHSource, 0, len(counts))
+	for ip, count := range counts {
+		sources = append(sources, SSHSource{SourceIP: ip, Count: count})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Count != sources[j].Count {
+			return sources[i].Count > sources[j].Count
+		}
+		return sources[i].SourceIP < sources[j].SourceIP
+	})
+	if c.TopSources > 0 && len(sources) > c.TopSources {
+		sources = sources[:c.TopSources]
+	}
+
+	return map[string]interface{}{
+		"total":            total,
+		"distinct_sources": len(counts),
+		"sources":          sources,
+	}
+}
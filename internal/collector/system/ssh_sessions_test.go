@@ -0,0 +1,93 @@
+package system
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func TestSSHSessionsCollector_Collect(t *testing.T) {
+	originalNetConnections := netConnections
+	defer func() { netConnections = originalNetConnections }()
+
+	sshConn := func(ip, status string) net.ConnectionStat {
+		return net.ConnectionStat{
+			Status: status,
+			Laddr:  net.Addr{IP: "10.0.0.1", Port: 22},
+			Raddr:  net.Addr{IP: ip, Port: 50000},
+		}
+	}
+	conns := []net.ConnectionStat{
+		{Status: "LISTEN", Laddr: net.Addr{IP: "0.0.0.0", Port: 22}},
+		sshConn("203.0.113.7", "ESTABLISHED"),
+		sshConn("203.0.113.7", "ESTABLISHED"),
+		sshConn("203.0.113.7", "ESTABLISHED"),
+		sshConn("198.51.100.2", "ESTABLISHED"),
+		sshConn("192.0.2.9", "ESTABLISHED"),
+		sshConn("192.0.2.9", "TIME_WAIT"),
+		{Status: "ESTABLISHED", Laddr: net.Addr{IP: "10.0.0.1", Port: 443}, Raddr: net.Addr{IP: "203.0.113.7", Port: 50001}},
+	}
+
+	tests := []struct {
+		name       string
+		topSources int
+		connErr    error
+		want       map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "all sources",
+			topSources: 10,
+			want: map[string]interface{}{
+				"total":            5,
+				"distinct_sources": 3,
+				"sources": []SSHSource{
+					{SourceIP: "203.0.113.7", Count: 3},
+					{SourceIP: "192.0.2.9", Count: 1},
+					{SourceIP: "198.51.100.2", Count: 1},
+				},
+			},
+		},
+		{
+			name:       "top sources only",
+			topSources: 1,
+			want: map[string]interface{}{
+				"total":            5,
+				"distinct_sources": 3,
+				"sources":          []SSHSource{{SourceIP: "203.0.113.7", Count: 3}},
+			},
+		},
+		{
+			name:    "connection enumeration error",
+			connErr: errors.New("permission denied"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netConnections = func(kind string) ([]net.ConnectionStat, error) {
+				return conns, tt.connErr
+			}
+
+			c := NewSSHSessionsCollector(22, tt.topSources)
+			metrics, err := c.Collect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SSHSessionsCollector.Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(metrics) != 1 || metrics[0].Name != collector.NameSSHSessions {
+				t.Fatalf("SSHSessionsCollector.Collect() returned unexpected metrics: %+v", metrics)
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("SSHSessionsCollector.Collect() value = %v, want %v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
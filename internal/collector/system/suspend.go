@@ -0,0 +1,60 @@
+package system
+
+import (
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// clockOrigin is the reference for monotonic clock readings
+var clockOrigin = time.Now()
+
+// readClocks returns the wall clock time and the monotonic time elapsed since clockOrigin
+// The monotonic clock stops while the host is suspended, the wall clock doesn't.
+// It is a variable to allow simulating a suspend in tests
+var readClocks = func() (time.Time, time.Duration) {
+	now := time.Now()
+	return now.Round(0), now.Sub(clockOrigin)
+}
+
+// SuspendCollector implements the collector.Collector interface for suspend detection
+type SuspendCollector struct {
+	Threshold time.Duration // Minimum clock divergence reported as a suspend, filters out NTP adjustments
+
+	lastWall time.Time
+	lastMono time.Duration
+}
+
+// NewSuspendCollector creates a new instance of SuspendCollector
+func NewSuspendCollector(threshold time.Duration) collector.Collector {
+	wall, mono := readClocks()
+	return &SuspendCollector{
+		Threshold: threshold,
+		lastWall:  wall,
+		lastMono:  mono,
+	}
+}
+
+// Collect compares the wall clock and monotonic time elapsed since the previous sample
+// A metric is only returned when the wall clock got ahead by at least the threshold,
+// which means the host was suspended for about that long
+func (c *SuspendCollector) Collect() ([]collector.Metrics, error) {
+	wall, mono := readClocks()
+	suspended := wall.Sub(c.lastWall) - (mono - c.lastMono)
+	c.lastWall, c.lastMono = wall, mono
+
+	if suspended < c.Threshold {
+		return nil, nil
+	}
+
+	return []collector.Metrics{
+		{
+			Timestamp: wall,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameSuspend,
+			Value: map[string]interface{}{
+				"suspended_seconds": suspended.Seconds(),
+			},
+		},
+	}, nil
+}
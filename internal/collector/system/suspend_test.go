@@ -0,0 +1,60 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestSuspendCollector_Collect(t *testing.T) {
+	originalReadClocks := readClocks
+	defer func() { readClocks = originalReadClocks }()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		wallElapsed time.Duration
+		monoElapsed time.Duration
+		want        float64 // Expected suspended_seconds, 0 when no metric is expected
+	}{
+		{name: "no suspend", wallElapsed: time.Minute, monoElapsed: time.Minute},
+		{name: "small NTP adjustment", wallElapsed: time.Minute + 2*time.Second, monoElapsed: time.Minute},
+		{name: "wall clock stepped back", wallElapsed: 30 * time.Second, monoElapsed: time.Minute},
+		{name: "suspended for an hour", wallElapsed: time.Hour + time.Minute, monoElapsed: time.Minute, want: 3600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wall, mono := start, time.Duration(0)
+			readClocks = func() (time.Time, time.Duration) { return wall, mono }
+			c := NewSuspendCollector(5 * time.Second)
+
+			wall, mono = start.Add(tt.wallElapsed), tt.monoElapsed
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+
+			if tt.want == 0 {
+				if len(metrics) != 0 {
+					t.Errorf("Collect() = %v, want no metric", metrics)
+				}
+				return
+			}
+			if len(metrics) != 1 || metrics[0].Name != collector.NameSuspend {
+				t.Fatalf("Collect() = %v, want one suspend metric", metrics)
+			}
+			value := metrics[0].Value.(map[string]interface{})
+			if value["suspended_seconds"] != tt.want {
+				t.Errorf("suspended_seconds = %v, want %v", value["suspended_seconds"], tt.want)
+			}
+
+			// The next sample starts from the resumed clocks
+			wall, mono = wall.Add(time.Minute), mono+time.Minute
+			if metrics, _ := c.Collect(); len(metrics) != 0 {
+				t.Errorf("Collect() after resume = %v, want no metric", metrics)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package system
 
 import (
+	"time"
+
 	"github.com/monitorly-app/probe/internal/collector"
 	"github.com/monitorly-app/probe/internal/config"
 )
@@ -9,19 +11,19 @@ import (
 func NewCollectors() map[string]func() collector.Collector {
 	return map[string]func() collector.Collector{
 		"cpu": func() collector.Collector {
-			return NewCPUCollector()
+			return NewCPUCollector(collector.DefaultPrecision)
 		},
 		"ram": func() collector.Collector {
-			return NewRAMCollector()
+			return NewRAMCollector(collector.DefaultPrecision)
 		},
 		"user_activity": func() collector.Collector {
-			return NewUserActivityCollector()
+			return NewUserActivityCollector(false)
 		},
 		"login_failures": func() collector.Collector {
-			return NewLoginFailuresCollector()
+			return NewLoginFailuresCollector(false, false, nil, time.Minute, 0, "")
 		},
 		"port": func() collector.Collector {
-			return NewPortCollector()
+			return NewPortCollector(nil, nil)
 		},
 	}
 }
@@ -29,7 +31,7 @@ func NewCollectors() map[string]func() collector.Collector {
 // NewDiskCollectorFunc returns a function that creates a new disk collector with the specified mount points
 func NewDiskCollectorFunc(mountPoints []config.MountPoint) func() collector.Collector {
 	return func() collector.Collector {
-		return NewDiskCollector(mountPoints)
+		return NewDiskCollector(mountPoints, false, nil, nil, collector.DefaultPrecision)
 	}
 }
 
@@ -39,6 +41,6 @@ func NewServiceCollectorFunc(services []config.Service) func() collector.Collect
 		return nil
 	}
 	return func() collector.Collector {
-		return NewServiceCollector(services)
+		return NewServiceCollector(services, false)
 	}
 }
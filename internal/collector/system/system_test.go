@@ -1,10 +1,13 @@
 package system
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/monitorly-app/probe/internal/collector"
 	"github.com/monitorly-app/probe/internal/config"
+	"github.com/shirou/gopsutil/v4/disk"
 )
 
 func TestNewCollectors(t *testing.T) {
@@ -38,7 +41,7 @@ func TestNewCollectors(t *testing.T) {
 }
 
 func TestNewCPUCollector(t *testing.T) {
-	c := NewCPUCollector()
+	c := NewCPUCollector(2)
 
 	if c == nil {
 		t.Errorf("NewCPUCollector() returned nil")
@@ -55,7 +58,7 @@ func TestNewCPUCollector(t *testing.T) {
 }
 
 func TestNewRAMCollector(t *testing.T) {
-	c := NewRAMCollector()
+	c := NewRAMCollector(2)
 
 	if c == nil {
 		t.Errorf("NewRAMCollector() returned nil")
@@ -106,7 +109,7 @@ func TestNewDiskCollector(t *testing.T) {
 		},
 	}
 
-	c := NewDiskCollector(mountPoints)
+	c := NewDiskCollector(mountPoints, false, nil, nil, 2)
 
 	if c == nil {
 		t.Errorf("NewDiskCollector() returned nil")
@@ -185,6 +188,84 @@ func TestNewDiskCollectorFunc(t *testing.T) {
 	}
 }
 
+func TestDiskCollector_AutoDiscover(t *testing.T) {
+	originalDiskPartitions := diskPartitions
+	defer func() { diskPartitions = originalDiskPartitions }()
+
+	dataDir := t.TempDir()
+	partitions := []disk.PartitionStat{
+		{Mountpoint: "/", Fstype: "ext4"},
+		{Mountpoint: dataDir, Fstype: "xfs"},
+		{Mountpoint: "/run", Fstype: "tmpfs"},
+		{Mountpoint: "/snap/core/1", Fstype: "squashfs"},
+		{Mountpoint: "/var/lib/docker/overlay2/abc/merged", Fstype: "overlay"},
+		{Mountpoint: "/boot/efi", Fstype: "vfat"},
+		{Mountpoint: dataDir, Fstype: "xfs"},
+	}
+	configured := []config.MountPoint{
+		{Path: "/", Label: "root", CollectUsage: true, CollectPercent: true, CollectInodes: true},
+	}
+
+	tests := []struct {
+		name          string
+		autoDiscover  bool
+		partitionsErr error
+		wantPaths     []string
+	}{
+		{
+			name:      "discovery disabled",
+			wantPaths: []string{"/"},
+		},
+		{
+			name:         "discovered mount points merged without duplicates",
+			autoDiscover: true,
+			wantPaths:    []string{"/", dataDir},
+		},
+		{
+			name:          "discovery failure keeps configured mount points",
+			autoDiscover:  true,
+			partitionsErr: errors.New("mounts unreadable"),
+			wantPaths:     []string{"/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskPartitions = func(all bool) ([]disk.PartitionStat, error) {
+				if all {
+					t.Errorf("diskPartitions() all = true, want false")
+				}
+				return partitions, tt.partitionsErr
+			}
+
+			c := NewDiskCollector(configured, tt.autoDiscover, []string{"tmpfs", "squashfs", "overlay"}, []string{"/boot/*"}, 2).(*DiskCollector)
+
+			var paths []string
+			for _, mp := range c.mountPoints() {
+				paths = append(paths, mp.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Fatalf("mountPoints() = %v, want %v", paths, tt.wantPaths)
+			}
+
+			metrics, err := c.Collect()
+			if err != nil {
+				t.Fatalf("DiskCollector.Collect() error = %v", err)
+			}
+			for _, m := range metrics {
+				switch m.Metadata["mountpoint"] {
+				case "/run", "/snap/core/1", "/var/lib/docker/overlay2/abc/merged", "/boot/efi":
+					t.Errorf("excluded mount point %s produced a metric", m.Metadata["mountpoint"])
+				case "/":
+					if m.Metadata["label"] != "root" {
+						t.Errorf("configured mount point label = %s, want root", m.Metadata["label"])
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestCPUCollector_Collect(t *testing.T) {
 	c := &CPUCollector{}
 
@@ -438,6 +519,62 @@ func TestDiskCollector_CollectWithInvalidPath(t *testing.T) {
 	}
 }
 
+func TestAddInodeFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		usage       *disk.UsageStat
+		precision   int
+		wantKeys    bool
+		wantPercent float64
+	}{
+		{
+			name: "filesystem reporting inodes",
+			usage: &disk.UsageStat{
+				InodesTotal:       1000,
+				InodesUsed:        250,
+				InodesFree:        750,
+				InodesUsedPercent: 25,
+			},
+			precision:   2,
+			wantKeys:    true,
+			wantPercent: 25,
+		},
+		{
+			name: "small usage kept by a higher precision",
+			usage: &disk.UsageStat{
+				InodesTotal:       100000000,
+				InodesUsed:        1200,
+				InodesFree:        99998800,
+				InodesUsedPercent: 0.0012,
+			},
+			precision:   4,
+			wantKeys:    true,
+			wantPercent: 0.0012,
+		},
+		{
+			name:     "filesystem without inodes",
+			usage:    &disk.UsageStat{},
+			wantKeys: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskMetric := map[string]interface{}{}
+			addInodeFields(diskMetric, tt.usage, tt.precision)
+
+			for _, key := range []string{"inodes_percent", "inodes_used", "inodes_free"} {
+				if _, ok := diskMetric[key]; ok != tt.wantKeys {
+					t.Errorf("addInodeFields() key %q present = %v, want %v", key, ok, tt.wantKeys)
+				}
+			}
+			if tt.wantKeys && diskMetric["inodes_percent"] != tt.wantPercent {
+				t.Errorf("addInodeFields() inodes_percent = %v, want %v", diskMetric["inodes_percent"], tt.wantPercent)
+			}
+		})
+	}
+}
+
 // BenchmarkCPUCollector_Collect benchmarks CPU collection
 func BenchmarkCPUCollector_Collect(b *testing.B) {
 	c := &CPUCollector{}
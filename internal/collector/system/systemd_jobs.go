@@ -0,0 +1,77 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// SystemdJobsCollector implements the collector.Collector interface for the systemd job queue depth
+type SystemdJobsCollector struct{}
+
+// NewSystemdJobsCollector creates a new instance of SystemdJobsCollector
+func NewSystemdJobsCollector() collector.Collector {
+	return &SystemdJobsCollector{}
+}
+
+// Supported reports whether the host runs Linux, the only OS systemd runs on
+func (c *SystemdJobsCollector) Supported() bool {
+	return goos == "linux"
+}
+
+// Collect counts the queued and running systemd jobs and their types
+// Nothing is returned on hosts without systemd or systemctl
+func (c *SystemdJobsCollector) Collect() ([]collector.Metrics, error) {
+	if !systemdBooted() {
+		return nil, nil
+	}
+
+	output, err := execCommand("systemctl", "list-jobs", "--no-legend", "--no-pager", "--plain").Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list systemd jobs: %w", err)
+	}
+
+	return []collector.Metrics{
+		{
+			Timestamp: time.Now(),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameSystemdJobs,
+			Value:     parseSystemdJobs(string(output)),
+		},
+	}, nil
+}
+
+// parseSystemdJobs parses systemctl list-jobs output, one "JOB UNIT TYPE STATE" line per job
+// Older systemd versions print "No jobs running." instead of nothing when the queue is empty
+func parseSystemdJobs(output string) map[string]interface{} {
+	pending, running := 0, 0
+	types := make(map[string]int)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		switch fields[3] {
+		case "running":
+			running++
+		case "waiting":
+			pending++
+		default:
+			continue
+		}
+		types[fields[2]]++
+	}
+
+	return map[string]interface{}{
+		"pending_jobs": pending,
+		"running_jobs": running,
+		"job_types":    types,
+	}
+}
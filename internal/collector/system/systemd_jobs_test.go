@@ -0,0 +1,83 @@
+package system
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestSystemdJobsCollector_Collect(t *testing.T) {
+	originalExecCommand, originalRunRoot := execCommand, runRoot
+	defer func() { execCommand, runRoot = originalExecCommand, originalRunRoot }()
+
+	runRoot = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(runRoot, "systemd/system"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		output string
+		want   map[string]interface{}
+	}{
+		{
+			name: "queued jobs",
+			output: "1234 nginx.service start running\n" +
+				"1235 postgresql.service start waiting\n" +
+				"1236 backup.timer stop waiting\n",
+			want: map[string]interface{}{
+				"pending_jobs": 2,
+				"running_jobs": 1,
+				"job_types":    map[string]int{"start": 2, "stop": 1},
+			},
+		},
+		{
+			name:   "empty queue on older systemd",
+			output: "No jobs running.\n",
+			want: map[string]interface{}{
+				"pending_jobs": 0,
+				"running_jobs": 0,
+				"job_types":    map[string]int{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execCommand = func(name string, args ...string) *exec.Cmd {
+				return exec.Command("printf", "%s", tt.output)
+			}
+
+			metrics, err := NewSystemdJobsCollector().Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if len(metrics) != 1 || metrics[0].Name != collector.NameSystemdJobs {
+				t.Fatalf("Collect() = %v, want one systemd_jobs metric", metrics)
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("Collect() value = %v, want %v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdJobsCollector_NoSystemd(t *testing.T) {
+	originalExecCommand, originalRunRoot := execCommand, runRoot
+	defer func() { execCommand, runRoot = originalExecCommand, originalRunRoot }()
+
+	runRoot = t.TempDir()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Errorf("systemctl run on a host without systemd")
+		return exec.Command("true")
+	}
+
+	metrics, err := NewSystemdJobsCollector().Collect()
+	if err != nil || len(metrics) != 0 {
+		t.Errorf("Collect() = %v, %v, want no metrics", metrics, err)
+	}
+}
@@ -0,0 +1,157 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func TestNewTCPStatesCollector(t *testing.T) {
+	c := NewTCPStatesCollector(true)
+
+	tc, ok := c.(*TCPStatesCollector)
+	if !ok {
+		t.Fatalf("NewTCPStatesCollector() returned wrong type: %T", c)
+	}
+	if !tc.ListenEstablishedOnly {
+		t.Error("NewTCPStatesCollector() ListenEstablishedOnly = false, want true")
+	}
+}
+
+func TestTCPStatesCollector_Collect(t *testing.T) {
+	originalNetConnections, originalGOOS := netConnections, goos
+	defer func() { netConnections, goos = originalNetConnections, originalGOOS }()
+
+	// Outside Linux listen_established_only filters the full enumeration
+	goos = "darwin"
+
+	conns := []net.ConnectionStat{
+		{Status: "LISTEN"},
+		{Status: "ESTABLISHED"},
+		{Status: "ESTABLISHED"},
+		{Status: "TIME_WAIT"},
+		{Status: "CLOSE_WAIT"},
+	}
+
+	tests := []struct {
+		name                  string
+		listenEstablishedOnly bool
+		connErr               error
+		want                  map[string]int
+		wantErr               bool
+	}{
+		{
+			name: "all states",
+			want: map[string]int{"LISTEN": 1, "ESTABLISHED": 2, "TIME_WAIT": 1, "CLOSE_WAIT": 1},
+		},
+		{
+			name:                  "listen and established only",
+			listenEstablishedOnly: true,
+			want:                  map[string]int{"LISTEN": 1, "ESTABLISHED": 2},
+		},
+		{
+			name:    "connection enumeration error",
+			connErr: errors.New("permission denied"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netConnections = func(kind string) ([]net.ConnectionStat, error) {
+				if kind != "tcp" {
+					t.Errorf("netConnections() kind = %q, want tcp", kind)
+				}
+				return conns, tt.connErr
+			}
+
+			c := &TCPStatesCollector{ListenEstablishedOnly: tt.listenEstablishedOnly}
+			metrics, err := c.Collect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TCPStatesCollector.Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(metrics) != 1 || metrics[0].Name != collector.NameTCPStates {
+				t.Fatalf("TCPStatesCollector.Collect() returned unexpected metrics: %+v", metrics)
+			}
+			got, ok := metrics[0].Value.(map[string]int)
+			if !ok {
+				t.Fatalf("metric value is not map[string]int: %T", metrics[0].Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("counts = %v, want %v", got, tt.want)
+			}
+			for state, count := range tt.want {
+				if got[state] != count {
+					t.Errorf("counts[%s] = %d, want %d", state, got[state], count)
+				}
+			}
+		})
+	}
+}
+
+func TestTCPStatesCollector_ListenEstablishedFromProc(t *testing.T) {
+	originalNetConnections, originalGOOS, originalProcRoot := netConnections, goos, procRoot
+	defer func() { netConnections, goos, procRoot = originalNetConnections, originalGOOS, originalProcRoot }()
+
+	goos = "linux"
+	procRoot = t.TempDir()
+	netConnections = func(kind string) ([]net.ConnectionStat, error) {
+		t.Error("netConnections() called, want the socket tables read directly")
+		return nil, nil
+	}
+
+	const header = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	tcp := header +
+		"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1\n" +
+		"   1: 0100007F:0016 0100007F:A1B2 01 00000000:00000000 00:00000000 00000000     0        0 1002 1\n" +
+		"   2: 0100007F:0016 0100007F:A1B3 06 00000000:00000000 00:00000000 00000000     0        0 0 1\n" +
+		"   3: 0100007F:0016 0100007F:A1B4 08 00000000:00000000 00:00000000 00000000     0        0 1004 1\n"
+	tcp6 := header +
+		"   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2001 1\n" +
+		"   1: 00000000000000000000000001000000:0050 00000000000000000000000001000000:B1C2 01 00000000:00000000 00:00000000 00000000     0        0 2002 1\n"
+	writeTable := func(name, content string) {
+		if err := os.MkdirAll(filepath.Join(procRoot, "net"), 0755); err != nil {
+			t.Fatalf("failed to create net directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(procRoot, "net", name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s table: %v", name, err)
+		}
+	}
+
+	collect := func() map[string]int {
+		t.Helper()
+		metrics, err := (&TCPStatesCollector{ListenEstablishedOnly: true}).Collect()
+		if err != nil {
+			t.Fatalf("TCPStatesCollector.Collect() error = %v", err)
+		}
+		return metrics[0].Value.(map[string]int)
+	}
+
+	// Without IPv6 only the IPv4 table exists
+	writeTable("tcp", tcp)
+	if got, want := collect(), map[string]int{"LISTEN": 1, "ESTABLISHED": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts without IPv6 = %v, want %v", got, want)
+	}
+
+	writeTable("tcp6", tcp6)
+	if got, want := collect(), map[string]int{"LISTEN": 2, "ESTABLISHED": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+
+	// A missing IPv4 table is an error
+	if err := os.Remove(filepath.Join(procRoot, "net", "tcp")); err != nil {
+		t.Fatalf("failed to remove tcp table: %v", err)
+	}
+	if _, err := (&TCPStatesCollector{ListenEstablishedOnly: true}).Collect(); err == nil {
+		t.Error("TCPStatesCollector.Collect() without /proc/net/tcp error = nil, want an error")
+	}
+}
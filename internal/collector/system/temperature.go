@@ -0,0 +1,62 @@
+package system
+
+import (
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+// sensorsTemperatures is a variable to allow mocking the sensors from gopsutil in tests
+var sensorsTemperatures = sensors.SensorsTemperatures
+
+// TemperatureCollector implements the collector.Collector interface for thermal sensor metrics
+type TemperatureCollector struct {
+	Precision int // Decimal places of the reported temperatures
+
+	reportedNoSensors bool // Whether the absence of sensors was already logged
+}
+
+// NewTemperatureCollector creates a new instance of TemperatureCollector
+func NewTemperatureCollector(precision int) collector.Collector {
+	return &TemperatureCollector{Precision: precision}
+}
+
+// Collect reports the temperature of each sensor in degrees Celsius, with the sensor key as metadata
+// Hosts exposing no sensors, e.g. VMs and containers, get no metrics and a single info message
+func (c *TemperatureCollector) Collect() ([]collector.Metrics, error) {
+	now := time.Now()
+
+	// Sensors that can't be read are returned as warnings alongside the ones that could
+	temperatures, err := sensorsTemperatures()
+	if len(temperatures) == 0 {
+		if !c.reportedNoSensors {
+			c.reportedNoSensors = true
+			if err != nil {
+				logger.Infof("No temperature sensors available, temperature collection is skipped: %v", err)
+			} else {
+				logger.Infof("No temperature sensors available, temperature collection is skipped")
+			}
+		}
+		return []collector.Metrics{}, nil
+	}
+	if err != nil {
+		logger.Debugf("Some temperature sensors could not be read: %v", err)
+	}
+
+	metrics := make([]collector.Metrics, 0, len(temperatures))
+	for _, t := range temperatures {
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameTemperature,
+			Metadata: collector.MetricMetadata{
+				"sensor": t.SensorKey,
+			},
+			Value: collector.RoundTo(t.Temperature, c.Precision),
+		})
+	}
+
+	return metrics, nil
+}
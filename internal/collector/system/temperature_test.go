@@ -0,0 +1,88 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/sensors"
+)
+
+func TestTemperatureCollector_Collect(t *testing.T) {
+	originalTemperatures := sensorsTemperatures
+	defer func() { sensorsTemperatures = originalTemperatures }()
+
+	tests := []struct {
+		name         string
+		temperatures []sensors.TemperatureStat
+		precision    int
+		err          error
+		want         map[string]float64
+	}{
+		{
+			name: "sensors",
+			temperatures: []sensors.TemperatureStat{
+				{SensorKey: "coretemp_core0", Temperature: 48.123},
+				{SensorKey: "cpu_thermal", Temperature: 61.5},
+			},
+			precision: 2,
+			want:      map[string]float64{"coretemp_core0": 48.12, "cpu_thermal": 61.5},
+		},
+		{
+			name:         "whole degrees",
+			temperatures: []sensors.TemperatureStat{{SensorKey: "coretemp_core0", Temperature: 48.6}},
+			precision:    0,
+			want:         map[string]float64{"coretemp_core0": 49},
+		},
+		{
+			name:         "some sensors unreadable",
+			temperatures: []sensors.TemperatureStat{{SensorKey: "acpitz", Temperature: 27.8}},
+			precision:    2,
+			err:          errors.New("open /sys/class/hwmon/hwmon1/temp1_input: permission denied"),
+			want:         map[string]float64{"acpitz": 27.8},
+		},
+		{name: "no sensors", want: map[string]float64{}},
+		{name: "no sensors with error", err: errors.New("open /sys/class/hwmon: no such file or directory"), want: map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sensorsTemperatures = func() ([]sensors.TemperatureStat, error) {
+				return tt.temperatures, tt.err
+			}
+
+			metrics, err := NewTemperatureCollector(tt.precision).Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if metrics == nil || len(metrics) != len(tt.want) {
+				t.Fatalf("Collect() returned %v, want %d metrics", metrics, len(tt.want))
+			}
+			for _, metric := range metrics {
+				if metric.Name != collector.NameTemperature {
+					t.Errorf("metric name = %s, want %s", metric.Name, collector.NameTemperature)
+				}
+				want, ok := tt.want[metric.Metadata["sensor"]]
+				if !ok || metric.Value != want {
+					t.Errorf("sensor %s = %v, want %v", metric.Metadata["sensor"], metric.Value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTemperatureCollector_NoSensorsLoggedOnce(t *testing.T) {
+	originalTemperatures := sensorsTemperatures
+	defer func() { sensorsTemperatures = originalTemperatures }()
+	sensorsTemperatures = func() ([]sensors.TemperatureStat, error) { return nil, nil }
+
+	c := &TemperatureCollector{}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Collect(); err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+	}
+	if !c.reportedNoSensors {
+		t.Error("Collect() didn't record that the missing sensors were reported")
+	}
+}
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"os/exec"
+	"sort"
+
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+// lookPath is a variable to allow mocking tool lookups in tests
+var lookPath = exec.LookPath
+
+// RequiredTools returns the external tools needed by each enabled collector that shells out, keyed by
+// the collector's configuration name. Tools a collector can do without, like journalctl for login
+// failures which falls back to the auth logs, are not listed, nor are the tools of collectors skipped
+// as unsupported on this operating system
+func RequiredTools(cfg *config.Config) map[string][]string {
+	required := make(map[string][]string)
+	if cfg.Collection.Service.Enabled && cfg.Collection.Service.Accounting && (&ServiceCollector{}).Supported() {
+		required["service"] = []string{"systemctl"}
+	}
+	if cfg.Collection.UserActivity.Enabled && (&UserActivityCollector{}).Supported() {
+		required["user_activity"] = []string{"who", "w"}
+	}
+	if cfg.Collection.DB.Enabled {
+		clients := make(map[string]bool)
+		for _, db := range cfg.Collection.DB.Databases {
+			switch db.Type {
+			case "postgres":
+				clients["psql"] = true
+			case "mysql":
+				clients["mysql"] = true
+			}
+		}
+		for client := range clients {
+			required["db"] = append(required["db"], client)
+		}
+		sort.Strings(required["db"])
+	}
+	if cfg.Collection.ServiceRestarts.Enabled && (&ServiceRestartsCollector{}).Supported() {
+		required["service_restarts"] = []string{"systemctl"}
+	}
+	if cfg.Collection.JournalLag.Enabled && (&JournalLagCollector{}).Supported() {
+		required["journal_lag"] = []string{"journalctl"}
+	}
+	if cfg.Collection.SystemdJobs.Enabled && (&SystemdJobsCollector{}).Supported() {
+		required["systemd_jobs"] = []string{"systemctl"}
+	}
+	if cfg.Collection.FsckStatus.Enabled && (&FsckStatusCollector{}).Supported() {
+		required["fsck_status"] = []string{"tune2fs"}
+	}
+	return required
+}
+
+// MissingTools returns the tools that can't be found on PATH for each collector in required
+// Collectors with all their tools available are left out
+func MissingTools(required map[string][]string) map[string][]string {
+	missing := make(map[string][]string)
+	for name, tools := range required {
+		for _, tool := range tools {
+			if _, err := lookPath(tool); err != nil {
+				missing[name] = append(missing[name], tool)
+			}
+		}
+	}
+	return missing
+}
@@ -0,0 +1,58 @@
+package system
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/config"
+)
+
+func TestRequiredTools(t *testing.T) {
+	originalGOOS := goos
+	defer func() { goos = originalGOOS }()
+	goos = "linux"
+
+	cfg := &config.Config{}
+	cfg.Collection.Service.Enabled = true
+	cfg.Collection.ServiceRestarts.Enabled = true
+	cfg.Collection.DB.Enabled = true
+	cfg.Collection.DB.Databases = []config.Database{{Type: "postgres"}, {Type: "mysql"}, {Type: "postgres"}}
+	cfg.Collection.JournalLag.Enabled = false
+
+	want := map[string][]string{
+		"service_restarts": {"systemctl"},
+		"db":               {"mysql", "psql"},
+	}
+	if got := RequiredTools(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredTools() = %v, want %v", got, want)
+	}
+
+	// Collectors skipped as unsupported need no tools
+	goos = "windows"
+	want = map[string][]string{"db": {"mysql", "psql"}}
+	if got := RequiredTools(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredTools() on windows = %v, want %v", got, want)
+	}
+}
+
+func TestMissingTools(t *testing.T) {
+	originalLookPath := lookPath
+	defer func() { lookPath = originalLookPath }()
+
+	lookPath = func(file string) (string, error) {
+		if file == "systemctl" {
+			return "/usr/bin/systemctl", nil
+		}
+		return "", exec.ErrNotFound
+	}
+
+	got := MissingTools(map[string][]string{
+		"service_restarts": {"systemctl"},
+		"db":               {"mysql", "psql"},
+	})
+	want := map[string][]string{"db": {"mysql", "psql"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingTools() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package system
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+// hostBootTime is a variable to allow mocking the boot time from gopsutil in tests
+var hostBootTime = host.BootTime
+
+// UptimeCollector implements the collector.Collector interface for host uptime
+// A drop in uptime between two samples means the host rebooted
+type UptimeCollector struct{}
+
+// NewUptimeCollector creates a new instance of UptimeCollector
+func NewUptimeCollector() collector.Collector {
+	return &UptimeCollector{}
+}
+
+// Collect reports the seconds since the host booted, with the boot time (unix seconds) as metadata
+func (c *UptimeCollector) Collect() ([]collector.Metrics, error) {
+	now := time.Now()
+
+	bootTime, err := hostBootTime()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get boot time: %w", err)
+	}
+
+	uptime := now.Unix() - int64(bootTime)
+	if uptime < 0 {
+		uptime = 0
+	}
+
+	return []collector.Metrics{
+		{
+			Timestamp: now,
+			Category:  collector.CategorySystem,
+			Name:      collector.NameUptime,
+			Metadata: collector.MetricMetadata{
+				"boot_time": strconv.FormatUint(bootTime, 10),
+			},
+			Value: uptime,
+		},
+	}, nil
+}
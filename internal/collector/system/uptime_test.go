@@ -0,0 +1,42 @@
+package system
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestUptimeCollector_Collect(t *testing.T) {
+	originalBootTime := hostBootTime
+	defer func() { hostBootTime = originalBootTime }()
+
+	bootTime := uint64(time.Now().Add(-time.Hour).Unix())
+	hostBootTime = func() (uint64, error) { return bootTime, nil }
+
+	metrics, err := NewUptimeCollector().Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("Collect() returned %d metrics, want 1", len(metrics))
+	}
+
+	metric := metrics[0]
+	if metric.Category != collector.CategorySystem || metric.Name != collector.NameUptime {
+		t.Errorf("Collect() metric = %s/%s, want system/uptime", metric.Category, metric.Name)
+	}
+	if uptime, ok := metric.Value.(int64); !ok || uptime < 3600 || uptime > 3660 {
+		t.Errorf("Collect() value = %v, want about 3600 seconds", metric.Value)
+	}
+	if metric.Metadata["boot_time"] != strconv.FormatUint(bootTime, 10) {
+		t.Errorf("Collect() boot_time = %q, want %d", metric.Metadata["boot_time"], bootTime)
+	}
+
+	hostBootTime = func() (uint64, error) { return 0, errors.New("no /proc/stat") }
+	if _, err := NewUptimeCollector().Collect(); err == nil {
+		t.Error("Collect() error = nil, want the boot time error")
+	}
+}
@@ -9,14 +9,24 @@ import (
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/shirou/gopsutil/v4/host"
 )
 
+// hostUsers is a variable to allow mocking the utmp sessions from gopsutil in tests
+var hostUsers = host.Users
+
+// whoLoginTimeFormat is the login time layout of 'who', used for sessions read from utmp as well
+const whoLoginTimeFormat = "2006-01-02 15:04"
+
 // UserActivityCollector implements the collector.Collector interface for user activity metrics
-type UserActivityCollector struct{}
+type UserActivityCollector struct {
+	CountOnly bool // Report only the number of sessions and distinct users instead of every session
+}
 
 // NewUserActivityCollector creates a new instance of UserActivityCollector
-func NewUserActivityCollector() collector.Collector {
-	return &UserActivityCollector{}
+func NewUserActivityCollector(countOnly bool) collector.Collector {
+	return &UserActivityCollector{CountOnly: countOnly}
 }
 
 // UserSession represents an active user session
@@ -27,6 +37,17 @@ type UserSession struct {
 	LoginTime string `json:"login_time"`
 }
 
+// UserActivityCount is the user activity reported in count-only mode
+type UserActivityCount struct {
+	Sessions int `json:"sessions"`
+	Users    int `json:"users"` // Distinct usernames among the sessions
+}
+
+// Supported reports whether the host keeps utmp login records or has the who command, which Windows doesn't
+func (c *UserActivityCollector) Supported() bool {
+	return goos != "windows" && goos != "plan9"
+}
+
 // Collect gathers user activity metrics by listing active sessions
 func (c *UserActivityCollector) Collect() ([]collector.Metrics, error) {
 	metrics := make([]collector.Metrics, 0, 1)
@@ -37,19 +58,39 @@ func (c *UserActivityCollector) Collect() ([]collector.Metrics, error) {
 		return metrics, fmt.Errorf("failed to get active sessions: %w", err)
 	}
 
-	// Create a single metric with all active sessions
+	// Create a single metric with all active sessions, or only their count
+	var value interface{} = sessions
+	if c.CountOnly {
+		value = countSessions(sessions)
+	}
 	metrics = append(metrics, collector.Metrics{
 		Timestamp: now,
 		Category:  collector.CategorySystem,
 		Name:      collector.NameUserActivity,
-		Value:     sessions,
+		Value:     value,
 	})
 
 	return metrics, nil
 }
 
-// getActiveSessions retrieves active user sessions using the 'who' command
+// countSessions counts sessions and the distinct users they belong to
+func countSessions(sessions []UserSession) UserActivityCount {
+	users := make(map[string]bool)
+	for _, session := range sessions {
+		users[session.Username] = true
+	}
+	return UserActivityCount{Sessions: len(sessions), Users: len(users)}
+}
+
+// getActiveSessions retrieves active user sessions from utmp, falling back to the 'who' command
+// where utmp can't be read directly, e.g. a missing or unreadable /var/run/utmp
 func (c *UserActivityCollector) getActiveSessions() ([]UserSession, error) {
+	users, err := hostUsers()
+	if err == nil {
+		return sessionsFromUtmp(users), nil
+	}
+	logger.Debugf("Failed to read utmp sessions, falling back to who: %v", err)
+
 	// Use 'who' command to get active sessions
 	cmd := exec.Command("who")
 	output, err := cmd.Output()
@@ -60,6 +101,25 @@ func (c *UserActivityCollector) getActiveSessions() ([]UserSession, error) {
 	return c.parseWhoOutput(string(output))
 }
 
+// sessionsFromUtmp converts the utmp user processes to sessions, the host being the source of
+// remote logins
+func sessionsFromUtmp(users []host.UserStat) []UserSession {
+	sessions := make([]UserSession, 0, len(users))
+	for _, user := range users {
+		loginTime := ""
+		if user.Started > 0 {
+			loginTime = time.Unix(int64(user.Started), 0).Format(whoLoginTimeFormat)
+		}
+		sessions = append(sessions, UserSession{
+			Username:  user.User,
+			Terminal:  user.Terminal,
+			LoginIP:   user.Host,
+			LoginTime: loginTime,
+		})
+	}
+	return sessions
+}
+
 // parseWhoOutput parses the output of the 'who' command
 func (c *UserActivityCollector) parseWhoOutput(output string) ([]UserSession, error) {
 	var sessions []UserSession
@@ -1,13 +1,17 @@
 package system
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/shirou/gopsutil/v4/host"
 )
 
 func TestNewUserActivityCollector(t *testing.T) {
-	c := NewUserActivityCollector()
+	c := NewUserActivityCollector(true)
 
 	if c == nil {
 		t.Errorf("NewUserActivityCollector() returned nil")
@@ -18,8 +22,77 @@ func TestNewUserActivityCollector(t *testing.T) {
 	var _ collector.Collector = c
 
 	// Test that it's the correct type
-	if _, ok := c.(*UserActivityCollector); !ok {
+	if uac, ok := c.(*UserActivityCollector); !ok {
 		t.Errorf("NewUserActivityCollector() returned wrong type: %T", c)
+	} else if !uac.CountOnly {
+		t.Errorf("NewUserActivityCollector(true) CountOnly = false, want true")
+	}
+}
+
+func TestUserActivityCollector_Collect_Utmp(t *testing.T) {
+	originalHostUsers := hostUsers
+	defer func() { hostUsers = originalHostUsers }()
+
+	started := time.Date(2024, 1, 15, 10, 30, 0, 0, time.Local)
+	hostUsers = func() ([]host.UserStat, error) {
+		return []host.UserStat{
+			{User: "alice", Terminal: "pts/0", Host: "192.168.1.100", Started: int(started.Unix())},
+			{User: "alice", Terminal: "pts/1", Host: "192.168.1.101", Started: int(started.Unix())},
+			{User: "root", Terminal: "tty1", Started: int(started.Unix())},
+		}, nil
+	}
+
+	tests := []struct {
+		name      string
+		countOnly bool
+		want      interface{}
+	}{
+		{
+			name: "detailed",
+			want: []UserSession{
+				{Username: "alice", Terminal: "pts/0", LoginIP: "192.168.1.100", LoginTime: "2024-01-15 10:30"},
+				{Username: "alice", Terminal: "pts/1", LoginIP: "192.168.1.101", LoginTime: "2024-01-15 10:30"},
+				{Username: "root", Terminal: "tty1", LoginTime: "2024-01-15 10:30"},
+			},
+		},
+		{
+			name:      "count only",
+			countOnly: true,
+			want:      UserActivityCount{Sessions: 3, Users: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := NewUserActivityCollector(tt.countOnly).Collect()
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+			if len(metrics) != 1 {
+				t.Fatalf("Collect() returned %d metrics, want 1", len(metrics))
+			}
+			if !reflect.DeepEqual(metrics[0].Value, tt.want) {
+				t.Errorf("Collect() value = %+v, want %+v", metrics[0].Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserActivityCollector_Collect_UtmpUnreadable(t *testing.T) {
+	originalHostUsers := hostUsers
+	defer func() { hostUsers = originalHostUsers }()
+	hostUsers = func() ([]host.UserStat, error) {
+		return nil, errors.New("open /var/run/utmp: permission denied")
+	}
+
+	// Sessions then come from 'who', which may be missing in the test environment
+	metrics, err := NewUserActivityCollector(true).Collect()
+	if err != nil {
+		t.Logf("UserActivityCollector.Collect() error (might be expected in test environment): %v", err)
+		return
+	}
+	if _, ok := metrics[0].Value.(UserActivityCount); !ok {
+		t.Errorf("Collect() value = %T, want UserActivityCount", metrics[0].Value)
 	}
 }
 
@@ -1,70 +1,344 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/monitorly-app/probe/internal/encryption"
+	"github.com/monitorly-app/probe/internal/logger"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the application configuration
 type Config struct {
-	MachineName string `yaml:"machine_name"` // Machine name used to differentiate metrics from different servers
+	MachineName string            `yaml:"machine_name"` // Machine name used to differentiate metrics from different servers
+	Labels      map[string]string `yaml:"labels"`       // Labels added to the metadata of every metric
 	Collection  struct {
-		CPU struct {
-			Enabled  bool          `yaml:"enabled"`
-			Interval time.Duration `yaml:"interval"`
+		Scheduler string `yaml:"scheduler"` // How collectors are run: goroutine (one per collector) or pool
+		Workers   int    `yaml:"workers"`   // Number of collectors run concurrently in pool mode
+		Precision *int   `yaml:"precision"` // Decimal places metric values are rounded to, 2 when unset. Collectors can override it
+		CPU       struct {
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Precision *int              `yaml:"precision"`
 		} `yaml:"cpu"`
 		RAM struct {
-			Enabled  bool          `yaml:"enabled"`
-			Interval time.Duration `yaml:"interval"`
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Precision *int              `yaml:"precision"`
 		} `yaml:"ram"`
 		Disk struct {
-			Enabled     bool          `yaml:"enabled"`
-			Interval    time.Duration `yaml:"interval"`
-			MountPoints []MountPoint  `yaml:"mount_points"`
+			Enabled        bool              `yaml:"enabled"`
+			Interval       time.Duration     `yaml:"interval"`
+			Tags           map[string]string `yaml:"tags"`
+			TTL            time.Duration     `yaml:"ttl"`
+			MountPoints    []MountPoint      `yaml:"mount_points"`
+			AutoDiscover   bool              `yaml:"auto_discover"`   // Also monitor every mounted filesystem, merged with mount_points
+			ExcludeFSTypes []string          `yaml:"exclude_fstypes"` // Filesystem types never discovered (defaults to tmpfs, devtmpfs, squashfs and overlay)
+			ExcludePaths   []string          `yaml:"exclude_paths"`   // Glob patterns of mount points never discovered
+			Precision      *int              `yaml:"precision"`
 		} `yaml:"disk"`
 		Service struct {
-			Enabled  bool          `yaml:"enabled"`
-			Interval time.Duration `yaml:"interval"`
-			Services []Service     `yaml:"services"`
+			Enabled    bool              `yaml:"enabled"`
+			Interval   time.Duration     `yaml:"interval"`
+			Tags       map[string]string `yaml:"tags"`
+			TTL        time.Duration     `yaml:"ttl"`
+			Services   []Service         `yaml:"services"`
+			Accounting bool              `yaml:"accounting"` // Report systemd memory/CPU accounting per service
 		} `yaml:"service"`
 		UserActivity struct {
-			Enabled  bool          `yaml:"enabled"`
-			Interval time.Duration `yaml:"interval"`
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			CountOnly bool              `yaml:"count_only"` // Report only session and user counts instead of every session
 		} `yaml:"user_activity"`
 		LoginFailures struct {
-			Enabled  bool          `yaml:"enabled"`
-			Interval time.Duration `yaml:"interval"`
+			Enabled           bool              `yaml:"enabled"`
+			Interval          time.Duration     `yaml:"interval"`
+			Tags              map[string]string `yaml:"tags"`
+			TTL               time.Duration     `yaml:"ttl"`
+			SummarizeBySource bool              `yaml:"summarize_by_source"` // Report first/last seen, count and usernames per source IP
+			Aggregate         bool              `yaml:"aggregate"`           // Report only a failure count per source IP, for hosts under brute-force attack
+			Services          []LoginService    `yaml:"services"`            // Optional: Extra authentication services (e.g. vsftpd, dovecot) to collect failures from
+			InitialLookback   time.Duration     `yaml:"initial_lookback"`    // How far back the first collection scans (defaults to 1m)
+			StateFile         string            `yaml:"state_file"`          // Optional: File persisting the last check so restarts resume from it
+			MaxLookback       time.Duration     `yaml:"max_lookback"`        // Cap on how far back a resumed scan goes (defaults to 24h)
 		} `yaml:"login_failures"`
 		Port struct {
-			Enabled  bool          `yaml:"enabled"`
-			Interval time.Duration `yaml:"interval"`
+			Enabled        bool              `yaml:"enabled"`
+			Interval       time.Duration     `yaml:"interval"`
+			Tags           map[string]string `yaml:"tags"`
+			TTL            time.Duration     `yaml:"ttl"`
+			Targets        []PortTarget      `yaml:"targets"`         // Optional: Remote host:port targets checked for reachability
+			ExpectedOpen   []ExpectedPort    `yaml:"expected_open"`   // Optional: Ports that should have a listener, flagged when they don't
+			ExpectedClosed []ExpectedPort    `yaml:"expected_closed"` // Optional: Ports that should not have a listener, flagged when they do
 		} `yaml:"port"`
+		Freshness struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+			Files    []FreshnessFile   `yaml:"files"`
+		} `yaml:"freshness"`
+		TCPStates struct {
+			Enabled               bool              `yaml:"enabled"`
+			Interval              time.Duration     `yaml:"interval"`
+			Tags                  map[string]string `yaml:"tags"`
+			TTL                   time.Duration     `yaml:"ttl"`
+			ListenEstablishedOnly bool              `yaml:"listen_established_only"` // Only count LISTEN and ESTABLISHED sockets
+		} `yaml:"tcp_states"`
+		Process struct {
+			Enabled      bool              `yaml:"enabled"`
+			Interval     time.Duration     `yaml:"interval"`
+			Tags         map[string]string `yaml:"tags"`
+			TTL          time.Duration     `yaml:"ttl"`
+			Match        []string          `yaml:"match"`         // Process name patterns (glob syntax, e.g. "nginx*")
+			MaxProcesses int               `yaml:"max_processes"` // Maximum number of processes reported per collection
+			Precision    *int              `yaml:"precision"`
+		} `yaml:"process"`
+		DB struct {
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Databases []Database        `yaml:"databases"`
+			Precision *int              `yaml:"precision"`
+		} `yaml:"db"`
+		HTTPCheck struct {
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Endpoints []HTTPEndpoint    `yaml:"endpoints"`
+		} `yaml:"http_check"`
+		Inotify struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+		} `yaml:"inotify"`
+		HugePages struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+		} `yaml:"hugepages"`
+		DirQueue struct {
+			Enabled     bool              `yaml:"enabled"`
+			Interval    time.Duration     `yaml:"interval"`
+			Tags        map[string]string `yaml:"tags"`
+			TTL         time.Duration     `yaml:"ttl"`
+			Directories []QueueDir        `yaml:"directories"`
+			WalkTimeout time.Duration     `yaml:"walk_timeout"` // Maximum time spent walking a single directory
+		} `yaml:"dir_queue"`
+		ServiceRestarts struct {
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Units     []string          `yaml:"units"`     // Units to check, all service units when empty
+			Threshold uint64            `yaml:"threshold"` // Only units restarted more often than this are reported
+		} `yaml:"service_restarts"`
+		SSHSessions struct {
+			Enabled    bool              `yaml:"enabled"`
+			Interval   time.Duration     `yaml:"interval"`
+			Tags       map[string]string `yaml:"tags"`
+			TTL        time.Duration     `yaml:"ttl"`
+			Port       uint32            `yaml:"port"`        // Local port the SSH server listens on
+			TopSources int               `yaml:"top_sources"` // Maximum number of source IPs reported
+		} `yaml:"ssh_sessions"`
+		PIDFile struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+			Files    []PIDFile         `yaml:"files"`
+		} `yaml:"pidfile"`
+		ShutdownState struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+		} `yaml:"shutdown_state"`
+		CoreDumps struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+			Dir      string            `yaml:"dir"` // Optional: Core dump directory, found from kernel.core_pattern when empty
+		} `yaml:"coredumps"`
+		SuspendDetect struct {
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Threshold time.Duration     `yaml:"threshold"` // Minimum wall clock jump reported as a suspend
+		} `yaml:"suspend_detect"`
+		JournalLag struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+			Cursors  []JournalCursor   `yaml:"cursors"`
+		} `yaml:"journal_lag"`
+		SystemdJobs struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+		} `yaml:"systemd_jobs"`
+		FsckStatus struct {
+			Enabled      bool              `yaml:"enabled"`
+			Interval     time.Duration     `yaml:"interval"`
+			Tags         map[string]string `yaml:"tags"`
+			TTL          time.Duration     `yaml:"ttl"`
+			MountsMargin int               `yaml:"mounts_margin"` // fsck_due_soon is set when at most this many mounts are left
+			DueWithin    time.Duration     `yaml:"due_within"`    // fsck_due_soon is set when the check interval ends within this duration
+		} `yaml:"fsck_status"`
+		Uptime struct {
+			Enabled  bool              `yaml:"enabled"`
+			Interval time.Duration     `yaml:"interval"`
+			Tags     map[string]string `yaml:"tags"`
+			TTL      time.Duration     `yaml:"ttl"`
+		} `yaml:"uptime"`
+		Temperature struct {
+			Enabled   bool              `yaml:"enabled"`
+			Interval  time.Duration     `yaml:"interval"`
+			Tags      map[string]string `yaml:"tags"`
+			TTL       time.Duration     `yaml:"ttl"`
+			Precision *int              `yaml:"precision"`
+		} `yaml:"temperature"`
+		Custom map[string]CustomCollector `yaml:"custom"` // Collectors added with collectors.Register, keyed by registered name
 	} `yaml:"collection"`
 	Sender struct {
-		Target       string        `yaml:"target"`
-		SendInterval time.Duration `yaml:"send_interval"`
+		Target                string        `yaml:"target"`
+		SendInterval          time.Duration `yaml:"send_interval"`
+		EncryptAtRest         bool          `yaml:"encrypt_at_rest"`         // Encrypt locally stored metrics with api.encryption_key
+		SpoolDir              string        `yaml:"spool_dir"`               // Optional: Directory where unsent metrics are stored when the API is unreachable
+		SpoolMaxSizeMB        int           `yaml:"spool_max_size_mb"`       // Maximum total size of the spool, oldest batches are dropped first
+		MaxRetries            int           `yaml:"max_retries"`             // Retries for transient API failures, 0 disables retrying
+		InitialBackoff        time.Duration `yaml:"initial_backoff"`         // Delay before the first retry, doubled on each retry
+		MaxBackoff            time.Duration `yaml:"max_backoff"`             // Upper bound for the delay between retries
+		ShutdownTimeout       time.Duration `yaml:"shutdown_timeout"`        // Time allowed for sending buffered metrics on shutdown
+		SequenceFile          string        `yaml:"sequence_file"`           // Optional: State file enabling a persistent sequence number on each send
+		OnFull                string        `yaml:"on_full"`                 // What collectors do when the metrics queue is full: block, drop_oldest or drop_new
+		Streaming             bool          `yaml:"streaming"`               // Stream metrics to the API as gzipped NDJSON, requires backend support
+		IncludeChangeManifest bool          `yaml:"include_change_manifest"` // List the metric keys whose value changed since the last send in each payload
+		IncludeDigest         bool          `yaml:"include_digest"`          // Add the SHA-256 of each batch to payloads, spooled batches and log file records
+		Serialization         string        `yaml:"serialization"`           // Metrics payload format sent to the API: json or protobuf
+		MaxBatchSize          int           `yaml:"max_batch_size"`          // Maximum metrics per API request, larger sends are split, 0 for no limit
+		Aggregate             struct {
+			Enabled bool     `yaml:"enabled"`
+			Metrics []string `yaml:"metrics"`  // Names of scalar metrics to summarize (e.g. "cpu", "ram")
+			KeepRaw bool     `yaml:"keep_raw"` // Send raw samples alongside the summaries
+		} `yaml:"aggregate"`
 	} `yaml:"sender"`
 	API struct {
-		URL              string `yaml:"url"`
-		OrganizationID   string `yaml:"organization_id"`   // Organization ID (UUID) for API requests
-		ServerID         string `yaml:"server_id"`         // Server ID (UUID) for API requests
-		ApplicationToken string `yaml:"application_token"` // Application token for API authentication
-		EncryptionKey    string `yaml:"encryption_key"`    // Optional: If set, encrypts the request body. Requires premium subscription.
+		URL               string        `yaml:"url"`
+		OrganizationID    string        `yaml:"organization_id"`     // Organization ID (UUID) for API requests
+		ServerID          string        `yaml:"server_id"`           // Server ID (UUID) for API requests
+		ApplicationToken  string        `yaml:"application_token"`   // Application token for API authentication
+		EncryptionKey     string        `yaml:"encryption_key"`      // Optional: If set, encrypts the request body. Requires premium subscription.
+		EncryptionKeyFile string        `yaml:"encryption_key_file"` // Optional: File holding the encryption key, takes precedence over encryption_key
+		DecryptKeys       []string      `yaml:"decrypt_keys"`        // Optional: Previous keys still accepted when reading data stored at rest
+		KeyDerivation     string        `yaml:"key_derivation"`      // Optional: "pbkdf2" to treat the keys above as passphrases
+		MaxBodyBytes      int           `yaml:"max_body_bytes"`      // Optional: Hard ceiling on the compressed request body, lowest priority metrics are dropped to fit
+		CategoryPriority  []string      `yaml:"category_priority"`   // Metric categories from most to least important when trimming to max_body_bytes
+		RequestTimeout    time.Duration `yaml:"request_timeout"`     // Time allowed for each API request, including reading the response
+		Proxy             string        `yaml:"proxy"`               // Optional: Proxy URL for API and update requests, overrides HTTP_PROXY/HTTPS_PROXY
+		Compression       struct {
+			Enabled *bool `yaml:"enabled"`  // Gzip request bodies, true when unset
+			Level   int   `yaml:"level"`    // Gzip level from 1 (fastest) to 9 (smallest), 6 when unset
+			MinSize int   `yaml:"min_size"` // Bodies smaller than this many bytes are sent uncompressed
+		} `yaml:"compression"`
+		TLS struct {
+			CAFile         string `yaml:"ca_file"`          // Optional: PEM CA bundle trusted instead of the system trust store
+			ClientCertFile string `yaml:"client_cert_file"` // Optional: PEM client certificate presented for mutual TLS
+			ClientKeyFile  string `yaml:"client_key_file"`  // Optional: PEM private key of the client certificate
+		} `yaml:"tls"`
+		ConfigFetch struct {
+			MaxRetries     int           `yaml:"max_retries"`     // Retries for fetching config updated on the server, 0 disables retrying
+			InitialBackoff time.Duration `yaml:"initial_backoff"` // Delay before the first retry, doubled on each retry
+			MaxBackoff     time.Duration `yaml:"max_backoff"`     // Upper bound for the delay between retries
+		} `yaml:"config_fetch"`
 	} `yaml:"api"`
 	LogFile struct {
-		Path string `yaml:"path"`
+		Path   string `yaml:"path"`
+		Format string `yaml:"format"` // json or csv, defaults to csv for paths ending in .csv
 	} `yaml:"log_file"`
+	StatsD struct {
+		Address string `yaml:"address"` // StatsD server address (host:port)
+		Prefix  string `yaml:"prefix"`  // Prefix prepended to every metric path
+	} `yaml:"statsd"`
+	OTLP struct {
+		Endpoint string            `yaml:"endpoint"` // OpenTelemetry collector URL, e.g. http://localhost:4318
+		Protocol string            `yaml:"protocol"` // Export protocol: http (OTLP/HTTP with protobuf) or grpc
+		Headers  map[string]string `yaml:"headers"`  // Optional: Headers added to every export, e.g. for authentication
+		Timeout  time.Duration     `yaml:"timeout"`  // Time allowed for each export
+	} `yaml:"otlp"`
+	MQTT struct {
+		Host      string        `yaml:"host"`       // Broker host name or IP address
+		Port      int           `yaml:"port"`       // Broker port, 1883 or 8883 with TLS when unset
+		Topic     string        `yaml:"topic"`      // Topic of the batches, {machine_name} is replaced by the machine name
+		ClientID  string        `yaml:"client_id"`  // Optional: Client identifier, monitorly-probe-<machine name> when unset
+		Username  string        `yaml:"username"`   // Optional: User name for authentication
+		Password  string        `yaml:"password"`   // Optional: Password for authentication
+		QoS       int           `yaml:"qos"`        // Quality of service of the publishes: 0, 1 or 2
+		KeepAlive time.Duration `yaml:"keep_alive"` // Interval of the keep alive pings between batches
+		Timeout   time.Duration `yaml:"timeout"`    // Time allowed for connecting and for each publish
+		TLS       struct {
+			Enabled        bool   `yaml:"enabled"`          // Connect to the broker over TLS
+			CAFile         string `yaml:"ca_file"`          // Optional: PEM CA bundle trusted instead of the system trust store
+			ClientCertFile string `yaml:"client_cert_file"` // Optional: PEM client certificate presented for mutual TLS
+			ClientKeyFile  string `yaml:"client_key_file"`  // Optional: PEM private key of the client certificate
+		} `yaml:"tls"`
+	} `yaml:"mqtt"`
+	Syslog struct {
+		Network  string `yaml:"network"`  // Optional: "udp" or "tcp" to send to a remote server, the local daemon when empty
+		Address  string `yaml:"address"`  // Remote syslog server address (host:port), required with network
+		Facility string `yaml:"facility"` // Syslog facility, e.g. daemon or local0
+		Tag      string `yaml:"tag"`      // Tag (program name) of the syslog messages
+	} `yaml:"syslog"`
 	Logging struct {
 		FilePath string `yaml:"file_path"`
+		Level    string `yaml:"level"`  // Minimum level of logged messages: debug, info, warn or error
+		Format   string `yaml:"format"` // Log line format: text or json
 	} `yaml:"logging"`
 	Updates struct {
-		Enabled    bool          `yaml:"enabled"`
-		CheckTime  string        `yaml:"check_time"`  // Time of day to check for updates (HH:MM format)
-		RetryDelay time.Duration `yaml:"retry_delay"` // How long to wait before retrying after a failed update
+		Enabled            bool          `yaml:"enabled"`
+		CheckTime          string        `yaml:"check_time"`          // Time of day to check for updates (HH:MM format)
+		CheckJitter        time.Duration `yaml:"check_jitter"`        // Optional: Maximum random delay after check_time, to spread a fleet's checks
+		RetryDelay         time.Duration `yaml:"retry_delay"`         // How long to wait before retrying after a failed update
+		VerifySignature    bool          `yaml:"verify_signature"`    // Only install updates with a valid detached signature
+		PublicKeyFile      string        `yaml:"public_key_file"`     // Optional: PEM public key verifying update signatures, instead of the bundled release key
+		ReleaseURL         string        `yaml:"release_url"`         // Optional: Latest release API URL of another repository, e.g. a fork
+		IncludePrereleases bool          `yaml:"include_prereleases"` // Also install prereleases, picking the highest version
+		NotifyWebhook      string        `yaml:"notify_webhook"`      // Optional: URL receiving a JSON POST after each update attempt
 	} `yaml:"updates"`
+	Runtime struct {
+		User          string        `yaml:"user"`            // Optional: Unprivileged user to switch to after startup (Linux only)
+		Group         string        `yaml:"group"`           // Optional: Group to switch to, defaults to the user's primary group
+		StartupJitter time.Duration `yaml:"startup_jitter"`  // Optional: Maximum random delay before starting collectors and sender
+		SkipToolCheck bool          `yaml:"skip_tool_check"` // Optional: Don't warn at startup about external tools missing for enabled collectors
+	} `yaml:"runtime"`
+	Debug struct {
+		ValidateMetadata bool `yaml:"validate_metadata"` // Log a warning when metrics use reserved metadata keys or collide with labels
+		LogRequests      bool `yaml:"log_requests"`      // Log API request bodies and error responses, with the application token redacted
+	} `yaml:"debug"`
 }
 
 // MountPoint represents a disk mount point configuration
@@ -73,6 +347,7 @@ type MountPoint struct {
 	Label          string `yaml:"label"`
 	CollectUsage   bool   `yaml:"collect_usage"`
 	CollectPercent bool   `yaml:"collect_percent"`
+	CollectInodes  bool   `yaml:"collect_inodes"`
 }
 
 // Service represents a system service to monitor
@@ -81,6 +356,84 @@ type Service struct {
 	Label string `yaml:"label"` // User-friendly label for the service
 }
 
+// FreshnessFile represents a marker file whose age is monitored (e.g. a backup completion marker)
+type FreshnessFile struct {
+	Path   string        `yaml:"path"`    // Path to the marker file
+	Label  string        `yaml:"label"`   // User-friendly label for the file
+	MaxAge time.Duration `yaml:"max_age"` // Age after which the file is considered stale
+}
+
+// PIDFile represents a daemon PID file whose process liveness is checked
+type PIDFile struct {
+	PIDFile     string `yaml:"pidfile"`      // Path to the PID file
+	Label       string `yaml:"label"`        // User-friendly label for the daemon
+	ProcessName string `yaml:"process_name"` // Optional: Expected process name, guards against reused PIDs
+}
+
+// JournalCursor represents a journal cursor persisted by a log-processing pipeline
+type JournalCursor struct {
+	Name       string `yaml:"name"`        // Name of the consumer, sent as metadata
+	CursorFile string `yaml:"cursor_file"` // Path to the file holding the consumer's cursor
+}
+
+// Database represents a database whose connection count is monitored
+type Database struct {
+	Type     string `yaml:"type"`     // Database type: "postgres" or "mysql"
+	Label    string `yaml:"label"`    // User-friendly label for the database
+	Host     string `yaml:"host"`     // Host to connect to (defaults to the client's default)
+	Port     int    `yaml:"port"`     // Port to connect to (defaults to the client's default)
+	Socket   string `yaml:"socket"`   // Optional: Unix socket path (directory for postgres) instead of host/port
+	User     string `yaml:"user"`     // User to authenticate as
+	Password string `yaml:"password"` // Optional: Password, passed to the client through the environment
+	Database string `yaml:"database"` // Optional: Database name to connect to
+}
+
+// HTTPEndpoint represents an HTTP endpoint whose health is checked
+type HTTPEndpoint struct {
+	URL            string        `yaml:"url"`             // URL to request with GET
+	Label          string        `yaml:"label"`           // User-friendly label for the endpoint
+	ExpectedStatus int           `yaml:"expected_status"` // Status code considered healthy (defaults to 200)
+	Timeout        time.Duration `yaml:"timeout"`         // Request timeout (defaults to 10s)
+}
+
+// PortTarget represents a remote TCP port whose reachability is checked
+type PortTarget struct {
+	Address string        `yaml:"address"` // Target as host:port
+	Label   string        `yaml:"label"`   // User-friendly label for the target
+	Timeout time.Duration `yaml:"timeout"` // Dial timeout (defaults to 5s)
+}
+
+// ExpectedPort represents a local port whose listening state is checked against expectations
+type ExpectedPort struct {
+	Port     uint32 `yaml:"port"`     // Local port number
+	Protocol string `yaml:"protocol"` // "tcp" or "udp" (defaults to tcp)
+}
+
+// QueueDir represents a directory whose file backlog is monitored (e.g. a mail queue)
+type QueueDir struct {
+	Path      string `yaml:"path"`      // Directory to count files in
+	Label     string `yaml:"label"`     // User-friendly label for the directory
+	Recursive bool   `yaml:"recursive"` // Also count files in subdirectories
+	MaxFiles  int    `yaml:"max_files"` // Stop counting after this many files (defaults to 100000)
+}
+
+// LoginService represents an extra authentication service whose login failures are collected
+type LoginService struct {
+	Name     string   `yaml:"name"`     // Service name as it appears in log lines, also used as the reported service
+	Unit     string   `yaml:"unit"`     // Optional: systemd unit to read from the journal (defaults to name)
+	Builtin  string   `yaml:"builtin"`  // Optional: Built-in pattern set: "vsftpd", "dovecot" or "pam"
+	Patterns []string `yaml:"patterns"` // Optional: Regexes matching a failure, with optional named groups "user" and "ip"
+}
+
+// CustomCollector configures a collector registered by an embedding program with collectors.Register
+type CustomCollector struct {
+	Enabled  bool              `yaml:"enabled"`
+	Interval time.Duration     `yaml:"interval"`
+	Tags     map[string]string `yaml:"tags"`
+	TTL      time.Duration     `yaml:"ttl"`
+	Options  map[string]string `yaml:"options"` // Settings passed as is to the collector
+}
+
 // Collection holds the configuration for metric collection
 type Collection struct {
 	CPU struct {
@@ -130,7 +483,53 @@ func (c *Config) GetMachineName() (string, error) {
 	return hostname, nil
 }
 
-// Load reads the configuration file from the given path and returns a Config
+// Format is the syntax of a configuration file
+type Format string
+
+// Supported configuration file formats
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// FormatOf returns the format of the configuration file at path from its extension: .json and .toml
+// files are JSON and TOML, any other file is YAML
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// unmarshal decodes data in the given format into cfg. All formats go through the YAML decoder so
+// they share the yaml field names: JSON is valid YAML, and TOML is converted to YAML first
+func unmarshal(data []byte, format Format, cfg *Config) error {
+	switch format {
+	case FormatJSON:
+		// Reject what YAML would accept but isn't JSON
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	case FormatTOML:
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid TOML: %w", err)
+		}
+		var err error
+		if data, err = yaml.Marshal(doc); err != nil {
+			return err
+		}
+	}
+	return yaml.Unmarshal(data, cfg)
+}
+
+// Load reads the configuration file from the given path, in the format given by its extension, and returns a Config
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -138,19 +537,33 @@ func Load(path string) (*Config, error) {
 	}
 
 	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
-	if err != nil {
+	if err := unmarshal(data, FormatOf(path), &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Override fields from MONITORLY_* environment variables
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, err
+	}
+
 	// Apply defaults
 	applyDefaults(&cfg)
 
+	// Pick the encryption key from the environment, a key file or the config
+	if err := resolveEncryptionKey(&cfg); err != nil {
+		return nil, err
+	}
+
 	// Validate configuration
 	if err := validate(&cfg); err != nil {
 		return nil, err
 	}
 
+	// Turn passphrases into AES keys
+	if err := deriveKeys(&cfg); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
@@ -174,8 +587,12 @@ func applyDefaults(cfg *Config) {
 		cfg.Collection.Disk.Interval = 60 * time.Second
 	}
 
-	// If no mount points are specified, add the root mount point
-	if len(cfg.Collection.Disk.MountPoints) == 0 {
+	if cfg.Collection.Disk.AutoDiscover && cfg.Collection.Disk.ExcludeFSTypes == nil {
+		cfg.Collection.Disk.ExcludeFSTypes = []string{"tmpfs", "devtmpfs", "squashfs", "overlay"}
+	}
+
+	// If no mount points are specified or discovered, add the root mount point
+	if len(cfg.Collection.Disk.MountPoints) == 0 && !cfg.Collection.Disk.AutoDiscover {
 		cfg.Collection.Disk.MountPoints = []MountPoint{
 			{
 				Path:           "/",
@@ -197,12 +614,148 @@ func applyDefaults(cfg *Config) {
 	if cfg.Collection.LoginFailures.Interval == 0 {
 		cfg.Collection.LoginFailures.Interval = 1 * time.Minute
 	}
+	if cfg.Collection.LoginFailures.InitialLookback == 0 {
+		cfg.Collection.LoginFailures.InitialLookback = 1 * time.Minute
+	}
+	if cfg.Collection.LoginFailures.MaxLookback == 0 {
+		cfg.Collection.LoginFailures.MaxLookback = 24 * time.Hour
+	}
 
 	// Set defaults for port monitoring collection
 	cfg.Collection.Port.Enabled = true
 	if cfg.Collection.Port.Interval == 0 {
 		cfg.Collection.Port.Interval = 1 * time.Minute
 	}
+	for i := range cfg.Collection.Port.Targets {
+		if cfg.Collection.Port.Targets[i].Timeout == 0 {
+			cfg.Collection.Port.Targets[i].Timeout = 5 * time.Second
+		}
+	}
+
+	// Set defaults for freshness collection
+	if cfg.Collection.Freshness.Interval == 0 {
+		cfg.Collection.Freshness.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for TCP states collection
+	if cfg.Collection.TCPStates.Interval == 0 {
+		cfg.Collection.TCPStates.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for process collection
+	if cfg.Collection.Process.Interval == 0 {
+		cfg.Collection.Process.Interval = 1 * time.Minute
+	}
+	if cfg.Collection.Process.MaxProcesses == 0 {
+		cfg.Collection.Process.MaxProcesses = 50
+	}
+
+	// Set defaults for database connections collection
+	if cfg.Collection.DB.Interval == 0 {
+		cfg.Collection.DB.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for HTTP check collection
+	if cfg.Collection.HTTPCheck.Interval == 0 {
+		cfg.Collection.HTTPCheck.Interval = 1 * time.Minute
+	}
+	for i := range cfg.Collection.HTTPCheck.Endpoints {
+		if cfg.Collection.HTTPCheck.Endpoints[i].ExpectedStatus == 0 {
+			cfg.Collection.HTTPCheck.Endpoints[i].ExpectedStatus = 200
+		}
+		if cfg.Collection.HTTPCheck.Endpoints[i].Timeout == 0 {
+			cfg.Collection.HTTPCheck.Endpoints[i].Timeout = 10 * time.Second
+		}
+	}
+
+	// Set defaults for inotify collection
+	if cfg.Collection.Inotify.Interval == 0 {
+		cfg.Collection.Inotify.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for huge pages collection
+	if cfg.Collection.HugePages.Interval == 0 {
+		cfg.Collection.HugePages.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for directory queue collection
+	if cfg.Collection.DirQueue.Interval == 0 {
+		cfg.Collection.DirQueue.Interval = 1 * time.Minute
+	}
+	if cfg.Collection.DirQueue.WalkTimeout == 0 {
+		cfg.Collection.DirQueue.WalkTimeout = 5 * time.Second
+	}
+	for i := range cfg.Collection.DirQueue.Directories {
+		if cfg.Collection.DirQueue.Directories[i].MaxFiles == 0 {
+			cfg.Collection.DirQueue.Directories[i].MaxFiles = 100000
+		}
+	}
+
+	// Set defaults for service restarts collection
+	if cfg.Collection.ServiceRestarts.Interval == 0 {
+		cfg.Collection.ServiceRestarts.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for SSH sessions collection
+	if cfg.Collection.SSHSessions.Interval == 0 {
+		cfg.Collection.SSHSessions.Interval = 1 * time.Minute
+	}
+	if cfg.Collection.SSHSessions.Port == 0 {
+		cfg.Collection.SSHSessions.Port = 22
+	}
+	if cfg.Collection.SSHSessions.TopSources == 0 {
+		cfg.Collection.SSHSessions.TopSources = 10
+	}
+
+	// Set defaults for PID file collection
+	if cfg.Collection.PIDFile.Interval == 0 {
+		cfg.Collection.PIDFile.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for shutdown state collection
+	if cfg.Collection.ShutdownState.Interval == 0 {
+		cfg.Collection.ShutdownState.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for core dump collection
+	if cfg.Collection.CoreDumps.Interval == 0 {
+		cfg.Collection.CoreDumps.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for suspend detection
+	if cfg.Collection.SuspendDetect.Interval == 0 {
+		cfg.Collection.SuspendDetect.Interval = 1 * time.Minute
+	}
+	if cfg.Collection.SuspendDetect.Threshold == 0 {
+		cfg.Collection.SuspendDetect.Threshold = 5 * time.Second
+	}
+	if cfg.Collection.JournalLag.Interval == 0 {
+		cfg.Collection.JournalLag.Interval = 1 * time.Minute
+	}
+	if cfg.Collection.SystemdJobs.Interval == 0 {
+		cfg.Collection.SystemdJobs.Interval = 1 * time.Minute
+	}
+
+	// Set defaults for fsck status
+	if cfg.Collection.FsckStatus.Interval == 0 {
+		cfg.Collection.FsckStatus.Interval = 1 * time.Minute
+	}
+	if cfg.Collection.FsckStatus.MountsMargin == 0 {
+		cfg.Collection.FsckStatus.MountsMargin = 5
+	}
+	if cfg.Collection.FsckStatus.DueWithin == 0 {
+		cfg.Collection.FsckStatus.DueWithin = 7 * 24 * time.Hour
+	}
+
+	// Set defaults for uptime collection, which changes slowly
+	if cfg.Collection.Uptime.Interval == 0 {
+		cfg.Collection.Uptime.Interval = 5 * time.Minute
+	}
+
+	// Set defaults for temperature collection
+	if cfg.Collection.Temperature.Interval == 0 {
+		cfg.Collection.Temperature.Interval = 1 * time.Minute
+	}
 
 	// Set defaults for sender
 	if cfg.Sender.SendInterval == 0 {
@@ -211,14 +764,212 @@ func applyDefaults(cfg *Config) {
 	if cfg.Sender.Target == "" {
 		cfg.Sender.Target = "api"
 	}
+	if cfg.Sender.SpoolMaxSizeMB == 0 {
+		cfg.Sender.SpoolMaxSizeMB = 100
+	}
+	if cfg.Sender.InitialBackoff == 0 {
+		cfg.Sender.InitialBackoff = 1 * time.Second
+	}
+	if cfg.Sender.MaxBackoff == 0 {
+		cfg.Sender.MaxBackoff = 30 * time.Second
+	}
+	if cfg.Sender.ShutdownTimeout == 0 {
+		cfg.Sender.ShutdownTimeout = 10 * time.Second
+	}
+	if cfg.Sender.OnFull == "" {
+		cfg.Sender.OnFull = "block"
+	}
+	if cfg.Sender.Serialization == "" {
+		cfg.Sender.Serialization = "json"
+	}
+
+	// Set defaults for API requests
+	if cfg.API.RequestTimeout == 0 {
+		cfg.API.RequestTimeout = 30 * time.Second
+	}
+
+	// Set defaults for metric value rounding
+	if cfg.Collection.Precision == nil {
+		precision := defaultPrecision
+		cfg.Collection.Precision = &precision
+	}
+
+	// Set defaults for request compression
+	if cfg.API.Compression.Enabled == nil {
+		enabled := true
+		cfg.API.Compression.Enabled = &enabled
+	}
+	if cfg.API.Compression.Level == 0 {
+		cfg.API.Compression.Level = 6
+	}
+
+	// Set defaults for body size ceiling
+	if len(cfg.API.CategoryPriority) == 0 {
+		cfg.API.CategoryPriority = []string{"system", "probe"}
+	}
+
+	// Set defaults for config fetch retries
+	if cfg.API.ConfigFetch.InitialBackoff == 0 {
+		cfg.API.ConfigFetch.InitialBackoff = 1 * time.Second
+	}
+	if cfg.API.ConfigFetch.MaxBackoff == 0 {
+		cfg.API.ConfigFetch.MaxBackoff = 10 * time.Second
+	}
 
 	// Set defaults for log paths
 	if cfg.LogFile.Path == "" {
 		cfg.LogFile.Path = "logs/metrics.log"
 	}
+	if cfg.LogFile.Format == "" {
+		cfg.LogFile.Format = "json"
+		if strings.EqualFold(filepath.Ext(cfg.LogFile.Path), ".csv") {
+			cfg.LogFile.Format = "csv"
+		}
+	}
+
+	// Set defaults for OTLP
+	if cfg.OTLP.Protocol == "" {
+		cfg.OTLP.Protocol = "http"
+	}
+	if cfg.OTLP.Timeout == 0 {
+		cfg.OTLP.Timeout = 10 * time.Second
+	}
+
+	// Set defaults for MQTT
+	if cfg.MQTT.Port == 0 {
+		cfg.MQTT.Port = 1883
+		if cfg.MQTT.TLS.Enabled {
+			cfg.MQTT.Port = 8883
+		}
+	}
+	if cfg.MQTT.Topic == "" {
+		cfg.MQTT.Topic = "monitorly/{machine_name}/metrics"
+	}
+	if cfg.MQTT.KeepAlive == 0 {
+		cfg.MQTT.KeepAlive = 60 * time.Second
+	}
+	if cfg.MQTT.Timeout == 0 {
+		cfg.MQTT.Timeout = 10 * time.Second
+	}
+
+	// Set defaults for StatsD
+	if cfg.StatsD.Prefix == "" {
+		cfg.StatsD.Prefix = "monitorly"
+	}
+
+	// Set defaults for syslog
+	if cfg.Syslog.Facility == "" {
+		cfg.Syslog.Facility = "daemon"
+	}
+	if cfg.Syslog.Tag == "" {
+		cfg.Syslog.Tag = "monitorly-probe"
+	}
+
 	if cfg.Logging.FilePath == "" {
 		cfg.Logging.FilePath = "logs/monitorly.log"
 	}
+	if cfg.Logging.Level == "" {
+		cfg.Logging.Level = "info"
+	}
+	if cfg.Logging.Format == "" {
+		cfg.Logging.Format = "text"
+	}
+
+	// Set defaults for the collector scheduler
+	if cfg.Collection.Scheduler == "" {
+		cfg.Collection.Scheduler = "goroutine"
+	}
+	if cfg.Collection.Workers == 0 {
+		cfg.Collection.Workers = 4
+	}
+
+	// Set defaults for custom collectors
+	for name, custom := range cfg.Collection.Custom {
+		if custom.Interval == 0 {
+			custom.Interval = 1 * time.Minute
+		}
+		if custom.TTL == 0 {
+			custom.TTL = custom.Interval + cfg.Sender.SendInterval
+		}
+		cfg.Collection.Custom[name] = custom
+	}
+
+	// Set defaults for collector TTLs, once intervals are known
+	applyTTLDefaults(cfg)
+}
+
+// validSyslogFacility reports whether name is a syslog facility name
+func validSyslogFacility(name string) bool {
+	switch name {
+	case "kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7":
+		return true
+	}
+	return false
+}
+
+// applyTTLDefaults sets the TTL of each collector left unset to its interval plus the send interval:
+// the next sample is collected one interval later and reaches the backend up to one send interval after that
+func applyTTLDefaults(cfg *Config) {
+	collection := reflect.ValueOf(&cfg.Collection).Elem()
+	for i := 0; i < collection.NumField(); i++ {
+		if collection.Field(i).Kind() != reflect.Struct {
+			continue
+		}
+		ttl := collection.Field(i).FieldByName("TTL")
+		interval := collection.Field(i).FieldByName("Interval")
+		if ttl.IsValid() && interval.IsValid() && ttl.Int() == 0 {
+			ttl.SetInt(interval.Int() + int64(cfg.Sender.SendInterval))
+		}
+	}
+}
+
+// OnlySchedulingChanged reports whether a and b differ at most in the send interval and in the enabled
+// flag, interval and TTL of the collectors, changes a running probe applies without restarting
+func OnlySchedulingChanged(a, b *Config) bool {
+	return reflect.DeepEqual(withoutScheduling(a), withoutScheduling(b))
+}
+
+// withoutScheduling returns a copy of cfg with the settings ignored by OnlySchedulingChanged cleared
+func withoutScheduling(cfg *Config) *Config {
+	c := *cfg
+	c.Sender.SendInterval = 0
+
+	collection := reflect.ValueOf(&c.Collection).Elem()
+	for i := 0; i < collection.NumField(); i++ {
+		if collection.Field(i).Kind() != reflect.Struct {
+			continue
+		}
+		for _, name := range []string{"Enabled", "Interval", "TTL"} {
+			if field := collection.Field(i).FieldByName(name); field.IsValid() {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+	}
+
+	custom := make(map[string]CustomCollector, len(cfg.Collection.Custom))
+	for name, cc := range cfg.Collection.Custom {
+		cc.Enabled, cc.Interval, cc.TTL = false, 0, 0
+		custom[name] = cc
+	}
+	c.Collection.Custom = custom
+	return &c
+}
+
+// validateTTLs checks that no collector has a negative TTL
+func validateTTLs(cfg *Config) error {
+	collection := reflect.ValueOf(&cfg.Collection).Elem()
+	for i := 0; i < collection.NumField(); i++ {
+		if collection.Field(i).Kind() != reflect.Struct {
+			continue
+		}
+		ttl := collection.Field(i).FieldByName("TTL")
+		if ttl.IsValid() && ttl.Int() < 0 {
+			name, _, _ := strings.Cut(collection.Type().Field(i).Tag.Get("yaml"), ",")
+			return fmt.Errorf("%s ttl must be positive", name)
+		}
+	}
+	return nil
 }
 
 // validate performs validation on the configuration
@@ -238,15 +989,346 @@ func validate(cfg *Config) error {
 		if cfg.API.ApplicationToken == "" {
 			return fmt.Errorf("application token is required when sender target is set to 'api'")
 		}
-		if cfg.API.EncryptionKey != "" {
+		if cfg.API.EncryptionKey != "" && !cfg.usesKeyDerivation() {
 			if len(cfg.API.EncryptionKey) != 32 {
 				return fmt.Errorf("encryption key must be exactly 32 bytes long")
 			}
 		}
 	case "log_file":
-		// No validation needed for log_file target
+		// CSV rows can't hold encrypted or digested batches
+		switch cfg.LogFile.Format {
+		case "json":
+		case "csv":
+			if cfg.Sender.EncryptAtRest {
+				return fmt.Errorf("log_file.format csv can't be used with sender.encrypt_at_rest")
+			}
+			if cfg.Sender.IncludeDigest {
+				return fmt.Errorf("log_file.format csv can't be used with sender.include_digest")
+			}
+		default:
+			return fmt.Errorf("invalid log_file.format: %s (must be 'json' or 'csv')", cfg.LogFile.Format)
+		}
+	case "statsd":
+		if cfg.StatsD.Address == "" {
+			return fmt.Errorf("statsd address is required when sender target is set to 'statsd'")
+		}
+		if _, _, err := net.SplitHostPort(cfg.StatsD.Address); err != nil {
+			return fmt.Errorf("invalid statsd address %q: %w", cfg.StatsD.Address, err)
+		}
+	case "otlp":
+		u, err := url.Parse(cfg.OTLP.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid otlp endpoint: %q (must be an http or https URL)", cfg.OTLP.Endpoint)
+		}
+		if cfg.OTLP.Protocol != "http" && cfg.OTLP.Protocol != "grpc" {
+			return fmt.Errorf("invalid otlp protocol: %s (must be 'http' or 'grpc')", cfg.OTLP.Protocol)
+		}
+		if cfg.OTLP.Timeout < 0 {
+			return fmt.Errorf("otlp timeout must be positive")
+		}
+	case "mqtt":
+		if cfg.MQTT.Host == "" {
+			return fmt.Errorf("mqtt host is required when sender target is set to 'mqtt'")
+		}
+		if cfg.MQTT.Port < 1 || cfg.MQTT.Port > 65535 {
+			return fmt.Errorf("invalid mqtt port: %d", cfg.MQTT.Port)
+		}
+		if strings.ContainsAny(cfg.MQTT.Topic, "+#") {
+			return fmt.Errorf("invalid mqtt topic %q: wildcards can't be published to", cfg.MQTT.Topic)
+		}
+		if cfg.MQTT.QoS < 0 || cfg.MQTT.QoS > 2 {
+			return fmt.Errorf("invalid mqtt qos: %d (must be 0, 1 or 2)", cfg.MQTT.QoS)
+		}
+		if cfg.MQTT.KeepAlive < 0 || cfg.MQTT.Timeout < 0 {
+			return fmt.Errorf("mqtt keep_alive and timeout must be positive")
+		}
+		if cfg.MQTT.Password != "" && cfg.MQTT.Username == "" {
+			return fmt.Errorf("mqtt password requires a username")
+		}
+		if (cfg.MQTT.TLS.ClientCertFile == "") != (cfg.MQTT.TLS.ClientKeyFile == "") {
+			return fmt.Errorf("mqtt.tls client_cert_file and client_key_file must be set together")
+		}
+		if !cfg.MQTT.TLS.Enabled && (cfg.MQTT.TLS.CAFile != "" || cfg.MQTT.TLS.ClientCertFile != "") {
+			return fmt.Errorf("mqtt.tls files require mqtt.tls.enabled")
+		}
+		for name, path := range map[string]string{
+			"ca_file":          cfg.MQTT.TLS.CAFile,
+			"client_cert_file": cfg.MQTT.TLS.ClientCertFile,
+			"client_key_file":  cfg.MQTT.TLS.ClientKeyFile,
+		} {
+			if path == "" {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("invalid mqtt.tls %s: %w", name, err)
+			}
+		}
+	case "syslog":
+		switch cfg.Syslog.Network {
+		case "":
+		case "udp", "tcp":
+			if _, _, err := net.SplitHostPort(cfg.Syslog.Address); err != nil {
+				return fmt.Errorf("invalid syslog address %q: %w", cfg.Syslog.Address, err)
+			}
+		default:
+			return fmt.Errorf("invalid syslog network: %s (must be 'udp' or 'tcp', or empty for the local daemon)", cfg.Syslog.Network)
+		}
+		if !validSyslogFacility(cfg.Syslog.Facility) {
+			return fmt.Errorf("invalid syslog facility: %s", cfg.Syslog.Facility)
+		}
 	default:
-		return fmt.Errorf("invalid sender target: %s (must be 'api' or 'log_file')", cfg.Sender.Target)
+		return fmt.Errorf("invalid sender target: %s (must be 'api', 'log_file', 'statsd', 'otlp', 'mqtt' or 'syslog')", cfg.Sender.Target)
+	}
+
+	// Validate encryption at rest
+	if cfg.Sender.EncryptAtRest {
+		if cfg.API.EncryptionKey == "" {
+			return fmt.Errorf("encryption key is required when encrypt_at_rest is enabled")
+		}
+		if len(cfg.API.EncryptionKey) != 32 && !cfg.usesKeyDerivation() {
+			return fmt.Errorf("encryption key must be exactly 32 bytes long")
+		}
+	}
+
+	// Validate runtime identity
+	if cfg.Runtime.Group != "" && cfg.Runtime.User == "" {
+		return fmt.Errorf("runtime.user is required when runtime.group is set")
+	}
+	// Self-updates replace the probe binary, which the unprivileged user can't write
+	if cfg.Runtime.User != "" && cfg.Updates.Enabled {
+		return fmt.Errorf("runtime.user can't be used with updates.enabled, the probe can't replace its own binary after dropping privileges")
+	}
+	if cfg.Runtime.StartupJitter < 0 {
+		return fmt.Errorf("runtime.startup_jitter must be positive")
+	}
+	if cfg.Updates.CheckJitter < 0 {
+		return fmt.Errorf("updates.check_jitter must be positive")
+	}
+
+	// Validate spool
+	if cfg.Sender.SpoolMaxSizeMB < 0 {
+		return fmt.Errorf("spool_max_size_mb must be positive")
+	}
+
+	// Validate log level
+	if _, err := logger.ParseLevel(cfg.Logging.Level); err != nil {
+		return err
+	}
+
+	// Validate log format
+	if _, err := logger.ParseFormat(cfg.Logging.Format); err != nil {
+		return err
+	}
+
+	// Validate collector scheduler
+	if cfg.Collection.Scheduler != "goroutine" && cfg.Collection.Scheduler != "pool" {
+		return fmt.Errorf("invalid collection scheduler: %s (must be 'goroutine' or 'pool')", cfg.Collection.Scheduler)
+	}
+	if cfg.Collection.Workers < 1 {
+		return fmt.Errorf("collection workers must be at least 1")
+	}
+	if err := validatePrecision("collection", cfg.Collection.Precision); err != nil {
+		return err
+	}
+	for section, precision := range map[string]*int{
+		"cpu":         cfg.Collection.CPU.Precision,
+		"ram":         cfg.Collection.RAM.Precision,
+		"disk":        cfg.Collection.Disk.Precision,
+		"process":     cfg.Collection.Process.Precision,
+		"db":          cfg.Collection.DB.Precision,
+		"temperature": cfg.Collection.Temperature.Precision,
+	} {
+		if err := validatePrecision("collection."+section, precision); err != nil {
+			return err
+		}
+	}
+
+	// Validate collector TTLs
+	if err := validateTTLs(cfg); err != nil {
+		return err
+	}
+
+	// Validate labels
+	for key := range cfg.Labels {
+		if key == "" {
+			return fmt.Errorf("label names must not be empty")
+		}
+	}
+
+	// Validate retries
+	if cfg.Sender.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must be positive")
+	}
+	if cfg.Sender.InitialBackoff < 0 || cfg.Sender.MaxBackoff < 0 {
+		return fmt.Errorf("backoff durations must be positive")
+	}
+	if cfg.Sender.MaxBackoff < cfg.Sender.InitialBackoff {
+		return fmt.Errorf("max_backoff must be greater than or equal to initial_backoff")
+	}
+	if cfg.Sender.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown_timeout must be positive")
+	}
+
+	// Validate queue backpressure policy
+	switch cfg.Sender.OnFull {
+	case "block", "drop_oldest", "drop_new":
+	default:
+		return fmt.Errorf("invalid on_full policy: %s (must be 'block', 'drop_oldest' or 'drop_new')", cfg.Sender.OnFull)
+	}
+
+	// Validate request timeout
+	if cfg.API.RequestTimeout <= 0 {
+		return fmt.Errorf("api.request_timeout must be positive")
+	}
+
+	// Validate proxy
+	if cfg.API.Proxy != "" {
+		proxy, err := url.Parse(cfg.API.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid api.proxy %q: %w", cfg.API.Proxy, err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid api.proxy %q: scheme must be http, https, socks5 or socks5h", cfg.API.Proxy)
+		}
+		if proxy.Host == "" {
+			return fmt.Errorf("invalid api.proxy %q: missing host", cfg.API.Proxy)
+		}
+	}
+
+	// Validate API TLS files. Their contents are checked when the sender is set up
+	if (cfg.API.TLS.ClientCertFile == "") != (cfg.API.TLS.ClientKeyFile == "") {
+		return fmt.Errorf("api.tls client_cert_file and client_key_file must be set together")
+	}
+	for name, path := range map[string]string{
+		"ca_file":          cfg.API.TLS.CAFile,
+		"client_cert_file": cfg.API.TLS.ClientCertFile,
+		"client_key_file":  cfg.API.TLS.ClientKeyFile,
+	} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("invalid api.tls %s: %w", name, err)
+		}
+	}
+
+	// Validate the update release URL
+	if cfg.Updates.ReleaseURL != "" {
+		u, err := url.Parse(cfg.Updates.ReleaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid updates.release_url: %s (must be an http or https URL)", cfg.Updates.ReleaseURL)
+		}
+	}
+	if cfg.Updates.NotifyWebhook != "" {
+		u, err := url.Parse(cfg.Updates.NotifyWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid updates.notify_webhook: %s (must be an http or https URL)", cfg.Updates.NotifyWebhook)
+		}
+	}
+
+	// Validate the update signing key. Its content is checked when updates are set up
+	if cfg.Updates.PublicKeyFile != "" {
+		if !cfg.Updates.VerifySignature {
+			return fmt.Errorf("updates.public_key_file requires updates.verify_signature")
+		}
+		if _, err := os.Stat(cfg.Updates.PublicKeyFile); err != nil {
+			return fmt.Errorf("invalid updates.public_key_file: %w", err)
+		}
+	}
+
+	// Validate body size ceiling
+	if cfg.API.MaxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must be positive")
+	}
+
+	// Validate streaming, which sends each metric as it's encoded and can't encrypt or trim the payload
+	if cfg.Sender.Streaming {
+		if cfg.API.EncryptionKey != "" {
+			return fmt.Errorf("sender.streaming can't be used with api.encryption_key")
+		}
+		if cfg.API.MaxBodyBytes > 0 {
+			return fmt.Errorf("sender.streaming can't be used with api.max_body_bytes")
+		}
+		if cfg.Sender.IncludeChangeManifest {
+			return fmt.Errorf("sender.streaming can't be used with sender.include_change_manifest")
+		}
+		if cfg.Sender.IncludeDigest {
+			return fmt.Errorf("sender.streaming can't be used with sender.include_digest")
+		}
+	}
+
+	if cfg.Sender.MaxBatchSize < 0 {
+		return fmt.Errorf("sender.max_batch_size must be positive")
+	}
+
+	// Validate serialization. Protobuf payloads carry no encryption envelope and aren't streamed,
+	// and the batch digest is defined over the JSON metrics array
+	switch cfg.Sender.Serialization {
+	case "json":
+	case "protobuf":
+		if cfg.API.EncryptionKey != "" {
+			return fmt.Errorf("sender.serialization protobuf can't be used with api.encryption_key")
+		}
+		if cfg.Sender.Streaming {
+			return fmt.Errorf("sender.serialization protobuf can't be used with sender.streaming")
+		}
+		if cfg.Sender.IncludeDigest {
+			return fmt.Errorf("sender.serialization protobuf can't be used with sender.include_digest")
+		}
+	default:
+		return fmt.Errorf("invalid sender.serialization: %s (must be 'json' or 'protobuf')", cfg.Sender.Serialization)
+	}
+
+	// Validate request compression. Streamed metrics are always gzipped
+	if cfg.API.Compression.Level < 1 || cfg.API.Compression.Level > 9 {
+		return fmt.Errorf("api.compression.level must be between 1 and 9")
+	}
+	if cfg.API.Compression.MinSize < 0 {
+		return fmt.Errorf("api.compression.min_size must be positive")
+	}
+	if enabled := cfg.API.Compression.Enabled; enabled != nil && !*enabled && cfg.Sender.Streaming {
+		return fmt.Errorf("sender.streaming can't be used with api.compression disabled")
+	}
+
+	// Validate config fetch retries
+	if cfg.API.ConfigFetch.MaxRetries < 0 {
+		return fmt.Errorf("config_fetch max_retries must be positive")
+	}
+	if cfg.API.ConfigFetch.InitialBackoff < 0 || cfg.API.ConfigFetch.MaxBackoff < 0 {
+		return fmt.Errorf("config_fetch backoff durations must be positive")
+	}
+	if cfg.API.ConfigFetch.MaxBackoff < cfg.API.ConfigFetch.InitialBackoff {
+		return fmt.Errorf("config_fetch max_backoff must be greater than or equal to initial_backoff")
+	}
+
+	// Validate aggregation
+	if cfg.Sender.Aggregate.Enabled && len(cfg.Sender.Aggregate.Metrics) == 0 {
+		return fmt.Errorf("aggregation requires at least one metric name")
+	}
+
+	// Validate key derivation
+	switch cfg.API.KeyDerivation {
+	case "", "none", "pbkdf2":
+	default:
+		return fmt.Errorf("invalid key_derivation: %s (must be 'none' or 'pbkdf2')", cfg.API.KeyDerivation)
+	}
+	if cfg.usesKeyDerivation() && cfg.API.EncryptionKey != "" && len(cfg.API.EncryptionKey) < minPassphraseLength {
+		return fmt.Errorf("encryption passphrase must be at least %d characters long", minPassphraseLength)
+	}
+
+	// Validate additional decryption keys
+	for i, key := range cfg.API.DecryptKeys {
+		if cfg.usesKeyDerivation() {
+			if key == "" {
+				return fmt.Errorf("decrypt key #%d must not be empty", i+1)
+			}
+			continue
+		}
+		if len(key) != 32 {
+			return fmt.Errorf("decrypt key #%d must be exactly 32 bytes long", i+1)
+		}
 	}
 
 	// Validate mount points
@@ -258,12 +1340,136 @@ func validate(cfg *Config) error {
 			if mp.Label == "" {
 				return fmt.Errorf("mount point #%d is missing a label", i+1)
 			}
-			if !mp.CollectUsage && !mp.CollectPercent {
-				return fmt.Errorf("mount point #%d must collect either usage or percent", i+1)
+			if !mp.CollectUsage && !mp.CollectPercent && !mp.CollectInodes {
+				return fmt.Errorf("mount point #%d must collect either usage, percent or inodes", i+1)
+			}
+		}
+		for _, pattern := range cfg.Collection.Disk.ExcludePaths {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("disk exclude_paths has invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	// Validate freshness files
+	if cfg.Collection.PIDFile.Enabled {
+		for i, f := range cfg.Collection.PIDFile.Files {
+			if f.PIDFile == "" {
+				return fmt.Errorf("PID file #%d is missing a pidfile path", i+1)
+			}
+			if f.Label == "" {
+				return fmt.Errorf("PID file #%d is missing a label", i+1)
+			}
+		}
+	}
+
+	if cfg.Collection.JournalLag.Enabled {
+		for i, c := range cfg.Collection.JournalLag.Cursors {
+			if c.Name == "" {
+				return fmt.Errorf("journal cursor #%d is missing a name", i+1)
+			}
+			if c.CursorFile == "" {
+				return fmt.Errorf("journal cursor #%d is missing a cursor_file", i+1)
 			}
 		}
 	}
 
+	if cfg.Collection.Freshness.Enabled {
+		for i, f := range cfg.Collection.Freshness.Files {
+			if f.Path == "" {
+				return fmt.Errorf("freshness file #%d is missing a path", i+1)
+			}
+			if f.Label == "" {
+				return fmt.Errorf("freshness file #%d is missing a label", i+1)
+			}
+			if f.MaxAge <= 0 {
+				return fmt.Errorf("freshness file #%d must have a positive max_age", i+1)
+			}
+		}
+	}
+
+	// Validate process patterns
+	if cfg.Collection.Process.Enabled {
+		if len(cfg.Collection.Process.Match) == 0 {
+			return fmt.Errorf("process collection requires at least one match pattern")
+		}
+		for i, pattern := range cfg.Collection.Process.Match {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("process match pattern #%d is invalid: %w", i+1, err)
+			}
+		}
+		if cfg.Collection.Process.MaxProcesses < 0 {
+			return fmt.Errorf("process max_processes must be positive")
+		}
+	}
+
+	// Validate databases
+	if cfg.Collection.DB.Enabled {
+		for i, db := range cfg.Collection.DB.Databases {
+			if db.Type != "postgres" && db.Type != "mysql" {
+				return fmt.Errorf("database #%d has invalid type: %s (must be 'postgres' or 'mysql')", i+1, db.Type)
+			}
+			if db.Label == "" {
+				return fmt.Errorf("database #%d is missing a label", i+1)
+			}
+		}
+	}
+
+	// Validate HTTP check endpoints
+	if cfg.Collection.HTTPCheck.Enabled {
+		for i, ep := range cfg.Collection.HTTPCheck.Endpoints {
+			if ep.URL == "" {
+				return fmt.Errorf("HTTP check endpoint #%d is missing a URL", i+1)
+			}
+			if ep.Label == "" {
+				return fmt.Errorf("HTTP check endpoint #%d is missing a label", i+1)
+			}
+		}
+	}
+
+	// Validate port check targets
+	if cfg.Collection.Port.Enabled {
+		for _, list := range []struct {
+			name  string
+			ports []ExpectedPort
+		}{{"expected_open", cfg.Collection.Port.ExpectedOpen}, {"expected_closed", cfg.Collection.Port.ExpectedClosed}} {
+			for i, p := range list.ports {
+				if p.Port == 0 || p.Port > 65535 {
+					return fmt.Errorf("port %s #%d has invalid port: %d", list.name, i+1, p.Port)
+				}
+				if p.Protocol != "" && p.Protocol != "tcp" && p.Protocol != "udp" {
+					return fmt.Errorf("port %s #%d has invalid protocol: %s (must be 'tcp' or 'udp')", list.name, i+1, p.Protocol)
+				}
+			}
+		}
+		for i, target := range cfg.Collection.Port.Targets {
+			if _, _, err := net.SplitHostPort(target.Address); err != nil {
+				return fmt.Errorf("port target #%d has invalid address %q: must be host:port", i+1, target.Address)
+			}
+			if target.Label == "" {
+				return fmt.Errorf("port target #%d is missing a label", i+1)
+			}
+		}
+	}
+
+	// Validate queue directories
+	if cfg.Collection.DirQueue.Enabled {
+		for i, dir := range cfg.Collection.DirQueue.Directories {
+			if dir.Path == "" {
+				return fmt.Errorf("queue directory #%d is missing a path", i+1)
+			}
+			if dir.Label == "" {
+				return fmt.Errorf("queue directory #%d is missing a label", i+1)
+			}
+			if dir.MaxFiles < 0 {
+				return fmt.Errorf("queue directory #%d must have a positive max_files", i+1)
+			}
+		}
+		if cfg.Collection.DirQueue.WalkTimeout < 0 {
+			return fmt.Errorf("dir_queue walk_timeout must be positive")
+		}
+	}
+
 	// Validate collection intervals
 	if cfg.Collection.CPU.Enabled && cfg.Collection.CPU.Interval < time.Second {
 		return fmt.Errorf("CPU collection interval must be at least 1 second")
@@ -283,13 +1489,230 @@ func validate(cfg *Config) error {
 	if cfg.Collection.LoginFailures.Enabled && cfg.Collection.LoginFailures.Interval < time.Second {
 		return fmt.Errorf("Login failures collection interval must be at least 1 second")
 	}
+	if cfg.Collection.LoginFailures.Aggregate && cfg.Collection.LoginFailures.SummarizeBySource {
+		return fmt.Errorf("collection.login_failures.aggregate can't be used with collection.login_failures.summarize_by_source")
+	}
+	if cfg.Collection.LoginFailures.InitialLookback < 0 {
+		return fmt.Errorf("login failures initial_lookback must be positive")
+	}
+	if cfg.Collection.LoginFailures.MaxLookback < cfg.Collection.LoginFailures.InitialLookback {
+		return fmt.Errorf("login failures max_lookback must be at least initial_lookback")
+	}
+	for i, svc := range cfg.Collection.LoginFailures.Services {
+		if svc.Name == "" {
+			return fmt.Errorf("login failures service #%d is missing a name", i+1)
+		}
+		if svc.Builtin == "" && len(svc.Patterns) == 0 {
+			return fmt.Errorf("login failures service %s needs a builtin or at least one pattern", svc.Name)
+		}
+		if svc.Builtin != "" && svc.Builtin != "vsftpd" && svc.Builtin != "dovecot" && svc.Builtin != "pam" {
+			return fmt.Errorf("login failures service %s has invalid builtin: %s (must be 'vsftpd', 'dovecot' or 'pam')", svc.Name, svc.Builtin)
+		}
+		for _, pattern := range svc.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("login failures service %s has invalid pattern %q: %w", svc.Name, pattern, err)
+			}
+		}
+	}
 	if cfg.Collection.Port.Enabled && cfg.Collection.Port.Interval < time.Second {
 		return fmt.Errorf("Port collection interval must be at least 1 second")
 	}
+	if cfg.Collection.Freshness.Enabled && cfg.Collection.Freshness.Interval < time.Second {
+		return fmt.Errorf("Freshness collection interval must be at least 1 second")
+	}
+	if cfg.Collection.TCPStates.Enabled && cfg.Collection.TCPStates.Interval < time.Second {
+		return fmt.Errorf("TCP states collection interval must be at least 1 second")
+	}
+	if cfg.Collection.Process.Enabled && cfg.Collection.Process.Interval < time.Second {
+		return fmt.Errorf("Process collection interval must be at least 1 second")
+	}
+	if cfg.Collection.DB.Enabled && cfg.Collection.DB.Interval < time.Second {
+		return fmt.Errorf("DB collection interval must be at least 1 second")
+	}
+	if cfg.Collection.HTTPCheck.Enabled && cfg.Collection.HTTPCheck.Interval < time.Second {
+		return fmt.Errorf("HTTP check collection interval must be at least 1 second")
+	}
+	if cfg.Collection.Inotify.Enabled && cfg.Collection.Inotify.Interval < time.Second {
+		return fmt.Errorf("Inotify collection interval must be at least 1 second")
+	}
+	if cfg.Collection.HugePages.Enabled && cfg.Collection.HugePages.Interval < time.Second {
+		return fmt.Errorf("Huge pages collection interval must be at least 1 second")
+	}
+	if cfg.Collection.DirQueue.Enabled && cfg.Collection.DirQueue.Interval < time.Second {
+		return fmt.Errorf("Directory queue collection interval must be at least 1 second")
+	}
+	if cfg.Collection.ServiceRestarts.Enabled && cfg.Collection.ServiceRestarts.Interval < time.Second {
+		return fmt.Errorf("Service restarts collection interval must be at least 1 second")
+	}
+	if cfg.Collection.SSHSessions.Enabled && cfg.Collection.SSHSessions.Interval < time.Second {
+		return fmt.Errorf("SSH sessions collection interval must be at least 1 second")
+	}
+	if cfg.Collection.PIDFile.Enabled && cfg.Collection.PIDFile.Interval < time.Second {
+		return fmt.Errorf("PID file collection interval must be at least 1 second")
+	}
+	if cfg.Collection.ShutdownState.Enabled && cfg.Collection.ShutdownState.Interval < time.Second {
+		return fmt.Errorf("shutdown state collection interval must be at least 1 second")
+	}
+	if cfg.Collection.CoreDumps.Enabled && cfg.Collection.CoreDumps.Interval < time.Second {
+		return fmt.Errorf("core dumps collection interval must be at least 1 second")
+	}
+	if cfg.Collection.JournalLag.Enabled && cfg.Collection.JournalLag.Interval < time.Second {
+		return fmt.Errorf("journal lag collection interval must be at least 1 second")
+	}
+	if cfg.Collection.SystemdJobs.Enabled && cfg.Collection.SystemdJobs.Interval < time.Second {
+		return fmt.Errorf("systemd jobs collection interval must be at least 1 second")
+	}
+	if cfg.Collection.FsckStatus.Enabled {
+		if cfg.Collection.FsckStatus.Interval < time.Second {
+			return fmt.Errorf("fsck status collection interval must be at least 1 second")
+		}
+		if cfg.Collection.FsckStatus.MountsMargin < 0 {
+			return fmt.Errorf("fsck status mounts_margin must be positive")
+		}
+		if cfg.Collection.FsckStatus.DueWithin < 0 {
+			return fmt.Errorf("fsck status due_within must be positive")
+		}
+	}
+	if cfg.Collection.Uptime.Enabled && cfg.Collection.Uptime.Interval < time.Second {
+		return fmt.Errorf("uptime collection interval must be at least 1 second")
+	}
+	if cfg.Collection.Temperature.Enabled && cfg.Collection.Temperature.Interval < time.Second {
+		return fmt.Errorf("temperature collection interval must be at least 1 second")
+	}
+	for name, custom := range cfg.Collection.Custom {
+		if custom.Enabled && custom.Interval < time.Second {
+			return fmt.Errorf("custom collector %s interval must be at least 1 second", name)
+		}
+		if custom.TTL < 0 {
+			return fmt.Errorf("custom collector %s ttl must be positive", name)
+		}
+	}
+	if cfg.Collection.SuspendDetect.Enabled {
+		if cfg.Collection.SuspendDetect.Interval < time.Second {
+			return fmt.Errorf("suspend detection interval must be at least 1 second")
+		}
+		if cfg.Collection.SuspendDetect.Threshold < time.Second {
+			return fmt.Errorf("suspend detection threshold must be at least 1 second")
+		}
+	}
+
+	return nil
+}
+
+// EncryptionKeyEnv is the environment variable that overrides the configured encryption key
+const EncryptionKeyEnv = "MONITORLY_ENCRYPTION_KEY"
+
+// minPassphraseLength is the shortest passphrase accepted when keys are derived with a KDF
+const minPassphraseLength = 12
+
+// resolveEncryptionKey sets API.EncryptionKey from the first configured source, in order of
+// precedence: the EncryptionKeyEnv environment variable, encryption_key_file, encryption_key
+func resolveEncryptionKey(cfg *Config) error {
+	var sources []string
+	if cfg.API.EncryptionKey != "" {
+		sources = append(sources, "encryption_key")
+	}
+
+	if cfg.API.EncryptionKeyFile != "" {
+		sources = append(sources, "encryption_key_file")
+	}
+
+	source := "encryption_key"
+	if key := os.Getenv(EncryptionKeyEnv); key != "" {
+		// The key file is not read at all when the environment overrides it, so a stale or
+		// unreadable file doesn't stop the probe from starting
+		cfg.API.EncryptionKey = key
+		source = EncryptionKeyEnv
+		sources = append(sources, source)
+	} else if cfg.API.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(cfg.API.EncryptionKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		key := strings.TrimRight(string(data), "\r\n")
+		if key == "" {
+			return fmt.Errorf("encryption key file %s is empty", cfg.API.EncryptionKeyFile)
+		}
+		cfg.API.EncryptionKey = key
+		source = "encryption_key_file"
+	}
+
+	if len(sources) > 1 {
+		logger.Printf("Encryption key set in %s, using %s", strings.Join(sources, ", "), source)
+	}
+	if source != "encryption_key" && !cfg.usesKeyDerivation() && len(cfg.API.EncryptionKey) != encryption.KeySize {
+		return fmt.Errorf("encryption key from %s must be exactly %d bytes long, got %d", source, encryption.KeySize, len(cfg.API.EncryptionKey))
+	}
+	return nil
+}
+
+// usesKeyDerivation reports whether the configured keys are passphrases to run through a KDF
+func (c *Config) usesKeyDerivation() bool {
+	return c.API.KeyDerivation == "pbkdf2"
+}
+
+// deriveKeys replaces passphrases with the AES keys derived from them, so the rest of the
+// probe only ever sees 32-byte keys. The derivation is deterministic, see encryption.DeriveKey
+func deriveKeys(cfg *Config) error {
+	if !cfg.usesKeyDerivation() {
+		return nil
+	}
+
+	if cfg.API.EncryptionKey != "" {
+		key, err := encryption.DeriveKey(cfg.API.EncryptionKey, cfg.API.OrganizationID)
+		if err != nil {
+			return fmt.Errorf("failed to derive encryption key: %w", err)
+		}
+		cfg.API.EncryptionKey = key
+	}
+
+	for i, passphrase := range cfg.API.DecryptKeys {
+		key, err := encryption.DeriveKey(passphrase, cfg.API.OrganizationID)
+		if err != nil {
+			return fmt.Errorf("failed to derive decrypt key #%d: %w", i+1, err)
+		}
+		cfg.API.DecryptKeys[i] = key
+	}
 
 	return nil
 }
 
+// defaultPrecision and maxPrecision are the default and most decimal places metric values are rounded to
+const (
+	defaultPrecision = 2
+	maxPrecision     = 6
+)
+
+// validatePrecision checks the precision set in section, if any
+func validatePrecision(section string, precision *int) error {
+	if precision != nil && (*precision < 0 || *precision > maxPrecision) {
+		return fmt.Errorf("%s.precision must be between 0 and %d", section, maxPrecision)
+	}
+	return nil
+}
+
+// GetPrecision returns the decimal places of a collector's values: its own precision if set,
+// collection.precision otherwise
+func (c *Config) GetPrecision(override *int) int {
+	if override != nil {
+		return *override
+	}
+	if c.Collection.Precision != nil {
+		return *c.Collection.Precision
+	}
+	return defaultPrecision
+}
+
+// GetDecryptionKeys returns the keys to try when decrypting data stored at rest
+// The primary encryption key is always tried first, followed by the additional decrypt keys
+func (c *Config) GetDecryptionKeys() []string {
+	var keys []string
+	if c.API.EncryptionKey != "" {
+		keys = append(keys, c.API.EncryptionKey)
+	}
+	return append(keys, c.API.DecryptKeys...)
+}
+
 // GetUpdateCheckTime returns the time to check for updates, defaulting to midnight if not specified
 func (c *Config) GetUpdateCheckTime() (time.Time, error) {
 	if c.Updates.CheckTime == "" {
@@ -310,9 +1733,10 @@ func (c *Config) GetUpdateCheckTime() (time.Time, error) {
 		now.Location(),
 	)
 
-	// If the scheduled time has already passed today, schedule for tomorrow
+	// If the scheduled time has already passed today, schedule for tomorrow, which may not be
+	// 24 hours later across a daylight saving time change
 	if scheduledTime.Before(now) {
-		scheduledTime = scheduledTime.Add(24 * time.Hour)
+		scheduledTime = scheduledTime.AddDate(0, 0, 1)
 	}
 
 	return scheduledTime, nil
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of environment variables overriding config fields
+const EnvPrefix = "MONITORLY_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnvOverrides overrides config fields with environment variables named after their YAML path,
+// e.g. MONITORLY_API_URL for api.url or MONITORLY_SENDER_SEND_INTERVAL for sender.send_interval.
+// Scalar fields, string lists (comma separated) and string maps (comma separated key=value pairs)
+// can be overridden, lists of objects can't
+func applyEnvOverrides(cfg *Config) error {
+	return overrideFromEnv(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"))
+}
+
+// overrideFromEnv walks the fields of a struct, setting each from the environment variable
+// made of prefix and the upper-cased YAML key
+func overrideFromEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := overrideFromEnv(v.Field(i), name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(v.Field(i), raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFromString parses raw into a config field according to its type
+func setFromString(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		value := reflect.New(field.Type().Elem())
+		if err := setFromString(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("maps of %s can't be set from the environment", field.Type())
+		}
+		items := make(map[string]string)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value pairs, got %q", item)
+			}
+			items[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("lists of %s can't be set from the environment", field.Type().Elem())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
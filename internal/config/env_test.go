@@ -0,0 +1,238 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadEnvOverrides(t *testing.T) {
+	const baseYAML = `
+api:
+  url: "https://api.monitorly.io"
+  organization_id: "123e4567-e89b-12d3-a456-426614174000"
+  server_id: "123e4567-e89b-12d3-a456-426614174001"
+  application_token: "test-token"
+sender:
+  send_interval: 5m
+`
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		errContains string
+		validate    func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "scalar fields",
+			env: map[string]string{
+				"MONITORLY_API_URL":                               "https://eu.api.monitorly.io",
+				"MONITORLY_API_ORGANIZATION_ID":                   "223e4567-e89b-12d3-a456-426614174000",
+				"MONITORLY_SENDER_SEND_INTERVAL":                  "90s",
+				"MONITORLY_SENDER_MAX_RETRIES":                    "4",
+				"MONITORLY_COLLECTION_PORT_ENABLED":               "true",
+				"MONITORLY_COLLECTION_PROCESS_MATCH":              "nginx*, postgres",
+				"MONITORLY_COLLECTION_SERVICE_RESTARTS_THRESHOLD": "2",
+				"MONITORLY_API_COMPRESSION_ENABLED":               "false",
+			},
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.API.URL != "https://eu.api.monitorly.io" {
+					t.Errorf("expected API URL from env, got %q", cfg.API.URL)
+				}
+				if cfg.API.OrganizationID != "223e4567-e89b-12d3-a456-426614174000" {
+					t.Errorf("expected organization ID from env, got %q", cfg.API.OrganizationID)
+				}
+				if cfg.API.ServerID != "123e4567-e89b-12d3-a456-426614174001" {
+					t.Errorf("expected server ID from YAML, got %q", cfg.API.ServerID)
+				}
+				if cfg.Sender.SendInterval != 90*time.Second {
+					t.Errorf("expected send interval 90s, got %v", cfg.Sender.SendInterval)
+				}
+				if cfg.Sender.MaxRetries != 4 {
+					t.Errorf("expected max retries 4, got %d", cfg.Sender.MaxRetries)
+				}
+				if !cfg.Collection.Port.Enabled {
+					t.Error("expected port collection to be enabled")
+				}
+				if cfg.API.Compression.Enabled == nil || *cfg.API.Compression.Enabled {
+					t.Error("expected compression to be disabled from env")
+				}
+				if len(cfg.Collection.Process.Match) != 2 || cfg.Collection.Process.Match[1] != "postgres" {
+					t.Errorf("expected process match [nginx* postgres], got %v", cfg.Collection.Process.Match)
+				}
+				if cfg.Collection.ServiceRestarts.Threshold != 2 {
+					t.Errorf("expected service restarts threshold 2, got %d", cfg.Collection.ServiceRestarts.Threshold)
+				}
+			},
+		},
+		{
+			name: "labels",
+			env:  map[string]string{"MONITORLY_LABELS": "environment=prod, region=eu-west"},
+			validate: func(t *testing.T, cfg *Config) {
+				if len(cfg.Labels) != 2 || cfg.Labels["environment"] != "prod" || cfg.Labels["region"] != "eu-west" {
+					t.Errorf("expected labels environment=prod region=eu-west, got %v", cfg.Labels)
+				}
+			},
+		},
+		{
+			name: "unsigned and pointer fields",
+			env: map[string]string{
+				"MONITORLY_COLLECTION_SSH_SESSIONS_PORT": "2222",
+				"MONITORLY_COLLECTION_PRECISION":         "3",
+				"MONITORLY_COLLECTION_CPU_PRECISION":     "1",
+			},
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Collection.SSHSessions.Port != 2222 {
+					t.Errorf("expected ssh sessions port 2222, got %d", cfg.Collection.SSHSessions.Port)
+				}
+				if cfg.Collection.Precision == nil || *cfg.Collection.Precision != 3 {
+					t.Errorf("expected collection precision 3, got %v", cfg.Collection.Precision)
+				}
+				if cfg.Collection.CPU.Precision == nil || *cfg.Collection.CPU.Precision != 1 {
+					t.Errorf("expected cpu precision 1, got %v", cfg.Collection.CPU.Precision)
+				}
+			},
+		},
+		{
+			name:        "invalid labels",
+			env:         map[string]string{"MONITORLY_LABELS": "environment"},
+			errContains: "expected key=value pairs",
+		},
+		{
+			name:        "invalid duration",
+			env:         map[string]string{"MONITORLY_SENDER_SEND_INTERVAL": "often"},
+			errContains: "invalid value for MONITORLY_SENDER_SEND_INTERVAL",
+		},
+		{
+			name:        "invalid bool",
+			env:         map[string]string{"MONITORLY_COLLECTION_PORT_ENABLED": "maybe"},
+			errContains: "invalid value for MONITORLY_COLLECTION_PORT_ENABLED",
+		},
+		{
+			name:        "list of objects",
+			env:         map[string]string{"MONITORLY_COLLECTION_DISK_MOUNT_POINTS": "/"},
+			errContains: "invalid value for MONITORLY_COLLECTION_DISK_MOUNT_POINTS",
+		},
+		{
+			name:        "override is validated",
+			env:         map[string]string{"MONITORLY_SENDER_TARGET": "carrier-pigeon"},
+			errContains: "invalid sender target: carrier-pigeon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(baseYAML), 0644); err != nil {
+				t.Fatalf("failed to write test config file: %v", err)
+			}
+
+			cfg, err := Load(configPath)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.validate(t, cfg)
+		})
+	}
+}
+
+// envTestValue returns a value to set a field of typ from the environment and the value it must parse to,
+// or false for lists and maps of objects, which can't be set from the environment
+func envTestValue(typ reflect.Type) (string, interface{}, bool) {
+	if typ == durationType {
+		return "90s", 90 * time.Second, true
+	}
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return envTestValue(typ.Elem())
+	case reflect.String:
+		return "overridden", "overridden", true
+	case reflect.Bool:
+		return "true", true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "7", reflect.ValueOf(7).Convert(typ).Interface(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "7", reflect.ValueOf(7).Convert(typ).Interface(), true
+	case reflect.Float64:
+		return "0.5", 0.5, true
+	case reflect.Map:
+		if typ.Elem().Kind() != reflect.String {
+			return "", nil, false
+		}
+		return "a=1, b=2", map[string]string{"a": "1", "b": "2"}, true
+	case reflect.Slice:
+		if typ.Elem().Kind() != reflect.String {
+			return "", nil, false
+		}
+		return "a, b", []string{"a", "b"}, true
+	}
+	return "", nil, false
+}
+
+func TestApplyEnvOverrides_EveryField(t *testing.T) {
+	// Every field that can be set from the environment gets an override, checked once they are all applied
+	type override struct {
+		name string
+		path []int
+		want interface{}
+	}
+	var overrides []override
+	var walk func(typ reflect.Type, prefix string, path []int)
+	walk = func(typ reflect.Type, prefix string, path []int) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if key == "" || key == "-" {
+				continue
+			}
+			name := prefix + "_" + strings.ToUpper(key)
+			fieldPath := append(append([]int(nil), path...), i)
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, name, fieldPath)
+				continue
+			}
+			raw, want, ok := envTestValue(field.Type)
+			if !ok {
+				continue
+			}
+			t.Setenv(name, raw)
+			overrides = append(overrides, override{name: name, path: fieldPath, want: want})
+		}
+	}
+	walk(reflect.TypeOf(Config{}), strings.TrimSuffix(EnvPrefix, "_"), nil)
+
+	var cfg Config
+	if err := applyEnvOverrides(&cfg); err != nil {
+		t.Fatalf("applyEnvOverrides() error = %v", err)
+	}
+
+	for _, o := range overrides {
+		got := reflect.ValueOf(cfg).FieldByIndex(o.path)
+		if got.Kind() == reflect.Pointer {
+			if got.IsNil() {
+				t.Errorf("%s: field not set", o.name)
+				continue
+			}
+			got = got.Elem()
+		}
+		if !reflect.DeepEqual(got.Interface(), o.want) {
+			t.Errorf("%s: got %v, want %v", o.name, got.Interface(), o.want)
+		}
+	}
+	if len(overrides) < 200 {
+		t.Errorf("only %d fields were overridden, the config walk missed fields", len(overrides))
+	}
+}
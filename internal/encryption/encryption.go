@@ -3,12 +3,34 @@ package encryption
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/pbkdf2"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 )
 
+// Key derivation parameters. They are part of the wire contract with the API: changing any
+// of them changes the derived key, so the server could no longer decrypt payloads
+const (
+	// KDFIterations is the PBKDF2-HMAC-SHA256 iteration count
+	KDFIterations = 600000
+	// KDFSaltPrefix is prepended to the organization ID to form the PBKDF2 salt
+	KDFSaltPrefix = "monitorly-probe:"
+	// KeySize is the AES-256 key size in bytes
+	KeySize = 32
+)
+
+var (
+	// ErrTruncated is returned by Decrypt when the input is too short to hold a nonce and an auth tag
+	ErrTruncated = errors.New("encrypted data too short")
+	// ErrAuthentication is returned by Decrypt when the auth tag doesn't verify, either because the
+	// key is wrong or because the data was modified
+	ErrAuthentication = errors.New("authentication failed, wrong key or corrupted data")
+)
+
 // Encrypt encrypts data using AES-256-GCM with the provided key
 // The key must be exactly 32 bytes long (for AES-256)
 // Returns base64-encoded encrypted data
@@ -41,6 +63,22 @@ func Encrypt(data []byte, key string) (string, error) {
 	return base64.StdEncoding.EncodeToString(encrypted), nil
 }
 
+// DeriveKey derives a 32-byte AES-256 key from a passphrase with PBKDF2-HMAC-SHA256
+// The salt is KDFSaltPrefix followed by the organization ID, so the same passphrase yields
+// the same key across restarts and can be derived by the server for that organization
+func DeriveKey(passphrase, organizationID string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, []byte(KDFSaltPrefix+organizationID), KDFIterations, KeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	return string(key), nil
+}
+
 // ValidateKey checks if the encryption key is valid (32 bytes)
 func ValidateKey(key string) error {
 	if len(key) != 32 {
@@ -48,3 +86,61 @@ func ValidateKey(key string) error {
 	}
 	return nil
 }
+
+// Decrypt reverses Encrypt: it decodes the base64 input, splits off the nonce
+// and opens the AES-256-GCM ciphertext with the provided key
+// The GCM auth tag is always verified, so a wrong key or tampered data returns
+// ErrAuthentication and never partial plaintext
+func Decrypt(ciphertextB64 string, key string) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be exactly 32 bytes long")
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 data: %w", err)
+	}
+
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonceSize := aesgcm.NonceSize()
+	if minSize := nonceSize + aesgcm.Overhead(); len(encrypted) < minSize {
+		return nil, fmt.Errorf("%w: got %d bytes, need at least %d", ErrTruncated, len(encrypted), minSize)
+	}
+
+	nonce, ciphertext := encrypted[:nonceSize], encrypted[nonceSize:]
+	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", ErrAuthentication)
+	}
+
+	return plaintext, nil
+}
+
+// DecryptWithKeys tries each key in order and returns the plaintext from the first key that
+// successfully authenticates the data. This allows data encrypted under a previous key to be
+// read during a key rotation window
+func DecryptWithKeys(ciphertextB64 string, keys []string) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no decryption keys provided")
+	}
+
+	var lastErr error
+	for _, key := range keys {
+		plaintext, err := Decrypt(ciphertextB64, key)
+		if err == nil {
+			return plaintext, nil
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("no key could decrypt data: %w", lastErr)
+}
@@ -1,8 +1,11 @@
 package encryption
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -175,8 +178,6 @@ func TestEncrypt(t *testing.T) {
 }
 
 func TestEncryptDecryptRoundTrip(t *testing.T) {
-	// Note: This test requires a decrypt function which doesn't exist in the current code
-	// But we can test that the encryption format is consistent
 	validKey := "12345678901234567890123456789012"
 
 	testData := [][]byte{
@@ -199,15 +200,21 @@ func TestEncryptDecryptRoundTrip(t *testing.T) {
 				t.Errorf("Encrypt() output identical to input")
 			}
 
-			// Verify it's valid base64
+			// Verify structure: nonce (12 bytes) + ciphertext + auth tag (16 bytes)
 			decoded, err := base64.StdEncoding.DecodeString(encrypted)
 			if err != nil {
 				t.Errorf("Encrypt() output is not valid base64: %v", err)
 			}
+			if len(decoded) != 12+len(data)+16 {
+				t.Errorf("Encrypted data is %d bytes, want %d", len(decoded), 12+len(data)+16)
+			}
 
-			// Verify structure: nonce (12 bytes) + ciphertext + auth tag (16 bytes)
-			if len(decoded) < 28 { // 12 + 16 minimum
-				t.Errorf("Encrypted data too short: %d bytes", len(decoded))
+			decrypted, err := Decrypt(encrypted, validKey)
+			if err != nil {
+				t.Fatalf("Decrypt() failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("Decrypt() = %x, want %x", decrypted, data)
 			}
 		})
 	}
@@ -447,3 +454,180 @@ func TestEncryptLargeDataSizes(t *testing.T) {
 		})
 	}
 }
+
+func TestDecrypt(t *testing.T) {
+	validKey := "12345678901234567890123456789012"
+	data := []byte("sensitive metrics payload")
+
+	encrypted, err := Encrypt(data, validKey)
+	if err != nil {
+		t.Fatalf("Encrypt() failed: %v", err)
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(encrypted)
+	tampered := append([]byte(nil), raw...)
+	tampered[len(tampered)-1] ^= 0x01
+
+	tests := []struct {
+		name        string
+		input       string
+		key         string
+		want        []byte
+		wantErrIs   error
+		errContains string
+	}{
+		{
+			name:  "valid ciphertext",
+			input: encrypted,
+			key:   validKey,
+			want:  data,
+		},
+		{
+			name:      "wrong key",
+			input:     encrypted,
+			key:       "abcdefghijklmnopqrstuvwxyz123456",
+			wantErrIs: ErrAuthentication,
+		},
+		{
+			name:      "tampered auth tag",
+			input:     base64.StdEncoding.EncodeToString(tampered),
+			key:       validKey,
+			wantErrIs: ErrAuthentication,
+		},
+		{
+			name:      "truncated input",
+			input:     base64.StdEncoding.EncodeToString(raw[:20]),
+			key:       validKey,
+			wantErrIs: ErrTruncated,
+		},
+		{
+			name:      "empty input",
+			input:     "",
+			key:       validKey,
+			wantErrIs: ErrTruncated,
+		},
+		{
+			name:        "invalid key length",
+			input:       encrypted,
+			key:         "short",
+			errContains: "must be exactly 32 bytes",
+		},
+		{
+			name:        "invalid base64",
+			input:       "not base64!",
+			key:         validKey,
+			errContains: "failed to decode base64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypt(tt.input, tt.key)
+			if tt.wantErrIs == nil && tt.errContains == "" {
+				if err != nil {
+					t.Fatalf("Decrypt() error = %v", err)
+				}
+				if !bytes.Equal(got, tt.want) {
+					t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("Decrypt() expected error")
+			}
+			if got != nil {
+				t.Errorf("Decrypt() returned plaintext %q alongside error", got)
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("Decrypt() error = %v, want %v", err, tt.wantErrIs)
+			}
+			if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Decrypt() error = %v, want error containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestDecryptWithKeys(t *testing.T) {
+	oldKey := "12345678901234567890123456789012"
+	newKey := "abcdefghijklmnopqrstuvwxyz123456"
+	otherKey := "ABCDEFGHIJKLMNOPQRSTUVWXYZ123456"
+	data := []byte("spooled before rotation")
+
+	encrypted, err := Encrypt(data, oldKey)
+	if err != nil {
+		t.Fatalf("Encrypt() failed: %v", err)
+	}
+
+	decrypted, err := DecryptWithKeys(encrypted, []string{newKey, oldKey})
+	if err != nil {
+		t.Fatalf("DecryptWithKeys() failed: %v", err)
+	}
+	if string(decrypted) != string(data) {
+		t.Errorf("DecryptWithKeys() = %q, want %q", decrypted, data)
+	}
+
+	if _, err := DecryptWithKeys(encrypted, []string{newKey, otherKey}); err == nil {
+		t.Error("DecryptWithKeys() without the original key should fail")
+	}
+	if _, err := DecryptWithKeys(encrypted, nil); err == nil {
+		t.Error("DecryptWithKeys() with no keys should fail")
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	// Vectors computed independently with Python's hashlib.pbkdf2_hmac("sha256", ...)
+	tests := []struct {
+		name           string
+		passphrase     string
+		organizationID string
+		wantHex        string
+		wantErr        bool
+	}{
+		{
+			name:           "passphrase with organization salt",
+			passphrase:     "correct horse battery staple",
+			organizationID: "123e4567-e89b-12d3-a456-426614174000",
+			wantHex:        "63120285a8223009ea8eff4b999a3aa53df52c496eec9bfb89cd745da34ba941",
+		},
+		{
+			name:       "passphrase without organization",
+			passphrase: "another passphrase",
+			wantHex:    "36866982e64fd841a3c0b218b918343ff410d3d01dd5300c389f3ce87eed694e",
+		},
+		{
+			name:    "empty passphrase",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := DeriveKey(tt.passphrase, tt.organizationID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeriveKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := hex.EncodeToString([]byte(key)); got != tt.wantHex {
+				t.Errorf("DeriveKey() = %s, want %s", got, tt.wantHex)
+			}
+			if err := ValidateKey(key); err != nil {
+				t.Errorf("DeriveKey() produced an invalid key: %v", err)
+			}
+
+			// A derived key works for a full encrypt/decrypt round trip
+			encrypted, err := Encrypt([]byte("payload"), key)
+			if err != nil {
+				t.Fatalf("Encrypt() with derived key failed: %v", err)
+			}
+			decrypted, err := Decrypt(encrypted, key)
+			if err != nil || string(decrypted) != "payload" {
+				t.Errorf("Decrypt() with derived key = %q, %v", decrypted, err)
+			}
+		})
+	}
+}
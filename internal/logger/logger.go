@@ -1,39 +1,140 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // LoggerInterface defines the interface for logging operations
 type LoggerInterface interface {
 	Printf(format string, v ...interface{})
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
 	Fatalf(format string, v ...interface{})
 	Close() error
 }
 
+// Level is the severity of a log message
+type Level int32
+
+// Log levels, from the most to the least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level as used in the configuration
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+}
+
+// ParseLevel returns the level with the given name, case insensitively
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("invalid log level: %s (must be 'debug', 'info', 'warn' or 'error')", name)
+	}
+}
+
 var (
 	// Default logger instance
 	defaultLogger LoggerInterface
 	once          sync.Once
+
+	// Minimum level of the messages logged through the package functions
+	minLevel atomic.Int32
 )
 
+func init() {
+	minLevel.Store(int32(LevelInfo))
+}
+
+// SetLevel sets the minimum level of the messages logged through the package functions
+func SetLevel(level Level) {
+	minLevel.Store(int32(level))
+}
+
+// GetLevel returns the minimum level of the messages logged through the package functions
+func GetLevel() Level {
+	return Level(minLevel.Load())
+}
+
+// Enabled reports whether messages of the given level are logged
+func Enabled(level Level) bool {
+	return level >= GetLevel()
+}
+
+// Format is the output format of a Logger
+type Format string
+
+const (
+	// FormatText logs messages as text lines prefixed with the date and time
+	FormatText Format = "text"
+	// FormatJSON logs messages as one JSON object per line with ts, level, msg and the logger's fields
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format with the given name, an empty name meaning text
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return FormatText, fmt.Errorf("invalid log format: %s (must be 'text' or 'json')", name)
+	}
+}
+
+// Fields are structured fields added to every message of a Logger
+type Fields map[string]interface{}
+
 // Logger represents a logger that writes to both stdout and a file
 type Logger struct {
 	stdLog  *log.Logger
 	fileLog *log.Logger
 	logFile io.WriteCloser
+	format  Format
+	fields  Fields
 }
 
-// Initialize sets up the default logger with the specified log file path
-func Initialize(logFilePath string) error {
+// Initialize sets up the default logger with the specified log file path and format
+func Initialize(logFilePath string, format Format) error {
 	var err error
 	once.Do(func() {
-		logger, initErr := NewLogger(logFilePath)
+		logger, initErr := NewLogger(logFilePath, format)
 		if initErr != nil {
 			err = initErr
 			return
@@ -55,8 +156,8 @@ func SetDefaultLogger(logger LoggerInterface) {
 	defaultLogger = logger
 }
 
-// NewLogger creates a new instance of Logger with the specified log file path
-func NewLogger(logFilePath string) (*Logger, error) {
+// NewLogger creates a new instance of Logger with the specified log file path and format
+func NewLogger(logFilePath string, format Format) (*Logger, error) {
 	// Create directory for log file if it doesn't exist
 	dir := filepath.Dir(logFilePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -72,16 +173,23 @@ func NewLogger(logFilePath string) (*Logger, error) {
 	// Create multi-writer to write to both stdout and log file
 	multiWriter := io.MultiWriter(os.Stdout, logFile)
 
+	// JSON lines carry their own timestamp
+	flags := log.Ldate | log.Ltime
+	if format == FormatJSON {
+		flags = 0
+	}
+
 	// Create logger with timestamp, file, and line number
-	stdLogger := log.New(multiWriter, "", log.Ldate|log.Ltime)
+	stdLogger := log.New(multiWriter, "", flags)
 
 	// Create file-only logger for the full log format
-	fileLogger := log.New(logFile, "", log.Ldate|log.Ltime)
+	fileLogger := log.New(logFile, "", flags)
 
 	logger := &Logger{
 		stdLog:  stdLogger,
 		fileLog: fileLogger,
 		logFile: logFile,
+		format:  format,
 	}
 
 	// Log initialization
@@ -108,22 +216,141 @@ func (l *Logger) Close() error {
 	return nil
 }
 
-// Printf logs a formatted message to both stdout and the log file
-func Printf(format string, v ...interface{}) {
+// levelPrefixes are prepended to messages of each level, info messages are logged as is
+var levelPrefixes = map[Level]string{
+	LevelDebug: "Debug: ",
+	LevelWarn:  "Warning: ",
+	LevelError: "Error: ",
+}
+
+// logf logs a formatted message at the given level through the default logger
+// Messages below the minimum level are dropped before being formatted
+func logf(level Level, format string, v ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+
 	if defaultLogger == nil {
 		// Fall back to standard logger if not initialized
-		log.Printf(format, v...)
+		log.Printf(levelPrefixes[level]+format, v...)
 		return
 	}
 
-	defaultLogger.Printf(format, v...)
+	switch level {
+	case LevelDebug:
+		defaultLogger.Debugf(format, v...)
+	case LevelWarn:
+		defaultLogger.Warnf(format, v...)
+	case LevelError:
+		defaultLogger.Errorf(format, v...)
+	default:
+		defaultLogger.Infof(format, v...)
+	}
+}
+
+// Printf logs a formatted message to both stdout and the log file
+// It is an alias for Infof kept for backward compatibility
+func Printf(format string, v ...interface{}) {
+	logf(LevelInfo, format, v...)
+}
+
+// Debugf logs a formatted troubleshooting message, dropped unless the level is debug
+func Debugf(format string, v ...interface{}) {
+	logf(LevelDebug, format, v...)
+}
+
+// Infof logs a formatted message to both stdout and the log file
+func Infof(format string, v ...interface{}) {
+	logf(LevelInfo, format, v...)
+}
+
+// Warnf logs a formatted message prefixed with "Warning: "
+func Warnf(format string, v ...interface{}) {
+	logf(LevelWarn, format, v...)
+}
+
+// Errorf logs a formatted message prefixed with "Error: "
+func Errorf(format string, v ...interface{}) {
+	logf(LevelError, format, v...)
+}
+
+// WithFields returns a logger sharing the outputs of l that adds fields to every message
+// Fields are JSON object keys in the json format and key=value pairs in the text format.
+// Closing the returned logger is a no-op, l owns the log file
+func (l *Logger) WithFields(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{stdLog: l.stdLog, fileLog: l.fileLog, format: l.format, fields: merged}
+}
+
+// formatMessage renders a message of the given level in the logger's format
+func (l *Logger) formatMessage(level, msg string) string {
+	if l.format != FormatJSON {
+		keys := make([]string, 0, len(l.fields))
+		for k := range l.fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			msg += fmt.Sprintf(" %s=%v", k, l.fields[k])
+		}
+		return msg
+	}
+
+	entry := make(map[string]interface{}, len(l.fields)+3)
+	for k, v := range l.fields {
+		entry[k] = v
+	}
+	entry["ts"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["msg"] = msg
+	line, err := json.Marshal(entry)
+	if err != nil {
+		// Fields that can't be encoded are dropped rather than losing the message
+		line, _ = json.Marshal(map[string]interface{}{"ts": entry["ts"], "level": level, "msg": msg})
+	}
+	return string(line)
+}
+
+// logLevel logs a formatted message at the given level, the text format prefixes it as levelPrefixes does
+func (l *Logger) logLevel(level Level, format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if l.format != FormatJSON {
+		msg = levelPrefixes[level] + msg
+	}
+	line := l.formatMessage(level.String(), msg)
+	l.stdLog.Print(line)
+	l.fileLog.Print(line)
 }
 
 // Printf logs a formatted message for a specific logger instance
 func (l *Logger) Printf(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	l.stdLog.Print(msg)
-	l.fileLog.Print(msg)
+	l.logLevel(LevelInfo, format, v...)
+}
+
+// Debugf logs a formatted message prefixed with "Debug: " for a specific logger instance
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.logLevel(LevelDebug, format, v...)
+}
+
+// Infof logs a formatted message for a specific logger instance
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.logLevel(LevelInfo, format, v...)
+}
+
+// Warnf logs a formatted message prefixed with "Warning: " for a specific logger instance
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	l.logLevel(LevelWarn, format, v...)
+}
+
+// Errorf logs a formatted message prefixed with "Error: " for a specific logger instance
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.logLevel(LevelError, format, v...)
 }
 
 // Fatalf logs a formatted message and exits the program
@@ -140,6 +367,6 @@ func Fatalf(format string, v ...interface{}) {
 // Fatalf logs a formatted message and exits the program for a specific logger instance
 func (l *Logger) Fatalf(format string, v ...interface{}) {
 	msg := fmt.Sprintf(format, v...)
-	l.stdLog.Print(msg)
+	l.stdLog.Print(l.formatMessage("fatal", msg))
 	os.Exit(1)
 }
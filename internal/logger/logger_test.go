@@ -2,15 +2,18 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // MockLogger implements LoggerInterface for testing
@@ -27,6 +30,22 @@ func (m *MockLogger) Printf(format string, v ...interface{}) {
 	m.messages = append(m.messages, fmt.Sprintf(format, v...))
 }
 
+func (m *MockLogger) Debugf(format string, v ...interface{}) {
+	m.Printf("Debug: "+format, v...)
+}
+
+func (m *MockLogger) Infof(format string, v ...interface{}) {
+	m.Printf(format, v...)
+}
+
+func (m *MockLogger) Warnf(format string, v ...interface{}) {
+	m.Printf("Warning: "+format, v...)
+}
+
+func (m *MockLogger) Errorf(format string, v ...interface{}) {
+	m.Printf("Error: "+format, v...)
+}
+
 func (m *MockLogger) Fatalf(format string, v ...interface{}) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -85,7 +104,7 @@ func TestNewLogger(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			logger, err := NewLogger(tt.logFilePath)
+			logger, err := NewLogger(tt.logFilePath, FormatText)
 
 			if tt.wantErr {
 				if err == nil {
@@ -123,7 +142,7 @@ func TestLogger_Printf(t *testing.T) {
 	tempDir := t.TempDir()
 	logFile := filepath.Join(tempDir, "test.log")
 
-	logger, err := NewLogger(logFile)
+	logger, err := NewLogger(logFile, FormatText)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
@@ -190,7 +209,7 @@ func TestLogger_Close(t *testing.T) {
 	tempDir := t.TempDir()
 	logFile := filepath.Join(tempDir, "test.log")
 
-	logger, err := NewLogger(logFile)
+	logger, err := NewLogger(logFile, FormatText)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
@@ -239,7 +258,7 @@ func TestInitialize(t *testing.T) {
 			defaultLogger = nil
 			once = sync.Once{}
 
-			err := Initialize(tt.logFilePath)
+			err := Initialize(tt.logFilePath, FormatText)
 
 			if tt.wantErr {
 				if err == nil {
@@ -259,7 +278,7 @@ func TestInitialize(t *testing.T) {
 
 			// Test that second call doesn't reinitialize
 			oldLogger := defaultLogger
-			err = Initialize(tt.logFilePath)
+			err = Initialize(tt.logFilePath, FormatText)
 			if err != nil {
 				t.Errorf("Initialize() second call error = %v", err)
 			}
@@ -515,7 +534,7 @@ func TestFileLoggerFatalf(t *testing.T) {
 			os.Exit(2)
 		}
 
-		logger, err := NewLogger(logFilePath)
+		logger, err := NewLogger(logFilePath, FormatText)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
 			os.Exit(2)
@@ -593,3 +612,126 @@ func TestGlobalFatalfFallback(t *testing.T) {
 	}
 	t.Fatalf("process ran with err %v, want exit status 1", err)
 }
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Level
+		wantErr bool
+	}{
+		{name: "debug", want: LevelDebug},
+		{name: "INFO", want: LevelInfo},
+		{name: "warning", want: LevelWarn},
+		{name: "error", want: LevelError},
+		{name: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	mock := &MockLogger{}
+	SetDefaultLogger(mock)
+	defer SetDefaultLogger(nil)
+	defer SetLevel(LevelInfo)
+
+	log := func() {
+		Debugf("debug %d", 1)
+		Printf("info %d", 2)
+		Warnf("warn %d", 3)
+		Errorf("error %d", 4)
+	}
+
+	log()
+	want := []string{"info 2", "Warning: warn 3", "Error: error 4"}
+	if got := mock.GetMessages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages at info level = %v, want %v", got, want)
+	}
+
+	mock.messages = nil
+	SetLevel(LevelDebug)
+	log()
+	if got := mock.GetMessages(); len(got) != 4 || got[0] != "Debug: debug 1" {
+		t.Errorf("messages at debug level = %v, want all four starting with the debug one", got)
+	}
+
+	mock.messages = nil
+	SetLevel(LevelError)
+	log()
+	if got := mock.GetMessages(); !reflect.DeepEqual(got, []string{"Error: error 4"}) {
+		t.Errorf("messages at error level = %v, want only the error", got)
+	}
+}
+
+func TestLogger_JSONFormat(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	logger, err := NewLogger(logFile, FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Printf("user %s has %d items", "john", 5)
+	logger.Warnf("disk almost full")
+	logger.WithFields(Fields{"collector": "cpu", "attempt": 2}).Errorf("collection failed")
+	logger.Close()
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line %q is not valid JSON: %v", line, err)
+		}
+		if ts, _ := entry["ts"].(string); ts == "" {
+			t.Errorf("Log line %q has no ts", line)
+		} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+			t.Errorf("Log line %q has an invalid ts: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	find := func(msg string) map[string]interface{} {
+		for _, entry := range entries {
+			if entry["msg"] == msg {
+				return entry
+			}
+		}
+		t.Fatalf("No log line with msg %q in %v", msg, entries)
+		return nil
+	}
+	if entry := find("user john has 5 items"); entry["level"] != "info" {
+		t.Errorf("Printf() level = %v, want info", entry["level"])
+	}
+	if entry := find("disk almost full"); entry["level"] != "warn" {
+		t.Errorf("Warnf() level = %v, want warn", entry["level"])
+	}
+	entry := find("collection failed")
+	if entry["level"] != "error" || entry["collector"] != "cpu" || entry["attempt"] != 2.0 {
+		t.Errorf("Errorf() with fields = %v, want level error with collector and attempt fields", entry)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"": FormatText, "text": FormatText, "JSON": FormatJSON} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(\"xml\") expected an error")
+	}
+}
@@ -0,0 +1,16 @@
+package privileges
+
+import "sync/atomic"
+
+// dropped records the uid the process switched to, or -1 while it still runs with its original identity
+var dropped atomic.Int64
+
+func init() {
+	dropped.Store(-1)
+}
+
+// Dropped reports whether the process has given up its root privileges
+// Collectors that read privileged sources use it to explain missing data
+func Dropped() bool {
+	return dropped.Load() >= 0
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package privileges
+
+import "fmt"
+
+// Drop always fails where the process can't change its user and group ids
+func Drop(userName, groupName string, ownedPaths []string) error {
+	return fmt.Errorf("dropping privileges to %s is unsupported on this platform", userName)
+}
+
+// Check always fails where the process can't change its user and group ids
+func Check(userName, groupName string) error {
+	return Drop(userName, groupName, nil)
+}
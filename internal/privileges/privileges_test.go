@@ -0,0 +1,207 @@
+//go:build unix
+
+package privileges
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mockSyscalls replaces the system calls with recorders and restores them when the test ends
+func mockSyscalls(t *testing.T, euid int) *[]string {
+	t.Helper()
+
+	origGeteuid, origLookupUser, origLookupGroup, origUserGroupIds := geteuid, lookupUser, lookupGroup, userGroupIds
+	origChown, origSetgroups, origSetgid, origSetuid, origGOOS := chown, setgroups, setgid, setuid, goos
+	t.Cleanup(func() {
+		geteuid, lookupUser, lookupGroup, userGroupIds = origGeteuid, origLookupUser, origLookupGroup, origUserGroupIds
+		chown, setgroups, setgid, setuid, goos = origChown, origSetgroups, origSetgid, origSetuid, origGOOS
+		dropped.Store(-1)
+	})
+
+	calls := &[]string{}
+	goos = "linux"
+	geteuid = func() int { return euid }
+	lookupUser = func(name string) (*user.User, error) {
+		if name != "monitorly" {
+			return nil, user.UnknownUserError(name)
+		}
+		return &user.User{Username: name, Uid: "999", Gid: "999"}, nil
+	}
+	lookupGroup = func(name string) (*user.Group, error) {
+		if name != "adm" {
+			return nil, user.UnknownGroupError(name)
+		}
+		return &user.Group{Name: name, Gid: "4"}, nil
+	}
+	userGroupIds = func(u *user.User) ([]string, error) {
+		return []string{"999", "4", "190"}, nil
+	}
+	chown = func(path string, uid, gid int) error {
+		*calls = append(*calls, "chown "+path)
+		return nil
+	}
+	setgroups = func(gids []int) error {
+		*calls = append(*calls, fmt.Sprintf("setgroups %v", gids))
+		return nil
+	}
+	setgid = func(gid int) error {
+		*calls = append(*calls, "setgid")
+		if gid != 999 && gid != 4 {
+			return errors.New("unexpected gid")
+		}
+		return nil
+	}
+	setuid = func(uid int) error {
+		*calls = append(*calls, "setuid")
+		return nil
+	}
+	return calls
+}
+
+func TestDrop(t *testing.T) {
+	tests := []struct {
+		name        string
+		euid        int
+		user        string
+		group       string
+		goos        string
+		wantCalls   []string
+		wantDropped bool
+		errContains string
+	}{
+		{
+			name:        "drops from root",
+			euid:        0,
+			user:        "monitorly",
+			wantCalls:   []string{"chown /var/log/probe.log", "setgroups [999 4 190]", "setgid", "setuid"},
+			wantDropped: true,
+		},
+		{
+			name:        "drops with explicit group",
+			euid:        0,
+			user:        "monitorly",
+			group:       "adm",
+			wantCalls:   []string{"chown /var/log/probe.log", "setgroups [4 999 190]", "setgid", "setuid"},
+			wantDropped: true,
+		},
+		{
+			name:      "already running as target user",
+			euid:      999,
+			user:      "monitorly",
+			wantCalls: []string{},
+		},
+		{
+			name:        "not root",
+			euid:        1000,
+			user:        "monitorly",
+			wantCalls:   []string{},
+			errContains: "requires starting the probe as root",
+		},
+		{
+			name:        "unknown user",
+			euid:        0,
+			user:        "nobody-here",
+			wantCalls:   []string{},
+			errContains: "failed to look up user",
+		},
+		{
+			name:        "unknown group",
+			euid:        0,
+			user:        "monitorly",
+			group:       "nogroup-here",
+			wantCalls:   []string{},
+			errContains: "failed to look up group",
+		},
+		{
+			name:        "unsupported platform",
+			euid:        0,
+			user:        "monitorly",
+			goos:        "darwin",
+			wantCalls:   []string{},
+			errContains: "only supported on Linux",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := mockSyscalls(t, tt.euid)
+			if tt.goos != "" {
+				goos = tt.goos
+			}
+
+			err := Drop(tt.user, tt.group, []string{"/var/log/probe.log", ""})
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("Drop() error = %v, want error containing %q", err, tt.errContains)
+				}
+			} else if err != nil {
+				t.Fatalf("Drop() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(*calls, tt.wantCalls) {
+				t.Errorf("Drop() calls = %v, want %v", *calls, tt.wantCalls)
+			}
+			if Dropped() != tt.wantDropped {
+				t.Errorf("Dropped() = %v, want %v", Dropped(), tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestDrop_Repeated(t *testing.T) {
+	calls := mockSyscalls(t, 0)
+
+	if err := Drop("monitorly", "", nil); err != nil {
+		t.Fatalf("Drop() error = %v", err)
+	}
+
+	// After the drop the process is no longer root, a restart with the same user is a no-op
+	geteuid = func() int { return 999 }
+	if err := Drop("monitorly", "", nil); err != nil {
+		t.Errorf("second Drop() error = %v, want nil", err)
+	}
+	if len(*calls) != 3 {
+		t.Errorf("Drop() made %d system calls, want 3 for a single drop", len(*calls))
+	}
+
+	// Switching to another user can't be done without root
+	lookupUser = func(name string) (*user.User, error) {
+		return &user.User{Username: name, Uid: "1001", Gid: "1001"}, nil
+	}
+	if err := Drop("other", "", nil); err == nil || !strings.Contains(err.Error(), "already dropped") {
+		t.Errorf("Drop() to another user error = %v, want already dropped error", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	calls := mockSyscalls(t, 0)
+
+	if err := Check("monitorly", "adm"); err != nil {
+		t.Errorf("Check() as root error = %v, want nil", err)
+	}
+	if err := Check("nobody-here", ""); err == nil {
+		t.Error("Check() for an unknown user error = nil, want lookup error")
+	}
+	if len(*calls) != 0 {
+		t.Errorf("Check() made system calls %v, want none", *calls)
+	}
+
+	if err := Drop("monitorly", "", nil); err != nil {
+		t.Fatalf("Drop() error = %v", err)
+	}
+	geteuid = func() int { return 999 }
+	if err := Check("monitorly", ""); err != nil {
+		t.Errorf("Check() for the current user error = %v, want nil", err)
+	}
+	lookupUser = func(name string) (*user.User, error) {
+		return &user.User{Username: name, Uid: "1001", Gid: "1001"}, nil
+	}
+	if err := Check("other", ""); err == nil || !strings.Contains(err.Error(), "already dropped") {
+		t.Errorf("Check() for another user error = %v, want already dropped error", err)
+	}
+}
@@ -0,0 +1,141 @@
+//go:build unix
+
+package privileges
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+// Variables to allow mocking the system calls in tests
+var (
+	geteuid      = os.Geteuid
+	lookupUser   = user.Lookup
+	lookupGroup  = user.LookupGroup
+	userGroupIds = (*user.User).GroupIds
+	chown        = os.Chown
+	setgroups    = syscall.Setgroups
+	setgid       = syscall.Setgid
+	setuid       = syscall.Setuid
+	goos         = runtime.GOOS
+)
+
+// Drop switches the process to the given user and group for the rest of its lifetime
+// If groupName is empty the user's primary group is used. The user keeps the groups it belongs to
+// as supplementary groups. Paths in ownedPaths are chowned to the target identity first so the
+// probe can keep writing its own files afterwards
+// Calling Drop again with the same user is a no-op
+func Drop(userName, groupName string, ownedPaths []string) error {
+	uid, gid, groups, noop, err := plan(userName, groupName)
+	if err != nil || noop {
+		return err
+	}
+
+	for _, path := range ownedPaths {
+		if path == "" {
+			continue
+		}
+		if err := chown(path, uid, gid); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to change owner of %s: %w", path, err)
+		}
+	}
+
+	// Supplementary groups go first, setuid removes the right to change them
+	if err := setgroups(groups); err != nil {
+		return fmt.Errorf("failed to set supplementary groups: %w", err)
+	}
+	if err := setgid(gid); err != nil {
+		return fmt.Errorf("failed to set group id %d: %w", gid, err)
+	}
+	if err := setuid(uid); err != nil {
+		return fmt.Errorf("failed to set user id %d: %w", uid, err)
+	}
+
+	dropped.Store(int64(uid))
+	return nil
+}
+
+// Check reports whether Drop can switch to the given user and group, without changing anything
+// It is used to reject a reloaded configuration before the running probe is stopped
+func Check(userName, groupName string) error {
+	_, _, _, _, err := plan(userName, groupName)
+	return err
+}
+
+// plan resolves the identity Drop switches to and checks that the process can still switch to it
+// noop is set when the process already runs as that user
+func plan(userName, groupName string) (uid, gid int, groups []int, noop bool, err error) {
+	if goos != "linux" {
+		return 0, 0, nil, false, fmt.Errorf("dropping privileges is only supported on Linux")
+	}
+
+	uid, gid, groups, err = resolve(userName, groupName)
+	if err != nil {
+		return 0, 0, nil, false, err
+	}
+
+	if prev := dropped.Load(); prev >= 0 {
+		if prev == int64(uid) {
+			return uid, gid, groups, true, nil
+		}
+		return 0, 0, nil, false, fmt.Errorf("privileges already dropped to uid %d, restart the probe as root to switch to %s", prev, userName)
+	}
+
+	if euid := geteuid(); euid != 0 {
+		if euid == uid {
+			return uid, gid, groups, true, nil
+		}
+		return 0, 0, nil, false, fmt.Errorf("dropping privileges to %s requires starting the probe as root", userName)
+	}
+	return uid, gid, groups, false, nil
+}
+
+// resolve looks up the numeric user and group ids for the given names, and the groups to keep
+// as supplementary groups: the primary group followed by every group the user belongs to
+func resolve(userName, groupName string) (int, int, []int, error) {
+	u, err := lookupUser(userName)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to look up user %s: %w", userName, err)
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid uid %q for user %s", u.Uid, userName)
+	}
+
+	gidStr := u.Gid
+	if groupName != "" {
+		g, err := lookupGroup(groupName)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to look up group %s: %w", groupName, err)
+		}
+		gidStr = g.Gid
+	}
+
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid gid %q for group %s", gidStr, groupName)
+	}
+
+	// Memberships such as adm or systemd-journal grant access to logs and the journal
+	ids, err := userGroupIds(u)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to look up groups of user %s: %w", userName, err)
+	}
+	groups := []int{gid}
+	for _, id := range ids {
+		g, err := strconv.Atoi(id)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid gid %q in the groups of user %s", id, userName)
+		}
+		if g != gid {
+			groups = append(groups, g)
+		}
+	}
+
+	return uid, gid, groups, nil
+}
@@ -3,18 +3,27 @@ package sender
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/config"
 	"github.com/monitorly-app/probe/internal/encryption"
 	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/monitorly-app/probe/internal/serialization"
+	"gopkg.in/yaml.v3"
 )
 
 // APISender sends metrics to a remote API endpoint
@@ -27,12 +36,42 @@ type APISender struct {
 	encryptionKey         string
 	client                *http.Client
 	encryptionWarningOnce sync.Once
-	configPath            string        // Path to the config file
-	restartChan           chan struct{} // Channel to signal restart
+	configPath            string         // Path to the config file
+	restartChan           chan struct{}  // Channel to signal restart
+	retryPolicy           RetryPolicy    // Retry behavior for transient failures, no retries by default
+	configFetchPolicy     RetryPolicy    // Retry behavior for fetching updated config, no retries by default
+	configMu              sync.Mutex     // Guards the config update state below
+	pendingConfigUpdate   time.Time      // Server config version still to fetch after a failed attempt
+	rejectedConfigUpdate  time.Time      // Server config version that failed validation, not fetched again
+	sequence              *Sequence      // Optional: Numbers successful metric sends
+	changes               *ChangeTracker // Optional: Lists the metric keys that changed since the last send
+	digest                bool           // Include the SHA-256 digest of the metrics in each payload
+	bodyLimit             BodyLimit      // Optional: Ceiling on the compressed request body
+	compression           Compression    // Gzip settings for request bodies, every body compressed by default
+	batchSize             atomic.Int64   // Maximum metrics per request, lowered on 413 responses, unlimited when zero
+	proxy                 *url.URL       // Optional: Proxy overriding the proxy from the environment
+	tlsConfig             *tls.Config    // Optional: Custom CAs and client certificate, system trust store when nil
+	streaming             bool           // Stream metrics as NDJSON instead of sending one JSON document
+	protobuf              atomic.Bool    // Serialize metrics payloads as protobuf, cleared if the API rejects it
+	debugRequests         bool           // Log requests and error responses, with the token redacted
 }
 
+// RetryPolicy configures how APISender retries transient failures
+type RetryPolicy struct {
+	MaxRetries     int           // Number of retries after the first attempt
+	InitialBackoff time.Duration // Delay before the first retry, doubled on each retry
+	MaxBackoff     time.Duration // Upper bound for the delay between retries
+}
+
+// DefaultRequestTimeout is the time allowed for an API request when none is configured
+const DefaultRequestTimeout = 30 * time.Second
+
 // NewAPISender creates a new APISender instance
-func NewAPISender(baseURL, organizationID, serverID, applicationToken, machineName, encryptionKey, configPath string, restartChan chan struct{}) *APISender {
+// requestTimeout bounds each request including reading the response, DefaultRequestTimeout when zero
+func NewAPISender(baseURL, organizationID, serverID, applicationToken, machineName, encryptionKey, configPath string, restartChan chan struct{}, requestTimeout time.Duration) *APISender {
+	if requestTimeout <= 0 {
+		requestTimeout = DefaultRequestTimeout
+	}
 	return &APISender{
 		baseURL:               baseURL,
 		organizationID:        organizationID,
@@ -40,130 +79,228 @@ func NewAPISender(baseURL, organizationID, serverID, applicationToken, machineNa
 		applicationToken:      applicationToken,
 		machineName:           machineName,
 		encryptionKey:         encryptionKey,
-		client:                &http.Client{Timeout: 30 * time.Second},
+		client:                &http.Client{Timeout: requestTimeout, Transport: newTransport(nil, nil)},
 		encryptionWarningOnce: sync.Once{},
 		configPath:            configPath,
 		restartChan:           restartChan,
 	}
 }
 
+// SetRetryPolicy sets how transient send failures are retried
+func (s *APISender) SetRetryPolicy(policy RetryPolicy) {
+	s.retryPolicy = policy
+}
+
+// SetConfigFetchRetryPolicy sets how transient failures fetching updated config are retried
+func (s *APISender) SetConfigFetchRetryPolicy(policy RetryPolicy) {
+	s.configFetchPolicy = policy
+}
+
+// SetCompression sets how request bodies are compressed. Streamed metrics are always gzipped
+func (s *APISender) SetCompression(compression Compression) {
+	s.compression = compression
+}
+
+// SetSequence makes the sender number each metrics payload with seq, committing the number once sent
+func (s *APISender) SetSequence(seq *Sequence) {
+	s.sequence = seq
+}
+
+// SetChangeManifest makes the sender add to each metrics payload the keys of the metrics whose
+// value changed since the last successful send, tracked by changes
+func (s *APISender) SetChangeManifest(changes *ChangeTracker) {
+	s.changes = changes
+}
+
+// SetDigest makes the sender include in each metrics payload a batch_digest, the SHA-256 of the
+// "metrics" array as serialized in the payload, before compression and encryption
+func (s *APISender) SetDigest(digest bool) {
+	s.digest = digest
+}
+
+// SetDebugRequests makes the sender log each request body and error response, for troubleshooting.
+// The application token is redacted but metrics are logged as is
+func (s *APISender) SetDebugRequests(debug bool) {
+	s.debugRequests = debug
+}
+
+// redactToken hides all but the last 4 characters of a token, enough to tell tokens apart
+func redactToken(token string) string {
+	if len(token) <= 8 {
+		return "****"
+	}
+	return "****" + token[len(token)-4:]
+}
+
 // Send sends metrics to the API endpoint
 func (s *APISender) Send(metrics []collector.Metrics) error {
 	return s.SendWithContext(context.Background(), metrics)
 }
 
-// SendWithContext sends metrics to the API endpoint with the provided context
+// SendWithContext sends metrics to the API endpoint with the provided context, split into
+// batches when a maximum batch size is set or the API rejected a request as too large
+// Transient failures are retried with exponential backoff according to the retry policy
 func (s *APISender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
-	// Determine if this is system info or regular metrics
-	isSystemInfo := false
-	if len(metrics) == 1 && metrics[0].Name == collector.NameSystemInfo {
-		isSystemInfo = true
+	if len(metrics) == 0 || isSystemInfoBatch(metrics) {
+		return s.sendBatch(ctx, metrics)
 	}
+	return s.sendBatches(ctx, metrics)
+}
 
-	// Build the appropriate URL
-	var url string
-	if isSystemInfo {
-		url = fmt.Sprintf("%s/api/%s/servers/%s/info", s.baseURL, s.organizationID, s.serverID)
-	} else {
-		url = fmt.Sprintf("%s/api/%s/servers/%s/metrics", s.baseURL, s.organizationID, s.serverID)
-	}
-	
-	// DEBUG: Log what we're sending
-	fmt.Printf("DEBUG: Sending to URL: %s\n", url)
-	fmt.Printf("DEBUG: Organization ID: %s\n", s.organizationID)
-	fmt.Printf("DEBUG: Server ID: %s\n", s.serverID)
-	fmt.Printf("DEBUG: Application Token: %s\n", s.applicationToken)
-	fmt.Printf("DEBUG: Is System Info: %v\n", isSystemInfo)
-
-	// Prepare request body
-	requestBody := map[string]interface{}{
-		"machine_name": s.machineName,
-		"metrics":      metrics,
+// sendBatch sends metrics in a single request, retried according to the retry policy
+func (s *APISender) sendBatch(ctx context.Context, metrics []collector.Metrics) error {
+	// Retries carry the same sequence number, it is only committed once the payload is accepted
+	// and released again for the next send otherwise
+	var seq uint64
+	if s.sequence != nil && !isSystemInfoBatch(metrics) {
+		seq = s.sequence.Next()
 	}
-	
-	// DEBUG: Log the request body
-	requestBodyJSON, _ := json.MarshalIndent(requestBody, "", "  ")
-	fmt.Printf("DEBUG: Request body: %s\n", string(requestBodyJSON))
 
-	// First try with encryption if a key is provided
-	var requestData []byte
-	var isEncrypted bool
-	var isCompressed bool
-
-	if s.encryptionKey != "" {
-		if err := encryption.ValidateKey(s.encryptionKey); err != nil {
-			return fmt.Errorf("invalid encryption key: %w", err)
+	if !isSystemInfoBatch(metrics) {
+		var err error
+		if metrics, err = s.fitBodyLimit(metrics, seq); err != nil || len(metrics) == 0 {
+			if seq > 0 {
+				s.sequence.Rollback(seq)
+			}
+			return err
 		}
+	}
 
-		// Marshal the original request body for encryption
-		jsonData, err := json.Marshal(requestBody)
+	send := s.sendOnce
+	if s.streaming && !isSystemInfoBatch(metrics) {
+		send = s.sendStream
+	}
+	err := s.retryPolicy.do(ctx, "Send", func() error {
+		return send(ctx, metrics, seq)
+	})
+	if seq > 0 {
 		if err != nil {
-			return fmt.Errorf("failed to marshal request body: %w", err)
+			s.sequence.Rollback(seq)
+		} else if err := s.sequence.Commit(seq); err != nil {
+			logger.Warnf("Failed to persist send sequence: %v", err)
 		}
+	}
+	// Changes are relative to what the backend has, so unsent values stay changed until they are sent
+	if err == nil && s.changes != nil && !isSystemInfoBatch(metrics) {
+		s.changes.Commit(metrics)
+	}
+	return err
+}
 
-		// Encrypt the data
-		encryptedData, err := encryption.Encrypt(jsonData, s.encryptionKey)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt data: %w", err)
-		}
+// isSystemInfoBatch reports whether metrics is the system information payload sent at startup
+func isSystemInfoBatch(metrics []collector.Metrics) bool {
+	return len(metrics) == 1 && metrics[0].Name == collector.NameSystemInfo
+}
 
-		// Prepare encrypted request body
-		encryptedBody := map[string]interface{}{
-			"machine_name": s.machineName,
-			"encrypted":    true,
-			"data":         encryptedData,
+// do runs op, retrying it with exponential backoff while it fails with a transient error.
+// The last error is returned once retries are exhausted or ctx is done
+func (p RetryPolicy) do(ctx context.Context, what string, op func() error) error {
+	err := op()
+	for attempt := 0; attempt < p.MaxRetries && isTransient(err); attempt++ {
+		delay := p.backoff(attempt)
+		logger.Printf("%s failed (%v), retrying in %v (%d/%d)", what, err, delay, attempt+1, p.MaxRetries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
 		}
 
-		requestData, err = json.Marshal(encryptedBody)
-		if err != nil {
-			return fmt.Errorf("failed to marshal encrypted request body: %w", err)
-		}
-		isEncrypted = true
+		err = op()
+	}
+	return err
+}
+
+// backoff returns the delay before the given retry, doubling from InitialBackoff up to
+// MaxBackoff. Jitter spreads the delay over [d/2, d) so probes don't retry in lockstep
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 0; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)))
+}
+
+// isTransient reports whether a send error is worth retrying right away:
+// network errors and 502, 503 or 504 responses. Other statuses, including 429
+// which is handled by adjusting send_interval, fail fast
+func isTransient(err error) bool {
+	var retryable *RetryableError
+	if !errors.As(err, &retryable) {
+		return false
+	}
+	switch retryable.StatusCode {
+	case 0, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// sendOnce makes a single attempt at sending metrics to the API endpoint
+// A non-zero seq is included in the payload along with the sequence session ID
+func (s *APISender) sendOnce(ctx context.Context, metrics []collector.Metrics, seq uint64) error {
+	// Determine if this is system info or regular metrics
+	isSystemInfo := isSystemInfoBatch(metrics)
+
+	// Build the appropriate URL
+	var url string
+	if isSystemInfo {
+		url = fmt.Sprintf("%s/api/%s/servers/%s/info", s.baseURL, s.organizationID, s.serverID)
 	} else {
-		// No encryption, marshal the request body
-		jsonData, err := json.Marshal(requestBody)
-		if err != nil {
-			return fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		requestData = jsonData
+		url = fmt.Sprintf("%s/api/%s/servers/%s/metrics", s.baseURL, s.organizationID, s.serverID)
 	}
 
-	// Always compress the request data
-	compressedData, err := compressData(requestData)
-	if err != nil {
-		return fmt.Errorf("failed to compress data: %w", err)
+	// Prepare request body
+	requestBody := s.requestBody(metrics, seq)
+
+	if s.debugRequests {
+		logger.Debugf("Sending to %s (organization %s, server %s, token %s, system info: %v)",
+			url, s.organizationID, s.serverID, redactToken(s.applicationToken), isSystemInfo)
+		requestBodyJSON, _ := json.MarshalIndent(requestBody, "", "  ")
+		logger.Debugf("Request body: %s", requestBodyJSON)
 	}
-	requestData = compressedData
-	isCompressed = true
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestData))
+	// First try with encryption if a key is provided
+	body, err := s.encodeRequest(requestBody)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.applicationToken)
-	req.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
-	if isCompressed {
-		req.Header.Set("Content-Encoding", "gzip")
+	req, err := s.newPayloadRequest(ctx, url, body)
+	if err != nil {
+		return err
 	}
 
 	// Send request
-	resp, err := s.client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return &RetryableError{Err: fmt.Errorf("failed to send request: %w", err)}
 	}
 	defer resp.Body.Close()
 
-	s.checkConfigUpdate(resp)
+	s.checkConfigUpdate(ctx, resp)
+
+	// Fall back to JSON for good when the API doesn't accept protobuf
+	if resp.StatusCode == http.StatusUnsupportedMediaType && body.contentType == serialization.ProtobufContentType {
+		logger.Warnf("API does not accept protobuf payloads, falling back to JSON")
+		s.protobuf.Store(false)
+		return s.sendOnce(ctx, metrics, seq)
+	}
 
 	// Handle encryption not available (premium feature)
-	if resp.StatusCode == http.StatusPreconditionFailed && isEncrypted {
+	if resp.StatusCode == http.StatusPreconditionFailed && body.encrypted {
 		// Log warning only once per sender instance
 		s.encryptionWarningOnce.Do(func() {
-			logger.GetDefaultLogger().Printf("Warning: Encryption not available (requires premium subscription). Falling back to unencrypted transmission.")
+			logger.Warnf("Encryption not available (requires premium subscription). Falling back to unencrypted transmission.")
 		})
 
 		// Retry without encryption - use the original request body
@@ -172,52 +309,46 @@ func (s *APISender) SendWithContext(ctx context.Context, metrics []collector.Met
 			return fmt.Errorf("failed to marshal fallback request body: %w", err)
 		}
 
-		// Always compress the fallback request
-		compressedData, err := compressData(jsonData)
+		fallback := encodedRequest{contentType: "application/json"}
+		fallback.data, fallback.compressed, err = s.compression.compress(jsonData)
 		if err != nil {
 			return fmt.Errorf("failed to compress fallback data: %w", err)
 		}
-		fallbackRequestData := compressedData
-		fallbackIsCompressed := true
 
-		// Create new request
-		fallbackReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(fallbackRequestData))
+		fallbackReq, err := s.newPayloadRequest(ctx, url, fallback)
 		if err != nil {
 			return fmt.Errorf("failed to create fallback request: %w", err)
 		}
 
-		// Set headers
-		fallbackReq.Header.Set("Content-Type", "application/json")
-		fallbackReq.Header.Set("Authorization", "Bearer "+s.applicationToken)
-		fallbackReq.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
-		if fallbackIsCompressed {
-			fallbackReq.Header.Set("Content-Encoding", "gzip")
-		}
-
 		// Send fallback request
-		resp, err = s.client.Do(fallbackReq)
+		resp, err = s.do(fallbackReq)
 		if err != nil {
-			return fmt.Errorf("failed to send fallback request: %w", err)
+			return &RetryableError{Err: fmt.Errorf("failed to send fallback request: %w", err)}
 		}
 		defer resp.Body.Close()
 
-		s.checkConfigUpdate(resp)
+		s.checkConfigUpdate(ctx, resp)
 	}
 
+	return s.checkResponse(resp)
+}
+
+// checkResponse turns an API response status into the matching error, nil on success
+func (s *APISender) checkResponse(resp *http.Response) error {
 	// Check response status
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// DEBUG: Log the response
-		responseBody, _ := io.ReadAll(resp.Body)
-		fmt.Printf("DEBUG: Response status: %d\n", resp.StatusCode)
-		fmt.Printf("DEBUG: Response body: %s\n", string(responseBody))
-		
+		if s.debugRequests {
+			responseBody, _ := io.ReadAll(resp.Body)
+			logger.Debugf("Response status: %d, body: %s", resp.StatusCode, responseBody)
+		}
+
 		switch resp.StatusCode {
 		case http.StatusNotFound: // 404
 			return fmt.Errorf("FATAL: API request failed with status 404 - Organization or server not found")
 		case http.StatusUnauthorized: // 401
 			return fmt.Errorf("FATAL: API request failed with status 401 - Invalid application token")
 		case http.StatusRequestEntityTooLarge: // 413
-			return fmt.Errorf("WARNING: API request failed with status 413 - Too many metrics for your plan, some metrics were ignored")
+			return ErrTooManyMetrics
 		case http.StatusTooManyRequests: // 429
 			// Check for rate limit header
 			if rateLimitHeader := resp.Header.Get("X-Rate-Limit"); rateLimitHeader != "" {
@@ -231,70 +362,292 @@ func (s *APISender) SendWithContext(ctx context.Context, metrics []collector.Met
 			}
 			return fmt.Errorf("WARNING: API request failed with status 429 - Rate limit exceeded")
 		case http.StatusServiceUnavailable: // 503
-			return fmt.Errorf("WARNING: API request failed with status 503 - Server is undergoing maintenance, metrics will be buffered")
+			return &RetryableError{Err: fmt.Errorf("WARNING: API request failed with status 503 - Server is undergoing maintenance, metrics will be buffered"), StatusCode: resp.StatusCode}
 		default:
-			return fmt.Errorf("API request failed with status %d", resp.StatusCode)
+			err := fmt.Errorf("API request failed with status %d", resp.StatusCode)
+			if resp.StatusCode >= 500 {
+				return &RetryableError{Err: err, StatusCode: resp.StatusCode}
+			}
+			return err
 		}
 	}
 
 	return nil
 }
 
-// checkConfigUpdate checks the X-Configuration-Last-Update header and updates config if needed
-func (s *APISender) checkConfigUpdate(resp *http.Response) {
-	header := resp.Header.Get("X-Configuration-Last-Update")
-	if header == "" || s.configPath == "" || s.restartChan == nil {
-		return
+// requestBody builds the payload for metrics. A non-zero seq is included along with the sequence session ID,
+// and the change manifest and batch digest when enabled
+func (s *APISender) requestBody(metrics []collector.Metrics, seq uint64) map[string]interface{} {
+	requestBody := map[string]interface{}{
+		"machine_name": s.machineName,
+		"metrics":      metrics,
 	}
-	serverTime, err := parseConfigTimestamp(header)
-	if err != nil {
-		logger.GetDefaultLogger().Printf("Invalid X-Configuration-Last-Update header: %v", err)
-		return
+	if seq > 0 {
+		requestBody["sequence"] = seq
+		requestBody["session_id"] = s.sequence.SessionID()
 	}
-	fileInfo, err := os.Stat(s.configPath)
+	if s.changes != nil && !isSystemInfoBatch(metrics) {
+		requestBody["changed_metrics"] = s.changes.Changed(metrics)
+	}
+	if s.digest && !isSystemInfoBatch(metrics) {
+		// A batch that can't be serialized fails when the payload is marshaled
+		if digest, err := batchDigest(metrics); err == nil {
+			requestBody["batch_digest"] = digest
+		}
+	}
+	return requestBody
+}
+
+// encodedRequest is a payload ready to be sent along with what its headers must describe
+type encodedRequest struct {
+	data        []byte
+	contentType string // Media type of data once decompressed
+	encrypted   bool   // data is the JSON envelope of an encrypted payload
+	compressed  bool   // data is gzipped
+}
+
+// encodeRequest marshals requestBody, encrypting it if a key is set or as protobuf if enabled, and compresses it
+func (s *APISender) encodeRequest(requestBody map[string]interface{}) (encodedRequest, error) {
+	var requestData []byte
+	var isEncrypted bool
+	contentType := "application/json"
+
+	// The marshalled body is only needed until it is encrypted or compressed, so its buffer is
+	// taken from a pool shared by all sends instead of being allocated each time
+	buf := getBuffer()
+	defer putBuffer(buf)
+	pooled := false
+
+	if s.encryptionKey != "" {
+		if err := encryption.ValidateKey(s.encryptionKey); err != nil {
+			return encodedRequest{}, fmt.Errorf("invalid encryption key: %w", err)
+		}
+
+		// Marshal the original request body for encryption
+		if err := marshalJSON(buf, requestBody); err != nil {
+			return encodedRequest{}, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+
+		// Encrypt the data
+		encryptedData, err := encryption.Encrypt(buf.Bytes(), s.encryptionKey)
+		if err != nil {
+			return encodedRequest{}, fmt.Errorf("failed to encrypt data: %w", err)
+		}
+
+		// Prepare encrypted request body
+		encryptedBody := map[string]interface{}{
+			"machine_name": s.machineName,
+			"encrypted":    true,
+			"data":         encryptedData,
+		}
+
+		requestData, err = json.Marshal(encryptedBody)
+		if err != nil {
+			return encodedRequest{}, fmt.Errorf("failed to marshal encrypted request body: %w", err)
+		}
+		isEncrypted = true
+	} else if metrics, _ := requestBody["metrics"].([]collector.Metrics); s.useProtobuf(metrics) {
+		protoData, err := serialization.SerializePayloadProto(protoPayload(requestBody))
+		if err != nil {
+			return encodedRequest{}, fmt.Errorf("failed to serialize request body: %w", err)
+		}
+		requestData = protoData
+		contentType = serialization.ProtobufContentType
+	} else {
+		// No encryption, marshal the request body
+		if err := marshalJSON(buf, requestBody); err != nil {
+			return encodedRequest{}, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		requestData = buf.Bytes()
+		pooled = true
+	}
+
+	requestData, isCompressed, err := s.compression.compress(requestData)
 	if err != nil {
-		logger.GetDefaultLogger().Printf("Could not stat config file: %v", err)
-		return
+		return encodedRequest{}, fmt.Errorf("failed to compress data: %w", err)
 	}
-	if !serverTime.After(fileInfo.ModTime()) {
-		return
+	if pooled && !isCompressed {
+		// An uncompressed body still points into the pooled buffer
+		requestData = append([]byte(nil), requestData...)
 	}
-	// Fetch new config
-	url := strings.TrimRight(s.baseURL, "/") + "/api/" + s.organizationID + "/servers/" + s.serverID + "/config"
-	req, err := http.NewRequest("GET", url, nil)
+	return encodedRequest{data: requestData, contentType: contentType, encrypted: isEncrypted, compressed: isCompressed}, nil
+}
+
+// newPayloadRequest creates the POST request sending body to url, with headers matching its encoding
+func (s *APISender) newPayloadRequest(ctx context.Context, url string, body encodedRequest) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body.data))
 	if err != nil {
-		logger.GetDefaultLogger().Printf("Failed to create config fetch request: %v", err)
-		return
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+
+	req.Header.Set("Content-Type", body.contentType)
+	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", "Bearer "+s.applicationToken)
 	req.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
-	resp2, err := s.client.Do(req)
+	if body.compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if body.encrypted {
+		req.Header.Set("X-Encrypted", "true")
+	}
+	return req, nil
+}
+
+// do sends req accepting a gzipped response, which is decompressed as the body is read
+func (s *APISender) do(req *http.Request) (*http.Response, error) {
+	// Setting the header ourselves stops the transport from decompressing the response on its own
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := s.client.Do(req)
 	if err != nil {
-		logger.GetDefaultLogger().Printf("Failed to fetch latest config: %v", err)
+		return nil, err
+	}
+	decodeResponseBody(resp)
+	return resp, nil
+}
+
+// checkConfigUpdate checks the X-Configuration-Last-Update header and updates config if needed.
+// A version that could not be fetched is retried on the next send even without the header
+func (s *APISender) checkConfigUpdate(ctx context.Context, resp *http.Response) {
+	if s.configPath == "" || s.restartChan == nil {
 		return
 	}
-	defer resp2.Body.Close()
-	if resp2.StatusCode != 200 {
-		logger.GetDefaultLogger().Printf("Failed to fetch config: status %d", resp2.StatusCode)
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	serverTime := s.pendingConfigUpdate
+	if header := resp.Header.Get("X-Configuration-Last-Update"); header != "" {
+		t, err := parseConfigTimestamp(header)
+		if err != nil {
+			logger.Printf("Invalid X-Configuration-Last-Update header: %v", err)
+			return
+		}
+		serverTime = t
+	}
+	if serverTime.IsZero() || serverTime.Equal(s.rejectedConfigUpdate) {
 		return
 	}
-	data, err := io.ReadAll(resp2.Body)
+
+	fileInfo, err := os.Stat(s.configPath)
 	if err != nil {
-		logger.GetDefaultLogger().Printf("Failed to read config body: %v", err)
+		logger.Printf("Could not stat config file: %v", err)
 		return
 	}
-	err = os.WriteFile(s.configPath, data, 0644)
+	if !serverTime.After(fileInfo.ModTime()) {
+		s.pendingConfigUpdate = time.Time{}
+		return
+	}
+
+	var data []byte
+	err = s.configFetchPolicy.do(ctx, "Config fetch", func() error {
+		var fetchErr error
+		data, fetchErr = s.fetchConfig(ctx)
+		return fetchErr
+	})
 	if err != nil {
-		logger.GetDefaultLogger().Printf("Failed to write new config: %v", err)
+		s.pendingConfigUpdate = serverTime
+		logger.Printf("Failed to fetch config: %v, will retry on next send", err)
 		return
 	}
-	logger.GetDefaultLogger().Printf("Config updated from server, triggering restart...")
+	s.pendingConfigUpdate = time.Time{}
+
+	if err := replaceConfigFile(s.configPath, data, fileInfo.Mode().Perm()); err != nil {
+		s.rejectedConfigUpdate = serverTime
+		logger.Printf("Rejected config from server: %v", err)
+		return
+	}
+
+	logger.Printf("Config updated from server, triggering restart...")
 	select {
 	case s.restartChan <- struct{}{}:
 	default:
 	}
 }
 
+// fetchConfig makes a single attempt at downloading the latest config from the API
+func (s *APISender) fetchConfig(ctx context.Context) ([]byte, error) {
+	url := strings.TrimRight(s.baseURL, "/") + "/api/" + s.organizationID + "/servers/" + s.serverID + "/config"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config fetch request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.applicationToken)
+	req.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, &RetryableError{Err: fmt.Errorf("failed to send config fetch request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("status %d", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			return nil, &RetryableError{Err: err, StatusCode: resp.StatusCode}
+		}
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &RetryableError{Err: fmt.Errorf("failed to read config body: %w", err)}
+	}
+	return data, nil
+}
+
+// createTemp is a variable to allow mocking an unwritable config directory in tests
+var createTemp = os.CreateTemp
+
+// replaceConfigFile validates data as a probe config and atomically replaces path with it.
+// The current config is left untouched if validation fails
+// After dropping privileges the probe owns its config file but usually not the directory, so the
+// file is then validated from the temporary directory and rewritten in place
+func replaceConfigFile(path string, data []byte, perm os.FileMode) error {
+	// The API serves YAML, which can't replace a JSON or TOML config file
+	if format := config.FormatOf(path); format != config.FormatYAML {
+		return fmt.Errorf("config updates from the API need a YAML config file, %s is %s", path, strings.ToUpper(string(format)))
+	}
+
+	inPlace := false
+	tmp, err := createTemp(filepath.Dir(path), ".config-*.yaml")
+	if errors.Is(err, os.ErrPermission) {
+		inPlace = true
+		tmp, err = createTemp("", ".config-*.yaml")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create temporary config file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temporary config file: %w", err)
+	}
+
+	if _, err := config.Load(tmpPath); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	if inPlace {
+		if err := os.WriteFile(path, data, perm); err != nil {
+			return fmt.Errorf("failed to write new config: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set config file permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write new config: %w", err)
+	}
+	return nil
+}
+
 // parseConfigTimestamp parses the config update timestamp from header
 func parseConfigTimestamp(ts string) (time.Time, error) {
 	// Try RFC3339 and fallback to Unix
@@ -317,52 +670,24 @@ func (s *APISender) updateSendIntervalInConfig(rateLimitStr string) error {
 		return fmt.Errorf("invalid rate limit format: %w", err)
 	}
 
+	// Only YAML files are patched, JSON and TOML configs keep their send_interval
+	if format := config.FormatOf(s.configPath); format != config.FormatYAML {
+		return fmt.Errorf("send_interval can only be updated in a YAML config file, %s is %s", s.configPath, strings.ToUpper(string(format)))
+	}
+
 	// Read the current config file
 	data, err := os.ReadFile(s.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Convert to string for easier manipulation
-	configStr := string(data)
-
-	// Look for the send_interval line in the sender section
-	senderSectionFound := false
-	sendIntervalFound := false
-	lines := strings.Split(configStr, "\n")
-
-	for i, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-
-		if trimmedLine == "sender:" {
-			senderSectionFound = true
-			continue
-		}
-
-		if senderSectionFound && strings.Contains(trimmedLine, "send_interval:") {
-			// Preserve the original indentation and replace only the value
-			indentMatch := strings.Index(line, "send_interval:")
-			if indentMatch >= 0 {
-				indent := line[:indentMatch]
-				lines[i] = indent + "send_interval: " + rateLimitStr + "s"
-				sendIntervalFound = true
-				break
-			}
-		}
-
-		// If we've moved past the sender section (line with no indentation and not empty), stop looking
-		if senderSectionFound && len(line) > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && trimmedLine != "" {
-			break
-		}
-	}
-
-	// If we didn't find the send_interval line, log a warning
-	if !sendIntervalFound {
-		return fmt.Errorf("could not find send_interval in config file")
+	updated, err := setSendInterval(data, rateLimitStr+"s")
+	if err != nil {
+		return err
 	}
 
 	// Write the updated config back to the file
-	err = os.WriteFile(s.configPath, []byte(strings.Join(lines, "\n")), 0644)
+	err = os.WriteFile(s.configPath, updated, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write updated config: %w", err)
 	}
@@ -377,32 +702,125 @@ func (s *APISender) updateSendIntervalInConfig(rateLimitStr string) error {
 	return nil
 }
 
+// setSendInterval returns the YAML config data with sender.send_interval set to interval. The
+// document is edited as a node tree, so comments and flow-style mappings are kept, but it is
+// re-indented with the indentation of its first nested line
+func setSendInterval(data []byte, interval string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("could not find send_interval in config file")
+	}
+
+	node := mappingValue(mappingValue(doc.Content[0], "sender"), "send_interval")
+	if node == nil {
+		return nil, fmt.Errorf("could not find send_interval in config file")
+	}
+	node.Kind, node.Tag, node.Style, node.Value = yaml.ScalarNode, "!!str", 0, interval
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(yamlIndent(data))
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue returns the value of key in the mapping node, nil if node isn't a mapping or
+// doesn't have the key
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlIndent returns the number of spaces the first indented line of a YAML document starts with,
+// or 2 when no line is indented
+func yamlIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return indent
+		}
+	}
+	return 2
+}
+
+// configContentTypes are the media types of the config file formats
+var configContentTypes = map[config.Format]string{
+	config.FormatYAML: "application/x-yaml",
+	config.FormatJSON: "application/json",
+	config.FormatTOML: "application/toml",
+}
+
 // SendConfigValidation sends configuration to API for validation
+// When the API made changes to the configuration, the config file is replaced with them
 func (s *APISender) SendConfigValidation(configPath string) error {
+	updatedConfig, err := s.CheckConfigValidation(configPath)
+	if err != nil || updatedConfig == nil {
+		return err
+	}
+
+	// API made changes - update local config and restart
+	logger.Warnf("API has made changes to the configuration")
+
+	// The changes come back as YAML, which can't replace a JSON or TOML config file
+	if format := config.FormatOf(configPath); format != config.FormatYAML {
+		return fmt.Errorf("API changes to the configuration need a YAML config file, %s is %s", configPath, strings.ToUpper(string(format)))
+	}
+
+	// Replace the config file only if the changes still load, keeping its permissions
+	fileInfo, err := os.Stat(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+	if err := replaceConfigFile(configPath, updatedConfig, fileInfo.Mode().Perm()); err != nil {
+		return fmt.Errorf("rejected configuration changes from API: %w", err)
+	}
+
+	logger.Printf("Configuration updated with API changes, restarting...")
+	return nil
+}
+
+// CheckConfigValidation has the API validate the config file at configPath without changing it
+// When the API would make changes to the configuration, the configuration it proposes is returned
+func (s *APISender) CheckConfigValidation(configPath string) ([]byte, error) {
 	// Read configuration file
 	configData, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	// Build API URL for config validation
 	url := fmt.Sprintf("%s/api/%s/servers/%s/config", s.baseURL, s.organizationID, s.serverID)
 
-	// Create request with YAML config in body
+	// Create request with the config file in body
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(configData))
 	if err != nil {
-		return fmt.Errorf("failed to create config validation request: %w", err)
+		return nil, fmt.Errorf("failed to create config validation request: %w", err)
 	}
 
 	// Set headers
-	req.Header.Set("Content-Type", "application/x-yaml")
+	req.Header.Set("Content-Type", configContentTypes[config.FormatOf(configPath)])
 	req.Header.Set("Authorization", "Bearer "+s.applicationToken)
 	req.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
 
 	// Send request
-	resp, err := s.client.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send config validation request: %w", err)
+		return nil, fmt.Errorf("failed to send config validation request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -410,33 +828,22 @@ func (s *APISender) SendConfigValidation(configPath string) error {
 	switch resp.StatusCode {
 	case 200:
 		// Configuration is valid - log and return success for restart
-		logger.GetDefaultLogger().Printf("Configuration validated successfully by API")
-		return nil
+		logger.Printf("Configuration validated successfully by API")
+		return nil, nil
 
 	case 205:
-		// API made changes - update local config and restart
-		logger.GetDefaultLogger().Printf("Warning: API has made changes to the configuration")
-
-		// Read the updated configuration from response
+		// API made changes, returned as YAML
 		updatedConfig, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("failed to read updated config from API: %w", err)
-		}
-
-		// Write updated configuration to file
-		err = os.WriteFile(configPath, updatedConfig, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to write updated config: %w", err)
+			return nil, fmt.Errorf("failed to read updated config from API: %w", err)
 		}
-
-		logger.GetDefaultLogger().Printf("Configuration updated with API changes, restarting...")
-		return nil
+		return updatedConfig, nil
 
 	case 422:
-		// Configuration is invalid - read error details and return fatal error
+		// Configuration is invalid - return the error details, the caller keeps its current config
 		bodyData, err := io.ReadAll(resp.Body)
 		if err != nil {
-			logger.GetDefaultLogger().Fatalf("FATAL: Configuration is invalid (status 422) - unable to read error details")
+			return nil, fmt.Errorf("configuration is invalid (status 422), unable to read error details: %w", err)
 		}
 
 		var errorResponse struct {
@@ -445,20 +852,18 @@ func (s *APISender) SendConfigValidation(configPath string) error {
 		}
 
 		if err := json.Unmarshal(bodyData, &errorResponse); err != nil {
-			logger.GetDefaultLogger().Fatalf("FATAL: Configuration is invalid (status 422) - %s", string(bodyData))
-		} else {
-			logger.GetDefaultLogger().Fatalf("FATAL: Configuration is invalid - %s: %v", errorResponse.Error, errorResponse.Details)
+			return nil, fmt.Errorf("configuration is invalid (status 422): %s", string(bodyData))
 		}
-		return fmt.Errorf("configuration validation failed")
+		return nil, fmt.Errorf("configuration is invalid: %s: %v", errorResponse.Error, errorResponse.Details)
 
 	case 401:
-		return fmt.Errorf("FATAL: Invalid authentication for config validation (status 401)")
+		return nil, fmt.Errorf("FATAL: Invalid authentication for config validation (status 401)")
 
 	case 404:
-		return fmt.Errorf("FATAL: Organization or server not found for config validation (status 404)")
+		return nil, fmt.Errorf("FATAL: Organization or server not found for config validation (status 404)")
 
 	default:
 		bodyData, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("config validation failed with status %d: %s", resp.StatusCode, string(bodyData))
+		return nil, fmt.Errorf("config validation failed with status %d: %s", resp.StatusCode, string(bodyData))
 	}
 }
@@ -19,6 +19,7 @@ import (
 
 	"github.com/monitorly-app/probe/internal/collector"
 	"github.com/monitorly-app/probe/internal/logger"
+	"gopkg.in/yaml.v3"
 )
 
 // mockLogger implements logger.LoggerInterface for testing
@@ -30,6 +31,22 @@ func (m *mockLogger) Printf(format string, v ...interface{}) {
 	m.buffer.WriteString(fmt.Sprintf(format+"\n", v...))
 }
 
+func (m *mockLogger) Debugf(format string, v ...interface{}) {
+	m.Printf("Debug: "+format, v...)
+}
+
+func (m *mockLogger) Infof(format string, v ...interface{}) {
+	m.Printf(format, v...)
+}
+
+func (m *mockLogger) Warnf(format string, v ...interface{}) {
+	m.Printf("Warning: "+format, v...)
+}
+
+func (m *mockLogger) Errorf(format string, v ...interface{}) {
+	m.Printf("Error: "+format, v...)
+}
+
 func (m *mockLogger) Fatalf(format string, v ...interface{}) {
 	m.buffer.WriteString(fmt.Sprintf("FATAL: "+format+"\n", v...))
 	// Note: Don't panic during tests, just log the fatal message for verification
@@ -308,6 +325,7 @@ func TestAPISender_Send(t *testing.T) {
 				tt.encryptionKey,
 				"", // no config path needed
 				restartChan,
+				DefaultRequestTimeout,
 			)
 
 			err := sender.Send(tt.metrics)
@@ -748,6 +766,7 @@ func TestAPISender_SendWithContext(t *testing.T) {
 				tt.encryptionKey,
 				"", // no config path needed
 				restartChan,
+				DefaultRequestTimeout,
 			)
 
 			err := sender.SendWithContext(context.Background(), tt.metrics)
@@ -818,6 +837,7 @@ func TestAPISender_SendWithContext_Concurrent(t *testing.T) {
 		"", // no encryption key
 		"", // no config path needed
 		restartChan,
+		DefaultRequestTimeout,
 	)
 
 	// Run concurrent requests
@@ -949,6 +969,7 @@ func TestAPISender_SendWithContext_Errors(t *testing.T) {
 				tt.encryptionKey,
 				"", // no config path needed
 				restartChan,
+				DefaultRequestTimeout,
 			)
 
 			// Set a short timeout for the client
@@ -1170,6 +1191,15 @@ func (m *mockWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// validServerConfig is a minimal config accepted by config.Load
+var validServerConfig = []byte(`
+api:
+  url: "https://api.monitorly.io"
+  organization_id: "123e4567-e89b-12d3-a456-426614174000"
+  server_id: "123e4567-e89b-12d3-a456-426614174001"
+  application_token: "test-token"
+`)
+
 func TestAPISender_ConfigAutoUpdate(t *testing.T) {
 	// Setup mock logger
 	ml := &mockLogger{}
@@ -1196,6 +1226,7 @@ func TestAPISender_ConfigAutoUpdate(t *testing.T) {
 		expectConfigFetch bool   // Whether we expect a config fetch request
 		expectRestart     bool   // Whether we expect a restart signal
 		serverStatus      int    // HTTP status for /config endpoint
+		gzipResponse      bool   // Whether the /config endpoint gzips its response
 		shouldReturnError bool
 		expectedLog       string
 	}{
@@ -1216,12 +1247,31 @@ func TestAPISender_ConfigAutoUpdate(t *testing.T) {
 		{
 			name:              "newer timestamp - update config",
 			configLastUpdate:  time.Now().Add(1 * time.Hour).Format(time.RFC3339),
-			configResponse:    []byte("new: config"),
+			configResponse:    validServerConfig,
 			expectConfigFetch: true,
 			expectRestart:     true,
 			serverStatus:      http.StatusOK,
 			expectedLog:       "Config updated from server, triggering restart...",
 		},
+		{
+			name:              "gzipped config - update config",
+			configLastUpdate:  time.Now().Add(3 * time.Hour).Format(time.RFC3339),
+			configResponse:    validServerConfig,
+			expectConfigFetch: true,
+			expectRestart:     true,
+			serverStatus:      http.StatusOK,
+			gzipResponse:      true,
+			expectedLog:       "Config updated from server, triggering restart...",
+		},
+		{
+			name:              "invalid config - no restart",
+			configLastUpdate:  time.Now().Add(2 * time.Hour).Format(time.RFC3339),
+			configResponse:    []byte("new: config"),
+			expectConfigFetch: true,
+			expectRestart:     false,
+			serverStatus:      http.StatusOK,
+			expectedLog:       "Rejected config from server: invalid config",
+		},
 		{
 			name:              "config fetch fails",
 			configLastUpdate:  time.Now().Add(1 * time.Hour).Format(time.RFC3339),
@@ -1253,6 +1303,14 @@ func TestAPISender_ConfigAutoUpdate(t *testing.T) {
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				if strings.HasSuffix(r.URL.Path, "/config") {
 					configFetchCount++
+					if tt.gzipResponse {
+						w.Header().Set("Content-Encoding", "gzip")
+						w.WriteHeader(tt.serverStatus)
+						gz := gzip.NewWriter(w)
+						gz.Write(tt.configResponse)
+						gz.Close()
+						return
+					}
 					w.WriteHeader(tt.serverStatus)
 					if tt.configResponse != nil {
 						w.Write(tt.configResponse)
@@ -1278,6 +1336,7 @@ func TestAPISender_ConfigAutoUpdate(t *testing.T) {
 				"",
 				tempConfigFile.Name(),
 				restartChan,
+				DefaultRequestTimeout,
 			)
 
 			// Send test metrics
@@ -1364,6 +1423,7 @@ collection:
 		"",
 		configPath,
 		restartChan,
+		DefaultRequestTimeout,
 	)
 
 	// Test updating the send interval
@@ -1423,12 +1483,102 @@ logging:
 		"",
 		invalidConfigPath,
 		restartChan,
+		DefaultRequestTimeout,
 	)
 
 	err = invalidSender.updateSendIntervalInConfig("60")
 	if err == nil {
 		t.Error("updateSendIntervalInConfig() expected error for missing send_interval")
 	}
+
+	// Test with a JSON config, which is left untouched
+	jsonConfigPath := filepath.Join(tempDir, "config.json")
+	jsonConfig := `{"sender": {"target": "api", "send_interval": "10s"}}`
+	if err := os.WriteFile(jsonConfigPath, []byte(jsonConfig), 0644); err != nil {
+		t.Fatalf("Failed to create JSON test config file: %v", err)
+	}
+
+	jsonSender := NewAPISender("https://api.example.com", "test-org", "test-server", "test-token", "test-machine", "", jsonConfigPath, restartChan, DefaultRequestTimeout)
+	if err := jsonSender.updateSendIntervalInConfig("60"); err == nil {
+		t.Error("updateSendIntervalInConfig() expected error for a JSON config")
+	}
+	if data, _ := os.ReadFile(jsonConfigPath); string(data) != jsonConfig {
+		t.Errorf("updateSendIntervalInConfig() changed the JSON config to %s", data)
+	}
+}
+
+func TestSetSendInterval(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		wantContains []string // Kept or updated in the output besides the new send_interval
+		wantErr      bool
+	}{
+		{
+			name:         "block style",
+			config:       "sender:\n  target: \"api\"\n  send_interval: \"10s\"\nlogging:\n  file_path: \"/tmp/app.log\"\n",
+			wantContains: []string{"send_interval: 60s", `target: "api"`, `file_path: "/tmp/app.log"`},
+		},
+		{
+			name:         "comment on the same line and above",
+			config:       "# Sender settings\nsender:\n  target: api\n  send_interval: 10s # Set by the API\n",
+			wantContains: []string{"# Sender settings", "send_interval: 60s # Set by the API"},
+		},
+		{
+			name:         "flow style mapping",
+			config:       "sender: {target: api, send_interval: 10s}\nmachine_name: web-1\n",
+			wantContains: []string{"send_interval: 60s}", "machine_name: web-1"},
+		},
+		{
+			name:         "tab after the colon",
+			config:       "sender:\n  target: api\n  send_interval:\t10s\n",
+			wantContains: []string{"send_interval: 60s"},
+		},
+		{
+			name:         "send_interval in another section is left alone and indentation is kept",
+			config:       "aggregate:\n    send_interval: 5s\nsender:\n    target: api\n    send_interval: 10s\n",
+			wantContains: []string{"    send_interval: 5s", "    send_interval: 60s"},
+		},
+		{
+			name:    "no send_interval",
+			config:  "sender:\n  target: api\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty file",
+			config:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setSendInterval([]byte(tt.config), "60s")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setSendInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var parsed struct {
+				Sender struct {
+					SendInterval time.Duration `yaml:"send_interval"`
+				} `yaml:"sender"`
+			}
+			if err := yaml.Unmarshal(got, &parsed); err != nil {
+				t.Fatalf("setSendInterval() output is not valid YAML: %v\n%s", err, got)
+			}
+			if parsed.Sender.SendInterval != time.Minute {
+				t.Errorf("setSendInterval() sender.send_interval = %v, want 1m0s", parsed.Sender.SendInterval)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("setSendInterval() output = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
 }
 
 func TestAPISender_SendConfigValidation(t *testing.T) {
@@ -1469,7 +1619,9 @@ collection:
 		serverResponse     int
 		responseBody       string
 		responseHeaders    map[string]string
+		gzipResponse       bool
 		shouldReturnError  bool
+		wantErr            string
 		shouldUpdateConfig bool
 		expectedLogRegex   string
 	}{
@@ -1504,6 +1656,30 @@ collection:
 			shouldUpdateConfig: true,
 			expectedLogRegex:   "Warning: API has made changes to the configuration",
 		},
+		{
+			name:           "gzipped config updated by API - 205",
+			serverResponse: 205,
+			responseBody: `machine_name: "test-server"
+sender:
+  target: "api"
+  send_interval: 10m
+api:
+  url: "https://api.test.com"
+  organization_id: "test-org"
+  server_id: "test-server"
+  application_token: "test-token"
+`,
+			gzipResponse:       true,
+			shouldReturnError:  false,
+			shouldUpdateConfig: true,
+		},
+		{
+			name:              "invalid config changes by API - 205",
+			serverResponse:    205,
+			responseBody:      `sender: [not, a, mapping]`,
+			shouldReturnError: true,
+			wantErr:           "rejected configuration changes from API",
+		},
 		{
 			name:           "invalid config - 422",
 			serverResponse: 422,
@@ -1512,7 +1688,7 @@ collection:
 				"Content-Type": "application/json",
 			},
 			shouldReturnError: true,
-			expectedLogRegex:  "FATAL: Configuration is invalid",
+			wantErr:           "configuration is invalid: Invalid configuration: [Missing required field: sender.target]",
 		},
 		{
 			name:              "authentication error - 401",
@@ -1547,6 +1723,14 @@ collection:
 				}
 
 				// Set status code and write response
+				if tt.gzipResponse {
+					w.Header().Set("Content-Encoding", "gzip")
+					w.WriteHeader(tt.serverResponse)
+					gz := gzip.NewWriter(w)
+					gz.Write([]byte(tt.responseBody))
+					gz.Close()
+					return
+				}
 				w.WriteHeader(tt.serverResponse)
 				w.Write([]byte(tt.responseBody))
 			}))
@@ -1562,10 +1746,9 @@ collection:
 				"",
 				tempConfigFile.Name(),
 				make(chan struct{}, 1),
+				DefaultRequestTimeout,
 			)
 
-			// Note: Fatal calls are captured by the mock logger's buffer
-
 			// Call SendConfigValidation
 			err := sender.SendConfigValidation(tempConfigFile.Name())
 
@@ -1573,6 +1756,14 @@ collection:
 			if (err != nil) != tt.shouldReturnError {
 				t.Errorf("SendConfigValidation() error = %v, wantErr %v", err, tt.shouldReturnError)
 			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("SendConfigValidation() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.serverResponse == 205 && !tt.shouldUpdateConfig {
+				if current, _ := os.ReadFile(tempConfigFile.Name()); strings.Contains(string(current), tt.responseBody) {
+					t.Error("Config was overwritten with changes that don't load")
+				}
+			}
 
 			// Check if config was updated
 			if tt.shouldUpdateConfig {
@@ -1593,8 +1784,345 @@ collection:
 					t.Errorf("Expected log to contain %q, got %q", tt.expectedLogRegex, logOutput)
 				}
 			}
+		})
+	}
+}
+
+func TestAPISender_CheckConfigValidation(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := "sender:\n  target: \"api\"\n"
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	proposed := "sender:\n  target: \"api\"\n  send_interval: 10m\n"
+
+	tests := []struct {
+		name    string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", status: http.StatusOK},
+		{name: "changed by the API", status: http.StatusResetContent, want: proposed},
+		{name: "invalid", status: http.StatusUnprocessableEntity, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusResetContent {
+					w.Write([]byte(proposed))
+				}
+			}))
+			defer server.Close()
 
-			// Fatal calls are logged to the mock logger buffer and checked via expectedLogRegex
+			s := NewAPISender(server.URL, "org", "server", "token", "machine", "", configPath, nil, DefaultRequestTimeout)
+			got, err := s.CheckConfigValidation(configPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckConfigValidation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("CheckConfigValidation() = %q, want %q", got, tt.want)
+			}
+			// The config file is never replaced
+			if current, _ := os.ReadFile(configPath); string(current) != original {
+				t.Errorf("CheckConfigValidation() changed the config file to %q", current)
+			}
 		})
 	}
 }
+
+func TestReplaceConfigFile_UnwritableDirectory(t *testing.T) {
+	originalCreateTemp := createTemp
+	defer func() { createTemp = originalCreateTemp }()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("sender:\n  target: \"api\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	// After dropping privileges the config directory stays owned by root
+	createTemp = func(tmpDir, pattern string) (*os.File, error) {
+		if tmpDir == dir {
+			return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrPermission}
+		}
+		return os.CreateTemp(tmpDir, pattern)
+	}
+
+	updated := "sender:\n  target: \"log_file\"\n"
+	if err := replaceConfigFile(configPath, []byte(updated), 0600); err != nil {
+		t.Fatalf("replaceConfigFile() error = %v", err)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != updated {
+		t.Errorf("config file = %q, want %q", data, updated)
+	}
+
+	// Invalid changes still leave the config untouched
+	if err := replaceConfigFile(configPath, []byte("sender: ["), 0600); err == nil {
+		t.Error("replaceConfigFile() with invalid config error = nil, want an error")
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != updated {
+		t.Errorf("config file after invalid changes = %q, want %q", data, updated)
+	}
+}
+
+func TestNewAPISender_RequestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{name: "configured timeout", timeout: 2 * time.Minute, want: 2 * time.Minute},
+		{name: "fast fail", timeout: 5 * time.Second, want: 5 * time.Second},
+		{name: "unset uses the default", timeout: 0, want: DefaultRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAPISender("https://api.example.com", "org", "server", "token", "machine", "", "", nil, tt.timeout)
+			if s.client.Timeout != tt.want {
+				t.Errorf("client timeout = %v, want %v", s.client.Timeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPISender_DebugRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid metric"}`))
+	}))
+	defer server.Close()
+
+	const token = "app-token-secret-1234"
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+
+	tests := []struct {
+		debug bool
+		level logger.Level
+		want  bool
+	}{
+		{debug: false, level: logger.LevelDebug, want: false},
+		{debug: true, level: logger.LevelDebug, want: true},
+		// Requests are logged at the debug level, filtered out like any other debug message
+		{debug: true, level: logger.LevelInfo, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("debug=%v level=%s", tt.debug, tt.level), func(t *testing.T) {
+			ml := &mockLogger{}
+			originalLogger := logger.GetDefaultLogger()
+			logger.SetDefaultLogger(ml)
+			defer logger.SetDefaultLogger(originalLogger)
+			originalLevel := logger.GetLevel()
+			logger.SetLevel(tt.level)
+			defer logger.SetLevel(originalLevel)
+
+			s := NewAPISender(server.URL, "org", "server", token, "machine", "", "", nil, DefaultRequestTimeout)
+			s.SetDebugRequests(tt.debug)
+			if err := s.Send(metrics); err == nil {
+				t.Fatal("Send() error = nil, want the 400 error")
+			}
+
+			logs := ml.buffer.String()
+			if strings.Contains(logs, token) {
+				t.Errorf("logs contain the application token: %s", logs)
+			}
+			logged := strings.Contains(logs, "Debug: Request body") && strings.Contains(logs, "****1234") && strings.Contains(logs, "invalid metric")
+			if logged != tt.want {
+				t.Errorf("request and response logged = %v, want %v, logs: %s", logged, tt.want, logs)
+			}
+		})
+	}
+}
+
+func TestAPISender_PayloadHeaders(t *testing.T) {
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+
+	tests := []struct {
+		name            string
+		encryptionKey   string
+		protobuf        bool
+		compression     Compression
+		wantContentType string
+		wantEncoding    string
+		wantEncrypted   string
+	}{
+		{name: "json", wantContentType: "application/json", wantEncoding: "gzip"},
+		{name: "uncompressed json", compression: Compression{Disabled: true}, wantContentType: "application/json"},
+		{name: "protobuf", protobuf: true, wantContentType: "application/x-protobuf", wantEncoding: "gzip"},
+		{name: "encrypted", encryptionKey: "12345678901234567890123456789012", wantContentType: "application/json", wantEncoding: "gzip", wantEncrypted: "true"},
+		{name: "encrypted with protobuf", encryptionKey: "12345678901234567890123456789012", protobuf: true, wantContentType: "application/json", wantEncoding: "gzip", wantEncrypted: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Clone()
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			s := NewAPISender(server.URL, "org", "server", "token", "machine", tt.encryptionKey, "", nil, DefaultRequestTimeout)
+			s.SetProtobuf(tt.protobuf)
+			s.SetCompression(tt.compression)
+			if err := s.Send(metrics); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			want := map[string]string{
+				"Content-Type":     tt.wantContentType,
+				"Content-Encoding": tt.wantEncoding,
+				"X-Encrypted":      tt.wantEncrypted,
+				"Accept":           "application/json",
+				"Accept-Encoding":  "gzip",
+			}
+			for key, value := range want {
+				if got := header.Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestAPISender_GzipResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusBadRequest)
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(`{"error":"invalid metric"}`))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	ml := &mockLogger{}
+	originalLogger := logger.GetDefaultLogger()
+	logger.SetDefaultLogger(ml)
+	defer logger.SetDefaultLogger(originalLogger)
+
+	originalLevel := logger.GetLevel()
+	logger.SetLevel(logger.LevelDebug)
+	defer logger.SetLevel(originalLevel)
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetDebugRequests(true)
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+	if err := s.Send(metrics); err == nil {
+		t.Fatal("Send() error = nil, want the 400 error")
+	}
+
+	if logs := ml.buffer.String(); !strings.Contains(logs, `{"error":"invalid metric"}`) {
+		t.Errorf("logs don't contain the decompressed response body: %s", logs)
+	}
+}
+
+func Test_decodeResponseBody(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("hello"))
+	gz.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+		wantErr  bool
+	}{
+		{name: "identity", body: []byte("hello"), want: "hello"},
+		{name: "gzip", encoding: "gzip", body: compressed.Bytes(), want: "hello"},
+		{name: "empty gzip", encoding: "gzip", want: ""},
+		{name: "corrupt gzip", encoding: "gzip", body: []byte("hello"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(tt.body))}
+			if tt.encoding != "" {
+				resp.Header.Set("Content-Encoding", tt.encoding)
+			}
+			decodeResponseBody(resp)
+			got, err := io.ReadAll(resp.Body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if resp.Header.Get("Content-Encoding") != "" {
+				t.Error("Content-Encoding is still set on the decoded response")
+			}
+		})
+	}
+}
+
+func TestAPISender_Compression(t *testing.T) {
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+
+	tests := []struct {
+		name        string
+		compression Compression
+		wantGzip    bool
+	}{
+		{name: "default", compression: Compression{}, wantGzip: true},
+		{name: "best compression", compression: Compression{Level: 9}, wantGzip: true},
+		{name: "disabled", compression: Compression{Disabled: true}, wantGzip: false},
+		{name: "below min size", compression: Compression{MinSize: 1 << 20}, wantGzip: false},
+		{name: "above min size", compression: Compression{MinSize: 10}, wantGzip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoding string
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				encoding = r.Header.Get("Content-Encoding")
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+			s.SetCompression(tt.compression)
+			if err := s.Send(metrics); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			if (encoding == "gzip") != tt.wantGzip {
+				t.Errorf("Content-Encoding = %q, want gzip %v", encoding, tt.wantGzip)
+			}
+			if tt.wantGzip {
+				if body, _ = decompressGzip(body); body == nil {
+					t.Fatal("failed to decompress request body")
+				}
+			}
+			var payload map[string]interface{}
+			if err := json.Unmarshal(body, &payload); err != nil || payload["machine_name"] != "machine" {
+				t.Errorf("request body = %s, want the JSON payload", body)
+			}
+		})
+	}
+}
+
+func Test_compressDataLevel(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"category":"system","name":"cpu","value":12.5}`), 100)
+
+	for _, level := range []int{gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression} {
+		compressed, err := compressDataLevel(data, level)
+		if err != nil {
+			t.Fatalf("compressDataLevel(%d) error = %v", level, err)
+		}
+		decompressed, err := decompressGzip(compressed)
+		if err != nil || !bytes.Equal(decompressed, data) {
+			t.Errorf("compressDataLevel(%d) did not round-trip, error = %v", level, err)
+		}
+	}
+
+	if _, err := compressDataLevel(data, 42); err == nil {
+		t.Error("compressDataLevel(42) error = nil, want an invalid level error")
+	}
+}
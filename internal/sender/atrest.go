@@ -0,0 +1,93 @@
+package sender
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/encryption"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+// sealAtRest serializes a batch of metrics into a single line suitable for storage on disk.
+// With withDigest the line is a {"batch_digest", "metrics"} record instead of the plain JSON array.
+// When an encryption key is provided the JSON payload is encrypted with AES-256-GCM and
+// stored as base64
+func sealAtRest(metrics []collector.Metrics, encryptionKey string, withDigest bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if withDigest {
+		record, err := sealDigestRecord(metrics)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(record)
+	} else if err := serialization.WriteMetricsTo(&buf, metrics, false); err != nil {
+		return nil, err
+	}
+
+	if encryptionKey == "" {
+		return buf.Bytes(), nil
+	}
+
+	encrypted, err := encryption.Encrypt(bytes.TrimRight(buf.Bytes(), "\n"), encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt metrics at rest: %w", err)
+	}
+
+	return []byte(encrypted + "\n"), nil
+}
+
+// openAtRest reverses sealAtRest for a single stored line
+// Each key is tried in turn so that data sealed before a key rotation can still be read.
+// Records with a digest are checked against it, so corrupted batches are reported as unreadable
+func openAtRest(line []byte, encryptionKeys []string) ([]collector.Metrics, error) {
+	line = bytes.TrimSpace(line)
+
+	if len(encryptionKeys) > 0 {
+		decrypted, err := encryption.DecryptWithKeys(string(line), encryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt metrics at rest: %w", err)
+		}
+		line = decrypted
+	}
+
+	if bytes.HasPrefix(line, []byte("{")) {
+		return openDigestRecord(line)
+	}
+
+	var metrics []collector.Metrics
+	if err := json.Unmarshal(line, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to parse stored metrics: %w", err)
+	}
+
+	return metrics, nil
+}
+
+// ReadMetricsLog reads back metrics written by a FileLogger, decrypting each batch
+// with the given keys when the log was written with encryption at rest enabled
+func ReadMetricsLog(r io.Reader, encryptionKeys ...string) ([]collector.Metrics, error) {
+	var all []collector.Metrics
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		metrics, err := openAtRest(scanner.Bytes(), encryptionKeys)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, metrics...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading metrics log: %w", err)
+	}
+
+	return all, nil
+}
@@ -0,0 +1,104 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+)
+
+// ErrTooManyMetrics is returned when the API rejects a request with 413 because it holds
+// more metrics than the plan allows
+var ErrTooManyMetrics = errors.New("WARNING: API request failed with status 413 - Too many metrics for your plan, some metrics were ignored")
+
+// PartialSendError reports a send split into batches that failed after the first Sent
+// metrics were accepted. Those metrics must not be sent again
+type PartialSendError struct {
+	Sent int
+	Err  error
+}
+
+// Error returns the message of the wrapped error along with the number of metrics sent
+func (e *PartialSendError) Error() string {
+	return fmt.Sprintf("%v (%d metrics were sent before the failure)", e.Err, e.Sent)
+}
+
+// Unwrap returns the wrapped error
+func (e *PartialSendError) Unwrap() error {
+	return e.Err
+}
+
+// SentBefore returns how many metrics from the start of a send were accepted before err
+func SentBefore(err error) int {
+	var partial *PartialSendError
+	if errors.As(err, &partial) {
+		return partial.Sent
+	}
+	return 0
+}
+
+// SetMaxBatchSize makes the sender split metrics into requests of at most size metrics, sent
+// one after the other. Zero sends all metrics in one request. The size is halved whenever the
+// API still rejects a request as too large
+func (s *APISender) SetMaxBatchSize(size int) {
+	s.batchSize.Store(int64(size))
+}
+
+// sendBatches sends metrics in consecutive batches of the current batch size, halving it and
+// resending the rejected batch on a 413. Batches stop at the first error
+func (s *APISender) sendBatches(ctx context.Context, metrics []collector.Metrics) error {
+	sent := 0
+rechunk:
+	for sent < len(metrics) {
+		for _, batch := range chunkMetrics(metrics[sent:], int(s.batchSize.Load())) {
+			err := s.sendBatch(ctx, batch)
+			if errors.Is(err, ErrTooManyMetrics) && len(batch) > 1 {
+				s.shrinkBatchSize(len(batch))
+				continue rechunk
+			}
+			if err != nil {
+				if sent > 0 {
+					return &PartialSendError{Sent: sent, Err: err}
+				}
+				return err
+			}
+			sent += len(batch)
+		}
+	}
+	return nil
+}
+
+// shrinkBatchSize halves the batch size after a batch of size metrics was rejected,
+// unless a concurrent send already lowered it further
+func (s *APISender) shrinkBatchSize(size int) {
+	next := int64(size / 2)
+	for {
+		current := s.batchSize.Load()
+		if current > 0 && current <= next {
+			return
+		}
+		if s.batchSize.CompareAndSwap(current, next) {
+			break
+		}
+	}
+	logger.Warnf("API rejected %d metrics as too many for the plan, sending at most %d metrics per request", size, next)
+}
+
+// chunkMetrics splits metrics into consecutive chunks of at most size metrics
+// A size of zero or less returns metrics as a single chunk
+func chunkMetrics(metrics []collector.Metrics, size int) [][]collector.Metrics {
+	if size <= 0 || len(metrics) <= size {
+		return [][]collector.Metrics{metrics}
+	}
+	chunks := make([][]collector.Metrics, 0, (len(metrics)+size-1)/size)
+	for start := 0; start < len(metrics); start += size {
+		end := start + size
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		chunks = append(chunks, metrics[start:end])
+	}
+	return chunks
+}
@@ -0,0 +1,200 @@
+package sender
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+)
+
+func batchTestMetrics(n int) []collector.Metrics {
+	metrics := make([]collector.Metrics, n)
+	for i := range metrics {
+		metrics[i] = collector.Metrics{
+			Timestamp: time.Date(2024, 1, 1, 12, 0, i, 0, time.UTC),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameCPU,
+			Value:     float64(i),
+		}
+	}
+	return metrics
+}
+
+func TestChunkMetrics(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		size  int
+		sizes []int
+	}{
+		{name: "no limit", n: 5, size: 0, sizes: []int{5}},
+		{name: "negative limit", n: 5, size: -1, sizes: []int{5}},
+		{name: "under limit", n: 3, size: 5, sizes: []int{3}},
+		{name: "exact limit", n: 5, size: 5, sizes: []int{5}},
+		{name: "even split", n: 6, size: 2, sizes: []int{2, 2, 2}},
+		{name: "remainder", n: 7, size: 3, sizes: []int{3, 3, 1}},
+		{name: "one per batch", n: 3, size: 1, sizes: []int{1, 1, 1}},
+		{name: "empty", n: 0, size: 2, sizes: []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := batchTestMetrics(tt.n)
+			chunks := chunkMetrics(metrics, tt.size)
+			if len(chunks) != len(tt.sizes) {
+				t.Fatalf("chunkMetrics() returned %d chunks, want %d", len(chunks), len(tt.sizes))
+			}
+
+			next := 0
+			for i, chunk := range chunks {
+				if len(chunk) != tt.sizes[i] {
+					t.Errorf("chunk %d has %d metrics, want %d", i, len(chunk), tt.sizes[i])
+				}
+				for _, m := range chunk {
+					if m.Value != float64(next) {
+						t.Errorf("chunk %d holds metric %v, want %d", i, m.Value, next)
+					}
+					next++
+				}
+			}
+		})
+	}
+}
+
+// batchTestServer accepts requests of at most limit metrics and records the size of each request
+func batchTestServer(t *testing.T, limit int) (*httptest.Server, func() []int) {
+	var mu sync.Mutex
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, err := decompressGzip(data)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		var payload struct {
+			Metrics []collector.Metrics `json:"metrics"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+
+		mu.Lock()
+		sizes = append(sizes, len(payload.Metrics))
+		mu.Unlock()
+
+		if len(payload.Metrics) > limit {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), sizes...)
+	}
+}
+
+func TestAPISender_MaxBatchSize(t *testing.T) {
+	server, sizes := batchTestServer(t, 100)
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetMaxBatchSize(4)
+	if err := s.Send(batchTestMetrics(10)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if got := sizes(); !reflect.DeepEqual(got, []int{4, 4, 2}) {
+		t.Errorf("request sizes = %v, want [4 4 2]", got)
+	}
+}
+
+func TestAPISender_BatchSizeHalvedOn413(t *testing.T) {
+	ml := &mockLogger{}
+	originalLogger := logger.GetDefaultLogger()
+	logger.SetDefaultLogger(ml)
+	defer logger.SetDefaultLogger(originalLogger)
+
+	server, sizes := batchTestServer(t, 3)
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetMaxBatchSize(8)
+	if err := s.Send(batchTestMetrics(10)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// 8 is rejected, then 4, then the remaining 10 metrics go out 2 at a time
+	if got := sizes(); !reflect.DeepEqual(got, []int{8, 4, 2, 2, 2, 2, 2}) {
+		t.Errorf("request sizes = %v, want [8 4 2 2 2 2 2]", got)
+	}
+	if s.batchSize.Load() != 2 {
+		t.Errorf("batch size = %d, want 2 kept for later sends", s.batchSize.Load())
+	}
+	if !strings.Contains(ml.buffer.String(), "sending at most 2 metrics per request") {
+		t.Errorf("expected the new batch size to be logged, got: %s", ml.buffer.String())
+	}
+
+	// Without a configured limit, a rejected send starts batching too
+	s = NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	if err := s.Send(batchTestMetrics(6)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if s.batchSize.Load() != 3 {
+		t.Errorf("batch size = %d, want 3 after a rejected send of 6 metrics", s.batchSize.Load())
+	}
+}
+
+func TestAPISender_PartialSend(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetMaxBatchSize(3)
+	err := s.Send(batchTestMetrics(8))
+
+	var partial *PartialSendError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Send() error = %v, want a PartialSendError", err)
+	}
+	if SentBefore(err) != 6 {
+		t.Errorf("SentBefore() = %d, want 6", SentBefore(err))
+	}
+	if !IsRetryable(err) {
+		t.Error("IsRetryable() = false, want the 502 to stay retryable")
+	}
+	if SentBefore(errors.New("boom")) != 0 {
+		t.Error("SentBefore() of a plain error should be 0")
+	}
+}
+
+func TestAPISender_BatchSizeRejectsSingleMetric(t *testing.T) {
+	server, sizes := batchTestServer(t, 0)
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	err := s.Send(batchTestMetrics(2))
+	if !errors.Is(err, ErrTooManyMetrics) {
+		t.Fatalf("Send() error = %v, want ErrTooManyMetrics", err)
+	}
+	if got := sizes(); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("request sizes = %v, want [2 1]", got)
+	}
+}
@@ -0,0 +1,137 @@
+package sender
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+)
+
+// BodyLimit caps the compressed size of metrics requests for plans that reject larger ones
+type BodyLimit struct {
+	MaxBytes         int                        // Ceiling on the compressed request body, disabled when zero
+	CategoryPriority []collector.MetricCategory // Categories from most to least important, unlisted ones are dropped first
+}
+
+// SetBodyLimit makes the sender drop the least important metrics of a request that exceeds limit
+func (s *APISender) SetBodyLimit(limit BodyLimit) {
+	s.bodyLimit = limit
+}
+
+// fitBodyLimit returns the metrics that fit in the body limit, dropping the lowest priority
+// categories first and the oldest metrics within a category. This is a last resort: the
+// dropped metrics are logged and never sent
+func (s *APISender) fitBodyLimit(metrics []collector.Metrics, seq uint64) ([]collector.Metrics, error) {
+	if s.bodyLimit.MaxBytes <= 0 || len(metrics) == 0 {
+		return metrics, nil
+	}
+
+	size, err := s.bodySize(metrics, seq)
+	if err != nil || size <= s.bodyLimit.MaxBytes {
+		return metrics, err
+	}
+
+	order := s.dropOrder(metrics)
+	without := func(n int) []collector.Metrics {
+		dropped := make(map[int]bool, n)
+		for _, i := range order[:n] {
+			dropped[i] = true
+		}
+		kept := make([]collector.Metrics, 0, len(metrics)-n)
+		for i, m := range metrics {
+			if !dropped[i] {
+				kept = append(kept, m)
+			}
+		}
+		return kept
+	}
+
+	// The compressed size can't be predicted per metric, so search for the fewest drops that fit
+	lo, hi := 1, len(metrics)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		n, err := s.bodySize(without(mid), seq)
+		if err != nil {
+			return nil, err
+		}
+		if n <= s.bodyLimit.MaxBytes {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	kept := without(lo)
+	if lo == len(metrics) {
+		if n, err := s.bodySize(kept, seq); err != nil {
+			return nil, err
+		} else if n > s.bodyLimit.MaxBytes {
+			return nil, fmt.Errorf("max_body_bytes (%d) is smaller than a request without metrics (%d bytes)", s.bodyLimit.MaxBytes, n)
+		}
+	}
+
+	droppedMetrics := make([]collector.Metrics, 0, lo)
+	for _, i := range order[:lo] {
+		droppedMetrics = append(droppedMetrics, metrics[i])
+	}
+	logger.Warnf("Request body of %d bytes exceeds max_body_bytes (%d), dropped %d of %d metrics: %s",
+		size, s.bodyLimit.MaxBytes, lo, len(metrics), summarizeMetrics(droppedMetrics))
+	return kept, nil
+}
+
+// bodySize returns the size of the request body for metrics as it would be sent
+func (s *APISender) bodySize(metrics []collector.Metrics, seq uint64) (int, error) {
+	body, err := s.encodeRequest(s.requestBody(metrics, seq))
+	if err != nil {
+		return 0, err
+	}
+	return len(body.data), nil
+}
+
+// dropOrder returns the indexes of metrics in the order they should be dropped
+func (s *APISender) dropOrder(metrics []collector.Metrics) []int {
+	rank := make(map[collector.MetricCategory]int, len(s.bodyLimit.CategoryPriority))
+	for i, category := range s.bodyLimit.CategoryPriority {
+		rank[category] = i
+	}
+	rankOf := func(category collector.MetricCategory) int {
+		if r, ok := rank[category]; ok {
+			return r
+		}
+		return len(s.bodyLimit.CategoryPriority)
+	}
+
+	order := make([]int, len(metrics))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ma, mb := metrics[order[a]], metrics[order[b]]
+		if ra, rb := rankOf(ma.Category), rankOf(mb.Category); ra != rb {
+			return ra > rb
+		}
+		return ma.Timestamp.Before(mb.Timestamp)
+	})
+	return order
+}
+
+// summarizeMetrics counts metrics by category and name, e.g. "system/cpu=3, system/disk=1"
+func summarizeMetrics(metrics []collector.Metrics) string {
+	counts := make(map[string]int)
+	for _, m := range metrics {
+		counts[string(m.Category)+"/"+string(m.Name)]++
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, counts[k]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,125 @@
+package sender
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestAPISender_BodyLimit(t *testing.T) {
+	var bodySizes []int
+	var sent [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodySizes = append(bodySizes, len(data))
+		decompressed, err := decompressGzip(data)
+		if err != nil {
+			t.Errorf("failed to decompress request body: %v", err)
+		}
+		var body struct {
+			Metrics []collector.Metrics `json:"metrics"`
+		}
+		json.Unmarshal(decompressed, &body)
+		var names []string
+		for _, m := range body.Metrics {
+			names = append(names, m.Metadata["id"])
+		}
+		sent = append(sent, names)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Hashes don't compress, so each metric adds a predictable amount to the body
+	start := time.Now()
+	newMetric := func(category collector.MetricCategory, id string, age int) collector.Metrics {
+		sum := sha256.Sum256([]byte(id))
+		return collector.Metrics{
+			Timestamp: start.Add(-time.Duration(age) * time.Minute),
+			Category:  category,
+			Name:      collector.NameCPU,
+			Metadata:  collector.MetricMetadata{"id": id},
+			Value:     hex.EncodeToString(sum[:]),
+		}
+	}
+	metrics := []collector.Metrics{
+		newMetric(collector.CategorySystem, "system-old", 2),
+		newMetric(collector.CategorySystem, "system-new", 1),
+		newMetric(collector.CategoryProbe, "probe", 1),
+		newMetric("custom", "custom", 1),
+	}
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	full, err := s.bodySize(metrics, 0)
+	if err != nil {
+		t.Fatalf("bodySize() error = %v", err)
+	}
+	withoutTwo, err := s.bodySize(metrics[:2], 0)
+	if err != nil {
+		t.Fatalf("bodySize() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		want     string
+		wantErr  bool
+	}{
+		{name: "disabled", maxBytes: 0, want: "system-old,system-new,probe,custom"},
+		{name: "under the limit", maxBytes: full, want: "system-old,system-new,probe,custom"},
+		{name: "unlisted and low priority categories dropped first", maxBytes: withoutTwo, want: "system-old,system-new"},
+		{name: "oldest metrics of a category dropped first", maxBytes: withoutTwo - 1, want: "system-new"},
+		{name: "limit below an empty request", maxBytes: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodySizes, sent = nil, nil
+			s.SetBodyLimit(BodyLimit{
+				MaxBytes:         tt.maxBytes,
+				CategoryPriority: []collector.MetricCategory{collector.CategorySystem, collector.CategoryProbe},
+			})
+
+			err := s.Send(metrics)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(sent) != 0 {
+					t.Errorf("Send() sent %d requests, want none", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("Send() sent %d requests, want 1", len(sent))
+			}
+			if got := strings.Join(sent[0], ","); got != tt.want {
+				t.Errorf("Send() sent metrics %s, want %s", got, tt.want)
+			}
+			if tt.maxBytes > 0 && bodySizes[0] > tt.maxBytes {
+				t.Errorf("Send() body is %d bytes, want at most %d", bodySizes[0], tt.maxBytes)
+			}
+		})
+	}
+}
+
+func TestSummarizeMetrics(t *testing.T) {
+	metrics := []collector.Metrics{
+		{Category: collector.CategorySystem, Name: collector.NameDisk},
+		{Category: collector.CategorySystem, Name: collector.NameCPU},
+		{Category: collector.CategorySystem, Name: collector.NameCPU},
+		{Category: collector.CategoryProbe, Name: collector.NameProbeHealth},
+	}
+	want := fmt.Sprintf("probe/%s=1, system/cpu=2, system/disk=1", collector.NameProbeHealth)
+	if got := summarizeMetrics(metrics); got != want {
+		t.Errorf("summarizeMetrics() = %q, want %q", got, want)
+	}
+}
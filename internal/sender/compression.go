@@ -5,6 +5,8 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 )
 
 // compressWriter is an interface that wraps io.WriteCloser and adds a Bytes method
@@ -53,6 +55,53 @@ func compressData(data []byte) ([]byte, error) {
 	return compressDataWithFactory(data, defaultGzipWriterFactory)
 }
 
+// compressDataLevel compresses data using gzip compression at level, from gzip.BestSpeed to
+// gzip.BestCompression, or gzip.DefaultCompression
+// The gzip writer and output buffer are pooled, the returned slice is a copy owned by the caller
+func compressDataLevel(data []byte, level int) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	gw, err := getGzipWriter(buf, level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip compression level: %w", err)
+	}
+	if _, err := gw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write to gzip writer: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	putGzipWriter(gw, level)
+
+	// The request may still be reading the body after the buffer is reused, so hand out a copy
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// Compression configures how APISender compresses request bodies
+// The zero value compresses every body with the default gzip level
+type Compression struct {
+	Disabled bool // Send request bodies uncompressed
+	Level    int  // Gzip level from 1 (fastest) to 9 (smallest), the default level when zero
+	MinSize  int  // Bodies smaller than this many bytes are sent uncompressed
+}
+
+// compress gzips data according to the compression settings and reports whether it did
+func (c Compression) compress(data []byte) ([]byte, bool, error) {
+	if c.Disabled || len(data) < c.MinSize {
+		return data, false, nil
+	}
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	compressed, err := compressDataLevel(data, level)
+	if err != nil {
+		return nil, false, err
+	}
+	return compressed, true, nil
+}
+
 // compressDataWithFactory compresses data using the provided writer factory
 func compressDataWithFactory(data []byte, factory writerFactory) ([]byte, error) {
 	var buf bytes.Buffer
@@ -87,3 +136,39 @@ func compressDataWithWriter(data []byte, writer compressWriter) ([]byte, error)
 
 	return result, nil
 }
+
+// gzipResponseBody decompresses a gzipped response body as it is read
+// The gzip header is only read on the first Read, so an empty body reads as empty
+type gzipResponseBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+// Read implements io.Reader
+func (b *gzipResponseBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+// Close implements io.Closer
+func (b *gzipResponseBody) Close() error {
+	return b.body.Close()
+}
+
+// decodeResponseBody makes resp.Body read the decompressed body of a gzip encoded response
+func decodeResponseBody(resp *http.Response) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return
+	}
+	resp.Body = &gzipResponseBody{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
@@ -0,0 +1,95 @@
+package sender
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// csvHeader is the first row of a CSV metrics log
+var csvHeader = []string{"timestamp", "category", "name", "metadata_json", "value"}
+
+// writeMetricsCSV writes metrics as flattened CSV rows, with the header first when header is true
+// Map values produce one row per leaf, named <name>.<key>[.<key>...]
+func writeMetricsCSV(w io.Writer, metrics []collector.Metrics, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range metrics {
+		rows, err := csvRows(m)
+		if err != nil {
+			return fmt.Errorf("failed to flatten metric %s: %w", m.Name, err)
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRows converts a metric into CSV rows, one per leaf of its value
+func csvRows(m collector.Metrics) ([][]string, error) {
+	metadata := []byte("{}")
+	if len(m.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(m.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	// Round-trip through JSON to walk every value type the collectors produce the same way
+	data, err := json.Marshal(m.Value)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	timestamp := m.Timestamp.UTC().Format(time.RFC3339Nano)
+	var rows [][]string
+	walkLeaves(string(m.Name), value, func(name, v string) {
+		rows = append(rows, []string{timestamp, string(m.Category), name, string(metadata), v})
+	})
+	return rows, nil
+}
+
+// walkLeaves calls fn for every non-map value in value, extending name with map keys
+// Lists are rendered as JSON and null as an empty field
+func walkLeaves(name string, value interface{}, fn func(string, string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkLeaves(name+"."+k, v[k], fn)
+		}
+	case nil:
+		fn(name, "")
+	case string:
+		fn(name, v)
+	case float64:
+		fn(name, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		fn(name, strconv.FormatBool(v))
+	default:
+		data, _ := json.Marshal(v)
+		fn(name, string(data))
+	}
+}
@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+// digestRecord is a batch stored on disk along with its digest
+// Metrics holds the batch exactly as it was hashed, so the digest can be checked before parsing it
+type digestRecord struct {
+	BatchDigest string          `json:"batch_digest"`
+	Metrics     json.RawMessage `json:"metrics"`
+}
+
+// batchDigest returns the hex encoded SHA-256 of the canonical serialized form of metrics,
+// the compact JSON array sent as "metrics" in API payloads
+func batchDigest(metrics []collector.Metrics) (string, error) {
+	data, err := serialization.SerializeMetrics(metrics)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize metrics for digest: %w", err)
+	}
+	return digestOf(data), nil
+}
+
+// digestOf returns the hex encoded SHA-256 of data
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sealDigestRecord serializes metrics into a single line holding the batch and its digest
+func sealDigestRecord(metrics []collector.Metrics) ([]byte, error) {
+	data, err := serialization.SerializeMetrics(metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize metrics: %w", err)
+	}
+	record, err := json.Marshal(digestRecord{BatchDigest: digestOf(data), Metrics: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize digest record: %w", err)
+	}
+	return append(record, '\n'), nil
+}
+
+// openDigestRecord parses a line written by sealDigestRecord, failing if the batch doesn't match its digest
+func openDigestRecord(line []byte) ([]collector.Metrics, error) {
+	var record digestRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse stored metrics: %w", err)
+	}
+	if digest := digestOf(record.Metrics); digest != record.BatchDigest {
+		return nil, fmt.Errorf("batch digest mismatch: stored %s, computed %s", record.BatchDigest, digest)
+	}
+
+	var metrics []collector.Metrics
+	if err := json.Unmarshal(record.Metrics, &metrics); err != nil {
+		return nil, fmt.Errorf("failed to parse stored metrics: %w", err)
+	}
+	return metrics, nil
+}
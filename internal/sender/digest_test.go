@@ -0,0 +1,173 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func digestTestBatch() []collector.Metrics {
+	return []collector.Metrics{
+		{
+			Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameDisk,
+			Metadata:  collector.MetricMetadata{"mountpoint": "/"},
+			Value:     map[string]interface{}{"used": 10.5, "total": 100},
+		},
+		{
+			Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameCPU,
+			Value:     42.0,
+		},
+	}
+}
+
+// sha256Hex independently hashes data the way the backend would
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAPISender_BatchDigest(t *testing.T) {
+	var body struct {
+		Metrics     json.RawMessage `json:"metrics"`
+		BatchDigest string          `json:"batch_digest"`
+	}
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		decompressed, err := decompressGzip(data)
+		if err != nil {
+			t.Errorf("failed to decompress request body: %v", err)
+		}
+		body.BatchDigest = ""
+		if err := json.Unmarshal(decompressed, &body); err != nil {
+			t.Errorf("failed to parse request body: %v", err)
+		}
+		received++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetDigest(true)
+
+	metrics := digestTestBatch()
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// The digest covers the canonical JSON array of the batch
+	canonical, err := json.Marshal(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.BatchDigest != sha256Hex(canonical) {
+		t.Errorf("batch_digest = %s, want %s", body.BatchDigest, sha256Hex(canonical))
+	}
+	// which is exactly what the payload carries, so the backend can hash the raw field
+	if body.BatchDigest != sha256Hex(body.Metrics) {
+		t.Errorf("batch_digest = %s, doesn't match the payload's metrics %s", body.BatchDigest, sha256Hex(body.Metrics))
+	}
+
+	// System information carries no digest
+	if err := s.Send([]collector.Metrics{{Name: collector.NameSystemInfo, Value: map[string]string{}}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if received != 2 || body.BatchDigest != "" {
+		t.Errorf("system info batch_digest = %q, want none", body.BatchDigest)
+	}
+
+	// Without the option there is no digest
+	s.SetDigest(false)
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if body.BatchDigest != "" {
+		t.Errorf("batch_digest = %q with digests disabled, want none", body.BatchDigest)
+	}
+}
+
+func TestFileLogger_BatchDigest(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "metrics.log")
+	fileLogger := NewFileLogger(logFile)
+	fileLogger.SetDigest(true)
+
+	metrics := digestTestBatch()
+	if err := fileLogger.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record struct {
+		BatchDigest string          `json:"batch_digest"`
+		Metrics     json.RawMessage `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("log line isn't a digest record: %v", err)
+	}
+	canonical, _ := json.Marshal(metrics)
+	if record.BatchDigest != sha256Hex(canonical) {
+		t.Errorf("batch_digest = %s, want %s", record.BatchDigest, sha256Hex(canonical))
+	}
+
+	read, err := ReadMetricsLog(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMetricsLog() error = %v", err)
+	}
+	if len(read) != len(metrics) || read[1].Value != 42.0 {
+		t.Errorf("ReadMetricsLog() = %v, want the logged batch", read)
+	}
+
+	// A modified record no longer matches its digest
+	tampered := strings.Replace(string(data), "42", "43", 1)
+	if _, err := ReadMetricsLog(strings.NewReader(tampered)); err == nil || !strings.Contains(err.Error(), "batch digest mismatch") {
+		t.Errorf("ReadMetricsLog() tampered error = %v, want digest mismatch", err)
+	}
+}
+
+func TestSpool_DigestMismatch(t *testing.T) {
+	dir := t.TempDir()
+	spool := NewSpool(dir, 0)
+	spool.SetDigest(true)
+	if err := spool.Write(digestTestBatch()); err != nil {
+		t.Fatalf("Spool.Write() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, spoolFilePrefix+"*"+spoolFileExt))
+	if len(files) != 1 {
+		t.Fatalf("found %d spool files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if err := os.WriteFile(files[0], bytes.Replace(data, []byte("42"), []byte("43"), 1), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+		t.Error("tampered batch replayed")
+		return nil
+	})
+	if err != nil || sent != 0 {
+		t.Errorf("Spool.Flush() = %d, %v, want the batch set aside", sent, err)
+	}
+	if bad, _ := filepath.Glob(filepath.Join(dir, "*"+spoolBadExt)); len(bad) != 1 {
+		t.Errorf("found %d unreadable spool files, want 1", len(bad))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/monitorly-app/probe/internal/collector"
@@ -13,17 +14,47 @@ import (
 
 // FileLogger implements the Sender interface for logging metrics to a file
 type FileLogger struct {
-	filePath string
-	mu       sync.Mutex
+	filePath      string
+	encryptionKey string // Optional: If set, each batch is encrypted before being written
+	digest        bool   // Write each batch as a record holding its SHA-256 digest
+	csv           bool   // Write flattened CSV rows instead of JSON arrays
+	mu            sync.Mutex
 }
 
 // NewFileLogger creates a new instance of FileLogger
+// Paths with a .csv extension are written as CSV, see SetCSV
 func NewFileLogger(filePath string) *FileLogger {
 	return &FileLogger{
 		filePath: filePath,
+		csv:      strings.EqualFold(filepath.Ext(filePath), ".csv"),
 	}
 }
 
+// NewEncryptedFileLogger creates a new instance of FileLogger that encrypts metrics at rest
+// Each batch is written as a single base64 line that can be read back with ReadMetricsLog
+func NewEncryptedFileLogger(filePath, encryptionKey string) *FileLogger {
+	return &FileLogger{
+		filePath:      filePath,
+		encryptionKey: encryptionKey,
+	}
+}
+
+// SetDigest makes the logger write each batch as a {"batch_digest", "metrics"} record instead of a
+// plain JSON array, so the log can be checked for tampering. ReadMetricsLog reads both forms
+func (f *FileLogger) SetDigest(digest bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.digest = digest
+}
+
+// SetCSV makes the logger write timestamp,category,name,metadata_json,value rows instead of JSON
+// arrays, with one row per leaf of map values. The header row is written when the file is created
+func (f *FileLogger) SetCSV(csv bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.csv = csv
+}
+
 // Send logs metrics to a file
 func (f *FileLogger) Send(metrics []collector.Metrics) error {
 	return f.SendWithContext(context.Background(), metrics)
@@ -55,6 +86,28 @@ func (f *FileLogger) SendWithContext(ctx context.Context, metrics []collector.Me
 	}
 	defer file.Close()
 
+	if f.encryptionKey != "" || f.digest {
+		data, err := sealAtRest(metrics, f.encryptionKey, f.digest)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			return fmt.Errorf("failed to write metrics to log file: %w", err)
+		}
+		return nil
+	}
+
+	if f.csv {
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat log file: %w", err)
+		}
+		if err := writeMetricsCSV(file, metrics, info.Size() == 0); err != nil {
+			return fmt.Errorf("failed to write metrics to log file: %w", err)
+		}
+		return nil
+	}
+
 	// Use the serialization package to write metrics (without indentation)
 	if err := serialization.WriteMetricsTo(file, metrics, false); err != nil {
 		return fmt.Errorf("failed to write metrics to log file: %w", err)
@@ -2,10 +2,12 @@ package sender
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"sync"
@@ -692,3 +694,152 @@ func TestFileLogger_FileRotation(t *testing.T) {
 
 	checkFileContents(logFile, 85.0)
 }
+
+func TestFileLogger_EncryptAtRest(t *testing.T) {
+	tempDir := t.TempDir()
+	logFile := filepath.Join(tempDir, "encrypted.log")
+	key := "12345678901234567890123456789012"
+
+	fileLogger := NewEncryptedFileLogger(logFile, key)
+
+	batches := [][]collector.Metrics{
+		{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.5}},
+		{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameRAM, Value: 42.0}},
+	}
+	for _, batch := range batches {
+		if err := fileLogger.Send(batch); err != nil {
+			t.Fatalf("FileLogger.Send() error = %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), string(collector.NameCPU)) {
+		t.Error("Encrypted log file contains plaintext metric names")
+	}
+
+	metrics, err := ReadMetricsLog(strings.NewReader(string(content)), key)
+	if err != nil {
+		t.Fatalf("ReadMetricsLog() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("ReadMetricsLog() returned %d metrics, want 2", len(metrics))
+	}
+	if metrics[0].Name != collector.NameCPU || metrics[1].Name != collector.NameRAM {
+		t.Errorf("ReadMetricsLog() returned unexpected metrics: %+v", metrics)
+	}
+
+	if _, err := ReadMetricsLog(strings.NewReader(string(content)), "abcdefghijklmnopqrstuvwxyz123456"); err == nil {
+		t.Error("ReadMetricsLog() with wrong key should fail")
+	}
+}
+
+func TestReadMetricsLog_KeyRotation(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "rotated.log")
+	oldKey := "12345678901234567890123456789012"
+	newKey := "abcdefghijklmnopqrstuvwxyz123456"
+
+	metric := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+	if err := NewEncryptedFileLogger(logFile, oldKey).Send(metric); err != nil {
+		t.Fatalf("Send() with old key error = %v", err)
+	}
+	if err := NewEncryptedFileLogger(logFile, newKey).Send(metric); err != nil {
+		t.Fatalf("Send() with new key error = %v", err)
+	}
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	metrics, err := ReadMetricsLog(file, newKey, oldKey)
+	if err != nil {
+		t.Fatalf("ReadMetricsLog() error = %v", err)
+	}
+	if len(metrics) != 2 {
+		t.Errorf("ReadMetricsLog() returned %d metrics, want 2", len(metrics))
+	}
+}
+
+func TestFileLogger_CSV(t *testing.T) {
+	tempDir := t.TempDir()
+	logFile := filepath.Join(tempDir, "metrics.csv")
+	ts := time.Date(2024, 6, 2, 10, 30, 0, 0, time.UTC)
+
+	fileLogger := NewFileLogger(logFile)
+	if !fileLogger.csv {
+		t.Fatal("NewFileLogger() with a .csv path should write CSV")
+	}
+
+	batches := [][]collector.Metrics{
+		{
+			{Timestamp: ts, Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.5},
+			{
+				Timestamp: ts,
+				Category:  collector.CategorySystem,
+				Name:      collector.NameDisk,
+				Metadata:  collector.MetricMetadata{"mountpoint": "/"},
+				Value:     map[string]interface{}{"percent": 40.0, "io": map[string]interface{}{"reads": 3}},
+			},
+		},
+		{
+			{Timestamp: ts, Category: collector.CategorySystem, Name: collector.NameRAM, Value: "a,\"quoted\" value"},
+		},
+	}
+	for _, batch := range batches {
+		if err := fileLogger.Send(batch); err != nil {
+			t.Fatalf("FileLogger.Send() error = %v", err)
+		}
+	}
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV log file: %v", err)
+	}
+
+	want := [][]string{
+		{"timestamp", "category", "name", "metadata_json", "value"},
+		{"2024-06-02T10:30:00Z", "system", "cpu", "{}", "12.5"},
+		{"2024-06-02T10:30:00Z", "system", "disk.io.reads", `{"mountpoint":"/"}`, "3"},
+		{"2024-06-02T10:30:00Z", "system", "disk.percent", `{"mountpoint":"/"}`, "40"},
+		{"2024-06-02T10:30:00Z", "system", "ram", "{}", `a,"quoted" value`},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("CSV rows = %q, want %q", rows, want)
+	}
+}
+
+func TestFileLogger_SetCSV(t *testing.T) {
+	tempDir := t.TempDir()
+	logFile := filepath.Join(tempDir, "metrics.log")
+
+	fileLogger := NewFileLogger(logFile)
+	fileLogger.SetCSV(true)
+	metrics := []collector.Metrics{
+		{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: []float64{1, 2}},
+	}
+	if err := fileLogger.Send(metrics); err != nil {
+		t.Fatalf("FileLogger.Send() error = %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || lines[0] != "timestamp,category,name,metadata_json,value" {
+		t.Fatalf("CSV log file = %q, want a header and one row", content)
+	}
+	if !strings.HasSuffix(lines[1], `,cpu,{},"[1,2]"`) {
+		t.Errorf("CSV row = %q, want the list value rendered as JSON", lines[1])
+	}
+}
@@ -0,0 +1,83 @@
+package sender
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// MetricKey identifies the metrics of one kind in a change manifest
+type MetricKey struct {
+	Category collector.MetricCategory `json:"category"`
+	Name     collector.MetricName     `json:"name"`
+}
+
+// ChangeTracker retains the last value sent for each series to list the metric keys that changed
+// since the previous successful send. A series is a metric key plus its metadata, so for example
+// each disk mount is tracked on its own and the "disk" key changes when any mount changes.
+// Series that stop being reported don't mark their key as changed
+type ChangeTracker struct {
+	mu   sync.Mutex
+	last map[string]string // Series to the JSON encoding of the last value sent
+}
+
+// NewChangeTracker creates a tracker with no previous values, so everything sent first is a change
+func NewChangeTracker() *ChangeTracker {
+	return &ChangeTracker{last: make(map[string]string)}
+}
+
+// Changed returns the sorted keys of metrics holding a value different from the last one sent for their
+// series. A series reported several times in metrics also changed if its samples differ from one another
+func (t *ChangeTracker) Changed(metrics []collector.Metrics) []MetricKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changed := make(map[MetricKey]bool)
+	seen := make(map[string]string)
+	for _, m := range metrics {
+		key := MetricKey{Category: m.Category, Name: m.Name}
+		series, value := seriesFingerprint(m)
+
+		previous, ok := seen[series]
+		if !ok {
+			previous, ok = t.last[series]
+		}
+		if !ok || previous != value {
+			changed[key] = true
+		}
+		seen[series] = value
+	}
+
+	keys := make([]MetricKey, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Category != keys[j].Category {
+			return keys[i].Category < keys[j].Category
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// Commit records the values of metrics as sent, the latest sample of each series wins
+func (t *ChangeTracker) Commit(metrics []collector.Metrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range metrics {
+		series, value := seriesFingerprint(m)
+		t.last[series] = value
+	}
+}
+
+// seriesFingerprint returns the identity of the series of m and the encoding of its value
+// encoding/json sorts map keys, so equal metadata and values always encode the same way
+func seriesFingerprint(m collector.Metrics) (string, string) {
+	metadata, _ := json.Marshal(m.Metadata)
+	value, _ := json.Marshal(m.Value)
+	return string(m.Category) + "\x00" + string(m.Name) + "\x00" + string(metadata), string(value)
+}
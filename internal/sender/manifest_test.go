@@ -0,0 +1,126 @@
+package sender
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestChangeTracker(t *testing.T) {
+	now := time.Now()
+	disk := func(mount string, used float64) collector.Metrics {
+		return collector.Metrics{Timestamp: now, Category: collector.CategorySystem, Name: collector.NameDisk,
+			Metadata: collector.MetricMetadata{"mountpoint": mount}, Value: map[string]float64{"used": used}}
+	}
+	cpu := func(value float64) collector.Metrics {
+		return collector.Metrics{Timestamp: now, Category: collector.CategorySystem, Name: collector.NameCPU, Value: value}
+	}
+	diskKey := MetricKey{Category: collector.CategorySystem, Name: collector.NameDisk}
+	cpuKey := MetricKey{Category: collector.CategorySystem, Name: collector.NameCPU}
+
+	tracker := NewChangeTracker()
+
+	// Nothing was sent yet, every key changed
+	first := []collector.Metrics{disk("/", 10), disk("/var", 20), cpu(5)}
+	if got, want := tracker.Changed(first), []MetricKey{cpuKey, diskKey}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() first send = %v, want %v", got, want)
+	}
+	tracker.Commit(first)
+
+	// Timestamps don't count, only values
+	later := now.Add(time.Minute)
+	unchanged := []collector.Metrics{disk("/", 10), disk("/var", 20), cpu(5)}
+	for i := range unchanged {
+		unchanged[i].Timestamp = later
+	}
+	if got := tracker.Changed(unchanged); len(got) != 0 {
+		t.Errorf("Changed() unchanged = %v, want none", got)
+	}
+
+	// A single series changing marks its key
+	if got, want := tracker.Changed([]collector.Metrics{disk("/", 10), disk("/var", 25), cpu(5)}), []MetricKey{diskKey}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() one mount changed = %v, want %v", got, want)
+	}
+
+	// Samples differing within a batch are a change even if the last one matches what was sent
+	if got, want := tracker.Changed([]collector.Metrics{cpu(7), cpu(5)}), []MetricKey{cpuKey}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() fluctuating samples = %v, want %v", got, want)
+	}
+
+	// A new series is a change, a missing one isn't
+	if got, want := tracker.Changed([]collector.Metrics{disk("/home", 1)}), []MetricKey{diskKey}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changed() new mount = %v, want %v", got, want)
+	}
+	if got := tracker.Changed(nil); len(got) != 0 {
+		t.Errorf("Changed() empty batch = %v, want none", got)
+	}
+
+	// Changed doesn't record anything, Commit keeps the latest sample of each series
+	tracker.Commit([]collector.Metrics{cpu(7), cpu(9)})
+	if got := tracker.Changed([]collector.Metrics{cpu(9)}); len(got) != 0 {
+		t.Errorf("Changed() after commit = %v, want none", got)
+	}
+}
+
+func TestAPISender_ChangeManifest(t *testing.T) {
+	var status int
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		decompressed, err := decompressGzip(data)
+		if err != nil {
+			t.Errorf("failed to decompress request body: %v", err)
+		}
+		var body map[string]interface{}
+		json.Unmarshal(decompressed, &body)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetChangeManifest(NewChangeTracker())
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: "test", Name: "metric", Value: 1.0}}
+
+	// A rejected payload leaves its values unsent, so they are still listed on the next send
+	status = http.StatusBadRequest
+	if err := s.Send(metrics); err == nil {
+		t.Fatal("Send() error = nil, want rejection")
+	}
+	status = http.StatusOK
+	for i := 0; i < 2; i++ {
+		if err := s.Send(metrics); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	// System information has no manifest
+	if err := s.Send([]collector.Metrics{{Name: collector.NameSystemInfo, Value: map[string]string{}}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	want := []int{1, 1, 0, -1}
+	if len(bodies) != len(want) {
+		t.Fatalf("server received %d requests, want %d", len(bodies), len(want))
+	}
+	for i, body := range bodies {
+		changed, ok := body["changed_metrics"].([]interface{})
+		if want[i] < 0 {
+			if _, present := body["changed_metrics"]; present {
+				t.Errorf("request %d has changed_metrics %v, want none", i, body["changed_metrics"])
+			}
+			continue
+		}
+		if !ok || len(changed) != want[i] {
+			t.Errorf("request %d changed_metrics = %v, want %d keys", i, body["changed_metrics"], want[i])
+		}
+	}
+	if key, _ := bodies[0]["changed_metrics"].([]interface{})[0].(map[string]interface{}); key["category"] != "test" || key["name"] != "metric" {
+		t.Errorf("changed_metrics key = %v, want test/metric", key)
+	}
+}
@@ -0,0 +1,459 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+// MQTTTopicMachineName is replaced by the machine name in MQTT topics
+const MQTTTopicMachineName = "{machine_name}"
+
+// DefaultMQTTTimeout is the time allowed for connecting to the broker and for each publish when
+// none is configured
+const DefaultMQTTTimeout = 10 * time.Second
+
+// MQTT 3.1.1 control packet types, shifted into the high nibble of the fixed header
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttPubAck     = 0x40
+	mqttPubRec     = 0x50
+	mqttPubRel     = 0x62 // PUBREL has the reserved flags 0010
+	mqttPubComp    = 0x70
+	mqttPingReq    = 0xC0
+	mqttPingResp   = 0xD0
+	mqttDisconnect = 0xE0
+)
+
+// mqttMaxRemainingLength is the largest packet body the 4-byte remaining length can encode
+const mqttMaxRemainingLength = 268435455
+
+// mqttConnAckErrors describes the CONNACK return codes refusing a connection
+var mqttConnAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// MQTTOptions configures the broker connection of an MQTTSender
+type MQTTOptions struct {
+	Address   string        // Broker address (host:port)
+	Topic     string        // Topic of the batches, MQTTTopicMachineName is replaced by the machine name
+	ClientID  string        // Client identifier, must be unique on the broker. Derived from the machine name when empty
+	Username  string        // Optional: User name for authentication
+	Password  string        // Optional: Password for authentication
+	QoS       byte          // Quality of service of the publishes: 0, 1 or 2
+	KeepAlive time.Duration // Interval of the keep alive pings of an idle connection, 0 disables them
+	Timeout   time.Duration // Time allowed for connecting and for each publish, DefaultMQTTTimeout when zero
+	TLSConfig *tls.Config   // Optional: Connect over TLS with this config
+}
+
+// MQTTSender implements the Sender interface by publishing each batch of metrics as JSON to an
+// MQTT broker. The connection is kept open between batches, and made again when the broker drops it
+type MQTTSender struct {
+	opts     MQTTOptions
+	topic    string
+	conn     net.Conn
+	lastSent time.Time     // Time of the last packet sent on conn, for keep alive pings
+	stopPing chan struct{} // Stops the keep alive pings of conn
+	packetID uint16
+	mu       sync.Mutex
+}
+
+// NewMQTTSender creates a new instance of MQTTSender publishing to the topic of opts templated with
+// machineName. The connection is made on the first send, so a broker that's unreachable at startup
+// doesn't prevent the probe from starting
+func NewMQTTSender(opts MQTTOptions, machineName string) (*MQTTSender, error) {
+	if _, _, err := net.SplitHostPort(opts.Address); err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker address %q: %w", opts.Address, err)
+	}
+	if opts.QoS > 2 {
+		return nil, fmt.Errorf("invalid MQTT QoS: %d (must be 0, 1 or 2)", opts.QoS)
+	}
+	topic := strings.ReplaceAll(opts.Topic, MQTTTopicMachineName, machineName)
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return nil, fmt.Errorf("invalid MQTT topic %q: must be non-empty and without wildcards", topic)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultMQTTTimeout
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "monitorly-probe-" + machineName
+	}
+	return &MQTTSender{opts: opts, topic: topic}, nil
+}
+
+// Send publishes metrics to the MQTT broker
+func (s *MQTTSender) Send(metrics []collector.Metrics) error {
+	return s.SendWithContext(context.Background(), metrics)
+}
+
+// SendWithContext publishes metrics to the MQTT broker as a single JSON message, connecting first
+// when needed. A publish failing on an established connection is tried once more on a new one,
+// and failures to reach the broker are returned as a RetryableError
+func (s *MQTTSender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled: %w", ctx.Err())
+	default:
+	}
+
+	payload, err := serialization.SerializeMetrics(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metrics: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reconnected := false
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+		reconnected = true
+	}
+
+	err = s.publish(ctx, payload)
+	if err != nil && !reconnected && ctx.Err() == nil {
+		logger.Warnf("Failed to publish metrics to MQTT broker %s, reconnecting: %v", s.opts.Address, err)
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+		err = s.publish(ctx, payload)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		return &RetryableError{Err: err}
+	}
+	return nil
+}
+
+// Close disconnects from the broker
+func (s *MQTTSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.opts.Timeout))
+	_, _ = s.conn.Write([]byte{mqttDisconnect, 0})
+	s.dropConn()
+	return nil
+}
+
+// connect replaces the current connection, if any, with a new session on the broker
+// Must be called with s.mu held
+func (s *MQTTSender) connect(ctx context.Context) error {
+	s.dropConn()
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if s.opts.TLSConfig != nil {
+		tlsConfig := s.opts.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(s.opts.Address)
+		}
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", s.opts.Address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", s.opts.Address)
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		return &RetryableError{Err: fmt.Errorf("failed to connect to MQTT broker %s: %w", s.opts.Address, err)}
+	}
+
+	if err := exchangeWithContext(ctx, conn, func() error { return s.handshake(conn) }); err != nil {
+		conn.Close()
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		var refused *mqttRefusedError
+		if errors.As(err, &refused) && refused.code != 3 {
+			return fmt.Errorf("MQTT broker %s refused the connection: %w", s.opts.Address, err)
+		}
+		return &RetryableError{Err: fmt.Errorf("failed to connect to MQTT broker %s: %w", s.opts.Address, err)}
+	}
+
+	s.conn = conn
+	s.lastSent = time.Now()
+	if s.opts.KeepAlive > 0 {
+		s.stopPing = make(chan struct{})
+		go s.keepAlive(conn, s.stopPing)
+	}
+	return nil
+}
+
+// mqttRefusedError is returned when the broker refuses a connection in its CONNACK
+// Apart from an unavailable server, retrying doesn't help and the configuration needs fixing
+type mqttRefusedError struct {
+	code byte
+}
+
+func (e *mqttRefusedError) Error() string {
+	if reason, ok := mqttConnAckErrors[e.code]; ok {
+		return reason
+	}
+	return "return code " + strconv.Itoa(int(e.code))
+}
+
+// handshake sends the CONNECT packet of a clean session on conn and waits for its CONNACK
+func (s *MQTTSender) handshake(conn net.Conn) error {
+	var flags byte = 0x02 // Clean session
+	if s.opts.Username != "" {
+		flags |= 0x80
+		if s.opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+	keepAlive := int((s.opts.KeepAlive + time.Second - 1) / time.Second)
+	if keepAlive > 0xFFFF {
+		keepAlive = 0xFFFF
+	}
+
+	body := appendMQTTString(nil, "MQTT")
+	body = append(body, 4, flags) // Protocol level 4 is MQTT 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(keepAlive))
+	body = appendMQTTString(body, s.opts.ClientID)
+	if flags&0x80 != 0 {
+		body = appendMQTTString(body, s.opts.Username)
+	}
+	if flags&0x40 != 0 {
+		body = appendMQTTString(body, s.opts.Password)
+	}
+	if err := writeMQTTPacket(conn, mqttConnect, body); err != nil {
+		return err
+	}
+
+	packetType, ack, err := readMQTTPacket(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read CONNACK: %w", err)
+	}
+	if packetType != mqttConnAck || len(ack) != 2 {
+		return fmt.Errorf("unexpected packet 0x%02x instead of CONNACK", packetType)
+	}
+	if ack[1] != 0 {
+		return &mqttRefusedError{code: ack[1]}
+	}
+	return nil
+}
+
+// publish sends payload to the topic with the configured QoS and waits for the broker to
+// acknowledge it. Must be called with s.mu held and a connection
+func (s *MQTTSender) publish(ctx context.Context, payload []byte) error {
+	conn := s.conn
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	err := exchangeWithContext(ctx, conn, func() error {
+		body := appendMQTTString(nil, s.topic)
+		var id uint16
+		if s.opts.QoS > 0 {
+			s.packetID++
+			if s.packetID == 0 {
+				s.packetID = 1
+			}
+			id = s.packetID
+			body = binary.BigEndian.AppendUint16(body, id)
+		}
+		body = append(body, payload...)
+		if err := writeMQTTPacket(conn, mqttPublish|s.opts.QoS<<1, body); err != nil {
+			return err
+		}
+
+		switch s.opts.QoS {
+		case 1:
+			return expectMQTTAck(conn, mqttPubAck, id)
+		case 2:
+			if err := expectMQTTAck(conn, mqttPubRec, id); err != nil {
+				return err
+			}
+			if err := writeMQTTPacket(conn, mqttPubRel, binary.BigEndian.AppendUint16(nil, id)); err != nil {
+				return err
+			}
+			return expectMQTTAck(conn, mqttPubComp, id)
+		}
+		return nil
+	})
+	s.lastSent = time.Now()
+	if err != nil {
+		s.dropConn()
+		return fmt.Errorf("failed to publish to MQTT topic %s: %w", s.topic, err)
+	}
+	return nil
+}
+
+// keepAlive pings the broker over conn while it's idle, so neither end drops it between batches
+// A failed ping drops the connection, and the next send reconnects
+func (s *MQTTSender) keepAlive(conn net.Conn, stop chan struct{}) {
+	ticker := time.NewTicker(s.opts.KeepAlive / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		if s.conn != conn {
+			s.mu.Unlock()
+			return
+		}
+		if time.Since(s.lastSent) >= s.opts.KeepAlive/2 {
+			err := s.ping(conn)
+			s.lastSent = time.Now()
+			if err != nil {
+				logger.Debugf("MQTT keep alive to %s failed, reconnecting on the next send: %v", s.opts.Address, err)
+				s.dropConn()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ping sends a PINGREQ on conn and waits for the PINGRESP
+func (s *MQTTSender) ping(conn net.Conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	return exchangeWithContext(ctx, conn, func() error {
+		if err := writeMQTTPacket(conn, mqttPingReq, nil); err != nil {
+			return err
+		}
+		packetType, _, err := readMQTTPacket(conn)
+		if err != nil {
+			return err
+		}
+		if packetType != mqttPingResp {
+			return fmt.Errorf("unexpected packet 0x%02x instead of PINGRESP", packetType)
+		}
+		return nil
+	})
+}
+
+// dropConn closes the current connection and stops its keep alive pings
+// Must be called with s.mu held
+func (s *MQTTSender) dropConn() {
+	if s.stopPing != nil {
+		close(s.stopPing)
+		s.stopPing = nil
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// exchangeWithContext runs fn, which reads and writes conn, bounded by the deadline of ctx and
+// interrupted when ctx is cancelled
+func exchangeWithContext(ctx context.Context, conn net.Conn, fn func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	err := fn()
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return err
+}
+
+// expectMQTTAck reads the next packet from r and checks it's an acknowledgement of type
+// packetType for the packet id
+func expectMQTTAck(r io.Reader, packetType byte, id uint16) error {
+	got, body, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("failed to read acknowledgement: %w", err)
+	}
+	if got != packetType || len(body) != 2 || binary.BigEndian.Uint16(body) != id {
+		return fmt.Errorf("unexpected packet 0x%02x instead of acknowledgement 0x%02x of packet %d", got, packetType, id)
+	}
+	return nil
+}
+
+// appendMQTTString appends s to buf as a length-prefixed UTF-8 string
+func appendMQTTString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// writeMQTTPacket writes a control packet with the fixed header byte header and body to w
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	if len(body) > mqttMaxRemainingLength {
+		return fmt.Errorf("packet of %d bytes exceeds the MQTT limit of %d bytes", len(body), mqttMaxRemainingLength)
+	}
+	packet := []byte{header}
+	// The remaining length is encoded in 7-bit groups, least significant first
+	for n := len(body); ; {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+	_, err := w.Write(packet)
+	return err
+}
+
+// readMQTTPacket reads a control packet from r, returning the type of its fixed header and its body
+func readMQTTPacket(r io.Reader) (byte, []byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, nil, err
+	}
+	packetType := b[0] & 0xF0
+
+	length := 0
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		var lb [1]byte
+		if _, err := io.ReadFull(r, lb[:]); err != nil {
+			return 0, nil, err
+		}
+		length |= int(lb[0]&0x7F) << (7 * i)
+		if lb[0]&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return packetType, body, nil
+}
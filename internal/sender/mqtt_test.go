@@ -0,0 +1,286 @@
+package sender
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// fakeMQTTBroker accepts MQTT connections and records what the clients publish
+// readMQTTPacket drops the fixed header flags, so the broker is told the QoS clients publish with
+type fakeMQTTBroker struct {
+	listener net.Listener
+	qos      byte // QoS of the publishes
+	connAck  byte // CONNACK return code
+	silent   bool // Never answer CONNECT
+	dropOnce bool // Close the first connection when it publishes, without acknowledging
+
+	mu        sync.Mutex
+	conns     int
+	clientIDs []string
+	topics    []string
+	payloads  [][]byte
+	pings     int
+}
+
+// startFakeMQTTBroker starts b listening on a local port
+func startFakeMQTTBroker(t *testing.T, b *fakeMQTTBroker) *fakeMQTTBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b.listener = listener
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeMQTTBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	b.mu.Lock()
+	b.conns++
+	drop := b.dropOnce && b.conns == 1
+	b.mu.Unlock()
+
+	for {
+		packetType, body, err := readMQTTPacket(conn)
+		if err != nil {
+			return
+		}
+		switch packetType {
+		case mqttConnect:
+			if b.silent {
+				continue
+			}
+			// Protocol name (6 bytes), level, flags and keep alive come before the client id
+			idLen := int(binary.BigEndian.Uint16(body[10:]))
+			b.mu.Lock()
+			b.clientIDs = append(b.clientIDs, string(body[12:12+idLen]))
+			b.mu.Unlock()
+			_ = writeMQTTPacket(conn, mqttConnAck, []byte{0, b.connAck})
+		case mqttPublish:
+			if drop {
+				return
+			}
+			topicLen := int(binary.BigEndian.Uint16(body))
+			topic, rest := string(body[2:2+topicLen]), body[2+topicLen:]
+			var id []byte
+			if b.qos > 0 {
+				id, rest = rest[:2], rest[2:]
+			}
+			b.mu.Lock()
+			b.topics = append(b.topics, topic)
+			b.payloads = append(b.payloads, rest)
+			b.mu.Unlock()
+			switch b.qos {
+			case 1:
+				_ = writeMQTTPacket(conn, mqttPubAck, id)
+			case 2:
+				_ = writeMQTTPacket(conn, mqttPubRec, id)
+			}
+		case mqttPubRel & 0xF0:
+			_ = writeMQTTPacket(conn, mqttPubComp, body)
+		case mqttPingReq:
+			b.mu.Lock()
+			b.pings++
+			b.mu.Unlock()
+			_ = writeMQTTPacket(conn, mqttPingResp, nil)
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+var mqttTestMetrics = []collector.Metrics{
+	{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 42.5},
+}
+
+func TestNewMQTTSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    MQTTOptions
+		wantErr bool
+	}{
+		{name: "valid", opts: MQTTOptions{Address: "localhost:1883", Topic: "monitorly/{machine_name}/metrics", QoS: 1}},
+		{name: "missing port", opts: MQTTOptions{Address: "localhost", Topic: "metrics"}, wantErr: true},
+		{name: "invalid qos", opts: MQTTOptions{Address: "localhost:1883", Topic: "metrics", QoS: 3}, wantErr: true},
+		{name: "wildcard topic", opts: MQTTOptions{Address: "localhost:1883", Topic: "monitorly/#"}, wantErr: true},
+		{name: "empty topic", opts: MQTTOptions{Address: "localhost:1883"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMQTTSender(tt.opts, "web-1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewMQTTSender() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMQTTSender_Send(t *testing.T) {
+	for _, qos := range []byte{0, 1, 2} {
+		t.Run(fmt.Sprintf("qos %d", qos), func(t *testing.T) {
+			broker := startFakeMQTTBroker(t, &fakeMQTTBroker{qos: qos})
+			s, err := NewMQTTSender(MQTTOptions{
+				Address: broker.listener.Addr().String(),
+				Topic:   "monitorly/{machine_name}/metrics",
+				QoS:     qos,
+			}, "web-1")
+			if err != nil {
+				t.Fatalf("NewMQTTSender() error = %v", err)
+			}
+			defer s.Close()
+
+			for i := 0; i < 2; i++ {
+				if err := s.Send(mqttTestMetrics); err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+			if qos == 0 {
+				// Nothing acknowledges QoS 0 publishes, wait for the broker to read them
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			broker.mu.Lock()
+			defer broker.mu.Unlock()
+			if broker.conns != 1 {
+				t.Errorf("broker got %d connections, want the connection to be reused", broker.conns)
+			}
+			if !reflect.DeepEqual(broker.clientIDs, []string{"monitorly-probe-web-1"}) {
+				t.Errorf("client ids = %v, want [monitorly-probe-web-1]", broker.clientIDs)
+			}
+			if !reflect.DeepEqual(broker.topics, []string{"monitorly/web-1/metrics", "monitorly/web-1/metrics"}) {
+				t.Errorf("topics = %v, want the templated topic twice", broker.topics)
+			}
+			var published []collector.Metrics
+			if len(broker.payloads) == 0 || json.Unmarshal(broker.payloads[0], &published) != nil || len(published) != 1 {
+				t.Errorf("payload %q isn't the JSON batch", broker.payloads)
+			}
+		})
+	}
+}
+
+func TestMQTTSender_Reconnect(t *testing.T) {
+	broker := startFakeMQTTBroker(t, &fakeMQTTBroker{qos: 1, dropOnce: true})
+	s, err := NewMQTTSender(MQTTOptions{Address: broker.listener.Addr().String(), Topic: "metrics", QoS: 1}, "web-1")
+	if err != nil {
+		t.Fatalf("NewMQTTSender() error = %v", err)
+	}
+	defer s.Close()
+
+	// The first publish goes to a fresh connection, which is dropped: the batch is left to the send loop
+	if err := s.Send(mqttTestMetrics); !IsRetryable(err) {
+		t.Fatalf("Send() error = %v, want a retryable error", err)
+	}
+	if err := s.Send(mqttTestMetrics); err != nil {
+		t.Fatalf("Send() after the broker dropped the connection error = %v", err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.conns != 2 || len(broker.payloads) != 1 {
+		t.Errorf("broker got %d connections and %d publishes, want 2 and 1", broker.conns, len(broker.payloads))
+	}
+}
+
+func TestMQTTSender_ConnectionRefused(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          byte
+		wantRetryable bool
+	}{
+		{name: "bad credentials", code: 4},
+		{name: "server unavailable", code: 3, wantRetryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := startFakeMQTTBroker(t, &fakeMQTTBroker{connAck: tt.code})
+			s, err := NewMQTTSender(MQTTOptions{Address: broker.listener.Addr().String(), Topic: "metrics"}, "web-1")
+			if err != nil {
+				t.Fatalf("NewMQTTSender() error = %v", err)
+			}
+
+			err = s.Send(mqttTestMetrics)
+			if err == nil || !strings.Contains(err.Error(), mqttConnAckErrors[tt.code]) {
+				t.Fatalf("Send() error = %v, want %q", err, mqttConnAckErrors[tt.code])
+			}
+			if IsRetryable(err) != tt.wantRetryable {
+				t.Errorf("IsRetryable(%v) = %v, want %v", err, IsRetryable(err), tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestMQTTSender_SendWithContext_CancelDuringConnect(t *testing.T) {
+	broker := startFakeMQTTBroker(t, &fakeMQTTBroker{silent: true})
+	s, err := NewMQTTSender(MQTTOptions{Address: broker.listener.Addr().String(), Topic: "metrics", Timeout: time.Minute}, "web-1")
+	if err != nil {
+		t.Fatalf("NewMQTTSender() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = s.SendWithContext(ctx, mqttTestMetrics)
+	if err == nil || !strings.Contains(err.Error(), "context cancelled") {
+		t.Errorf("SendWithContext() error = %v, want a cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SendWithContext() returned after %v, want it to stop on cancellation", elapsed)
+	}
+}
+
+func TestMQTTSender_KeepAlive(t *testing.T) {
+	broker := startFakeMQTTBroker(t, &fakeMQTTBroker{})
+	s, err := NewMQTTSender(MQTTOptions{Address: broker.listener.Addr().String(), Topic: "metrics", KeepAlive: 40 * time.Millisecond}, "web-1")
+	if err != nil {
+		t.Fatalf("NewMQTTSender() error = %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Send(mqttTestMetrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.pings == 0 {
+		t.Error("no keep alive ping was sent while the connection was idle")
+	}
+}
+
+func TestMQTTPacket_RemainingLength(t *testing.T) {
+	for _, size := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		var buf strings.Builder
+		if err := writeMQTTPacket(&buf, mqttPublish, make([]byte, size)); err != nil {
+			t.Fatalf("writeMQTTPacket(%d bytes) error = %v", size, err)
+		}
+		packetType, body, err := readMQTTPacket(strings.NewReader(buf.String()))
+		if err != nil || packetType != mqttPublish || len(body) != size {
+			t.Errorf("readMQTTPacket() = 0x%02x, %d bytes, %v, want 0x%02x, %d bytes", packetType, len(body), err, mqttPublish, size)
+		}
+	}
+}
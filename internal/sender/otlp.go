@@ -0,0 +1,194 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+// OTLP export protocols
+const (
+	OTLPProtocolHTTP = "http" // OTLP/HTTP with the protobuf encoding, to <endpoint>/v1/metrics
+	OTLPProtocolGRPC = "grpc" // OTLP/gRPC, over HTTP/2 with TLS for https endpoints and without for http ones
+)
+
+// otlpGRPCMethod is the gRPC method exporting metrics to an OpenTelemetry collector
+const otlpGRPCMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// grpcRetryableCodes are the gRPC status codes the OTLP specification deems retryable:
+// CANCELLED, DEADLINE_EXCEEDED, RESOURCE_EXHAUSTED, ABORTED, OUT_OF_RANGE, UNAVAILABLE and DATA_LOSS
+var grpcRetryableCodes = map[int]bool{1: true, 4: true, 8: true, 10: true, 11: true, 14: true, 15: true}
+
+// DefaultOTLPTimeout is the time allowed for an OTLP export when none is configured
+const DefaultOTLPTimeout = 10 * time.Second
+
+// OTLPSender implements the Sender interface by exporting metrics to an OpenTelemetry collector
+// Metrics are sent as gauges, see serialization.SerializeOTLP for the mapping
+type OTLPSender struct {
+	endpoint string
+	protocol string
+	headers  map[string]string
+	resource serialization.OTLPResource
+	client   *http.Client
+}
+
+// NewOTLPSender creates a new OTLPSender exporting to endpoint (http or https URL of the collector)
+// with protocol, OTLPProtocolHTTP or OTLPProtocolGRPC. headers are added to every export, e.g. for
+// authentication, and machineName is reported as the host.name resource attribute. timeout bounds
+// each export, DefaultOTLPTimeout when zero
+func NewOTLPSender(endpoint, protocol string, headers map[string]string, machineName, version string, timeout time.Duration) (*OTLPSender, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %s (must be an http or https URL)", endpoint)
+	}
+	if timeout <= 0 {
+		timeout = DefaultOTLPTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch protocol {
+	case OTLPProtocolHTTP:
+	case OTLPProtocolGRPC:
+		// gRPC needs HTTP/2, which collectors listening without TLS only speak with prior knowledge
+		var protocols http.Protocols
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = &protocols
+	default:
+		return nil, fmt.Errorf("invalid OTLP protocol: %s (must be '%s' or '%s')", protocol, OTLPProtocolHTTP, OTLPProtocolGRPC)
+	}
+
+	return &OTLPSender{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		protocol: protocol,
+		headers:  headers,
+		resource: serialization.OTLPResource{
+			Attributes: map[string]string{
+				"service.name":    "monitorly-probe",
+				"service.version": version,
+				"host.name":       machineName,
+			},
+			ScopeName:    "github.com/monitorly-app/probe",
+			ScopeVersion: version,
+		},
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}, nil
+}
+
+// Send exports metrics to the OpenTelemetry collector
+func (s *OTLPSender) Send(metrics []collector.Metrics) error {
+	return s.SendWithContext(context.Background(), metrics)
+}
+
+// SendWithContext exports metrics to the OpenTelemetry collector, giving up when ctx is done
+// Network failures and the responses the OTLP specification deems transient are returned as
+// a RetryableError
+func (s *OTLPSender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled: %w", ctx.Err())
+	default:
+	}
+
+	body, err := serialization.SerializeOTLP(metrics, s.resource)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metrics: %w", err)
+	}
+
+	if s.protocol == OTLPProtocolGRPC {
+		return s.exportGRPC(ctx, body)
+	}
+	return s.exportHTTP(ctx, body)
+}
+
+// exportHTTP posts an export request to the OTLP/HTTP metrics endpoint
+func (s *OTLPSender) exportHTTP(ctx context.Context, body []byte) error {
+	req, err := s.newRequest(ctx, s.endpoint+"/v1/metrics", serialization.ProtobufContentType, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &RetryableError{Err: fmt.Errorf("failed to export metrics: %w", err)}
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("OTLP export failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return &RetryableError{Err: err, StatusCode: resp.StatusCode}
+	}
+	return err
+}
+
+// exportGRPC calls the OTLP/gRPC Export method with a single length-prefixed message
+func (s *OTLPSender) exportGRPC(ctx context.Context, body []byte) error {
+	frame := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(body)))
+	frame = append(frame, body...)
+
+	req, err := s.newRequest(ctx, s.endpoint+otlpGRPCMethod, "application/grpc", frame)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("TE", "trailers")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &RetryableError{Err: fmt.Errorf("failed to export metrics: %w", err)}
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return &RetryableError{Err: fmt.Errorf("failed to read export response: %w", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return &RetryableError{Err: fmt.Errorf("OTLP export failed with HTTP status %d", resp.StatusCode), StatusCode: resp.StatusCode}
+	}
+
+	// The status is in the trailers, or in the headers of a response without a body
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return &RetryableError{Err: fmt.Errorf("OTLP export response has no valid gRPC status: %q", status)}
+	}
+	if code == 0 {
+		return nil
+	}
+	err = fmt.Errorf("OTLP export failed with gRPC status %d: %s", code, message)
+	if grpcRetryableCodes[code] {
+		return &RetryableError{Err: err}
+	}
+	return err
+}
+
+// newRequest creates an export request with the configured headers
+func (s *OTLPSender) newRequest(ctx context.Context, url, contentType string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
@@ -0,0 +1,150 @@
+package sender
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+var otlpTestMetrics = []collector.Metrics{
+	{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 42.5},
+}
+
+func TestNewOTLPSender(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		protocol string
+		wantErr  bool
+	}{
+		{name: "http", endpoint: "http://localhost:4318", protocol: OTLPProtocolHTTP},
+		{name: "grpc", endpoint: "https://otel.example.com:4317/", protocol: OTLPProtocolGRPC},
+		{name: "missing scheme", endpoint: "localhost:4318", protocol: OTLPProtocolHTTP, wantErr: true},
+		{name: "unsupported scheme", endpoint: "ftp://localhost:4318", protocol: OTLPProtocolHTTP, wantErr: true},
+		{name: "unknown protocol", endpoint: "http://localhost:4318", protocol: "thrift", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOTLPSender(tt.endpoint, tt.protocol, nil, "web-1", "1.0.0", 0)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewOTLPSender() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOTLPSender_SendHTTP(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{name: "accepted", status: http.StatusOK},
+		{name: "unavailable", status: http.StatusServiceUnavailable, wantErr: true, wantRetryable: true},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/metrics" {
+					t.Errorf("path = %s, want /v1/metrics", r.URL.Path)
+				}
+				if got := r.Header.Get("Content-Type"); got != "application/x-protobuf" {
+					t.Errorf("Content-Type = %s, want application/x-protobuf", got)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer token" {
+					t.Errorf("Authorization = %s, want Bearer token", got)
+				}
+				if body, _ := io.ReadAll(r.Body); len(body) == 0 {
+					t.Error("export request has an empty body")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			s, err := NewOTLPSender(server.URL, OTLPProtocolHTTP, map[string]string{"Authorization": "Bearer token"}, "web-1", "1.0.0", time.Second)
+			if err != nil {
+				t.Fatalf("NewOTLPSender() error = %v", err)
+			}
+			err = s.Send(otlpTestMetrics)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if IsRetryable(err) != tt.wantRetryable {
+				t.Errorf("IsRetryable(%v) = %v, want %v", err, IsRetryable(err), tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestOTLPSender_SendGRPC(t *testing.T) {
+	tests := []struct {
+		name          string
+		grpcStatus    string
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{name: "ok", grpcStatus: "0"},
+		{name: "unavailable", grpcStatus: "14", wantErr: true, wantRetryable: true},
+		{name: "invalid argument", grpcStatus: "3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.ProtoMajor != 2 {
+					t.Errorf("protocol = %s, want HTTP/2", r.Proto)
+				}
+				if r.URL.Path != otlpGRPCMethod {
+					t.Errorf("path = %s, want %s", r.URL.Path, otlpGRPCMethod)
+				}
+				body, _ := io.ReadAll(r.Body)
+				if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+					t.Errorf("request body isn't a single uncompressed gRPC message")
+				}
+				w.Header().Set("Trailer", "Grpc-Status")
+				w.Header().Set("Content-Type", "application/grpc")
+				w.WriteHeader(http.StatusOK)
+				w.Header().Set("Grpc-Status", tt.grpcStatus)
+			}))
+			server.Config.Protocols = new(http.Protocols)
+			server.Config.Protocols.SetHTTP1(true)
+			server.Config.Protocols.SetUnencryptedHTTP2(true)
+			server.Start()
+			defer server.Close()
+
+			s, err := NewOTLPSender(server.URL, OTLPProtocolGRPC, nil, "web-1", "1.0.0", time.Second)
+			if err != nil {
+				t.Fatalf("NewOTLPSender() error = %v", err)
+			}
+			err = s.Send(otlpTestMetrics)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if IsRetryable(err) != tt.wantRetryable {
+				t.Errorf("IsRetryable(%v) = %v, want %v", err, IsRetryable(err), tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestOTLPSender_SendWithContext_Cancelled(t *testing.T) {
+	s, err := NewOTLPSender("http://localhost:4318", OTLPProtocolHTTP, nil, "web-1", "1.0.0", 0)
+	if err != nil {
+		t.Fatalf("NewOTLPSender() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.SendWithContext(ctx, otlpTestMetrics); err == nil {
+		t.Error("SendWithContext() error = nil, want an error for a cancelled context")
+	}
+}
@@ -0,0 +1,69 @@
+package sender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer returned to the pool, so that one unusually large
+// batch doesn't keep its memory for the lifetime of the probe
+const maxPooledBufferSize = 4 << 20
+
+// bufferPool holds the buffers used to marshal and compress request bodies between sends
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// gzipWriterPools holds gzip writers by level, gzip.DefaultCompression first and then
+// gzip.NoCompression to gzip.BestCompression
+var gzipWriterPools [gzip.BestCompression + 2]sync.Pool
+
+// getBuffer returns an empty buffer from the pool, to be handed back with putBuffer
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool. Its contents must no longer be referenced
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// getGzipWriter returns a gzip writer at level writing to w, reused from the pool when possible
+func getGzipWriter(w io.Writer, level int) (*gzip.Writer, error) {
+	if level < gzip.DefaultCompression || level > gzip.BestCompression {
+		return gzip.NewWriterLevel(w, level)
+	}
+	if gw, ok := gzipWriterPools[level+1].Get().(*gzip.Writer); ok {
+		gw.Reset(w)
+		return gw, nil
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// putGzipWriter returns a closed gzip writer created at level to the pool
+func putGzipWriter(gw *gzip.Writer, level int) {
+	if level < gzip.DefaultCompression || level > gzip.BestCompression {
+		return
+	}
+	// Drop the reference to the last destination until the writer is reused
+	gw.Reset(io.Discard)
+	gzipWriterPools[level+1].Put(gw)
+}
+
+// marshalJSON appends the JSON encoding of v to buf, with the same output as json.Marshal
+func marshalJSON(buf *bytes.Buffer, v interface{}) error {
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+	// Encode terminates the value with a newline that json.Marshal doesn't add
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
@@ -0,0 +1,141 @@
+package sender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// benchmarkMetrics returns a batch resembling a busy send interval
+func benchmarkMetrics(n int) []collector.Metrics {
+	now := time.Now()
+	metrics := make([]collector.Metrics, 0, n)
+	for i := 0; i < n; i++ {
+		metrics = append(metrics, collector.Metrics{
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Category:  collector.CategorySystem,
+			Name:      collector.NameDisk,
+			Metadata:  collector.MetricMetadata{"mountpoint": fmt.Sprintf("/mnt/data%d", i%8)},
+			Value:     map[string]interface{}{"total": uint64(1 << 40), "used": uint64(i) << 30, "percent": float64(i%100) + 0.5},
+		})
+	}
+	return metrics
+}
+
+func TestMarshalJSON(t *testing.T) {
+	values := []interface{}{
+		map[string]interface{}{"machine_name": "web-1", "metrics": benchmarkMetrics(3)},
+		"<html> & friends",
+		nil,
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	for _, v := range values {
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		buf.Reset()
+		if err := marshalJSON(buf, v); err != nil {
+			t.Fatalf("marshalJSON() error = %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("marshalJSON() = %s, want %s", buf.Bytes(), want)
+		}
+	}
+
+	if err := marshalJSON(buf, make(chan int)); err == nil {
+		t.Error("marshalJSON() of a channel error = nil, want an error")
+	}
+}
+
+func TestCompressDataLevel_Concurrent(t *testing.T) {
+	levels := []int{gzip.DefaultCompression, gzip.BestSpeed, gzip.BestCompression}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := bytes.Repeat([]byte(fmt.Sprintf("payload-%d;", i)), 50+i)
+			for j := 0; j < 20; j++ {
+				compressed, err := compressDataLevel(data, levels[(i+j)%len(levels)])
+				if err != nil {
+					errs <- err
+					return
+				}
+				decompressed, err := decompressGzip(compressed)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(decompressed, data) {
+					errs <- fmt.Errorf("goroutine %d got another send's data", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestAPISender_encodeRequest_Uncompressed(t *testing.T) {
+	s := NewAPISender("http://localhost", "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetCompression(Compression{Disabled: true})
+
+	first, err := s.encodeRequest(s.requestBody(benchmarkMetrics(2), 0))
+	if err != nil {
+		t.Fatalf("encodeRequest() error = %v", err)
+	}
+	want := append([]byte(nil), first.data...)
+
+	// Reusing the pooled buffer for another send must not change the first body
+	if _, err := s.encodeRequest(s.requestBody(benchmarkMetrics(5), 0)); err != nil {
+		t.Fatalf("encodeRequest() error = %v", err)
+	}
+	if !bytes.Equal(first.data, want) {
+		t.Error("encodeRequest() body was overwritten by a later send")
+	}
+}
+
+// BenchmarkEncodeRequest compares the pooled marshal and compression path with allocating
+// fresh buffers and gzip writers on every send
+func BenchmarkEncodeRequest(b *testing.B) {
+	s := NewAPISender("http://localhost", "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	requestBody := s.requestBody(benchmarkMetrics(200), 0)
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(requestBody)
+			if err != nil {
+				b.Fatalf("json.Marshal() error = %v", err)
+			}
+			if _, err := compressData(data); err != nil {
+				b.Fatalf("compressData() error = %v", err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := s.encodeRequest(requestBody); err != nil {
+				b.Fatalf("encodeRequest() error = %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package sender
+
+import (
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+// SetProtobuf makes the sender serialize metrics payloads as protobuf (see metrics.proto in the
+// serialization package) instead of JSON. If the API answers 415 Unsupported Media Type, the
+// sender resends the payload as JSON and keeps using JSON from then on
+func (s *APISender) SetProtobuf(protobuf bool) {
+	s.protobuf.Store(protobuf)
+}
+
+// useProtobuf reports whether the payload for metrics is serialized as protobuf. Encrypted
+// payloads and the system information sent at startup are always JSON
+func (s *APISender) useProtobuf(metrics []collector.Metrics) bool {
+	return s.protobuf.Load() && s.encryptionKey == "" && !isSystemInfoBatch(metrics)
+}
+
+// protoPayload converts a payload built by requestBody to its protobuf form
+func protoPayload(requestBody map[string]interface{}) serialization.MetricsPayload {
+	var p serialization.MetricsPayload
+	p.Metrics, _ = requestBody["metrics"].([]collector.Metrics)
+	p.MachineName, _ = requestBody["machine_name"].(string)
+	p.Sequence, _ = requestBody["sequence"].(uint64)
+	p.SessionID, _ = requestBody["session_id"].(string)
+	p.BatchDigest, _ = requestBody["batch_digest"].(string)
+	if changed, ok := requestBody["changed_metrics"].([]MetricKey); ok {
+		p.ChangedMetrics = make([]serialization.MetricKey, 0, len(changed))
+		for _, k := range changed {
+			p.ChangedMetrics = append(p.ChangedMetrics, serialization.MetricKey(k))
+		}
+	}
+	return p
+}
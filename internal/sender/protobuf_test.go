@@ -0,0 +1,85 @@
+package sender
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+func TestAPISender_Protobuf(t *testing.T) {
+	var contentTypes []string
+	var payload serialization.MetricsPayload
+	acceptProtobuf := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		contentTypes = append(contentTypes, contentType)
+
+		data, _ := io.ReadAll(r.Body)
+		decompressed, err := decompressGzip(data)
+		if err != nil {
+			t.Errorf("failed to decompress request body: %v", err)
+		}
+		if contentType == serialization.ProtobufContentType {
+			if !acceptProtobuf {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			if payload, err = serialization.DeserializePayloadProto(decompressed); err != nil {
+				t.Errorf("failed to parse protobuf request body: %v", err)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetProtobuf(true)
+	s.SetChangeManifest(NewChangeTracker())
+
+	metrics := digestTestBatch()
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(contentTypes) != 1 || contentTypes[0] != serialization.ProtobufContentType {
+		t.Fatalf("Content-Type = %v, want %s", contentTypes, serialization.ProtobufContentType)
+	}
+	if payload.MachineName != "machine" || len(payload.Metrics) != 2 || len(payload.ChangedMetrics) != 2 {
+		t.Errorf("payload = %+v, want the machine name, 2 metrics and 2 changed keys", payload)
+	}
+	if payload.Metrics[1].Name != collector.NameCPU || payload.Metrics[1].Value != 42.0 {
+		t.Errorf("payload metric = %+v, want cpu 42", payload.Metrics[1])
+	}
+
+	// System information is always JSON
+	contentTypes = nil
+	if err := s.Send([]collector.Metrics{{Name: collector.NameSystemInfo, Value: map[string]string{}}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(contentTypes) != 1 || contentTypes[0] != "application/json" {
+		t.Errorf("system info Content-Type = %v, want application/json", contentTypes)
+	}
+
+	// A 415 response falls back to JSON for the same send and the following ones
+	acceptProtobuf = false
+	contentTypes = nil
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	want := []string{serialization.ProtobufContentType, "application/json", "application/json"}
+	if len(contentTypes) != len(want) {
+		t.Fatalf("Content-Types = %v, want %v", contentTypes, want)
+	}
+	for i := range want {
+		if contentTypes[i] != want[i] {
+			t.Errorf("Content-Types = %v, want %v", contentTypes, want)
+			break
+		}
+	}
+}
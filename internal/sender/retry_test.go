@@ -0,0 +1,236 @@
+package sender
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestAPISender_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // Status returned for each attempt, the last one repeats
+		maxRetries   int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "recovers after transient failures",
+			statuses:     []int{http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusOK},
+			maxRetries:   3,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives up after max retries",
+			statuses:     []int{http.StatusServiceUnavailable},
+			maxRetries:   2,
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "no retries by default",
+			statuses:     []int{http.StatusBadGateway},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "unauthorized fails fast",
+			statuses:     []int{http.StatusUnauthorized},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "not found fails fast",
+			statuses:     []int{http.StatusNotFound},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "rate limit is not retried",
+			statuses:     []int{http.StatusTooManyRequests},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "internal server error is not retried",
+			statuses:     []int{http.StatusInternalServerError},
+			maxRetries:   3,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&attempts, 1)) - 1
+				if n >= len(tt.statuses) {
+					n = len(tt.statuses) - 1
+				}
+				w.WriteHeader(tt.statuses[n])
+			}))
+			defer server.Close()
+
+			s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+			if tt.maxRetries > 0 {
+				s.SetRetryPolicy(RetryPolicy{
+					MaxRetries:     tt.maxRetries,
+					InitialBackoff: time.Millisecond,
+					MaxBackoff:     5 * time.Millisecond,
+				})
+			}
+
+			err := s.Send(spoolTestBatch(1))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("APISender.Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("APISender.Send() made %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestAPISender_RetryHonorsContext(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetRetryPolicy(RetryPolicy{MaxRetries: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := s.SendWithContext(ctx, spoolTestBatch(1))
+	if err == nil {
+		t.Fatal("APISender.SendWithContext() expected error")
+	}
+	if !IsRetryable(err) {
+		t.Errorf("APISender.SendWithContext() error = %v, want the last retryable send error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("APISender.SendWithContext() took %v, want it to stop when the context is cancelled", elapsed)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("APISender.SendWithContext() made %d attempts, want 1", got)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 0, max: 100 * time.Millisecond},
+		{attempt: 1, max: 200 * time.Millisecond},
+		{attempt: 2, max: 400 * time.Millisecond},
+		{attempt: 3, max: 500 * time.Millisecond},
+		{attempt: 10, max: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := p.backoff(tt.attempt)
+			if got < tt.max/2 || got >= tt.max {
+				t.Errorf("RetryPolicy.backoff(%d) = %v, want within [%v, %v)", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestAPISender_ConfigFetchRetry(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte("initial: config"), 0644); err != nil {
+		t.Fatalf("Failed to write initial config: %v", err)
+	}
+	lastUpdate := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	var fetches atomic.Int32
+	var failFetches atomic.Int32 // Number of upcoming fetches answered with 503
+	var sendHeader atomic.Bool
+	sendHeader.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/config") {
+			fetches.Add(1)
+			if failFetches.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(validServerConfig)
+			return
+		}
+		if sendHeader.Load() {
+			w.Header().Set("X-Configuration-Last-Update", lastUpdate)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	restartChan := make(chan struct{}, 1)
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", configFile, restartChan, DefaultRequestTimeout)
+	s.SetConfigFetchRetryPolicy(RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: "test", Name: "metric", Value: 1.0}}
+
+	// Three failures exhaust the first attempt and its retry, plus the first try of the next send
+	failFetches.Store(3)
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("fetches after first send = %d, want 2", got)
+	}
+	select {
+	case <-restartChan:
+		t.Fatal("unexpected restart after failed fetch")
+	default:
+	}
+
+	// The update is re-checked on the next send even though the header is gone
+	sendHeader.Store(false)
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := fetches.Load(); got != 4 {
+		t.Errorf("fetches after second send = %d, want 4", got)
+	}
+	select {
+	case <-restartChan:
+	default:
+		t.Fatal("expected restart once the config was fetched")
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if string(data) != string(validServerConfig) {
+		t.Errorf("config file = %q, want the fetched config", data)
+	}
+
+	// Nothing is pending anymore
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := fetches.Load(); got != 4 {
+		t.Errorf("fetches after third send = %d, want 4", got)
+	}
+}
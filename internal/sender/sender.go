@@ -2,6 +2,7 @@ package sender
 
 import (
 	"context"
+	"errors"
 
 	"github.com/monitorly-app/probe/internal/collector"
 )
@@ -14,3 +15,26 @@ type Sender interface {
 	// SendWithContext sends metrics with the provided context
 	SendWithContext(ctx context.Context, metrics []collector.Metrics) error
 }
+
+// RetryableError marks a send failure that may succeed if retried later,
+// such as a network error or a 5xx response from the API
+type RetryableError struct {
+	Err        error
+	StatusCode int // HTTP status code of the response, 0 for network errors
+}
+
+// Error returns the message of the wrapped error
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err, or any error it wraps, is a RetryableError
+func IsRetryable(err error) bool {
+	var retryable *RetryableError
+	return errors.As(err, &retryable)
+}
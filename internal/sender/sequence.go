@@ -0,0 +1,139 @@
+package sender
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/monitorly-app/probe/internal/logger"
+)
+
+// sequenceState is the content of the sequence state file
+type sequenceState struct {
+	SessionID string `json:"session_id"`
+	Sequence  uint64 `json:"sequence"`
+}
+
+// Sequence numbers successful metric sends so the backend can detect missed or reordered batches
+// The last number is persisted so it keeps increasing across restarts. When the state file can't
+// be read, numbering restarts from 1 under a new session ID so the gap isn't reported as lost data
+type Sequence struct {
+	path     string
+	state    sequenceState
+	reserved uint64   // Highest number handed out by Next, committed or not
+	released []uint64 // Numbers rolled back below reserved, handed out again first
+	mu       sync.Mutex
+}
+
+// NewSequence loads the sequence state from path, starting a new session if there is none
+func NewSequence(path string) (*Sequence, error) {
+	s := &Sequence{path: path}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &s.state)
+		if err == nil && s.state.SessionID != "" {
+			return s, nil
+		}
+		logger.Warnf("Invalid sequence state file %s, starting a new session", path)
+	} else if !os.IsNotExist(err) {
+		logger.Warnf("Failed to read sequence state file %s: %v, starting a new session", path, err)
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	s.state = sequenceState{SessionID: sessionID}
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SessionID returns the ID of the current numbering session
+func (s *Sequence) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.SessionID
+}
+
+// Next reserves the number to use for the next send, so concurrent sends never share a number
+// Retries of a send reuse the same number, which is then passed to Commit or Rollback once the
+// send result is known
+func (s *Sequence) Next() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.released) > 0 {
+		n := s.released[0]
+		s.released = s.released[1:]
+		return n
+	}
+	s.reserved = max(s.reserved, s.state.Sequence) + 1
+	return s.reserved
+}
+
+// Commit records the reserved number n as successfully sent and persists it
+func (s *Sequence) Commit(n uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n <= s.state.Sequence {
+		return nil
+	}
+	s.state.Sequence = n
+	return s.save()
+}
+
+// Rollback releases the reserved number n after a failed send so it is handed out again,
+// rather than leaving a gap the backend would report as lost data
+func (s *Sequence) Rollback(n uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n <= s.state.Sequence || n > s.reserved {
+		return
+	}
+	i, found := slices.BinarySearch(s.released, n)
+	if found {
+		return
+	}
+	s.released = slices.Insert(s.released, i, n)
+	// Released numbers at the top of the reservations are simply given back
+	for len(s.released) > 0 && s.released[len(s.released)-1] == s.reserved {
+		s.released = s.released[:len(s.released)-1]
+		s.reserved--
+	}
+}
+
+// save writes the state file in place, so only the file itself needs to be writable
+// A file truncated by a crash is detected on load and starts a new session
+func (s *Sequence) save() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sequence state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create sequence state directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write sequence state: %w", err)
+	}
+	return nil
+}
+
+// newSessionID returns a random 128-bit session ID in hex
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,180 @@
+package sender
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestSequence_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sequence.json")
+
+	seq, err := NewSequence(path)
+	if err != nil {
+		t.Fatalf("NewSequence() error = %v", err)
+	}
+	sessionID := seq.SessionID()
+	if len(sessionID) != 32 {
+		t.Errorf("SessionID() = %q, want 32 hex characters", sessionID)
+	}
+	if n := seq.Next(); n != 1 {
+		t.Errorf("Next() = %d, want 1", n)
+	}
+	if err := seq.Commit(1); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := seq.Commit(2); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	// Committing an older number never moves the sequence back
+	if err := seq.Commit(1); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// A restart resumes the same session
+	seq, err = NewSequence(path)
+	if err != nil {
+		t.Fatalf("NewSequence() error = %v", err)
+	}
+	if seq.SessionID() != sessionID || seq.Next() != 3 {
+		t.Errorf("reloaded sequence = %s/%d, want %s/3", seq.SessionID(), seq.Next(), sessionID)
+	}
+
+	// A corrupted state file starts a new session from 1
+	if err := os.WriteFile(path, []byte(`{"session_id": "abc`), 0644); err != nil {
+		t.Fatalf("failed to corrupt state file: %v", err)
+	}
+	seq, err = NewSequence(path)
+	if err != nil {
+		t.Fatalf("NewSequence() error = %v", err)
+	}
+	if seq.SessionID() == sessionID || seq.Next() != 1 {
+		t.Errorf("sequence after corruption = %s/%d, want a new session from 1", seq.SessionID(), seq.Next())
+	}
+}
+
+func TestSequence_ReserveAndRollback(t *testing.T) {
+	seq, err := NewSequence(filepath.Join(t.TempDir(), "sequence.json"))
+	if err != nil {
+		t.Fatalf("NewSequence() error = %v", err)
+	}
+
+	// Concurrent reservations never share a number
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[uint64]bool)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := seq.Next()
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[n] {
+				t.Errorf("Next() handed out %d twice", n)
+			}
+			seen[n] = true
+		}()
+	}
+	wg.Wait()
+	for n := uint64(1); n <= 50; n++ {
+		if err := seq.Commit(n); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+	}
+
+	// A number rolled back below other reservations is handed out again first
+	first, second, third := seq.Next(), seq.Next(), seq.Next()
+	seq.Rollback(second)
+	if n := seq.Next(); n != second {
+		t.Errorf("Next() after rolling back %d = %d, want %d", second, n, second)
+	}
+	// Rolling back the latest reservations gives the numbers back
+	seq.Rollback(third)
+	seq.Rollback(second)
+	if n := seq.Next(); n != second {
+		t.Errorf("Next() after rolling back %d and %d = %d, want %d", second, third, n, second)
+	}
+	if err := seq.Commit(first); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	// Committed numbers can't be rolled back
+	seq.Rollback(first)
+	if n := seq.Next(); n != third {
+		t.Errorf("Next() after rolling back committed %d = %d, want %d", first, n, third)
+	}
+}
+
+func TestAPISender_Sequence(t *testing.T) {
+	var statuses []int
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		decompressed, err := decompressGzip(data)
+		if err != nil {
+			t.Errorf("failed to decompress request body: %v", err)
+		}
+		var body map[string]interface{}
+		json.Unmarshal(decompressed, &body)
+		bodies = append(bodies, body)
+
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	seq, err := NewSequence(filepath.Join(t.TempDir(), "sequence.json"))
+	if err != nil {
+		t.Fatalf("NewSequence() error = %v", err)
+	}
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetSequence(seq)
+	s.SetRetryPolicy(RetryPolicy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: "test", Name: "metric", Value: 1.0}}
+
+	// The first payload is retried once and both attempts carry sequence 1
+	statuses = []int{http.StatusBadGateway}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	// A rejected payload doesn't consume a number
+	statuses = []int{http.StatusBadRequest}
+	if err := s.Send(metrics); err == nil {
+		t.Fatal("Send() error = nil, want rejection")
+	}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	// System information isn't numbered
+	if err := s.Send([]collector.Metrics{{Name: collector.NameSystemInfo, Value: map[string]string{}}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	want := []float64{1, 1, 2, 2, 0}
+	if len(bodies) != len(want) {
+		t.Fatalf("server received %d requests, want %d", len(bodies), len(want))
+	}
+	for i, body := range bodies {
+		got, _ := body["sequence"].(float64)
+		if got != want[i] {
+			t.Errorf("request %d sequence = %v, want %v", i, body["sequence"], want[i])
+		}
+		if want[i] > 0 && body["session_id"] != seq.SessionID() {
+			t.Errorf("request %d session_id = %v, want %s", i, body["session_id"], seq.SessionID())
+		}
+	}
+	if seq.Next() != 3 {
+		t.Errorf("Next() after sends = %d, want 3", seq.Next())
+	}
+}
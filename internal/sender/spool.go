@@ -0,0 +1,221 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+)
+
+const (
+	// spoolFilePrefix is the prefix of every batch file written to the spool directory
+	spoolFilePrefix = "spool-"
+	// spoolFileExt is the extension of batch files waiting to be replayed
+	spoolFileExt = ".batch"
+	// spoolBadExt is the extension given to batch files that could not be read back
+	spoolBadExt = ".bad"
+)
+
+// Spool is a disk-backed buffer for metrics that could not be sent
+// Each batch is stored in its own file so the oldest batches can be dropped when the
+// spool grows past its size limit, and replayed in order once the API is reachable again
+type Spool struct {
+	dir            string
+	maxBytes       int64
+	encryptionKey  string   // Optional: If set, batches are encrypted at rest
+	decryptionKeys []string // Keys tried in order when reading batches back
+	digest         bool     // Store each batch with its SHA-256 digest, checked when replaying it
+	seq            uint64   // Orders batches written within the same clock tick
+	mu             sync.Mutex
+}
+
+// NewSpool creates a new Spool storing batches under dir, capped at maxBytes in total
+func NewSpool(dir string, maxBytes int64) *Spool {
+	return &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+}
+
+// NewEncryptedSpool creates a new Spool that encrypts batches at rest with encryptionKey
+// Batches are read back with decryptionKeys, which should include encryptionKey and any
+// keys still in use during a key rotation
+func NewEncryptedSpool(dir string, maxBytes int64, encryptionKey string, decryptionKeys []string) *Spool {
+	return &Spool{
+		dir:            dir,
+		maxBytes:       maxBytes,
+		encryptionKey:  encryptionKey,
+		decryptionKeys: decryptionKeys,
+	}
+}
+
+// SetDigest makes the spool store each batch with its SHA-256 digest. Batches that no longer
+// match their digest when replayed are set aside as unreadable
+func (s *Spool) SetDigest(digest bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digest = digest
+}
+
+// Write stores a batch of metrics in the spool, dropping the oldest batches if the
+// spool exceeds its size limit
+func (s *Spool) Write(metrics []collector.Metrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	data, err := sealAtRest(metrics, s.encryptionKey, s.digest)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a partially written batch is never replayed
+	s.seq++
+	name := fmt.Sprintf("%s%020d-%06d%s", spoolFilePrefix, time.Now().UnixNano(), s.seq%1000000, spoolFileExt)
+	tmpPath := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to finalize spool file: %w", err)
+	}
+
+	return s.enforceLimit()
+}
+
+// Flush replays spooled batches, oldest first, through send
+// Successfully sent batches are removed; replay stops at the first send error so the
+// remaining batches are kept for the next attempt. Returns the number of metrics sent
+func (s *Spool) Flush(ctx context.Context, send func(context.Context, []collector.Metrics) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.batchFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return sent, fmt.Errorf("failed to read spool file: %w", err)
+		}
+
+		metrics, err := openAtRest(data, s.decryptionKeys)
+		if err != nil {
+			// Keep unreadable batches aside instead of blocking the spool or deleting data
+			logger.Warnf("Skipping unreadable spool file %s: %v", path, err)
+			os.Rename(path, strings.TrimSuffix(path, spoolFileExt)+spoolBadExt)
+			continue
+		}
+
+		if err := send(ctx, metrics); err != nil {
+			// Keep only the part of the batch that wasn't accepted, so it isn't replayed twice
+			if n := SentBefore(err); n > 0 {
+				if rewriteErr := s.rewrite(path, metrics[n:]); rewriteErr != nil {
+					logger.Warnf("Failed to update partially sent spool file %s: %v", path, rewriteErr)
+				} else {
+					sent += n
+				}
+			}
+			return sent, err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return sent, fmt.Errorf("failed to remove spool file: %w", err)
+		}
+		sent += len(metrics)
+	}
+
+	return sent, nil
+}
+
+// rewrite replaces the batch stored at path with metrics
+func (s *Spool) rewrite(path string, metrics []collector.Metrics) error {
+	data, err := sealAtRest(metrics, s.encryptionKey, s.digest)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to finalize spool file: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of batches waiting in the spool
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := s.batchFiles()
+	if err != nil {
+		return 0
+	}
+	return len(files)
+}
+
+// batchFiles returns the spooled batch files sorted from oldest to newest
+func (s *Spool) batchFiles() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, spoolFilePrefix+"*"+spoolFileExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool files: %w", err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// enforceLimit removes the oldest batches until the spool fits within maxBytes
+func (s *Spool) enforceLimit() error {
+	if s.maxBytes <= 0 {
+		return nil
+	}
+
+	files, err := s.batchFiles()
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(files))
+	var total int64
+	for i, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		sizes[i] = info.Size()
+		total += sizes[i]
+	}
+
+	// Always keep the newest batch, even if it alone exceeds the limit
+	for i := 0; i < len(files)-1 && total > s.maxBytes; i++ {
+		if err := os.Remove(files[i]); err != nil {
+			return fmt.Errorf("failed to drop oldest spool file: %w", err)
+		}
+		total -= sizes[i]
+		logger.Warnf("Spool size limit reached, dropped oldest batch %s", filepath.Base(files[i]))
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func spoolTestBatch(value float64) []collector.Metrics {
+	return []collector.Metrics{{
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Category:  collector.CategorySystem,
+		Name:      collector.NameCPU,
+		Value:     value,
+	}}
+}
+
+func TestSpool_WriteAndFlush(t *testing.T) {
+	tests := []struct {
+		name  string
+		spool func(dir string) *Spool
+	}{
+		{
+			name:  "plain spool",
+			spool: func(dir string) *Spool { return NewSpool(dir, 0) },
+		},
+		{
+			name: "encrypted spool",
+			spool: func(dir string) *Spool {
+				key := "12345678901234567890123456789012"
+				return NewEncryptedSpool(dir, 0, key, []string{key})
+			},
+		},
+		{
+			name: "spool with digest",
+			spool: func(dir string) *Spool {
+				spool := NewSpool(dir, 0)
+				spool.SetDigest(true)
+				return spool
+			},
+		},
+		{
+			name: "encrypted spool with digest",
+			spool: func(dir string) *Spool {
+				key := "12345678901234567890123456789012"
+				spool := NewEncryptedSpool(dir, 0, key, []string{key})
+				spool.SetDigest(true)
+				return spool
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spool := tt.spool(t.TempDir())
+
+			for _, v := range []float64{1, 2, 3} {
+				if err := spool.Write(spoolTestBatch(v)); err != nil {
+					t.Fatalf("Spool.Write() error = %v", err)
+				}
+			}
+			if spool.Len() != 3 {
+				t.Fatalf("Spool.Len() = %d, want 3", spool.Len())
+			}
+
+			var replayed []float64
+			sent, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+				replayed = append(replayed, metrics[0].Value.(float64))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Spool.Flush() error = %v", err)
+			}
+			if sent != 3 {
+				t.Errorf("Spool.Flush() sent = %d, want 3", sent)
+			}
+			if fmt.Sprint(replayed) != "[1 2 3]" {
+				t.Errorf("Spool.Flush() replayed %v, want oldest first [1 2 3]", replayed)
+			}
+			if spool.Len() != 0 {
+				t.Errorf("Spool.Len() after flush = %d, want 0", spool.Len())
+			}
+		})
+	}
+}
+
+func TestSpool_FlushStopsOnError(t *testing.T) {
+	spool := NewSpool(t.TempDir(), 0)
+	for _, v := range []float64{1, 2} {
+		if err := spool.Write(spoolTestBatch(v)); err != nil {
+			t.Fatalf("Spool.Write() error = %v", err)
+		}
+	}
+
+	calls := 0
+	sent, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+		calls++
+		return errors.New("API unreachable")
+	})
+	if err == nil {
+		t.Fatal("Spool.Flush() expected error")
+	}
+	if sent != 0 || calls != 1 {
+		t.Errorf("Spool.Flush() sent = %d with %d calls, want 0 sent after 1 call", sent, calls)
+	}
+	if spool.Len() != 2 {
+		t.Errorf("Spool.Len() = %d, want 2 batches kept", spool.Len())
+	}
+}
+
+func TestSpool_FlushKeepsUnsentPartOfBatch(t *testing.T) {
+	spool := NewSpool(t.TempDir(), 0)
+	batch := append(append(spoolTestBatch(1), spoolTestBatch(2)...), spoolTestBatch(3)...)
+	if err := spool.Write(batch); err != nil {
+		t.Fatalf("Spool.Write() error = %v", err)
+	}
+
+	sent, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+		return &PartialSendError{Sent: 2, Err: &RetryableError{Err: errors.New("API unreachable")}}
+	})
+	if err == nil || sent != 2 {
+		t.Fatalf("Spool.Flush() = %d, %v, want 2 sent and an error", sent, err)
+	}
+
+	var replayed []collector.Metrics
+	if _, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+		replayed = metrics
+		return nil
+	}); err != nil {
+		t.Fatalf("Spool.Flush() error = %v", err)
+	}
+	if len(replayed) != 1 || replayed[0].Value != 3.0 {
+		t.Errorf("Spool.Flush() replayed %+v, want only the unsent metric", replayed)
+	}
+}
+
+func TestSpool_DropsOldestOverLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	// Measure a single batch to size the limit to two batches
+	probe := NewSpool(filepath.Join(dir, "probe"), 0)
+	if err := probe.Write(spoolTestBatch(1)); err != nil {
+		t.Fatalf("Spool.Write() error = %v", err)
+	}
+	files, _ := probe.batchFiles()
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("failed to stat spool file: %v", err)
+	}
+
+	spool := NewSpool(filepath.Join(dir, "limited"), info.Size()*2)
+	for _, v := range []float64{1, 2, 3} {
+		if err := spool.Write(spoolTestBatch(v)); err != nil {
+			t.Fatalf("Spool.Write() error = %v", err)
+		}
+	}
+	if spool.Len() != 2 {
+		t.Fatalf("Spool.Len() = %d, want 2", spool.Len())
+	}
+
+	var replayed []float64
+	if _, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+		replayed = append(replayed, metrics[0].Value.(float64))
+		return nil
+	}); err != nil {
+		t.Fatalf("Spool.Flush() error = %v", err)
+	}
+	if fmt.Sprint(replayed) != "[2 3]" {
+		t.Errorf("Spool.Flush() replayed %v, want [2 3] after dropping the oldest", replayed)
+	}
+}
+
+func TestSpool_UnreadableBatchSetAside(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := "12345678901234567890123456789012"
+	newKey := "abcdefghijklmnopqrstuvwxyz123456"
+
+	if err := NewEncryptedSpool(dir, 0, oldKey, []string{oldKey}).Write(spoolTestBatch(1)); err != nil {
+		t.Fatalf("Spool.Write() error = %v", err)
+	}
+
+	spool := NewEncryptedSpool(dir, 0, newKey, []string{newKey})
+	sent, err := spool.Flush(context.Background(), func(ctx context.Context, metrics []collector.Metrics) error {
+		t.Error("send should not be called for an unreadable batch")
+		return nil
+	})
+	if err != nil || sent != 0 {
+		t.Errorf("Spool.Flush() = %d, %v, want 0, nil", sent, err)
+	}
+
+	bad, _ := filepath.Glob(filepath.Join(dir, "*"+spoolBadExt))
+	if len(bad) != 1 {
+		t.Errorf("expected unreadable batch to be kept as %s file, found %v", spoolBadExt, bad)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   bool
+	}{
+		{name: "server error", status: http.StatusInternalServerError, want: true},
+		{name: "maintenance", status: http.StatusServiceUnavailable, want: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, want: false},
+		{name: "bad request", status: http.StatusBadRequest, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+			err := s.Send(spoolTestBatch(1))
+			if err == nil {
+				t.Fatal("APISender.Send() expected error")
+			}
+			if got := IsRetryable(err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+
+	// Network errors are retryable
+	s := NewAPISender("http://127.0.0.1:1", "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	if err := s.Send(spoolTestBatch(1)); !IsRetryable(err) {
+		t.Errorf("IsRetryable(%v) = false for network error, want true", err)
+	}
+}
@@ -0,0 +1,192 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+)
+
+// statsdMaxPacketSize keeps each UDP packet below the typical Ethernet MTU to avoid fragmentation
+const statsdMaxPacketSize = 1432
+
+// StatsDSender implements the Sender interface by emitting metrics as StatsD gauges over UDP
+// Metric paths are built as prefix.category.name[.leaf] and metadata is sent as DogStatsD tags
+type StatsDSender struct {
+	address string
+	prefix  string
+	conn    net.Conn
+	mu      sync.Mutex
+}
+
+// NewStatsDSender creates a new instance of StatsDSender sending to address (host:port)
+func NewStatsDSender(address, prefix string) *StatsDSender {
+	return &StatsDSender{
+		address: address,
+		prefix:  prefix,
+	}
+}
+
+// Send emits metrics to the StatsD server
+func (s *StatsDSender) Send(metrics []collector.Metrics) error {
+	return s.SendWithContext(context.Background(), metrics)
+}
+
+// SendWithContext emits metrics to the StatsD server with context support
+// UDP delivery is best effort: network errors are logged and the batch is considered sent,
+// so an unreachable StatsD server never makes metrics pile up in the send loop
+func (s *StatsDSender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled: %w", ctx.Err())
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.address)
+		if err != nil {
+			logger.Warnf("Failed to connect to StatsD server %s: %v", s.address, err)
+			return nil
+		}
+		s.conn = conn
+	}
+
+	var lines []string
+	for _, m := range metrics {
+		lines = append(lines, s.formatMetric(m)...)
+	}
+
+	for _, packet := range packLines(lines, statsdMaxPacketSize) {
+		if ctx.Err() != nil {
+			return fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		if _, err := s.conn.Write(packet); err != nil {
+			logger.Warnf("Failed to send metrics to StatsD server %s: %v", s.address, err)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Close closes the UDP connection
+func (s *StatsDSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// formatMetric converts a metric into StatsD gauge lines, one per numeric leaf of its value
+func (s *StatsDSender) formatMetric(m collector.Metrics) []string {
+	// Round-trip through JSON to walk every value type the collectors produce the same way
+	data, err := json.Marshal(m.Value)
+	if err != nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+
+	base := []string{sanitizeStatsDName(string(m.Category)), sanitizeStatsDName(string(m.Name))}
+	if s.prefix != "" {
+		base = append([]string{sanitizeStatsDName(s.prefix)}, base...)
+	}
+	tags := formatStatsDTags(m.Metadata)
+
+	var lines []string
+	walkNumericLeaves(base, value, func(path []string, v float64) {
+		lines = append(lines, fmt.Sprintf("%s:%s|g%s", strings.Join(path, "."), strconv.FormatFloat(v, 'f', -1, 64), tags))
+	})
+	return lines
+}
+
+// walkNumericLeaves calls fn for every number or boolean in value, extending path with map keys
+// Booleans are reported as 1 or 0, strings and lists are skipped
+func walkNumericLeaves(path []string, value interface{}, fn func([]string, float64)) {
+	switch v := value.(type) {
+	case float64:
+		fn(path, v)
+	case bool:
+		if v {
+			fn(path, 1)
+		} else {
+			fn(path, 0)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := append(append([]string{}, path...), sanitizeStatsDName(k))
+			walkNumericLeaves(child, v[k], fn)
+		}
+	}
+}
+
+// formatStatsDTags renders metadata as a DogStatsD tag suffix, sorted for stable output
+func formatStatsDTags(metadata collector.MetricMetadata) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+
+	tags := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		tags = append(tags, sanitizeStatsDName(k)+":"+strings.NewReplacer(",", "_", "|", "_", "\n", "_").Replace(v))
+	}
+	sort.Strings(tags)
+	return "|#" + strings.Join(tags, ",")
+}
+
+// sanitizeStatsDName replaces characters that have a meaning in the StatsD protocol or metric paths
+func sanitizeStatsDName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// packLines groups newline separated lines into packets of at most maxSize bytes
+// A single line larger than maxSize is sent on its own
+func packLines(lines []string, maxSize int) [][]byte {
+	var packets [][]byte
+	var buf bytes.Buffer
+
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > maxSize {
+			packets = append(packets, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		packets = append(packets, buf.Bytes())
+	}
+
+	return packets
+}
@@ -0,0 +1,141 @@
+package sender
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestStatsDSender_FormatMetric(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		metric collector.Metrics
+		want   []string
+	}{
+		{
+			name:   "scalar value",
+			prefix: "monitorly",
+			metric: collector.Metrics{Category: collector.CategorySystem, Name: collector.NameCPU, Value: 42.5},
+			want:   []string{"monitorly.system.cpu:42.5|g"},
+		},
+		{
+			name:   "map value with metadata tags",
+			prefix: "monitorly",
+			metric: collector.Metrics{
+				Category: collector.CategorySystem,
+				Name:     collector.NameDisk,
+				Metadata: collector.MetricMetadata{"mountpoint": "/", "label": "Root, main"},
+				Value:    map[string]interface{}{"used": uint64(1024), "total": 4096, "percent": 25.0},
+			},
+			want: []string{
+				"monitorly.system.disk.percent:25|g|#label:Root_ main,mountpoint:/",
+				"monitorly.system.disk.total:4096|g|#label:Root_ main,mountpoint:/",
+				"monitorly.system.disk.used:1024|g|#label:Root_ main,mountpoint:/",
+			},
+		},
+		{
+			name: "booleans and nested maps, strings skipped",
+			metric: collector.Metrics{
+				Category: collector.CategorySystem,
+				Name:     collector.NameService,
+				Value: map[string]interface{}{
+					"active":    true,
+					"sub_state": "running",
+					"io":        map[string]int{"read.bytes": 10},
+				},
+			},
+			want: []string{
+				"system.service.active:1|g",
+				"system.service.io.read_bytes:10|g",
+			},
+		},
+		{
+			name:   "list values are skipped",
+			metric: collector.Metrics{Category: collector.CategorySystem, Name: collector.NameLoginFailures, Value: []string{"a"}},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStatsDSender("127.0.0.1:8125", tt.prefix)
+			got := s.formatMetric(tt.metric)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StatsDSender.formatMetric() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDSender_Send(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	s := NewStatsDSender(conn.LocalAddr().String(), "probe")
+	defer s.Close()
+
+	metrics := []collector.Metrics{
+		{Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.0},
+		{Category: collector.CategorySystem, Name: collector.NameRAM, Value: map[string]interface{}{"used": 1.0}},
+	}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("StatsDSender.Send() error = %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, statsdMaxPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read packet: %v", err)
+	}
+
+	lines := strings.Split(string(buf[:n]), "\n")
+	sort.Strings(lines)
+	want := []string{"probe.system.cpu:12|g", "probe.system.ram.used:1|g"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("StatsDSender.Send() sent %q, want %q", lines, want)
+	}
+}
+
+func TestStatsDSender_SendErrorsAreNotFatal(t *testing.T) {
+	// Port 1 on localhost refuses UDP, writes may fail but Send must not return an error
+	s := NewStatsDSender("127.0.0.1:1", "")
+	defer s.Close()
+
+	metrics := []collector.Metrics{{Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+	for i := 0; i < 3; i++ {
+		if err := s.Send(metrics); err != nil {
+			t.Errorf("StatsDSender.Send() error = %v, want nil", err)
+		}
+	}
+
+	// An unresolvable address is logged as well
+	s = NewStatsDSender("invalid-address", "")
+	if err := s.Send(metrics); err != nil {
+		t.Errorf("StatsDSender.Send() with invalid address error = %v, want nil", err)
+	}
+}
+
+func TestPackLines(t *testing.T) {
+	lines := []string{"aaaa", "bbbb", "cccc", "dddddddddddd"}
+
+	got := packLines(lines, 10)
+	want := []string{"aaaa\nbbbb", "cccc", "dddddddddddd"}
+	if len(got) != len(want) {
+		t.Fatalf("packLines() returned %d packets, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if string(got[i]) != want[i] {
+			t.Errorf("packLines() packet %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package sender
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// SetStreaming makes the sender stream metrics as gzipped newline-delimited JSON in a single
+// chunked request instead of building one JSON document. The backend must support it
+func (s *APISender) SetStreaming(streaming bool) {
+	s.streaming = streaming
+}
+
+// sendStream makes a single attempt at streaming metrics to the API endpoint, one JSON object per line
+// Each metric is encoded and compressed straight into the request body as it is sent, so the payload
+// is never held in memory. The machine name and sequence are sent as headers since every line is a metric
+func (s *APISender) sendStream(ctx context.Context, metrics []collector.Metrics, seq uint64) error {
+	url := fmt.Sprintf("%s/api/%s/servers/%s/metrics", s.baseURL, s.organizationID, s.serverID)
+
+	body, writer := io.Pipe()
+	// Stops the encoder if the request ends before the whole body was read
+	defer body.Close()
+	go func() {
+		writer.CloseWithError(writeNDJSON(ctx, writer, metrics))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.applicationToken)
+	req.Header.Set("User-Agent", "Monitorly-Probe/v1.0.0")
+	req.Header.Set("X-Machine-Name", s.machineName)
+	if seq > 0 {
+		req.Header.Set("X-Sequence", strconv.FormatUint(seq, 10))
+		req.Header.Set("X-Session-ID", s.sequence.SessionID())
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to stream metrics: %w", err)
+		}
+		return &RetryableError{Err: fmt.Errorf("failed to stream metrics: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	s.checkConfigUpdate(ctx, resp)
+
+	return s.checkResponse(resp)
+}
+
+// writeNDJSON writes metrics to w as gzipped newline-delimited JSON, stopping when ctx is done
+func writeNDJSON(ctx context.Context, w io.Writer, metrics []collector.Metrics) error {
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+	for _, m := range metrics {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := encoder.Encode(m); err != nil {
+			return fmt.Errorf("failed to encode metric: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to close gzip stream: %w", err)
+	}
+	return nil
+}
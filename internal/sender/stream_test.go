@@ -0,0 +1,111 @@
+package sender
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestAPISender_Streaming(t *testing.T) {
+	var lines []map[string]interface{}
+	var header http.Header
+	var chunked bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		chunked = r.ContentLength == -1 && len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("failed to read gzip stream: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Errorf("invalid NDJSON line %q: %v", scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	seq, err := NewSequence(filepath.Join(t.TempDir(), "sequence.json"))
+	if err != nil {
+		t.Fatalf("NewSequence() error = %v", err)
+	}
+	s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	s.SetStreaming(true)
+	s.SetSequence(seq)
+
+	metrics := []collector.Metrics{
+		{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.5},
+		{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameRAM, Value: 40.0},
+	}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if !chunked {
+		t.Error("Send() didn't use chunked transfer encoding")
+	}
+	if header.Get("Content-Type") != "application/x-ndjson" || header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Send() headers = %v, want gzipped application/x-ndjson", header)
+	}
+	if header.Get("X-Machine-Name") != "machine" || header.Get("X-Sequence") != "1" || header.Get("X-Session-ID") != seq.SessionID() {
+		t.Errorf("Send() headers = %v, want machine name, sequence 1 and session ID", header)
+	}
+	if len(lines) != 2 || lines[0]["name"] != "cpu" || lines[1]["name"] != "ram" {
+		t.Errorf("Send() streamed %v, want the cpu and ram metrics", lines)
+	}
+	if seq.Next() != 2 {
+		t.Errorf("Next() after a streamed send = %d, want 2", seq.Next())
+	}
+}
+
+// cancelOnMarshal cancels a context when it is encoded
+type cancelOnMarshal struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelOnMarshal) MarshalJSON() ([]byte, error) {
+	c.cancel()
+	return []byte(`0`), nil
+}
+
+func TestWriteNDJSON_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	metrics := []collector.Metrics{
+		{Name: collector.NameCPU, Value: cancelOnMarshal{cancel}},
+		{Name: collector.NameRAM, Value: 40.0},
+	}
+	var buf bytes.Buffer
+	err := writeNDJSON(ctx, &buf, metrics)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("writeNDJSON() error = %v, want context.Canceled", err)
+	}
+
+	// A canceled stream is never a valid gzip stream, so the backend can't accept a truncated batch
+	gz, err := gzip.NewReader(&buf)
+	if err == nil {
+		_, err = io.ReadAll(gz)
+	}
+	if err == nil {
+		t.Error("writeNDJSON() produced a complete gzip stream after cancellation")
+	}
+}
@@ -0,0 +1,131 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/monitorly-app/probe/internal/collector"
+	"github.com/monitorly-app/probe/internal/logger"
+	"github.com/monitorly-app/probe/internal/serialization"
+)
+
+// syslogFacilities maps facility names to their syslog codes (RFC 5424)
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// errSyslogUnsupported is returned by dialSyslog on platforms without syslog
+var errSyslogUnsupported = errors.New("syslog is not supported on this platform")
+
+// syslogWriter is the part of *syslog.Writer used by SyslogSender
+type syslogWriter interface {
+	Info(msg string) error
+	Close() error
+}
+
+// SyslogSender implements the Sender interface by writing each batch of metrics as a single
+// syslog message to the local syslog daemon or a remote server. When syslog is unavailable,
+// batches are written to stderr instead so they still reach the service manager's logs
+type SyslogSender struct {
+	network  string // "udp" or "tcp" for a remote server, empty for the local daemon
+	address  string
+	facility int
+	tag      string
+	writer   syslogWriter
+	fallback io.Writer
+	warned   bool
+	mu       sync.Mutex
+}
+
+// NewSyslogSender creates a new instance of SyslogSender
+// The connection is made on the first send, so a syslog daemon started after the probe is picked up
+func NewSyslogSender(network, address, facility, tag string) (*SyslogSender, error) {
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog facility: %s", facility)
+	}
+	return &SyslogSender{
+		network:  network,
+		address:  address,
+		facility: code,
+		tag:      tag,
+		fallback: os.Stderr,
+	}, nil
+}
+
+// Send writes metrics to syslog
+func (s *SyslogSender) Send(metrics []collector.Metrics) error {
+	return s.SendWithContext(context.Background(), metrics)
+}
+
+// SendWithContext writes metrics to syslog as a single JSON message with context support
+func (s *SyslogSender) SendWithContext(ctx context.Context, metrics []collector.Metrics) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context cancelled: %w", ctx.Err())
+	default:
+	}
+
+	data, err := serialization.SerializeMetrics(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metrics: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil {
+		writer, err := dialSyslog(s.network, s.address, s.facility, s.tag)
+		if err != nil {
+			s.warnFallback(err)
+			return s.writeFallback(data)
+		}
+		s.writer, s.warned = writer, false
+	}
+
+	if err := s.writer.Info(string(data)); err != nil {
+		// Reconnect on the next send, the daemon may have been restarted
+		s.writer.Close()
+		s.writer = nil
+		s.warnFallback(err)
+		return s.writeFallback(data)
+	}
+	return nil
+}
+
+// warnFallback logs once per outage that batches are written to stderr
+func (s *SyslogSender) warnFallback(err error) {
+	if s.warned {
+		return
+	}
+	s.warned = true
+	logger.Warnf("Syslog unavailable (%v), writing metrics to stderr", err)
+}
+
+// writeFallback writes a serialized batch to the fallback writer, one line per batch
+func (s *SyslogSender) writeFallback(data []byte) error {
+	if _, err := fmt.Fprintf(s.fallback, "%s: %s\n", s.tag, data); err != nil {
+		return fmt.Errorf("failed to write metrics to stderr: %w", err)
+	}
+	return nil
+}
+
+// Close closes the syslog connection
+func (s *SyslogSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Close()
+	s.writer = nil
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package sender
+
+// dialSyslog always fails where log/syslog isn't available, so metrics go to stderr
+var dialSyslog = func(network, address string, facility int, tag string) (syslogWriter, error) {
+	return nil, errSyslogUnsupported
+}
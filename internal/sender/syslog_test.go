@@ -0,0 +1,97 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// fakeSyslog records the messages written to it
+type fakeSyslog struct {
+	messages []string
+	err      error
+	closed   bool
+}
+
+func (f *fakeSyslog) Info(msg string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, msg)
+	return nil
+}
+
+func (f *fakeSyslog) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestSyslogSender(t *testing.T) {
+	originalDial := dialSyslog
+	defer func() { dialSyslog = originalDial }()
+
+	daemon := &fakeSyslog{}
+	var dialed []string
+	var dialErr error
+	dialSyslog = func(network, address string, facility int, tag string) (syslogWriter, error) {
+		dialed = append(dialed, network+"|"+address+"|"+tag)
+		if facility != 16 {
+			t.Errorf("dialSyslog() facility = %d, want local0 (16)", facility)
+		}
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		return daemon, nil
+	}
+
+	s, err := NewSyslogSender("udp", "logs.example.com:514", "local0", "probe")
+	if err != nil {
+		t.Fatalf("NewSyslogSender() error = %v", err)
+	}
+	var stderr bytes.Buffer
+	s.fallback = &stderr
+
+	metrics := []collector.Metrics{{Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.5}}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(daemon.messages) != 1 {
+		t.Fatalf("syslog received %d messages, want 1 per batch", len(daemon.messages))
+	}
+	var sent []collector.Metrics
+	if err := json.Unmarshal([]byte(daemon.messages[0]), &sent); err != nil || len(sent) != 1 || sent[0].Name != collector.NameCPU {
+		t.Errorf("syslog message = %q, want the serialized batch", daemon.messages[0])
+	}
+	if len(dialed) != 1 || dialed[0] != "udp|logs.example.com:514|probe" {
+		t.Errorf("dialSyslog() calls = %v, want one to the remote server", dialed)
+	}
+
+	// A failed write falls back to stderr and reconnects on the next send
+	daemon.err = errors.New("connection refused")
+	dialErr = errors.New("connection refused")
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() with syslog down error = %v", err)
+	}
+	if !daemon.closed {
+		t.Error("Send() didn't close the failed syslog connection")
+	}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() with syslog down error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(stderr.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "probe: [") {
+		t.Errorf("stderr = %q, want both batches prefixed with the tag", stderr.String())
+	}
+	if len(dialed) != 2 {
+		t.Errorf("dialSyslog() called %d times, want a reconnect attempt after the failure", len(dialed))
+	}
+}
+
+func TestNewSyslogSender_InvalidFacility(t *testing.T) {
+	if _, err := NewSyslogSender("", "", "local9", "probe"); err == nil {
+		t.Error("NewSyslogSender() expected an error for an unknown facility")
+	}
+}
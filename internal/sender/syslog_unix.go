@@ -0,0 +1,11 @@
+//go:build !windows && !plan9
+
+package sender
+
+import "log/syslog"
+
+// dialSyslog connects to the syslog daemon, locally when network is empty
+// It is a variable to allow replacing the syslog daemon in tests
+var dialSyslog = func(network, address string, facility int, tag string) (syslogWriter, error) {
+	return syslog.Dial(network, address, syslog.Priority(facility<<3)|syslog.LOG_INFO, tag)
+}
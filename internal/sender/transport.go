@@ -0,0 +1,74 @@
+package sender
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// SetProxy routes API requests through proxyURL (http, https or socks5) instead of the proxy
+// from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func (s *APISender) SetProxy(proxyURL string) error {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	s.proxy = proxy
+	s.client.Transport = newTransport(s.proxy, s.tlsConfig)
+	return nil
+}
+
+// SetTLSConfig makes API requests use tlsConfig, typically built with LoadTLSConfig
+func (s *APISender) SetTLSConfig(tlsConfig *tls.Config) {
+	s.tlsConfig = tlsConfig
+	s.client.Transport = newTransport(s.proxy, s.tlsConfig)
+}
+
+// LoadTLSConfig builds a TLS config trusting the CAs in caFile instead of the system trust store,
+// and presenting the client certificate in certFile and keyFile for mutual TLS. Empty paths keep
+// the defaults for that part
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("client_cert_file and client_key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s with key %s: %w", certFile, keyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newTransport returns an HTTP transport using proxy, or the proxy from the environment when nil,
+// and tlsConfig, or the system trust store when nil
+func newTransport(proxy *url.URL, tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport
+}
@@ -0,0 +1,175 @@
+package sender
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "probe"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestAPISender_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A request sent through an HTTP proxy carries the absolute URL of its target
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	s := NewAPISender("http://api.monitorly.invalid", "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+	if err := s.SetProxy(proxy.URL); err != nil {
+		t.Fatalf("SetProxy() error = %v", err)
+	}
+
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+	if err := s.Send(metrics); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	want := "http://api.monitorly.invalid/api/org/servers/server/metrics"
+	if len(proxied) != 1 || proxied[0] != want {
+		t.Errorf("proxy received %v, want [%s]", proxied, want)
+	}
+}
+
+func TestAPISender_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := []collector.Metrics{{Timestamp: time.Now(), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 1.0}}
+	tests := []struct {
+		name     string
+		caFile   string
+		certFile string
+		keyFile  string
+		wantErr  string
+	}{
+		{name: "system trust store rejects the internal CA", wantErr: "certificate"},
+		{name: "CA without client certificate", caFile: caFile, wantErr: "failed to send request"},
+		{name: "CA and client certificate", caFile: caFile, certFile: certFile, keyFile: keyFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAPISender(server.URL, "org", "server", "token", "machine", "", "", nil, DefaultRequestTimeout)
+			if tt.caFile != "" {
+				tlsConfig, err := LoadTLSConfig(tt.caFile, tt.certFile, tt.keyFile)
+				if err != nil {
+					t.Fatalf("LoadTLSConfig() error = %v", err)
+				}
+				s.SetTLSConfig(tlsConfig)
+			}
+
+			err := s.Send(metrics)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Send() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Send() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeClientCert(t, dir)
+	notPEM := filepath.Join(dir, "not-pem.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		caFile   string
+		certFile string
+		keyFile  string
+		wantErr  string
+	}{
+		{name: "missing CA file", caFile: filepath.Join(dir, "missing.pem"), wantErr: "failed to read CA file"},
+		{name: "malformed CA file", caFile: notPEM, wantErr: "no PEM certificates found"},
+		{name: "certificate without key", certFile: certFile, wantErr: "must be set together"},
+		{name: "malformed key", certFile: certFile, keyFile: notPEM, wantErr: "failed to load client certificate"},
+		{name: "key not matching certificate", certFile: certFile, keyFile: certFile, wantErr: "failed to load client certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTLSConfig(tt.caFile, tt.certFile, tt.keyFile)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadTLSConfig() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadTLSConfig("", certFile, keyFile); err != nil {
+		t.Errorf("LoadTLSConfig() with a valid client certificate error = %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// OTLPMetricPrefix is prepended to the instrument names of OTLP metrics
+const OTLPMetricPrefix = "monitorly"
+
+// Field numbers from the OpenTelemetry protocol (opentelemetry/proto/collector/metrics/v1 and
+// opentelemetry/proto/metrics/v1)
+const (
+	otlpRequestResourceMetrics = 1
+
+	otlpResourceMetricsResource = 1
+	otlpResourceMetricsScope    = 2
+
+	otlpResourceAttributes = 1
+
+	otlpScopeMetricsScope   = 1
+	otlpScopeMetricsMetrics = 2
+
+	otlpScopeName    = 1
+	otlpScopeVersion = 2
+
+	otlpMetricName  = 1
+	otlpMetricGauge = 5
+
+	otlpGaugeDataPoints = 1
+
+	otlpPointTime       = 3
+	otlpPointAsDouble   = 4
+	otlpPointAsInt      = 6
+	otlpPointAttributes = 7
+
+	otlpKeyValueKey   = 1
+	otlpKeyValueValue = 2
+
+	otlpAnyValueString = 1
+)
+
+// OTLPResource describes the source of the metrics of an OTLP export
+type OTLPResource struct {
+	Attributes   map[string]string // Resource attributes, e.g. service.name and host.name
+	ScopeName    string            // Name of the instrumentation scope
+	ScopeVersion string            // Version of the instrumentation scope
+}
+
+// otlpPoint is a single gauge data point of an instrument
+type otlpPoint struct {
+	timeUnixNano uint64
+	attributes   collector.MetricMetadata
+	value        interface{} // int64 or float64
+}
+
+// SerializeOTLP converts metrics to an OTLP ExportMetricsServiceRequest in the protobuf encoding.
+// Each metric becomes a gauge named monitorly.<category>.<name> with its metadata as attributes.
+// Map values expand into one gauge per numeric leaf, named after the path of keys leading to it,
+// and booleans are reported as 1 or 0. Integers are sent as integers, other numbers as doubles.
+// Strings and lists have no gauge representation and are left out
+func SerializeOTLP(metrics []collector.Metrics, resource OTLPResource) ([]byte, error) {
+	var names []string
+	points := make(map[string][]otlpPoint)
+	for _, m := range metrics {
+		var timestamp uint64
+		if !m.Timestamp.IsZero() {
+			timestamp = uint64(m.Timestamp.UnixNano())
+		}
+		base := OTLPMetricPrefix + "." + string(m.Category) + "." + string(m.Name)
+		err := walkGaugeLeaves(base, m.Value, func(name string, v interface{}) {
+			if _, ok := points[name]; !ok {
+				names = append(names, name)
+			}
+			points[name] = append(points[name], otlpPoint{timeUnixNano: timestamp, attributes: m.Metadata, value: v})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metric %s: %w", m.Name, err)
+		}
+	}
+
+	var scopeMetrics []byte
+	scope := appendStringField(nil, otlpScopeName, resource.ScopeName)
+	scope = appendStringField(scope, otlpScopeVersion, resource.ScopeVersion)
+	scopeMetrics = appendBytesField(scopeMetrics, otlpScopeMetricsScope, scope)
+	for _, name := range names {
+		var gauge []byte
+		for _, p := range points[name] {
+			gauge = appendBytesField(gauge, otlpGaugeDataPoints, appendOTLPPoint(nil, p))
+		}
+		metric := appendStringField(nil, otlpMetricName, name)
+		metric = appendBytesField(metric, otlpMetricGauge, gauge)
+		scopeMetrics = appendBytesField(scopeMetrics, otlpScopeMetricsMetrics, metric)
+	}
+
+	resourceMetrics := appendBytesField(nil, otlpResourceMetricsResource, appendOTLPAttributes(nil, otlpResourceAttributes, resource.Attributes))
+	resourceMetrics = appendBytesField(resourceMetrics, otlpResourceMetricsScope, scopeMetrics)
+
+	return appendBytesField(nil, otlpRequestResourceMetrics, resourceMetrics), nil
+}
+
+// walkGaugeLeaves calls fn for every number or boolean in value, as an int64 or a float64,
+// extending name with map keys. Values of other types are walked as the JSON they marshal to
+func walkGaugeLeaves(name string, value interface{}, fn func(string, interface{})) error {
+	switch v := value.(type) {
+	case nil, string, []interface{}:
+	case float64:
+		fn(name, v)
+	case float32:
+		fn(name, float64(v))
+	case int:
+		fn(name, int64(v))
+	case int32:
+		fn(name, int64(v))
+	case int64:
+		fn(name, v)
+	case uint:
+		fn(name, int64(v))
+	case uint32:
+		fn(name, int64(v))
+	case uint64:
+		fn(name, int64(v))
+	case bool:
+		if v {
+			fn(name, int64(1))
+		} else {
+			fn(name, int64(0))
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			fn(name, i)
+		} else if f, err := v.Float64(); err == nil {
+			fn(name, f)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := walkGaugeLeaves(name+"."+strings.ReplaceAll(k, " ", "_"), v[k], fn); err != nil {
+				return err
+			}
+		}
+	default:
+		// Structs, typed maps and slices are walked as the JSON they marshal to
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %T value: %w", v, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var generic interface{}
+		if err := decoder.Decode(&generic); err != nil {
+			return fmt.Errorf("failed to convert %T value: %w", v, err)
+		}
+		return walkGaugeLeaves(name, generic, fn)
+	}
+	return nil
+}
+
+// appendOTLPPoint appends the NumberDataPoint message fields of p to buf
+func appendOTLPPoint(buf []byte, p otlpPoint) []byte {
+	buf = appendOTLPAttributes(buf, otlpPointAttributes, p.attributes)
+	if p.timeUnixNano > 0 {
+		buf = appendFixed64Field(buf, otlpPointTime, p.timeUnixNano)
+	}
+	if i, ok := p.value.(int64); ok {
+		return appendFixed64Field(buf, otlpPointAsInt, uint64(i))
+	}
+	return appendFixed64Field(buf, otlpPointAsDouble, math.Float64bits(p.value.(float64)))
+}
+
+// appendOTLPAttributes appends attributes as KeyValue messages in field, with sorted keys so the
+// output is deterministic
+func appendOTLPAttributes(buf []byte, field int, attributes map[string]string) []byte {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		kv := appendStringField(nil, otlpKeyValueKey, k)
+		kv = appendBytesField(kv, otlpKeyValueValue, appendBytesField(nil, otlpAnyValueString, []byte(attributes[k])))
+		buf = appendBytesField(buf, field, kv)
+	}
+	return buf
+}
@@ -0,0 +1,172 @@
+package serialization
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// decodedPoint is a NumberDataPoint read back by decodeOTLP
+type decodedPoint struct {
+	time       uint64
+	attributes map[string]string
+	value      interface{} // int64 for as_int, float64 for as_double
+}
+
+// decodeOTLP reads back the resource attributes and the gauge points by instrument name of an
+// export request, in the order the instruments were encoded
+func decodeOTLP(t *testing.T, data []byte) (map[string]string, []string, map[string][]decodedPoint) {
+	t.Helper()
+	resource := map[string]string{}
+	var names []string
+	points := map[string][]decodedPoint{}
+
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("failed to decode OTLP request: %v", err)
+		}
+	}
+	readAttribute := func(data []byte, into map[string]string) error {
+		var key, value string
+		err := readFields(data, func(f protoField) error {
+			switch f.num {
+			case otlpKeyValueKey:
+				key = string(f.data)
+			case otlpKeyValueValue:
+				return readFields(f.data, func(f protoField) error {
+					if f.num == otlpAnyValueString {
+						value = string(f.data)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		into[key] = value
+		return err
+	}
+
+	must(readFields(data, func(f protoField) error {
+		return readFields(f.data, func(f protoField) error {
+			switch f.num {
+			case otlpResourceMetricsResource:
+				return readFields(f.data, func(f protoField) error {
+					return readAttribute(f.data, resource)
+				})
+			case otlpResourceMetricsScope:
+				return readFields(f.data, func(f protoField) error {
+					if f.num != otlpScopeMetricsMetrics {
+						return nil
+					}
+					var name string
+					return readFields(f.data, func(f protoField) error {
+						switch f.num {
+						case otlpMetricName:
+							name = string(f.data)
+							names = append(names, name)
+						case otlpMetricGauge:
+							return readFields(f.data, func(f protoField) error {
+								p := decodedPoint{attributes: map[string]string{}}
+								err := readFields(f.data, func(f protoField) error {
+									switch f.num {
+									case otlpPointTime:
+										p.time = f.varint
+									case otlpPointAsInt:
+										p.value = int64(f.varint)
+									case otlpPointAsDouble:
+										p.value = math.Float64frombits(f.varint)
+									case otlpPointAttributes:
+										return readAttribute(f.data, p.attributes)
+									}
+									return nil
+								})
+								points[name] = append(points[name], p)
+								return err
+							})
+						}
+						return nil
+					})
+				})
+			}
+			return nil
+		})
+	}))
+	return resource, names, points
+}
+
+func TestSerializeOTLP(t *testing.T) {
+	now := time.Date(2024, 6, 2, 10, 30, 0, 0, time.UTC)
+	metrics := []collector.Metrics{
+		{Timestamp: now, Category: "system", Name: "cpu", Value: 12.5},
+		{
+			Timestamp: now,
+			Category:  "system",
+			Name:      "disk",
+			Metadata:  collector.MetricMetadata{"mountpoint": "/"},
+			Value:     map[string]interface{}{"percent": 40.25, "used": uint64(1024), "readonly": false, "fstype": "ext4"},
+		},
+		{
+			Timestamp: now,
+			Category:  "system",
+			Name:      "disk",
+			Metadata:  collector.MetricMetadata{"mountpoint": "/var"},
+			Value:     map[string]interface{}{"percent": 10.0, "used": uint64(2048), "readonly": true},
+		},
+		{Timestamp: now, Category: "system", Name: "user_activity", Value: []interface{}{"alice"}},
+		{Timestamp: now, Category: "system", Name: "ssh", Value: struct {
+			Count int `json:"count"`
+		}{Count: 3}},
+	}
+	resource := OTLPResource{Attributes: map[string]string{"host.name": "web-1"}, ScopeName: "probe"}
+
+	data, err := SerializeOTLP(metrics, resource)
+	if err != nil {
+		t.Fatalf("SerializeOTLP() error = %v", err)
+	}
+	gotResource, names, points := decodeOTLP(t, data)
+
+	if !reflect.DeepEqual(gotResource, resource.Attributes) {
+		t.Errorf("resource attributes = %v, want %v", gotResource, resource.Attributes)
+	}
+	wantNames := []string{
+		"monitorly.system.cpu",
+		"monitorly.system.disk.percent",
+		"monitorly.system.disk.readonly",
+		"monitorly.system.disk.used",
+		"monitorly.system.ssh.count",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("instruments = %v, want %v", names, wantNames)
+	}
+
+	ts := uint64(now.UnixNano())
+	want := map[string][]decodedPoint{
+		"monitorly.system.cpu": {{time: ts, attributes: map[string]string{}, value: 12.5}},
+		"monitorly.system.disk.percent": {
+			{time: ts, attributes: map[string]string{"mountpoint": "/"}, value: 40.25},
+			{time: ts, attributes: map[string]string{"mountpoint": "/var"}, value: 10.0},
+		},
+		"monitorly.system.disk.readonly": {
+			{time: ts, attributes: map[string]string{"mountpoint": "/"}, value: int64(0)},
+			{time: ts, attributes: map[string]string{"mountpoint": "/var"}, value: int64(1)},
+		},
+		"monitorly.system.disk.used": {
+			{time: ts, attributes: map[string]string{"mountpoint": "/"}, value: int64(1024)},
+			{time: ts, attributes: map[string]string{"mountpoint": "/var"}, value: int64(2048)},
+		},
+		"monitorly.system.ssh.count": {{time: ts, attributes: map[string]string{}, value: int64(3)}},
+	}
+	if !reflect.DeepEqual(points, want) {
+		t.Errorf("points = %+v, want %+v", points, want)
+	}
+}
+
+func TestSerializeOTLP_Errors(t *testing.T) {
+	metrics := []collector.Metrics{{Category: "system", Name: "broken", Value: make(chan int)}}
+	if _, err := SerializeOTLP(metrics, OTLPResource{}); err == nil {
+		t.Error("SerializeOTLP() error = nil, want an error for a value that can't be marshaled")
+	}
+}
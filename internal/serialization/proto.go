@@ -0,0 +1,557 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+// ProtobufContentType is the content type of payloads serialized with metrics.proto
+const ProtobufContentType = "application/x-protobuf"
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Field numbers from metrics.proto
+const (
+	payloadMetrics        = 1
+	payloadMachineName    = 2
+	payloadSequence       = 3
+	payloadSessionID      = 4
+	payloadChangedMetrics = 5
+	payloadBatchDigest    = 6
+
+	keyCategory = 1
+	keyName     = 2
+
+	metricTimestamp = 1
+	metricCategory  = 2
+	metricName      = 3
+	metricMetadata  = 4
+	metricValue     = 5
+
+	valueNull   = 1
+	valueNumber = 2
+	valueString = 3
+	valueBool   = 4
+	valueStruct = 5
+	valueList   = 6
+	valueInt    = 7
+	valueUint   = 8
+
+	structFields = 1
+	listValues   = 1
+
+	mapKey   = 1
+	mapValue = 2
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// MetricKey identifies a metric series listed in MetricsPayload.ChangedMetrics
+type MetricKey struct {
+	Category collector.MetricCategory
+	Name     collector.MetricName
+}
+
+// MetricsPayload is the envelope of a metrics request sent as protobuf, see metrics.proto
+type MetricsPayload struct {
+	Metrics        []collector.Metrics
+	MachineName    string
+	Sequence       uint64
+	SessionID      string
+	ChangedMetrics []MetricKey
+	BatchDigest    string
+}
+
+// SerializeMetricsProto converts a slice of metrics to a protobuf MetricBatch
+// Values of common types (numbers, strings, bools, maps and slices of interface{}) are encoded
+// directly, anything else as the JSON it marshals to. Integers are kept apart from floats
+func SerializeMetricsProto(metrics []collector.Metrics) ([]byte, error) {
+	return SerializePayloadProto(MetricsPayload{Metrics: metrics})
+}
+
+// SerializePayloadProto converts a metrics request payload to a protobuf MetricsPayload
+func SerializePayloadProto(p MetricsPayload) ([]byte, error) {
+	var buf []byte
+	for _, m := range p.Metrics {
+		metric, err := appendMetric(nil, m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metric %s: %w", m.Name, err)
+		}
+		buf = appendBytesField(buf, payloadMetrics, metric)
+	}
+
+	buf = appendStringField(buf, payloadMachineName, p.MachineName)
+	if p.Sequence > 0 {
+		buf = appendVarintField(buf, payloadSequence, p.Sequence)
+	}
+	buf = appendStringField(buf, payloadSessionID, p.SessionID)
+	for _, k := range p.ChangedMetrics {
+		key := appendStringField(nil, keyCategory, string(k.Category))
+		key = appendStringField(key, keyName, string(k.Name))
+		buf = appendBytesField(buf, payloadChangedMetrics, key)
+	}
+	buf = appendStringField(buf, payloadBatchDigest, p.BatchDigest)
+
+	return buf, nil
+}
+
+// DeserializeMetricsProto parses a protobuf MetricBatch, or the metrics of a MetricsPayload
+func DeserializeMetricsProto(data []byte) ([]collector.Metrics, error) {
+	p, err := DeserializePayloadProto(data)
+	if err != nil {
+		return nil, err
+	}
+	return p.Metrics, nil
+}
+
+// DeserializePayloadProto parses a protobuf MetricsPayload. Values decode to nil, bool, string,
+// float64, int64, uint64, map[string]interface{} or []interface{}
+func DeserializePayloadProto(data []byte) (MetricsPayload, error) {
+	var p MetricsPayload
+	err := readFields(data, func(f protoField) error {
+		switch f.num {
+		case payloadMetrics:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			m, err := readMetric(f.data)
+			if err != nil {
+				return err
+			}
+			p.Metrics = append(p.Metrics, m)
+		case payloadMachineName:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			p.MachineName = string(f.data)
+		case payloadSequence:
+			if err := f.expect(wireVarint); err != nil {
+				return err
+			}
+			p.Sequence = f.varint
+		case payloadSessionID:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			p.SessionID = string(f.data)
+		case payloadChangedMetrics:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			var k MetricKey
+			err := readFields(f.data, func(f protoField) error {
+				switch f.num {
+				case keyCategory:
+					k.Category = collector.MetricCategory(f.data)
+				case keyName:
+					k.Name = collector.MetricName(f.data)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			p.ChangedMetrics = append(p.ChangedMetrics, k)
+		case payloadBatchDigest:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			p.BatchDigest = string(f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return MetricsPayload{}, fmt.Errorf("failed to decode metrics payload: %w", err)
+	}
+	return p, nil
+}
+
+// appendMetric appends the Metric message fields of m to buf
+func appendMetric(buf []byte, m collector.Metrics) ([]byte, error) {
+	if !m.Timestamp.IsZero() {
+		buf = appendVarintField(buf, metricTimestamp, uint64(m.Timestamp.UnixNano()))
+	}
+	buf = appendStringField(buf, metricCategory, string(m.Category))
+	buf = appendStringField(buf, metricName, string(m.Name))
+
+	keys := make([]string, 0, len(m.Metadata))
+	for k := range m.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		entry := appendStringField(nil, mapKey, k)
+		entry = appendStringField(entry, mapValue, m.Metadata[k])
+		buf = appendBytesField(buf, metricMetadata, entry)
+	}
+
+	value, err := appendValue(nil, m.Value)
+	if err != nil {
+		return nil, err
+	}
+	return appendBytesField(buf, metricValue, value), nil
+}
+
+// appendValue appends the Value message fields of v to buf
+func appendValue(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return appendVarintField(buf, valueNull, 0), nil
+	case bool:
+		var b uint64
+		if v {
+			b = 1
+		}
+		return appendVarintField(buf, valueBool, b), nil
+	case string:
+		return appendBytesField(buf, valueString, []byte(v)), nil
+	case float64:
+		return appendFixed64Field(buf, valueNumber, math.Float64bits(v)), nil
+	case float32:
+		return appendFixed64Field(buf, valueNumber, math.Float64bits(float64(v))), nil
+	case int:
+		return appendVarintField(buf, valueInt, zigzag(int64(v))), nil
+	case int8:
+		return appendVarintField(buf, valueInt, zigzag(int64(v))), nil
+	case int16:
+		return appendVarintField(buf, valueInt, zigzag(int64(v))), nil
+	case int32:
+		return appendVarintField(buf, valueInt, zigzag(int64(v))), nil
+	case int64:
+		return appendVarintField(buf, valueInt, zigzag(v)), nil
+	case uint:
+		return appendVarintField(buf, valueUint, uint64(v)), nil
+	case uint8:
+		return appendVarintField(buf, valueUint, uint64(v)), nil
+	case uint16:
+		return appendVarintField(buf, valueUint, uint64(v)), nil
+	case uint32:
+		return appendVarintField(buf, valueUint, uint64(v)), nil
+	case uint64:
+		return appendVarintField(buf, valueUint, v), nil
+	case json.Number:
+		return appendNumber(buf, v)
+	case map[string]interface{}:
+		return appendStruct(buf, v)
+	case []interface{}:
+		return appendList(buf, v)
+	default:
+		// Structs, typed maps and slices are encoded as the JSON they marshal to
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %T value: %w", v, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var generic interface{}
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, fmt.Errorf("failed to convert %T value: %w", v, err)
+		}
+		return appendValue(buf, generic)
+	}
+}
+
+// appendNumber encodes a JSON number as an integer when it is one, a double otherwise
+func appendNumber(buf []byte, n json.Number) ([]byte, error) {
+	if i, err := n.Int64(); err == nil {
+		return appendVarintField(buf, valueInt, zigzag(i)), nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return appendVarintField(buf, valueUint, u), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q: %w", n, err)
+	}
+	return appendFixed64Field(buf, valueNumber, math.Float64bits(f)), nil
+}
+
+// appendStruct encodes a map as a Struct value, with sorted keys so the output is deterministic
+func appendStruct(buf []byte, fields map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var s []byte
+	for _, k := range keys {
+		value, err := appendValue(nil, fields[k])
+		if err != nil {
+			return nil, err
+		}
+		entry := appendStringField(nil, mapKey, k)
+		entry = appendBytesField(entry, mapValue, value)
+		s = appendBytesField(s, structFields, entry)
+	}
+	return appendBytesField(buf, valueStruct, s), nil
+}
+
+// appendList encodes a slice as a ListValue value
+func appendList(buf []byte, values []interface{}) ([]byte, error) {
+	var l []byte
+	for _, v := range values {
+		value, err := appendValue(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		l = appendBytesField(l, listValues, value)
+	}
+	return appendBytesField(buf, valueList, l), nil
+}
+
+// readMetric decodes a Metric message
+func readMetric(data []byte) (collector.Metrics, error) {
+	var m collector.Metrics
+	err := readFields(data, func(f protoField) error {
+		switch f.num {
+		case metricTimestamp:
+			if err := f.expect(wireVarint); err != nil {
+				return err
+			}
+			m.Timestamp = time.Unix(0, int64(f.varint))
+		case metricCategory:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			m.Category = collector.MetricCategory(f.data)
+		case metricName:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			m.Name = collector.MetricName(f.data)
+		case metricMetadata:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			var key, value string
+			err := readFields(f.data, func(f protoField) error {
+				switch f.num {
+				case mapKey:
+					key = string(f.data)
+				case mapValue:
+					value = string(f.data)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if m.Metadata == nil {
+				m.Metadata = collector.MetricMetadata{}
+			}
+			m.Metadata[key] = value
+		case metricValue:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			value, err := readValue(f.data)
+			if err != nil {
+				return err
+			}
+			m.Value = value
+		}
+		return nil
+	})
+	return m, err
+}
+
+// readValue decodes a Value message
+func readValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := readFields(data, func(f protoField) error {
+		var err error
+		switch f.num {
+		case valueNull:
+			value = nil
+		case valueNumber:
+			err = f.expect(wireFixed64)
+			value = math.Float64frombits(f.varint)
+		case valueString:
+			err = f.expect(wireBytes)
+			value = string(f.data)
+		case valueBool:
+			err = f.expect(wireVarint)
+			value = f.varint != 0
+		case valueStruct:
+			if err = f.expect(wireBytes); err == nil {
+				value, err = readStruct(f.data)
+			}
+		case valueList:
+			if err = f.expect(wireBytes); err == nil {
+				value, err = readList(f.data)
+			}
+		case valueInt:
+			err = f.expect(wireVarint)
+			value = unzigzag(f.varint)
+		case valueUint:
+			err = f.expect(wireVarint)
+			value = f.varint
+		}
+		return err
+	})
+	return value, err
+}
+
+// readStruct decodes the fields of a Struct message
+func readStruct(data []byte) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	err := readFields(data, func(f protoField) error {
+		if f.num != structFields {
+			return nil
+		}
+		if err := f.expect(wireBytes); err != nil {
+			return err
+		}
+
+		var key string
+		var value interface{}
+		err := readFields(f.data, func(f protoField) error {
+			var err error
+			switch f.num {
+			case mapKey:
+				key = string(f.data)
+			case mapValue:
+				value, err = readValue(f.data)
+			}
+			return err
+		})
+		fields[key] = value
+		return err
+	})
+	return fields, err
+}
+
+// readList decodes the values of a ListValue message
+func readList(data []byte) ([]interface{}, error) {
+	values := []interface{}{}
+	err := readFields(data, func(f protoField) error {
+		if f.num != listValues {
+			return nil
+		}
+		if err := f.expect(wireBytes); err != nil {
+			return err
+		}
+		value, err := readValue(f.data)
+		values = append(values, value)
+		return err
+	})
+	return values, err
+}
+
+// protoField is a decoded field: varint holds varint and fixed-size values, data length-delimited ones
+type protoField struct {
+	num    int
+	wire   int
+	varint uint64
+	data   []byte
+}
+
+// expect returns an error unless the field has the wire type of its declaration
+func (f protoField) expect(wire int) error {
+	if f.wire != wire {
+		return fmt.Errorf("field %d has wire type %d, want %d", f.num, f.wire, wire)
+	}
+	return nil
+}
+
+// readFields calls fn for each field of a message, in encoding order
+func readFields(data []byte, fn func(protoField) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		f := protoField{num: int(tag >> 3), wire: int(tag & 7)}
+		if f.num == 0 {
+			return fmt.Errorf("invalid field number 0")
+		}
+
+		switch f.wire {
+		case wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncated
+			}
+			f.varint = v
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			f.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || l > uint64(len(data)-n) {
+				return errTruncated
+			}
+			f.data = data[n : n+int(l)]
+			data = data[n+int(l):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			f.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", f.wire)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendTag(buf []byte, field, wire int) []byte {
+	return binary.AppendUvarint(buf, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendTag(buf, field, wireVarint), v)
+}
+
+func appendFixed64Field(buf []byte, field int, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(appendTag(buf, field, wireFixed64), v)
+}
+
+func appendBytesField(buf []byte, field int, b []byte) []byte {
+	buf = binary.AppendUvarint(appendTag(buf, field, wireBytes), uint64(len(b)))
+	return append(buf, b...)
+}
+
+// appendStringField omits empty strings, the proto3 default
+func appendStringField(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	return appendBytesField(buf, field, []byte(s))
+}
+
+// zigzag maps signed integers to unsigned so small negative numbers stay short (sint64)
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
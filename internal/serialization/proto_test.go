@@ -0,0 +1,201 @@
+package serialization
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monitorly-app/probe/internal/collector"
+)
+
+func TestSerializeMetricsProto_WireFormat(t *testing.T) {
+	metrics := []collector.Metrics{
+		{Category: "system", Name: "cpu", Value: 1.5},
+	}
+
+	// MetricBatch{metrics: [Metric{category: "system", name: "cpu", value: {number_value: 1.5}}]}
+	want := []byte{
+		0x0a, 0x18, // metrics, 24 bytes
+		0x12, 0x06, 's', 'y', 's', 't', 'e', 'm', // category
+		0x1a, 0x03, 'c', 'p', 'u', // name
+		0x2a, 0x09, 0x11, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f, // value.number_value
+	}
+
+	got, err := SerializeMetricsProto(metrics)
+	if err != nil {
+		t.Fatalf("SerializeMetricsProto() error = %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("SerializeMetricsProto() = % x, want % x", got, want)
+	}
+}
+
+func TestSerializeMetricsProto_RoundTrip(t *testing.T) {
+	now := time.Date(2024, 6, 2, 10, 30, 0, 123456789, time.UTC)
+
+	type portInfo struct {
+		Protocol  string `json:"protocol"`
+		LocalPort uint32 `json:"local_port"`
+		Listening bool   `json:"listening"`
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "float64", value: 75.5, want: 75.5},
+		{name: "zero float64", value: 0.0, want: 0.0},
+		{name: "float32", value: float32(0.25), want: 0.25},
+		{name: "infinity", value: math.Inf(1), want: math.Inf(1)},
+		{name: "negative int", value: -42, want: int64(-42)},
+		{name: "int64", value: int64(math.MinInt64), want: int64(math.MinInt64)},
+		{name: "uint64", value: uint64(math.MaxUint64), want: uint64(math.MaxUint64)},
+		{name: "string", value: "active", want: "active"},
+		{name: "empty string", value: "", want: ""},
+		{name: "bool", value: false, want: false},
+		{name: "nil", value: nil, want: nil},
+		{
+			name: "map",
+			value: map[string]interface{}{
+				"percent":   42.25,
+				"used":      uint64(1 << 40),
+				"ok":        true,
+				"nested":    map[string]interface{}{"error": nil},
+				"empty_map": map[string]interface{}{},
+			},
+			want: map[string]interface{}{
+				"percent":   42.25,
+				"used":      uint64(1 << 40),
+				"ok":        true,
+				"nested":    map[string]interface{}{"error": nil},
+				"empty_map": map[string]interface{}{},
+			},
+		},
+		{
+			name:  "slice",
+			value: []interface{}{1.5, "two", int64(3), []interface{}{}},
+			want:  []interface{}{1.5, "two", int64(3), []interface{}{}},
+		},
+		{
+			name:  "typed map",
+			value: map[string]int{"10.0.0.1": 3, "2001:db8::1": 1},
+			want:  map[string]interface{}{"10.0.0.1": int64(3), "2001:db8::1": int64(1)},
+		},
+		{
+			name:  "slice of structs",
+			value: []portInfo{{Protocol: "tcp", LocalPort: 22, Listening: true}},
+			want:  []interface{}{map[string]interface{}{"protocol": "tcp", "local_port": int64(22), "listening": true}},
+		},
+		{
+			name:  "struct with fractional number",
+			value: struct{ Load float64 }{Load: 0.5},
+			want:  map[string]interface{}{"Load": 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := []collector.Metrics{
+				{
+					Timestamp: now,
+					Category:  collector.CategorySystem,
+					Name:      collector.NameCPU,
+					Metadata:  collector.MetricMetadata{"label": "root", "empty": ""},
+					Value:     tt.value,
+				},
+			}
+
+			data, err := SerializeMetricsProto(metrics)
+			if err != nil {
+				t.Fatalf("SerializeMetricsProto() error = %v", err)
+			}
+			got, err := DeserializeMetricsProto(data)
+			if err != nil {
+				t.Fatalf("DeserializeMetricsProto() error = %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("DeserializeMetricsProto() returned %d metrics, want 1", len(got))
+			}
+
+			m := got[0]
+			if !m.Timestamp.Equal(now) {
+				t.Errorf("Timestamp = %v, want %v", m.Timestamp, now)
+			}
+			if m.Category != collector.CategorySystem || m.Name != collector.NameCPU {
+				t.Errorf("Category/Name = %s/%s, want %s/%s", m.Category, m.Name, collector.CategorySystem, collector.NameCPU)
+			}
+			if !reflect.DeepEqual(m.Metadata, metrics[0].Metadata) {
+				t.Errorf("Metadata = %v, want %v", m.Metadata, metrics[0].Metadata)
+			}
+			if !reflect.DeepEqual(m.Value, tt.want) {
+				t.Errorf("Value = %#v, want %#v", m.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestSerializePayloadProto_RoundTrip(t *testing.T) {
+	payload := MetricsPayload{
+		Metrics: []collector.Metrics{
+			{Timestamp: time.Unix(1717320000, 0), Category: collector.CategorySystem, Name: collector.NameCPU, Value: 12.5},
+			{Timestamp: time.Unix(1717320001, 0), Category: collector.CategorySystem, Name: collector.NameRAM, Value: 40.0},
+		},
+		MachineName: "web-1",
+		Sequence:    7,
+		SessionID:   "0123abcd",
+		ChangedMetrics: []MetricKey{
+			{Category: collector.CategorySystem, Name: collector.NameCPU},
+		},
+		BatchDigest: "deadbeef",
+	}
+
+	data, err := SerializePayloadProto(payload)
+	if err != nil {
+		t.Fatalf("SerializePayloadProto() error = %v", err)
+	}
+	got, err := DeserializePayloadProto(data)
+	if err != nil {
+		t.Fatalf("DeserializePayloadProto() error = %v", err)
+	}
+
+	if got.MachineName != payload.MachineName || got.Sequence != payload.Sequence ||
+		got.SessionID != payload.SessionID || got.BatchDigest != payload.BatchDigest {
+		t.Errorf("envelope = %+v, want %+v", got, payload)
+	}
+	if !reflect.DeepEqual(got.ChangedMetrics, payload.ChangedMetrics) {
+		t.Errorf("ChangedMetrics = %v, want %v", got.ChangedMetrics, payload.ChangedMetrics)
+	}
+	if len(got.Metrics) != 2 || got.Metrics[1].Name != collector.NameRAM || got.Metrics[1].Value != 40.0 {
+		t.Errorf("Metrics = %+v, want %+v", got.Metrics, payload.Metrics)
+	}
+
+	// A payload is a valid batch: the envelope fields are skipped
+	metrics, err := DeserializeMetricsProto(data)
+	if err != nil || len(metrics) != 2 {
+		t.Errorf("DeserializeMetricsProto() = %d metrics, %v, want 2 metrics", len(metrics), err)
+	}
+}
+
+func TestSerializeMetricsProto_Errors(t *testing.T) {
+	_, err := SerializeMetricsProto([]collector.Metrics{
+		{Category: collector.CategorySystem, Name: collector.NameCPU, Value: make(chan int)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to encode metric cpu") {
+		t.Errorf("SerializeMetricsProto() error = %v, want an encode error", err)
+	}
+
+	data, err := SerializeMetricsProto([]collector.Metrics{{Category: collector.CategorySystem, Name: collector.NameCPU, Value: "x"}})
+	if err != nil {
+		t.Fatalf("SerializeMetricsProto() error = %v", err)
+	}
+	if _, err := DeserializeMetricsProto(data[:len(data)-1]); err == nil {
+		t.Error("DeserializeMetricsProto() of a truncated message succeeded, want an error")
+	}
+	if _, err := DeserializeMetricsProto([]byte{0x0d, 0, 0, 0, 0}); err == nil {
+		t.Error("DeserializeMetricsProto() of a mistyped metrics field succeeded, want an error")
+	}
+}
@@ -0,0 +1,114 @@
+package version
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// checksumSuffix is the suffix of the release asset holding the SHA-256 checksum of another asset
+const checksumSuffix = ".sha256"
+
+// findChecksum returns the SHA-256 checksum published for assetName in the release, from a
+// <asset>.sha256 asset or, failing that, from a "<checksum>  <asset>" line in the release notes
+func findChecksum(release *GitHubRelease, assetName string) (string, error) {
+	for _, asset := range release.Assets {
+		if asset.Name == assetName+checksumSuffix {
+			data, err := fetchReleaseFile(asset.BrowserDownloadURL)
+			if err != nil {
+				return "", fmt.Errorf("failed to download %s: %w", asset.Name, err)
+			}
+			// sha256sum output names the file after the checksum, a bare checksum is accepted too
+			if checksum, ok := parseChecksum(data, assetName, true); ok {
+				return checksum, nil
+			}
+			return "", fmt.Errorf("%s does not contain a SHA-256 checksum", asset.Name)
+		}
+	}
+
+	if checksum, ok := parseChecksum(release.Body, assetName, false); ok {
+		return checksum, nil
+	}
+	return "", fmt.Errorf("no SHA-256 checksum published for %s", assetName)
+}
+
+// parseChecksum finds the checksum of assetName in sha256sum formatted text
+// When bare is set, a line holding only a checksum is accepted as the checksum of assetName
+func parseChecksum(text, assetName string, bare bool) (string, bool) {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !isSHA256(fields[0]) {
+			continue
+		}
+		if len(fields) == 1 && bare {
+			return strings.ToLower(fields[0]), true
+		}
+		// sha256sum marks binary mode files with a leading *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == assetName {
+			return strings.ToLower(fields[0]), true
+		}
+	}
+	return "", false
+}
+
+// isSHA256 reports whether s is a hex encoded SHA-256 digest
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// fetchReleaseFile downloads a small release asset such as a checksum or signature file
+func fetchReleaseFile(url string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Monitorly-Probe/"+Version)
+
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	return string(data), nil
+}
+
+// verifyChecksum checks that the SHA-256 checksum of the file at path is expected
+func verifyChecksum(path, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded binary: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read downloaded binary: %w", err)
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != strings.ToLower(expected) {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", actual, expected)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMockBinary writes a fake downloaded binary and returns its path and SHA-256 checksum
+func writeMockBinary(t *testing.T) (string, string) {
+	t.Helper()
+	content := []byte("mock binary content")
+	path := filepath.Join(t.TempDir(), "mock-binary")
+	if err := os.WriteFile(path, content, 0755); err != nil {
+		t.Fatalf("failed to write mock binary: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestFindChecksum(t *testing.T) {
+	const assetName = "monitorly-probe-2.0.0-linux-amd64"
+	checksum := strings.Repeat("ab", 32)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sha256sum":
+			fmt.Fprintf(w, "%s *%s\n", checksum, assetName)
+		case "/bare":
+			fmt.Fprintln(w, strings.ToUpper(checksum))
+		case "/garbage":
+			fmt.Fprintln(w, "not a checksum")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	release := func(body string, assets ...string) *GitHubRelease {
+		r := &GitHubRelease{TagName: "v2.0.0", Body: body}
+		for _, path := range assets {
+			r.Assets = append(r.Assets, struct {
+				Name               string `json:"name"`
+				BrowserDownloadURL string `json:"browser_download_url"`
+			}{Name: assetName + checksumSuffix, BrowserDownloadURL: server.URL + path})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		release     *GitHubRelease
+		want        string
+		errContains string
+	}{
+		{
+			name:    "sha256sum asset",
+			release: release("", "/sha256sum"),
+			want:    checksum,
+		},
+		{
+			name:    "bare checksum asset",
+			release: release("", "/bare"),
+			want:    checksum,
+		},
+		{
+			name:        "checksum asset without checksum",
+			release:     release("", "/garbage"),
+			errContains: "does not contain a SHA-256 checksum",
+		},
+		{
+			name:        "checksum asset download fails",
+			release:     release("", "/missing"),
+			errContains: "unexpected status code: 404",
+		},
+		{
+			name: "release body",
+			release: release(fmt.Sprintf("## Checksums\n\n%s  monitorly-probe-2.0.0-linux-arm64\n%s  %s\n",
+				strings.Repeat("cd", 32), checksum, assetName)),
+			want: checksum,
+		},
+		{
+			name:        "release body lists other assets only",
+			release:     release(fmt.Sprintf("%s  monitorly-probe-2.0.0-linux-arm64\n", checksum)),
+			errContains: "no SHA-256 checksum published",
+		},
+		{
+			name:        "no checksum",
+			release:     release("Bug fixes"),
+			errContains: "no SHA-256 checksum published",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findChecksum(tt.release, assetName)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("findChecksum() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findChecksum() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("findChecksum() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path, checksum := writeMockBinary(t)
+
+	if err := verifyChecksum(path, checksum); err != nil {
+		t.Errorf("verifyChecksum() with matching checksum error = %v", err)
+	}
+	if err := verifyChecksum(path, strings.ToUpper(checksum)); err != nil {
+		t.Errorf("verifyChecksum() with uppercase checksum error = %v", err)
+	}
+	if err := verifyChecksum(path, strings.Repeat("0", 64)); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("verifyChecksum() with wrong checksum error = %v, want checksum mismatch", err)
+	}
+	if err := verifyChecksum(filepath.Join(t.TempDir(), "missing"), checksum); err == nil {
+		t.Error("verifyChecksum() with missing file expected error")
+	}
+}
@@ -0,0 +1,127 @@
+package version
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of an UpdateEvent
+const (
+	UpdateStatusSuccess    = "success"
+	UpdateStatusFailed     = "failed"
+	UpdateStatusRolledBack = "rolled_back" // The new version failed its post-update check and was replaced by the old one
+)
+
+var (
+	// notifyTimeout bounds a webhook notification, so an unreachable webhook delays an update by
+	// this long at most
+	notifyTimeout = 5 * time.Second
+
+	// updateWebhook is the webhook set with SetUpdateWebhook, nil when update events aren't notified
+	updateWebhook atomic.Pointer[webhookTarget]
+)
+
+// webhookTarget is where and as which host update events are notified
+type webhookTarget struct {
+	url      string
+	hostname string
+}
+
+// UpdateEvent is the JSON payload posted to the update webhook after each update attempt
+type UpdateEvent struct {
+	Hostname   string `json:"hostname"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	Status     string `json:"status"`          // UpdateStatusSuccess, UpdateStatusFailed or UpdateStatusRolledBack
+	Error      string `json:"error,omitempty"` // Why the update failed
+}
+
+// SetUpdateWebhook makes SelfUpdate post an UpdateEvent for hostname to webhookURL after each
+// update attempt, and RunPostUpdateCheck after each rollback. An empty webhookURL disables
+// notifications
+func SetUpdateWebhook(webhookURL, hostname string) error {
+	if webhookURL == "" {
+		updateWebhook.Store(nil)
+		return nil
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL: %s", webhookURL)
+	}
+	updateWebhook.Store(&webhookTarget{url: webhookURL, hostname: hostname})
+	return nil
+}
+
+// notifyUpdate posts the outcome of the update from the running version to newVersion to the
+// update webhook, if one is set. Notification failures are only logged, they never fail the update
+func notifyUpdate(newVersion string, updateErr error) {
+	event := UpdateEvent{
+		OldVersion: GetVersion(),
+		NewVersion: newVersion,
+		Status:     UpdateStatusSuccess,
+	}
+	if updateErr != nil {
+		event.Status = UpdateStatusFailed
+		event.Error = updateErr.Error()
+	}
+	notify(event)
+}
+
+// notifyRollback posts to the update webhook, if one is set, that the update from previous to the
+// running version failed its post-update check. status is UpdateStatusRolledBack when previous was
+// restored and UpdateStatusFailed when restoring it failed too
+func notifyRollback(previous, status string, rollbackErr error) {
+	notify(UpdateEvent{
+		OldVersion: previous,
+		NewVersion: GetVersion(),
+		Status:     status,
+		Error:      rollbackErr.Error(),
+	})
+}
+
+// notify posts event for the configured host to the update webhook, if one is set
+func notify(event UpdateEvent) {
+	target := updateWebhook.Load()
+	if target == nil {
+		return
+	}
+
+	event.Hostname = target.hostname
+	if err := postUpdateEvent(target.url, event); err != nil {
+		log.Printf("Failed to notify update webhook: %v", err)
+	}
+}
+
+// postUpdateEvent posts event as JSON to webhookURL
+func postUpdateEvent(webhookURL string, event UpdateEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode update event: %w", err)
+	}
+
+	client := newHTTPClient()
+	client.Timeout = notifyTimeout
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Monitorly-Probe/"+Version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send update event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package version
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestSetUpdateWebhook(t *testing.T) {
+	defer updateWebhook.Store(nil)
+
+	if err := SetUpdateWebhook("hooks.example.com/probe", "web-1"); err == nil {
+		t.Error("SetUpdateWebhook() accepted a URL without a scheme")
+	}
+	if err := SetUpdateWebhook("https://hooks.example.com/probe", "web-1"); err != nil {
+		t.Errorf("SetUpdateWebhook() error = %v", err)
+	}
+	if target := updateWebhook.Load(); target == nil || target.hostname != "web-1" {
+		t.Errorf("SetUpdateWebhook() target = %+v, want hostname web-1", target)
+	}
+	if err := SetUpdateWebhook("", ""); err != nil || updateWebhook.Load() != nil {
+		t.Errorf("SetUpdateWebhook(\"\") error = %v, want notifications disabled", err)
+	}
+}
+
+func TestSelfUpdateNotifiesWebhook(t *testing.T) {
+	originalVersion := Version
+	originalURL := GitHubAPIReleaseURL
+	originalDownloadBinary := downloadBinaryFunc
+	originalReplaceBinary := replaceBinaryFunc
+	originalGetOS := getOS
+	originalGetArch := getArch
+	defer func() {
+		Version = originalVersion
+		GitHubAPIReleaseURL = originalURL
+		downloadBinaryFunc = originalDownloadBinary
+		replaceBinaryFunc = originalReplaceBinary
+		getOS = originalGetOS
+		getArch = originalGetArch
+		updateWebhook.Store(nil)
+	}()
+
+	Version = "v1.0.0"
+	getOS = func() string { return "linux" }
+	getArch = func() string { return runtime.GOARCH }
+
+	assetName := fmt.Sprintf("monitorly-probe-2.0.0-linux-%s", runtime.GOARCH)
+	_, checksum := writeMockBinary(t)
+	release := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := GitHubRelease{
+			TagName: "v2.0.0",
+			Body:    fmt.Sprintf("%s  %s", checksum, assetName),
+		}
+		response.Assets = append(response.Assets, struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		}{Name: assetName, BrowserDownloadURL: "https://example.com/download"})
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer release.Close()
+	GitHubAPIReleaseURL = release.URL
+
+	tests := []struct {
+		name          string
+		replaceErr    error
+		webhookStatus int
+		wantErr       bool
+		wantStatus    string
+	}{
+		{name: "successful update", webhookStatus: http.StatusOK, wantStatus: UpdateStatusSuccess},
+		{name: "failed update", replaceErr: errors.New("disk full"), webhookStatus: http.StatusOK, wantErr: true, wantStatus: UpdateStatusFailed},
+		{name: "webhook failure doesn't fail the update", webhookStatus: http.StatusInternalServerError, wantStatus: UpdateStatusSuccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []UpdateEvent
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("webhook request = %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+				}
+				var event UpdateEvent
+				if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+					t.Errorf("failed to decode update event: %v", err)
+				}
+				events = append(events, event)
+				w.WriteHeader(tt.webhookStatus)
+			}))
+			defer webhook.Close()
+			if err := SetUpdateWebhook(webhook.URL, "web-1"); err != nil {
+				t.Fatalf("SetUpdateWebhook() error = %v", err)
+			}
+
+			downloadPath, _ := writeMockBinary(t)
+			downloadBinaryFunc = func(url string) (string, error) {
+				return downloadPath, nil
+			}
+			replaceBinaryFunc = func(newBinaryPath string) error {
+				return tt.replaceErr
+			}
+
+			if err := SelfUpdate(); (err != nil) != tt.wantErr {
+				t.Errorf("SelfUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(events) != 1 {
+				t.Fatalf("webhook received %d events, want 1", len(events))
+			}
+			event := events[0]
+			if event.Hostname != "web-1" || event.OldVersion != "v1.0.0" || event.NewVersion != "v2.0.0" || event.Status != tt.wantStatus {
+				t.Errorf("update event = %+v, want web-1 from v1.0.0 to v2.0.0 with status %s", event, tt.wantStatus)
+			}
+			if (event.Error != "") != (tt.replaceErr != nil) {
+				t.Errorf("update event error = %q, want one only for a failed update", event.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// postUpdateCheckSuffix names the sentinel file, next to the executable, left by replaceBinary
+	// until the new binary passes its post-update check. It holds the version that was replaced
+	postUpdateCheckSuffix = ".post-update-check"
+
+	// postUpdateStartedSuffix names the sentinel once its check started, so a check that crashes
+	// the probe is counted as failed on the next start
+	postUpdateStartedSuffix = ".post-update-check.started"
+)
+
+// markPendingUpdate leaves the sentinel asking the next start of the binary at execPath to check
+// itself, recording previous as the version it replaced
+func markPendingUpdate(execPath, previous string) error {
+	_ = os.Remove(execPath + postUpdateStartedSuffix)
+	return os.WriteFile(execPath+postUpdateCheckSuffix, []byte(previous+"\n"), 0644)
+}
+
+// RunPostUpdateCheck gates a freshly installed update: when the running binary was installed by
+// an update that wasn't checked yet, it runs check and keeps the update if check succeeds.
+// Otherwise, or if a previous run of the check never finished, the backup binary is restored, the
+// rollback is posted to the update webhook and an error is returned, so the caller exits and the service manager restarts the previous version.
+// Without a pending update check isn't run and nil is returned
+func RunPostUpdateCheck(check func() error) error {
+	execPath, err := osExecutable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if execPath, err = filepath.EvalSymlinks(execPath); err != nil {
+		return fmt.Errorf("failed to resolve symlinks: %w", err)
+	}
+	pendingPath := execPath + postUpdateCheckSuffix
+	startedPath := execPath + postUpdateStartedSuffix
+
+	if previous, err := os.ReadFile(startedPath); err == nil {
+		return rollbackUpdate(execPath, strings.TrimSpace(string(previous)), errors.New("the previous post-update check did not finish"))
+	}
+
+	previous, err := os.ReadFile(pendingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read post-update check sentinel: %w", err)
+	}
+	previousVersion := strings.TrimSpace(string(previous))
+
+	if err := os.Rename(pendingPath, startedPath); err != nil {
+		return fmt.Errorf("failed to start post-update check: %w", err)
+	}
+	log.Printf("Running post-update check of %s (updated from %s)", GetVersion(), previousVersion)
+	if err := check(); err != nil {
+		return rollbackUpdate(execPath, previousVersion, err)
+	}
+
+	if err := os.Remove(startedPath); err != nil {
+		log.Printf("Failed to remove post-update check sentinel: %v", err)
+	}
+	log.Printf("Post-update check passed, keeping %s", GetVersion())
+	return nil
+}
+
+// rollbackUpdate restores the backup binary kept by replaceBinary over execPath after the update
+// from previous failed its check with checkErr, and notifies the update webhook of the rollback
+func rollbackUpdate(execPath, previous string, checkErr error) error {
+	_ = os.Remove(execPath + postUpdateStartedSuffix)
+	if err := os.Rename(execPath+".bak", execPath); err != nil {
+		err = fmt.Errorf("post-update check failed (%v) and restoring %s failed: %w", checkErr, previous, err)
+		notifyRollback(previous, UpdateStatusFailed, err)
+		return err
+	}
+	err := fmt.Errorf("post-update check failed, %s restored: %w", previous, checkErr)
+	notifyRollback(previous, UpdateStatusRolledBack, err)
+	return err
+}
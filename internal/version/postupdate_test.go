@@ -0,0 +1,151 @@
+package version
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPostUpdateCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		sentinel    string // Sentinel suffix present before the check, none when empty
+		checkErr    error
+		wantCalled  bool
+		errContains string
+		wantContent string // Content of the executable afterwards
+	}{
+		{name: "no pending update", wantContent: "new binary"},
+		{name: "check passes", sentinel: postUpdateCheckSuffix, wantCalled: true, wantContent: "new binary"},
+		{
+			name:        "check fails",
+			sentinel:    postUpdateCheckSuffix,
+			checkErr:    errors.New("collection timed out"),
+			wantCalled:  true,
+			errContains: "v1.0.0 restored: collection timed out",
+			wantContent: "old binary",
+		},
+		{
+			name:        "previous check crashed",
+			sentinel:    postUpdateStartedSuffix,
+			errContains: "did not finish",
+			wantContent: "old binary",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execPath := filepath.Join(t.TempDir(), "monitorly-probe")
+			if err := os.WriteFile(execPath, []byte("new binary"), 0755); err != nil {
+				t.Fatalf("Failed to write executable: %v", err)
+			}
+			if err := os.WriteFile(execPath+".bak", []byte("old binary"), 0755); err != nil {
+				t.Fatalf("Failed to write backup: %v", err)
+			}
+			if tt.sentinel != "" {
+				if err := os.WriteFile(execPath+tt.sentinel, []byte("v1.0.0\n"), 0644); err != nil {
+					t.Fatalf("Failed to write sentinel: %v", err)
+				}
+			}
+
+			oldExec := osExecutable
+			osExecutable = func() (string, error) { return execPath, nil }
+			defer func() { osExecutable = oldExec }()
+
+			called := false
+			err := RunPostUpdateCheck(func() error {
+				called = true
+				return tt.checkErr
+			})
+
+			if called != tt.wantCalled {
+				t.Errorf("RunPostUpdateCheck() ran the check: %v, want %v", called, tt.wantCalled)
+			}
+			if tt.errContains == "" && err != nil {
+				t.Errorf("RunPostUpdateCheck() error = %v, want nil", err)
+			}
+			if tt.errContains != "" && (err == nil || !strings.Contains(err.Error(), tt.errContains)) {
+				t.Errorf("RunPostUpdateCheck() error = %v, want it to contain %q", err, tt.errContains)
+			}
+			if content, _ := os.ReadFile(execPath); string(content) != tt.wantContent {
+				t.Errorf("executable content = %q, want %q", content, tt.wantContent)
+			}
+			for _, suffix := range []string{postUpdateCheckSuffix, postUpdateStartedSuffix} {
+				if _, err := os.Stat(execPath + suffix); err == nil {
+					t.Errorf("RunPostUpdateCheck() left %s", suffix)
+				}
+			}
+		})
+	}
+}
+
+func TestRunPostUpdateCheckNotifiesRollback(t *testing.T) {
+	originalVersion := Version
+	oldExec := osExecutable
+	defer func() {
+		Version = originalVersion
+		osExecutable = oldExec
+		updateWebhook.Store(nil)
+	}()
+	Version = "v2.0.0"
+
+	tests := []struct {
+		name       string
+		withBackup bool
+		wantStatus string
+	}{
+		{name: "previous version restored", withBackup: true, wantStatus: UpdateStatusRolledBack},
+		{name: "restore fails", withBackup: false, wantStatus: UpdateStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []UpdateEvent
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var event UpdateEvent
+				if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+					t.Errorf("Failed to decode update event: %v", err)
+				}
+				events = append(events, event)
+			}))
+			defer webhook.Close()
+			if err := SetUpdateWebhook(webhook.URL, "web-1"); err != nil {
+				t.Fatalf("SetUpdateWebhook() error = %v", err)
+			}
+
+			execPath := filepath.Join(t.TempDir(), "monitorly-probe")
+			if err := os.WriteFile(execPath, []byte("new binary"), 0755); err != nil {
+				t.Fatalf("Failed to write executable: %v", err)
+			}
+			if tt.withBackup {
+				if err := os.WriteFile(execPath+".bak", []byte("old binary"), 0755); err != nil {
+					t.Fatalf("Failed to write backup: %v", err)
+				}
+			}
+			if err := os.WriteFile(execPath+postUpdateCheckSuffix, []byte("v1.0.0\n"), 0644); err != nil {
+				t.Fatalf("Failed to write sentinel: %v", err)
+			}
+			osExecutable = func() (string, error) { return execPath, nil }
+
+			if err := RunPostUpdateCheck(func() error { return errors.New("collection timed out") }); err == nil {
+				t.Fatal("RunPostUpdateCheck() error = nil, want the check failure")
+			}
+
+			if len(events) != 1 {
+				t.Fatalf("webhook received %d events, want 1", len(events))
+			}
+			event := events[0]
+			if event.Status != tt.wantStatus || event.OldVersion != "v1.0.0" || event.NewVersion != "v2.0.0" || event.Hostname != "web-1" {
+				t.Errorf("update event = %+v, want status %s from v1.0.0 to v2.0.0 for web-1", event, tt.wantStatus)
+			}
+			if !strings.Contains(event.Error, "collection timed out") {
+				t.Errorf("update event error = %q, want the check failure", event.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package version
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// signatureSuffix is the suffix of the release asset holding the detached signature of another asset
+const signatureSuffix = ".sig"
+
+// signingKey is the key set with SetSignatureKey, nil when update signatures aren't verified
+var signingKey atomic.Pointer[crypto.PublicKey]
+
+// SetSignatureKey makes SelfUpdate verify the detached signature of the downloaded binary against key,
+// refusing to install it when the signature is missing or invalid. The key is a PEM encoded public key,
+// or its base64 encoded DER as in ReleasePublicKey. ECDSA (e.g. cosign) and Ed25519 keys are supported.
+// An empty key disables signature verification
+func SetSignatureKey(key []byte) error {
+	if len(strings.TrimSpace(string(key))) == 0 {
+		signingKey.Store(nil)
+		return nil
+	}
+
+	publicKey, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	signingKey.Store(&publicKey)
+	return nil
+}
+
+// parsePublicKey parses a PEM or base64 encoded PKIX public key
+func parsePublicKey(key []byte) (crypto.PublicKey, error) {
+	der := key
+	if block, _ := pem.Decode(key); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(key)))
+		if err != nil {
+			return nil, fmt.Errorf("public key is neither PEM nor base64 encoded")
+		}
+		der = decoded
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T, must be ECDSA or Ed25519", publicKey)
+	}
+}
+
+// findSignature downloads the detached signature published for assetName as a <asset>.sig asset
+func findSignature(release *GitHubRelease, assetName string) ([]byte, error) {
+	for _, asset := range release.Assets {
+		if asset.Name == assetName+signatureSuffix {
+			data, err := fetchReleaseFile(asset.BrowserDownloadURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+			}
+			return decodeSignature(data), nil
+		}
+	}
+	return nil, fmt.Errorf("no signature published for %s", assetName)
+}
+
+// decodeSignature returns the raw signature from a signature file, which cosign writes base64 encoded
+func decodeSignature(data string) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data)); err == nil {
+		return decoded
+	}
+	return []byte(data)
+}
+
+// verifySignature checks that signature is a valid signature of the file at path by key
+// ECDSA signatures are ASN.1 encoded and made over the SHA-256 digest of the file, as with
+// cosign sign-blob. Ed25519 signatures are made over the file itself
+func verifySignature(path string, signature []byte, key crypto.PublicKey) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read downloaded binary: %w", err)
+	}
+
+	var valid bool
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(k, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, data, signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package version
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// publicKeyPEM returns the PEM encoding of a public key
+func publicKeyPEM(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestSetSignatureKey(t *testing.T) {
+	defer signingKey.Store(nil)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecDER, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+
+	tests := []struct {
+		name        string
+		key         []byte
+		wantKey     bool
+		errContains string
+	}{
+		{name: "ECDSA PEM", key: publicKeyPEM(t, &ecKey.PublicKey), wantKey: true},
+		{name: "Ed25519 PEM", key: publicKeyPEM(t, edKey), wantKey: true},
+		{name: "base64 DER", key: []byte(base64.StdEncoding.EncodeToString(ecDER)), wantKey: true},
+		{name: "empty disables verification", key: nil},
+		{name: "RSA unsupported", key: publicKeyPEM(t, &rsaKey.PublicKey), errContains: "unsupported public key type"},
+		{name: "garbage", key: []byte("not a key!"), errContains: "neither PEM nor base64"},
+		{name: "invalid DER", key: []byte(base64.StdEncoding.EncodeToString([]byte("junk"))), errContains: "failed to parse public key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetSignatureKey(tt.key)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("SetSignatureKey() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetSignatureKey() error = %v", err)
+			}
+			if got := signingKey.Load() != nil; got != tt.wantKey {
+				t.Errorf("SetSignatureKey() key set = %v, want %v", got, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	path, _ := writeMockBinary(t)
+	content, _ := os.ReadFile(path)
+	digest := sha256.Sum256(content)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSignature, _ := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	edSignature := ed25519.Sign(edPrivate, content)
+
+	if err := verifySignature(path, ecSignature, &ecKey.PublicKey); err != nil {
+		t.Errorf("verifySignature() ECDSA error = %v", err)
+	}
+	if err := verifySignature(path, edSignature, edPublic); err != nil {
+		t.Errorf("verifySignature() Ed25519 error = %v", err)
+	}
+	if err := verifySignature(path, ecSignature, &otherKey.PublicKey); err == nil {
+		t.Error("verifySignature() with the wrong key expected error")
+	}
+	if err := verifySignature(path, edSignature[:10], edPublic); err == nil {
+		t.Error("verifySignature() with a truncated signature expected error")
+	}
+
+	// cosign writes signatures base64 encoded, raw signatures are accepted too
+	if got := decodeSignature(base64.StdEncoding.EncodeToString(ecSignature) + "\n"); string(got) != string(ecSignature) {
+		t.Error("decodeSignature() didn't decode a base64 signature")
+	}
+	if got := decodeSignature(string(edSignature)); string(got) != string(edSignature) {
+		t.Error("decodeSignature() changed a raw signature")
+	}
+}
+
+func TestSelfUpdateSignature(t *testing.T) {
+	originalVersion := Version
+	originalURL := GitHubAPIReleaseURL
+	originalDownloadBinary := downloadBinaryFunc
+	originalReplaceBinary := replaceBinaryFunc
+	originalGetOS := getOS
+	originalGetArch := getArch
+	defer func() {
+		Version = originalVersion
+		GitHubAPIReleaseURL = originalURL
+		downloadBinaryFunc = originalDownloadBinary
+		replaceBinaryFunc = originalReplaceBinary
+		getOS = originalGetOS
+		getArch = originalGetArch
+		signingKey.Store(nil)
+	}()
+
+	Version = "v1.0.0"
+	getOS = func() string { return "linux" }
+	getArch = func() string { return runtime.GOARCH }
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err := SetSignatureKey(publicKeyPEM(t, &key.PublicKey)); err != nil {
+		t.Fatalf("SetSignatureKey() error = %v", err)
+	}
+
+	assetName := fmt.Sprintf("monitorly-probe-2.0.0-linux-%s", runtime.GOARCH)
+	binaryPath, checksum := writeMockBinary(t)
+	content, _ := os.ReadFile(binaryPath)
+	digest := sha256.Sum256(content)
+	validSignature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	forgedSignature, _ := ecdsa.SignASN1(rand.Reader, otherKey, digest[:])
+
+	tests := []struct {
+		name         string
+		signature    []byte // Served as the .sig asset, no asset when nil
+		wantReplaced bool
+		errContains  string
+	}{
+		{name: "valid signature", signature: validSignature, wantReplaced: true},
+		{name: "signature from another key", signature: forgedSignature, errContains: "failed to verify binary signature"},
+		{name: "no signature published", errContains: "no signature published"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/sig" {
+					fmt.Fprintln(w, base64.StdEncoding.EncodeToString(tt.signature))
+					return
+				}
+				release := GitHubRelease{
+					TagName: "v2.0.0",
+					Body:    fmt.Sprintf("%s  %s", checksum, assetName),
+				}
+				release.Assets = append(release.Assets, struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+				}{Name: assetName, BrowserDownloadURL: server.URL + "/binary"})
+				if tt.signature != nil {
+					release.Assets = append(release.Assets, struct {
+						Name               string `json:"name"`
+						BrowserDownloadURL string `json:"browser_download_url"`
+					}{Name: assetName + signatureSuffix, BrowserDownloadURL: server.URL + "/sig"})
+				}
+				json.NewEncoder(w).Encode(release)
+			}))
+			defer server.Close()
+			GitHubAPIReleaseURL = server.URL
+
+			// The mock binary is removed when verification fails, each run gets its own copy
+			downloadPath, _ := writeMockBinary(t)
+			downloadBinaryFunc = func(url string) (string, error) {
+				return downloadPath, nil
+			}
+			replaced := false
+			replaceBinaryFunc = func(newBinaryPath string) error {
+				replaced = true
+				return nil
+			}
+
+			err := SelfUpdate()
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("SelfUpdate() error = %v, want error containing %q", err, tt.errContains)
+				}
+			} else if err != nil {
+				t.Errorf("SelfUpdate() error = %v", err)
+			}
+			if replaced != tt.wantReplaced {
+				t.Errorf("SelfUpdate() replaced binary = %v, want %v", replaced, tt.wantReplaced)
+			}
+		})
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	goversion "github.com/hashicorp/go-version"
@@ -46,12 +50,71 @@ var (
 
 	// replaceBinaryFunc is a variable to allow mocking replaceBinary in tests
 	replaceBinaryFunc = replaceBinary
+
+	// verifyBinaryFunc is a variable to allow mocking verifyBinary in tests
+	verifyBinaryFunc = verifyBinary
+
+	// httpProxy is the proxy set with SetProxy, nil to use the proxy from the environment
+	httpProxy atomic.Pointer[url.URL]
+
+	// releaseURL is the latest release URL set with SetReleaseChannel, nil to use GitHubAPIReleaseURL
+	releaseURL atomic.Pointer[string]
+
+	// includePrereleases makes updates consider prereleases, set with SetReleaseChannel
+	includePrereleases atomic.Bool
 )
 
+// SetProxy routes update requests through proxyURL (http, https or socks5) instead of the proxy
+// from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. An empty proxyURL
+// restores the proxy from the environment
+func SetProxy(proxyURL string) error {
+	if proxyURL == "" {
+		httpProxy.Store(nil)
+		return nil
+	}
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	httpProxy.Store(proxy)
+	return nil
+}
+
+// SetReleaseChannel points updates at the latest release URL of another repository, e.g.
+// https://api.github.com/repos/OWNER/REPO/releases/latest for a fork. An empty latestURL restores
+// GitHubAPIReleaseURL. With includePrereleases, the release list next to it is searched for the
+// highest version instead, so beta releases are installed as well
+func SetReleaseChannel(latestURL string, prereleases bool) error {
+	if latestURL == "" {
+		releaseURL.Store(nil)
+	} else {
+		u, err := url.Parse(latestURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid release URL: %s", latestURL)
+		}
+		releaseURL.Store(&latestURL)
+	}
+	includePrereleases.Store(prereleases)
+	return nil
+}
+
+// newHTTPClient returns a client for update requests using the configured proxy
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy := httpProxy.Load(); proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Transport: transport}
+}
+
 // GitHubRelease represents the GitHub API response for a release
 type GitHubRelease struct {
-	TagName string `json:"tag_name"`
-	Assets  []struct {
+	TagName    string `json:"tag_name"`
+	Body       string `json:"body"` // Release notes, which may list the checksums of the assets
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	Assets     []struct {
 		Name               string `json:"name"`
 		BrowserDownloadURL string `json:"browser_download_url"`
 	} `json:"assets"`
@@ -81,45 +144,19 @@ func CheckForUpdates() (bool, string, error) {
 
 // GetLatestVersionFromGitHub fetches the latest version from GitHub
 func GetLatestVersionFromGitHub() (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, GitHubAPIReleaseURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "Monitorly-Probe/"+Version)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	release, err := getLatestReleaseInfo()
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	var release GitHubRelease
-	if err := json.Unmarshal(body, &release); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return "", err
 	}
 
 	// Return the tag name (version)
 	return release.TagName, nil
 }
 
-// SelfUpdate updates the application to the latest version
+// SelfUpdate updates the application to the latest version. The outcome of an attempted update is
+// posted to the webhook set with SetUpdateWebhook
 func SelfUpdate() error {
-	updateAvailable, _, err := CheckForUpdates()
+	updateAvailable, latestVersion, err := CheckForUpdates()
 	if err != nil {
 		return fmt.Errorf("failed to check for updates: %w", err)
 	}
@@ -128,6 +165,14 @@ func SelfUpdate() error {
 		return nil // No update needed
 	}
 
+	err = installLatestRelease()
+	notifyUpdate(latestVersion, err)
+	return err
+}
+
+// installLatestRelease downloads, verifies and installs the binary of the latest release
+func installLatestRelease() error {
+
 	// Get the latest release information
 	release, err := getLatestReleaseInfo()
 	if err != nil {
@@ -135,17 +180,44 @@ func SelfUpdate() error {
 	}
 
 	// Find the appropriate asset for the current OS and architecture
-	assetURL, err := findAppropriateAsset(release)
+	assetName, assetURL, err := findAsset(release)
 	if err != nil {
 		return fmt.Errorf("failed to find appropriate asset: %w", err)
 	}
 
+	// Get the published checksum before downloading, an unverifiable release is never installed
+	checksum, err := findChecksum(release, assetName)
+	if err != nil {
+		return fmt.Errorf("failed to get checksum: %w", err)
+	}
+
+	// With a signing key, only a release signed by its owner is installed
+	key := signingKey.Load()
+	var signature []byte
+	if key != nil {
+		if signature, err = findSignature(release, assetName); err != nil {
+			return fmt.Errorf("failed to get signature: %w", err)
+		}
+	}
+
 	// Download the new binary
 	newBinaryPath, err := downloadBinaryFunc(assetURL)
 	if err != nil {
 		return fmt.Errorf("failed to download binary: %w", err)
 	}
 
+	// Make sure the download is complete and untampered before it replaces the running binary
+	if err := verifyChecksum(newBinaryPath, checksum); err != nil {
+		os.Remove(newBinaryPath)
+		return fmt.Errorf("failed to verify binary: %w", err)
+	}
+	if key != nil {
+		if err := verifySignature(newBinaryPath, signature, *key); err != nil {
+			os.Remove(newBinaryPath)
+			return fmt.Errorf("failed to verify binary signature: %w", err)
+		}
+	}
+
 	// Replace the current binary
 	if err := replaceBinaryFunc(newBinaryPath); err != nil {
 		return fmt.Errorf("failed to replace binary: %w", err)
@@ -155,60 +227,123 @@ func SelfUpdate() error {
 }
 
 // getLatestReleaseInfo fetches detailed information about the latest release
+// When prereleases are included, the release with the highest version is picked from the release list
 func getLatestReleaseInfo() (*GitHubRelease, error) {
+	latestURL := GitHubAPIReleaseURL
+	if u := releaseURL.Load(); u != nil {
+		latestURL = *u
+	}
+
+	if !includePrereleases.Load() {
+		var release GitHubRelease
+		if err := fetchReleaseJSON(latestURL, &release); err != nil {
+			return nil, err
+		}
+		return &release, nil
+	}
+
+	// The release list is next to the latest release: .../releases and .../releases/latest
+	listURL := strings.TrimSuffix(strings.TrimSuffix(latestURL, "/"), "/latest") + "?per_page=100"
+	var releases []GitHubRelease
+	if err := fetchReleaseJSON(listURL, &releases); err != nil {
+		return nil, err
+	}
+	return selectLatestRelease(releases)
+}
+
+// selectLatestRelease returns the release with the highest semantic version, prereleases included
+// Drafts and releases not tagged with a valid version are ignored
+func selectLatestRelease(releases []GitHubRelease) (*GitHubRelease, error) {
+	var latest *GitHubRelease
+	var latestVer *goversion.Version
+	for i := range releases {
+		if releases[i].Draft {
+			continue
+		}
+		ver, err := goversion.NewVersion(strings.TrimPrefix(releases[i].TagName, "v"))
+		if err != nil {
+			continue
+		}
+		if latestVer == nil || ver.GreaterThan(latestVer) {
+			latest, latestVer = &releases[i], ver
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no release with a valid version found")
+	}
+	return latest, nil
+}
+
+// fetchReleaseJSON gets a GitHub API URL and decodes the JSON response into v
+func fetchReleaseJSON(url string, v interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, GitHubAPIReleaseURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("User-Agent", "Monitorly-Probe/"+Version)
 
-	client := &http.Client{}
+	client := newHTTPClient()
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var release GitHubRelease
-	if err := json.Unmarshal(body, &release); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
+	return nil
+}
 
-	return &release, nil
+// updatePlatforms are the operating systems release binaries are published for
+var updatePlatforms = map[string]bool{
+	"linux":  true,
+	"darwin": true,
 }
 
 // findAppropriateAsset finds the asset for the current OS and architecture
 func findAppropriateAsset(release *GitHubRelease) (string, error) {
-	// We only build for Linux
-	if getOS() != "linux" {
-		return "", fmt.Errorf("this application only runs on Linux, current OS: %s", getOS())
+	_, url, err := findAsset(release)
+	return url, err
+}
+
+// findAsset finds the name and download URL of the binary for the current OS and architecture
+func findAsset(release *GitHubRelease) (string, string, error) {
+	// Releases are built for Linux, and for macOS development machines and CI runners
+	goos := getOS()
+	if !updatePlatforms[goos] {
+		return "", "", fmt.Errorf("self-update is not supported on %s, only on Linux and macOS", goos)
 	}
 
-	// Expected naming pattern: monitorly-probe-{version}-linux-{arch}
-	// Example: monitorly-probe-1.0.0-linux-amd64
-	expectedPattern := fmt.Sprintf("linux-%s", getArch())
+	// Expected naming pattern: monitorly-probe-{version}-{os}-{arch}
+	// Example: monitorly-probe-1.0.0-linux-amd64, monitorly-probe-1.0.0-darwin-arm64
+	expectedPattern := fmt.Sprintf("%s-%s", goos, getArch())
 
 	for _, asset := range release.Assets {
+		// Checksum and signature files share the binary's name
+		if strings.HasSuffix(asset.Name, checksumSuffix) || strings.HasSuffix(asset.Name, signatureSuffix) {
+			continue
+		}
 		if strings.Contains(asset.Name, expectedPattern) {
-			return asset.BrowserDownloadURL, nil
+			return asset.Name, asset.BrowserDownloadURL, nil
 		}
 	}
 
-	return "", fmt.Errorf("no matching asset found for linux/%s", getArch())
+	return "", "", fmt.Errorf("no matching asset found for %s/%s", goos, getArch())
 }
 
 // downloadBinary downloads the binary from the given URL
@@ -228,7 +363,7 @@ func downloadBinaryWithTimeout(url string, timeout time.Duration) (string, error
 
 	req.Header.Set("User-Agent", "Monitorly-Probe/"+Version)
 
-	client := &http.Client{}
+	client := newHTTPClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
@@ -268,6 +403,9 @@ func downloadBinaryWithTimeout(url string, timeout time.Duration) (string, error
 }
 
 // replaceBinary replaces the current binary with the new one
+// The new binary is staged next to the current one and renamed over it, so the executable path always
+// holds a complete binary. The previous binary is kept as <executable>.bak and restored if the new one
+// fails to run, or later by RunPostUpdateCheck if the new one fails its post-update check
 func replaceBinary(newBinaryPath string) error {
 	// Get the path to the current executable
 	execPath, err := osExecutable()
@@ -281,53 +419,128 @@ func replaceBinary(newBinaryPath string) error {
 		return fmt.Errorf("failed to resolve symlinks: %w", err)
 	}
 
-	// On Windows, can't replace a running executable directly
-	// so we rename the current binary and copy the new one
-	if runtime.GOOS == "windows" {
-		oldPath := execPath + ".old"
-		// Remove old backup if it exists
-		_ = os.Remove(oldPath)
+	// Stage the new binary in the same directory, a rename is only atomic within a filesystem
+	stagedPath, err := stageBinary(newBinaryPath, filepath.Dir(execPath))
+	if err != nil {
+		return err
+	}
 
-		// Rename current executable to .old
-		if err := os.Rename(execPath, oldPath); err != nil {
-			return fmt.Errorf("failed to rename current executable: %w", err)
+	backupPath := execPath + ".bak"
+	if err := backupBinary(execPath, backupPath); err != nil {
+		os.Remove(stagedPath)
+		return fmt.Errorf("failed to back up current binary: %w", err)
+	}
+
+	if err := os.Rename(stagedPath, execPath); err != nil {
+		os.Remove(stagedPath)
+		// On Windows the backup was made by moving the current binary away
+		if runtime.GOOS == "windows" {
+			_ = os.Rename(backupPath, execPath)
 		}
+		return fmt.Errorf("failed to install new binary: %w", err)
 	}
 
-	// Copy the new binary to the executable path
-	sourceFile, err := os.Open(newBinaryPath)
+	// Make sure the new binary runs before committing to it
+	if err := verifyBinaryFunc(execPath); err != nil {
+		if rollbackErr := os.Rename(backupPath, execPath); rollbackErr != nil {
+			return fmt.Errorf("new binary failed verification (%v) and rollback failed: %w", err, rollbackErr)
+		}
+		return fmt.Errorf("new binary failed verification, previous binary restored: %w", err)
+	}
+
+	// Have the next start of the new binary check itself before the update is kept
+	if err := markPendingUpdate(execPath, GetVersion()); err != nil {
+		log.Printf("Failed to request a post-update check, the update will not be checked: %v", err)
+	}
+
+	// Clean up temporary file
+	_ = os.Remove(newBinaryPath)
+
+	return nil
+}
+
+// stageBinary copies the binary at srcPath to a new executable file in dir and returns its path
+// The file is synced to disk so a crash can't leave a partial binary to be renamed into place
+func stageBinary(srcPath, dir string) (string, error) {
+	sourceFile, err := os.Open(srcPath)
 	if err != nil {
-		return fmt.Errorf("failed to open new binary: %w", err)
+		return "", fmt.Errorf("failed to open new binary: %w", err)
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(execPath)
+	stagedFile, err := os.CreateTemp(dir, ".monitorly-probe-new-*")
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return "", fmt.Errorf("failed to create staging file: %w", err)
 	}
-	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		return fmt.Errorf("failed to copy new binary: %w", err)
+	_, err = io.Copy(stagedFile, sourceFile)
+	if err == nil {
+		err = stagedFile.Sync()
+	}
+	if closeErr := stagedFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(stagedFile.Name())
+		return "", fmt.Errorf("failed to copy new binary: %w", err)
 	}
 
 	// Make sure the new binary is executable
-	if err := os.Chmod(execPath, 0755); err != nil {
-		return fmt.Errorf("failed to set permissions: %w", err)
+	if err := os.Chmod(stagedFile.Name(), 0755); err != nil {
+		os.Remove(stagedFile.Name())
+		return "", fmt.Errorf("failed to set permissions: %w", err)
 	}
 
-	// Clean up temporary file
-	_ = os.Remove(newBinaryPath)
+	return stagedFile.Name(), nil
+}
+
+// backupBinary keeps the binary at execPath as backupPath, replacing any previous backup
+// A hard link leaves the current binary in place until the new one is renamed over it.
+// Windows can't replace a running executable, so there it is moved away instead
+func backupBinary(execPath, backupPath string) error {
+	// Remove old backup if it exists
+	_ = os.Remove(backupPath)
+
+	if runtime.GOOS == "windows" {
+		return os.Rename(execPath, backupPath)
+	}
+	if err := os.Link(execPath, backupPath); err == nil {
+		return nil
+	}
+
+	// Some filesystems don't support hard links, copy the binary instead
+	stagedPath, err := stageBinary(execPath, filepath.Dir(backupPath))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(stagedPath, backupPath); err != nil {
+		os.Remove(stagedPath)
+		return err
+	}
+	return nil
+}
 
+// verifyBinary checks that the binary at path starts, by running it with --version
+func verifyBinary(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s --version failed: %w: %s", path, err, strings.TrimSpace(string(output)))
+	}
 	return nil
 }
 
-// StartUpdateChecker starts a goroutine that checks for updates at the specified time each day
-func StartUpdateChecker(ctx context.Context, nextCheck time.Time, retryDelay time.Duration) {
+// StartUpdateChecker starts a goroutine that checks for updates at the time of day of nextCheck,
+// starting with nextCheck itself. Each check is delayed by a random duration of up to jitter, so
+// a fleet sharing the same check time doesn't query the release server all at once
+func StartUpdateChecker(ctx context.Context, nextCheck time.Time, jitter, retryDelay time.Duration) {
 	go func() {
+		checkAt := nextCheck.Add(randomDelay(jitter))
 		for {
 			// Wait until the next check time
-			waitDuration := time.Until(nextCheck)
+			waitDuration := time.Until(checkAt)
 			select {
 			case <-ctx.Done():
 				return
@@ -337,7 +550,7 @@ func StartUpdateChecker(ctx context.Context, nextCheck time.Time, retryDelay tim
 				if err != nil {
 					log.Printf("Error checking for updates: %v, will retry in %v", err, retryDelay)
 					// Schedule retry
-					nextCheck = time.Now().Add(retryDelay)
+					checkAt = time.Now().Add(retryDelay)
 					continue
 				}
 
@@ -346,7 +559,7 @@ func StartUpdateChecker(ctx context.Context, nextCheck time.Time, retryDelay tim
 					if err := SelfUpdate(); err != nil {
 						log.Printf("Error updating: %v, will retry in %v", err, retryDelay)
 						// Schedule retry
-						nextCheck = time.Now().Add(retryDelay)
+						checkAt = time.Now().Add(retryDelay)
 						continue
 					}
 					log.Println("Update successful. Restarting...")
@@ -359,9 +572,27 @@ func StartUpdateChecker(ctx context.Context, nextCheck time.Time, retryDelay tim
 					log.Println("No updates available")
 				}
 
-				// Schedule next check for tomorrow at the same time
-				nextCheck = nextCheck.Add(24 * time.Hour)
+				// Schedule the next check at the same time of day, skipping the days retries ran into
+				now := time.Now()
+				for !nextCheck.After(now) {
+					nextCheck = nextDay(nextCheck)
+				}
+				checkAt = nextCheck.Add(randomDelay(jitter))
 			}
 		}
 	}()
 }
+
+// nextDay returns t one calendar day later at the same time of day in its location, which is not
+// 24 hours later across a daylight saving time change
+func nextDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// randomDelay returns a random duration up to maxDelay, zero if maxDelay isn't positive
+func randomDelay(maxDelay time.Duration) time.Duration {
+	if maxDelay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(maxDelay)))
+}
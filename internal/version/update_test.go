@@ -2,12 +2,14 @@ package version
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -293,6 +295,28 @@ func TestFindAppropriateAsset(t *testing.T) {
 			want:    "https://example.com/linux-arm64",
 			wantErr: false,
 		},
+		{
+			name:   "checksum asset listed first",
+			goos:   "linux",
+			goarch: "amd64",
+			release: &GitHubRelease{
+				Assets: []struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+				}{
+					{
+						Name:               "monitorly-probe-1.0.0-linux-amd64.sha256",
+						BrowserDownloadURL: "https://example.com/linux-amd64.sha256",
+					},
+					{
+						Name:               "monitorly-probe-1.0.0-linux-amd64",
+						BrowserDownloadURL: "https://example.com/linux-amd64",
+					},
+				},
+			},
+			want:    "https://example.com/linux-amd64",
+			wantErr: false,
+		},
 		{
 			name:   "no matching asset",
 			goos:   "linux",
@@ -311,7 +335,51 @@ func TestFindAppropriateAsset(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:   "unsupported OS",
+			name:   "matching darwin/amd64 asset",
+			goos:   "darwin",
+			goarch: "amd64",
+			release: &GitHubRelease{
+				Assets: []struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+				}{
+					{
+						Name:               "monitorly-probe-1.0.0-linux-amd64",
+						BrowserDownloadURL: "https://example.com/linux-amd64",
+					},
+					{
+						Name:               "monitorly-probe-1.0.0-darwin-amd64",
+						BrowserDownloadURL: "https://example.com/darwin-amd64",
+					},
+				},
+			},
+			want:    "https://example.com/darwin-amd64",
+			wantErr: false,
+		},
+		{
+			name:   "matching darwin/arm64 asset",
+			goos:   "darwin",
+			goarch: "arm64",
+			release: &GitHubRelease{
+				Assets: []struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+				}{
+					{
+						Name:               "monitorly-probe-1.0.0-darwin-amd64",
+						BrowserDownloadURL: "https://example.com/darwin-amd64",
+					},
+					{
+						Name:               "monitorly-probe-1.0.0-darwin-arm64",
+						BrowserDownloadURL: "https://example.com/darwin-arm64",
+					},
+				},
+			},
+			want:    "https://example.com/darwin-arm64",
+			wantErr: false,
+		},
+		{
+			name:   "no darwin asset published",
 			goos:   "darwin",
 			goarch: "amd64",
 			release: &GitHubRelease{
@@ -327,6 +395,23 @@ func TestFindAppropriateAsset(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name:   "unsupported OS",
+			goos:   "windows",
+			goarch: "amd64",
+			release: &GitHubRelease{
+				Assets: []struct {
+					Name               string `json:"name"`
+					BrowserDownloadURL string `json:"browser_download_url"`
+				}{
+					{
+						Name:               "monitorly-probe-1.0.0-windows-amd64",
+						BrowserDownloadURL: "https://example.com/windows",
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name:   "empty assets",
 			goos:   "linux",
@@ -480,8 +565,9 @@ func TestStartUpdateChecker(t *testing.T) {
 	}
 
 	// Mock download and replace functions
+	binaryPath, checksum := writeMockBinary(t)
 	downloadBinaryFunc = func(url string) (string, error) {
-		return "/tmp/mock-binary", nil
+		return binaryPath, nil
 	}
 	replaceBinaryFunc = func(newBinaryPath string) error {
 		return nil
@@ -499,6 +585,7 @@ func TestStartUpdateChecker(t *testing.T) {
 
 		response := GitHubRelease{
 			TagName: "v2.0.0",
+			Body:    fmt.Sprintf("%s  monitorly-probe-2.0.0-linux-%s", checksum, runtime.GOARCH),
 			Assets: []struct {
 				Name               string `json:"name"`
 				BrowserDownloadURL string `json:"browser_download_url"`
@@ -523,7 +610,7 @@ func TestStartUpdateChecker(t *testing.T) {
 
 	// Start the update checker with next check in 10ms
 	nextCheck := time.Now().Add(10 * time.Millisecond)
-	StartUpdateChecker(ctx, nextCheck, 1*time.Millisecond)
+	StartUpdateChecker(ctx, nextCheck, 0, 1*time.Millisecond)
 
 	// Wait for the exit call or timeout
 	select {
@@ -551,29 +638,51 @@ func TestSelfUpdate(t *testing.T) {
 		return
 	}
 
+	// Asset URLs under mockServerURL are served by the test server
+	const mockServerURL = "http://mock-server"
+	binary := []byte("new probe binary")
+	digest := sha256.Sum256(binary)
+	assetName := fmt.Sprintf("monitorly-probe-2.0.0-linux-%s", runtime.GOARCH)
+
+	type asset = struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	}
+
 	tests := []struct {
 		name           string
 		currentVersion string
 		mockResponse   GitHubRelease
 		mockStatus     int
 		wantErr        bool
+		errContains    string
+		wantInstalled  bool
 	}{
 		{
 			name:           "successful update",
 			currentVersion: "1.0.0",
 			mockResponse: GitHubRelease{
 				TagName: "v2.0.0",
-				Assets: []struct {
-					Name               string `json:"name"`
-					BrowserDownloadURL string `json:"browser_download_url"`
-				}{
-					{
-						Name:               fmt.Sprintf("monitorly-probe-2.0.0-linux-%s", runtime.GOARCH),
-						BrowserDownloadURL: "https://example.com/download",
-					},
+				Assets: []asset{
+					{Name: assetName, BrowserDownloadURL: mockServerURL + "/download"},
+					{Name: assetName + ".sha256", BrowserDownloadURL: mockServerURL + "/download.sha256"},
 				},
 			},
-			mockStatus: http.StatusOK,
+			mockStatus:    http.StatusOK,
+			wantInstalled: true,
+		},
+		{
+			name:           "missing checksum",
+			currentVersion: "1.0.0",
+			mockResponse: GitHubRelease{
+				TagName: "v2.0.0",
+				Assets: []asset{
+					{Name: assetName, BrowserDownloadURL: mockServerURL + "/download"},
+				},
+			},
+			mockStatus:  http.StatusOK,
+			wantErr:     true,
+			errContains: "no SHA-256 checksum published",
 		},
 		{
 			name:           "no update needed",
@@ -594,14 +703,8 @@ func TestSelfUpdate(t *testing.T) {
 			currentVersion: "1.0.0",
 			mockResponse: GitHubRelease{
 				TagName: "v2.0.0",
-				Assets: []struct {
-					Name               string `json:"name"`
-					BrowserDownloadURL string `json:"browser_download_url"`
-				}{
-					{
-						Name:               "monitorly-probe-2.0.0-windows-amd64",
-						BrowserDownloadURL: "https://example.com/download",
-					},
+				Assets: []asset{
+					{Name: "monitorly-probe-2.0.0-windows-amd64", BrowserDownloadURL: mockServerURL + "/download"},
 				},
 			},
 			mockStatus: http.StatusOK,
@@ -611,10 +714,23 @@ func TestSelfUpdate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(tt.mockStatus)
-				if tt.mockStatus == http.StatusOK {
-					json.NewEncoder(w).Encode(tt.mockResponse)
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/download":
+					w.Write(binary)
+				case "/download.sha256":
+					fmt.Fprintf(w, "%x  %s\n", digest, assetName)
+				default:
+					w.WriteHeader(tt.mockStatus)
+					if tt.mockStatus == http.StatusOK {
+						release := tt.mockResponse
+						release.Assets = append([]asset(nil), release.Assets...)
+						for i := range release.Assets {
+							release.Assets[i].BrowserDownloadURL = strings.Replace(release.Assets[i].BrowserDownloadURL, mockServerURL, server.URL, 1)
+						}
+						json.NewEncoder(w).Encode(release)
+					}
 				}
 			}))
 			defer server.Close()
@@ -622,18 +738,36 @@ func TestSelfUpdate(t *testing.T) {
 			// Save original values
 			origURL := GitHubAPIReleaseURL
 			origVersion := Version
+			origReplaceBinary := replaceBinaryFunc
 			defer func() {
 				GitHubAPIReleaseURL = origURL
 				Version = origVersion
+				replaceBinaryFunc = origReplaceBinary
 			}()
 
-			// Set test values
+			// Set test values, the downloaded binary is checked instead of replacing the test binary
 			GitHubAPIReleaseURL = server.URL
 			Version = tt.currentVersion
+			var installed []byte
+			replaceBinaryFunc = func(newBinaryPath string) error {
+				var err error
+				installed, err = os.ReadFile(newBinaryPath)
+				os.Remove(newBinaryPath)
+				return err
+			}
 
 			err := SelfUpdate()
 			if (err != nil) != tt.wantErr {
-				t.Errorf("SelfUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("SelfUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.errContains != "" && !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("SelfUpdate() error = %v, want error containing %q", err, tt.errContains)
+			}
+			if tt.wantInstalled != (installed != nil) {
+				t.Errorf("SelfUpdate() installed = %q, want an install %v", installed, tt.wantInstalled)
+			}
+			if tt.wantInstalled && string(installed) != string(binary) {
+				t.Errorf("SelfUpdate() installed %q, want %q", installed, binary)
 			}
 		})
 	}
@@ -645,9 +779,12 @@ func TestReplaceBinary(t *testing.T) {
 	}
 
 	tests := []struct {
-		name    string
-		setup   func() (string, string, error)
-		wantErr bool
+		name        string
+		setup       func() (string, string, error)
+		verifyErr   error
+		wantErr     bool
+		wantContent string // Expected content of the current binary afterwards, unchecked when empty
+		wantBackup  string // Expected content of the backup afterwards, no backup expected when empty
 	}{
 		{
 			name: "successful replace",
@@ -674,7 +811,49 @@ func TestReplaceBinary(t *testing.T) {
 
 				return currentBin, newBin, nil
 			},
-			wantErr: false,
+			wantErr:     false,
+			wantContent: "new content",
+			wantBackup:  "old content",
+		},
+		{
+			name: "verification fails",
+			setup: func() (string, string, error) {
+				tmpDir, err := os.MkdirTemp("", "test-binary-*")
+				if err != nil {
+					return "", "", err
+				}
+				currentBin := filepath.Join(tmpDir, "current")
+				if err := os.WriteFile(currentBin, []byte("old content"), 0755); err != nil {
+					os.RemoveAll(tmpDir)
+					return "", "", err
+				}
+				newBin := filepath.Join(tmpDir, "new")
+				if err := os.WriteFile(newBin, []byte("broken content"), 0755); err != nil {
+					os.RemoveAll(tmpDir)
+					return "", "", err
+				}
+				return currentBin, newBin, nil
+			},
+			verifyErr:   fmt.Errorf("exec format error"),
+			wantErr:     true,
+			wantContent: "old content",
+		},
+		{
+			name: "new binary missing leaves current binary untouched",
+			setup: func() (string, string, error) {
+				tmpDir, err := os.MkdirTemp("", "test-binary-*")
+				if err != nil {
+					return "", "", err
+				}
+				currentBin := filepath.Join(tmpDir, "current")
+				if err := os.WriteFile(currentBin, []byte("old content"), 0755); err != nil {
+					os.RemoveAll(tmpDir)
+					return "", "", err
+				}
+				return currentBin, filepath.Join(tmpDir, "nonexistent"), nil
+			},
+			wantErr:     true,
+			wantContent: "old content",
 		},
 		{
 			name: "source file does not exist",
@@ -733,26 +912,69 @@ func TestReplaceBinary(t *testing.T) {
 			}
 			defer func() { osExecutable = oldExec }()
 
+			// The fake binaries can't run, verification is mocked
+			oldVerify := verifyBinaryFunc
+			verifyBinaryFunc = func(path string) error {
+				return tt.verifyErr
+			}
+			defer func() { verifyBinaryFunc = oldVerify }()
+
 			err = replaceBinary(newBin)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("replaceBinary() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if !tt.wantErr {
-				// Verify the content was replaced
+			if tt.wantContent != "" {
 				content, err := os.ReadFile(currentBin)
 				if err != nil {
-					t.Errorf("Failed to read replaced binary: %v", err)
+					t.Errorf("Failed to read binary: %v", err)
 					return
 				}
-				if string(content) != "new content" {
-					t.Errorf("replaceBinary() content = %v, want %v", string(content), "new content")
+				if string(content) != tt.wantContent {
+					t.Errorf("replaceBinary() content = %v, want %v", string(content), tt.wantContent)
 				}
+				if info, err := os.Stat(currentBin); err == nil && info.Mode().Perm() != 0755 {
+					t.Errorf("replaceBinary() mode = %v, want 0755", info.Mode().Perm())
+				}
+			}
+
+			backup, err := os.ReadFile(currentBin + ".bak")
+			if tt.wantBackup == "" {
+				if err == nil {
+					t.Errorf("replaceBinary() left backup %q, want none", backup)
+				}
+			} else if string(backup) != tt.wantBackup {
+				t.Errorf("replaceBinary() backup = %q (%v), want %q", backup, err, tt.wantBackup)
+			}
+
+			// Only an installed binary is checked on its next start
+			_, err = os.Stat(currentBin + postUpdateCheckSuffix)
+			if wantCheck := tt.wantBackup != ""; (err == nil) != wantCheck {
+				t.Errorf("replaceBinary() left a post-update check sentinel: %v, want %v", err == nil, wantCheck)
+			}
+
+			// Staging files never outlive the replacement
+			if staged, _ := filepath.Glob(filepath.Join(filepath.Dir(currentBin), ".monitorly-probe-new-*")); len(staged) > 0 {
+				t.Errorf("replaceBinary() left staging files %v", staged)
 			}
 		})
 	}
 }
 
+func TestVerifyBinary(t *testing.T) {
+	// A file that isn't a valid executable fails to start
+	path := filepath.Join(t.TempDir(), "broken")
+	if err := os.WriteFile(path, []byte("not a binary"), 0755); err != nil {
+		t.Fatalf("Failed to write binary: %v", err)
+	}
+	if err := verifyBinary(path); err == nil {
+		t.Error("verifyBinary() error = nil, want error for an invalid binary")
+	}
+	if err := verifyBinary(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("verifyBinary() error = nil, want error for a missing binary")
+	}
+}
+
 // TestSelfUpdateErrorCases tests error scenarios for SelfUpdate
 func TestSelfUpdateErrorCases(t *testing.T) {
 	// Save original values
@@ -771,6 +993,8 @@ func TestSelfUpdateErrorCases(t *testing.T) {
 		getArch = originalGetArch
 	}()
 
+	binaryPath, checksum := writeMockBinary(t)
+
 	tests := []struct {
 		name           string
 		currentVersion string
@@ -858,6 +1082,7 @@ func TestSelfUpdateErrorCases(t *testing.T) {
 				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					response := GitHubRelease{
 						TagName: "v2.0.0",
+						Body:    fmt.Sprintf("%s  monitorly-probe-2.0.0-linux-%s", checksum, runtime.GOARCH),
 						Assets: []struct {
 							Name               string `json:"name"`
 							BrowserDownloadURL string `json:"browser_download_url"`
@@ -887,6 +1112,7 @@ func TestSelfUpdateErrorCases(t *testing.T) {
 				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					response := GitHubRelease{
 						TagName: "v2.0.0",
+						Body:    fmt.Sprintf("%s  monitorly-probe-2.0.0-linux-%s", checksum, runtime.GOARCH),
 						Assets: []struct {
 							Name               string `json:"name"`
 							BrowserDownloadURL string `json:"browser_download_url"`
@@ -903,7 +1129,7 @@ func TestSelfUpdateErrorCases(t *testing.T) {
 				getOS = func() string { return "linux" }
 				getArch = func() string { return runtime.GOARCH }
 				downloadBinaryFunc = func(url string) (string, error) {
-					return "/tmp/mock-binary", nil
+					return binaryPath, nil
 				}
 				replaceBinaryFunc = func(newBinaryPath string) error {
 					return fmt.Errorf("replace failed")
@@ -912,6 +1138,71 @@ func TestSelfUpdateErrorCases(t *testing.T) {
 			wantErr:     true,
 			errContains: "failed to replace binary",
 		},
+		{
+			name:           "checksum not published",
+			currentVersion: "v1.0.0",
+			setupMocks: func() {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					response := GitHubRelease{
+						TagName: "v2.0.0",
+						Assets: []struct {
+							Name               string `json:"name"`
+							BrowserDownloadURL string `json:"browser_download_url"`
+						}{
+							{
+								Name:               fmt.Sprintf("monitorly-probe-2.0.0-linux-%s", runtime.GOARCH),
+								BrowserDownloadURL: "https://example.com/download",
+							},
+						},
+					}
+					json.NewEncoder(w).Encode(response)
+				}))
+				GitHubAPIReleaseURL = server.URL
+				getOS = func() string { return "linux" }
+				getArch = func() string { return runtime.GOARCH }
+				downloadBinaryFunc = func(url string) (string, error) {
+					t.Error("binary downloaded without a published checksum")
+					return binaryPath, nil
+				}
+			},
+			wantErr:     true,
+			errContains: "no SHA-256 checksum published",
+		},
+		{
+			name:           "checksum mismatch",
+			currentVersion: "v1.0.0",
+			setupMocks: func() {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					response := GitHubRelease{
+						TagName: "v2.0.0",
+						Body:    fmt.Sprintf("%s  monitorly-probe-2.0.0-linux-%s", strings.Repeat("0", 64), runtime.GOARCH),
+						Assets: []struct {
+							Name               string `json:"name"`
+							BrowserDownloadURL string `json:"browser_download_url"`
+						}{
+							{
+								Name:               fmt.Sprintf("monitorly-probe-2.0.0-linux-%s", runtime.GOARCH),
+								BrowserDownloadURL: "https://example.com/download",
+							},
+						},
+					}
+					json.NewEncoder(w).Encode(response)
+				}))
+				GitHubAPIReleaseURL = server.URL
+				getOS = func() string { return "linux" }
+				getArch = func() string { return runtime.GOARCH }
+				mismatchPath, _ := writeMockBinary(t)
+				downloadBinaryFunc = func(url string) (string, error) {
+					return mismatchPath, nil
+				}
+				replaceBinaryFunc = func(newBinaryPath string) error {
+					t.Error("binary replaced despite a checksum mismatch")
+					return nil
+				}
+			},
+			wantErr:     true,
+			errContains: "checksum mismatch",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1116,7 +1407,7 @@ func TestStartUpdateCheckerErrorCases(t *testing.T) {
 
 			// Start the update checker
 			nextCheck := time.Now().Add(10 * time.Millisecond)
-			StartUpdateChecker(ctx, nextCheck, 5*time.Millisecond)
+			StartUpdateChecker(ctx, nextCheck, 0, 5*time.Millisecond)
 
 			// Wait for a short period to allow the checker to run
 			time.Sleep(30 * time.Millisecond)
@@ -1131,3 +1422,209 @@ func TestStartUpdateCheckerErrorCases(t *testing.T) {
 		})
 	}
 }
+
+func TestSetProxy(t *testing.T) {
+	originalURL := GitHubAPIReleaseURL
+	defer func() {
+		GitHubAPIReleaseURL = originalURL
+		SetProxy("")
+	}()
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A request sent through an HTTP proxy carries the absolute URL of its target
+		proxied = append(proxied, r.URL.String())
+		json.NewEncoder(w).Encode(GitHubRelease{TagName: "v9.9.9"})
+	}))
+	defer proxy.Close()
+
+	GitHubAPIReleaseURL = "http://github.invalid/repos/monitorly-app/probe/releases/latest"
+	if err := SetProxy(proxy.URL); err != nil {
+		t.Fatalf("SetProxy() error = %v", err)
+	}
+
+	latest, err := GetLatestVersionFromGitHub()
+	if err != nil {
+		t.Fatalf("GetLatestVersionFromGitHub() error = %v", err)
+	}
+	if latest != "v9.9.9" {
+		t.Errorf("GetLatestVersionFromGitHub() = %s, want v9.9.9", latest)
+	}
+	if len(proxied) != 1 || proxied[0] != GitHubAPIReleaseURL {
+		t.Errorf("proxy received %v, want [%s]", proxied, GitHubAPIReleaseURL)
+	}
+
+	if err := SetProxy("://bad"); err == nil {
+		t.Error("SetProxy() with an invalid URL succeeded, want an error")
+	}
+}
+
+func TestSelectLatestRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		releases []GitHubRelease
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "prerelease newer than stable",
+			releases: []GitHubRelease{
+				{TagName: "v1.2.0"},
+				{TagName: "v1.3.0-beta.1", Prerelease: true},
+				{TagName: "v1.1.0"},
+			},
+			want: "v1.3.0-beta.1",
+		},
+		{
+			name: "stable release supersedes its prereleases",
+			releases: []GitHubRelease{
+				{TagName: "v1.3.0-rc.2", Prerelease: true},
+				{TagName: "v1.3.0"},
+				{TagName: "v1.3.0-rc.1", Prerelease: true},
+			},
+			want: "v1.3.0",
+		},
+		{
+			name: "prereleases ordered by semver, not by list order",
+			releases: []GitHubRelease{
+				{TagName: "v2.0.0-beta.2", Prerelease: true},
+				{TagName: "v2.0.0-beta.10", Prerelease: true},
+				{TagName: "v2.0.0-alpha.5", Prerelease: true},
+			},
+			want: "v2.0.0-beta.10",
+		},
+		{
+			name: "drafts and invalid tags ignored",
+			releases: []GitHubRelease{
+				{TagName: "v9.0.0", Draft: true},
+				{TagName: "nightly"},
+				{TagName: "1.0.1"},
+			},
+			want: "1.0.1",
+		},
+		{
+			name:     "no usable release",
+			releases: []GitHubRelease{{TagName: "nightly"}, {TagName: "v3.0.0", Draft: true}},
+			wantErr:  true,
+		},
+		{
+			name:    "empty list",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectLatestRelease(tt.releases)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectLatestRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.TagName != tt.want {
+				t.Errorf("selectLatestRelease() = %s, want %s", got.TagName, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetReleaseChannel(t *testing.T) {
+	defer SetReleaseChannel("", false)
+
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/repos/fork/probe/releases/latest":
+			json.NewEncoder(w).Encode(GitHubRelease{TagName: "v1.5.0"})
+		case "/repos/fork/probe/releases":
+			json.NewEncoder(w).Encode([]GitHubRelease{
+				{TagName: "v1.5.0"},
+				{TagName: "v1.6.0-beta.1", Prerelease: true},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	latestURL := server.URL + "/repos/fork/probe/releases/latest"
+
+	// The fork's latest stable release
+	if err := SetReleaseChannel(latestURL, false); err != nil {
+		t.Fatalf("SetReleaseChannel() error = %v", err)
+	}
+	if got, err := GetLatestVersionFromGitHub(); err != nil || got != "v1.5.0" {
+		t.Errorf("GetLatestVersionFromGitHub() = %s, %v, want v1.5.0", got, err)
+	}
+
+	// The fork's beta channel
+	if err := SetReleaseChannel(latestURL, true); err != nil {
+		t.Fatalf("SetReleaseChannel() error = %v", err)
+	}
+	if got, err := GetLatestVersionFromGitHub(); err != nil || got != "v1.6.0-beta.1" {
+		t.Errorf("GetLatestVersionFromGitHub() with prereleases = %s, %v, want v1.6.0-beta.1", got, err)
+	}
+
+	want := []string{"/repos/fork/probe/releases/latest", "/repos/fork/probe/releases?per_page=100"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+
+	for _, invalid := range []string{"ftp://example.com/releases/latest", "not a url", "https://"} {
+		if err := SetReleaseChannel(invalid, false); err == nil {
+			t.Errorf("SetReleaseChannel(%q) error = nil, want invalid URL", invalid)
+		}
+	}
+}
+
+func TestNextDay(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{
+			name: "regular day",
+			t:    time.Date(2025, time.June, 10, 3, 0, 0, 0, newYork),
+			want: time.Date(2025, time.June, 11, 3, 0, 0, 0, newYork),
+		},
+		{
+			name: "daylight saving time starts",
+			t:    time.Date(2025, time.March, 8, 3, 0, 0, 0, newYork),
+			want: time.Date(2025, time.March, 9, 3, 0, 0, 0, newYork),
+		},
+		{
+			name: "daylight saving time ends",
+			t:    time.Date(2025, time.November, 1, 3, 0, 0, 0, newYork),
+			want: time.Date(2025, time.November, 2, 3, 0, 0, 0, newYork),
+		},
+		{
+			name: "end of month",
+			t:    time.Date(2025, time.January, 31, 23, 30, 0, 0, newYork),
+			want: time.Date(2025, time.February, 1, 23, 30, 0, 0, newYork),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextDay(tt.t); !got.Equal(tt.want) {
+				t.Errorf("nextDay(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomDelay(t *testing.T) {
+	if got := randomDelay(0); got != 0 {
+		t.Errorf("randomDelay(0) = %v, want 0", got)
+	}
+	for i := 0; i < 100; i++ {
+		if got := randomDelay(time.Minute); got < 0 || got >= time.Minute {
+			t.Fatalf("randomDelay(1m) = %v, want it within [0, 1m)", got)
+		}
+	}
+}
@@ -16,6 +16,10 @@ var (
 
 	// Commit is the git commit hash from which the binary was built
 	Commit = "unknown"
+
+	// ReleasePublicKey is the base64 encoded DER (PKIX) public key that signs releases,
+	// used to verify updates when no other key is configured. Empty in development builds
+	ReleasePublicKey = ""
 )
 
 // Info returns a formatted string with version information
@@ -32,6 +32,15 @@ if git rev-parse "v$VERSION" >/dev/null 2>&1; then
   exit 1
 fi
 
+# Vet every platform the probe is built for, platform specific files only compile there
+for GOOS_TARGET in linux darwin windows freebsd; do
+  echo "Running go vet for $GOOS_TARGET..."
+  if ! GOOS=$GOOS_TARGET go vet ./...; then
+    echo "Error: go vet failed for $GOOS_TARGET."
+    exit 1
+  fi
+done
+
 # Run tests with coverage
 echo "Running test suite with coverage..."
 go test -v -coverprofile=coverage.out ./...
@@ -86,6 +95,11 @@ LDFLAGS="-s -w"  # Strip debug info and symbol table
 LDFLAGS="$LDFLAGS -X 'github.com/monitorly-app/probe/internal/version.Version=$VERSION'"
 LDFLAGS="$LDFLAGS -X 'github.com/monitorly-app/probe/internal/version.BuildDate=$BUILD_DATE'"
 LDFLAGS="$LDFLAGS -X 'github.com/monitorly-app/probe/internal/version.Commit=$COMMIT'"
+# Bundle the release signing public key (PEM file) so updates can be verified without configuration
+if [ -n "$RELEASE_PUBLIC_KEY_FILE" ]; then
+  RELEASE_PUBLIC_KEY=$(grep -v -- '-----' "$RELEASE_PUBLIC_KEY_FILE" | tr -d '\n')
+  LDFLAGS="$LDFLAGS -X 'github.com/monitorly-app/probe/internal/version.ReleasePublicKey=$RELEASE_PUBLIC_KEY'"
+fi
 
 go build -v -a -installsuffix cgo -trimpath -ldflags="$LDFLAGS" -o bin/monitorly-probe ./cmd/probe
 